@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"migraptor/internal/fake"
+
+	"github.com/spf13/cobra"
+)
+
+func main() {
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+var rootCmd = &cobra.Command{
+	Use:   "fake-gitlab",
+	Short: "Local fake GitLab and container registry",
+	Long: `Serve an in-memory GitLab instance with its container registry, to rehearse
+migrations without touching a real instance. Nothing is persisted: the state is
+lost when the server stops.`,
+	RunE: runServer,
+}
+
+func init() {
+	rootCmd.Flags().String("listen", "localhost:8080", "address to listen on")
+	rootCmd.Flags().String("token", "", "token expected by the API, any token is accepted if empty")
+	rootCmd.Flags().Bool("seed", true, "create demo groups, projects and images")
+}
+
+func runServer(cmd *cobra.Command, args []string) error {
+	listen, _ := cmd.Flags().GetString("listen")
+	token, _ := cmd.Flags().GetString("token")
+	seed, _ := cmd.Flags().GetBool("seed")
+
+	server, err := fake.NewServer(listen)
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+	server.Token = token
+
+	if seed {
+		if err := seedDemo(server); err != nil {
+			return fmt.Errorf("failed to seed demo data: %w", err)
+		}
+	}
+
+	fmt.Printf("🦖 Fake GitLab listening on %s\n", server.URL())
+	fmt.Printf("Run: migraptor -i %s -r %s -g <any token> -o demo/team -n demo/platform\n", server.Addr(), server.Addr())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+	return nil
+}
+
+// seedDemo creates a source group with nested projects holding images, one of them signed and multi-architecture,
+// and an empty destination group
+func seedDemo(server *fake.Server) error {
+	server.GitLab.AddGroup("demo/platform")
+	for _, path := range []string{"demo/team/app", "demo/team/backend/api", "demo/team/docs"} {
+		server.GitLab.AddProject(path)
+	}
+	for _, image := range []string{"demo/team/app:1.0", "demo/team/app:latest", "demo/team/app/worker:1.0", "demo/team/backend/api:2.0"} {
+		if err := server.AddImage(server.Addr()+"/"+image, 1<<20); err != nil {
+			return err
+		}
+	}
+
+	// A signed multi-architecture image, copied at registry level
+	multiArch := server.Addr() + "/demo/team/app:multiarch"
+	if err := server.AddIndex(multiArch, []string{"amd64", "arm64"}, 1<<20); err != nil {
+		return err
+	}
+	_, err := server.AddArtifact(multiArch, "application/vnd.dev.cosign.artifact.sig.v1+json")
+	return err
+}
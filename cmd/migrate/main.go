@@ -1,16 +1,22 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"maps"
 	"migraptor/internal/check"
 	"migraptor/internal/command"
 	"os"
+	"os/signal"
 	"strings"
-	"time"
+	"sync/atomic"
+	"syscall"
 
 	"migraptor/internal/config"
+	"migraptor/internal/container"
+	"migraptor/internal/gitlab"
 	"migraptor/internal/migration"
+	"migraptor/internal/oci"
 	"migraptor/internal/ui"
 
 	"github.com/spf13/cobra"
@@ -20,6 +26,15 @@ var (
 	consoleUI *ui.UI
 )
 
+const (
+	// checkpointFile receives what remains to do when a migration is interrupted or fails after deleting registries or packages
+	checkpointFile = "migraptor-checkpoint.json"
+	// imageArchiveDir holds the multi-architecture images and images with attached artifacts between backup and restore
+	imageArchiveDir = "migraptor-images"
+	// packageDir holds the files of the packages between backup and restore
+	packageDir = "migraptor-packages"
+)
+
 func main() {
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
@@ -45,13 +60,36 @@ func init() {
 	rootCmd.PersistentFlags().BoolP(config.KEEP_PARENT, "k", false, "don't keep the parent group, transfer projects individually instead")
 	rootCmd.PersistentFlags().StringSliceP(config.PROJECTS_LIST, "l", []string{}, "list projects to move if you want to keep some in origin group (comma-separated)")
 	rootCmd.PersistentFlags().StringP(config.DOCKER_PASSWORD, "p", "", "password for registry")
+	rootCmd.PersistentFlags().String(config.CONTAINER_ENGINE, "docker", "container engine holding images locally: docker, podman or containerd")
+	rootCmd.PersistentFlags().String(config.DOCKER_USER, "", "username for registry, when using separate registry credentials such as a deploy token")
 	rootCmd.PersistentFlags().StringP(config.GITLAB_REGISTRY, "r", "", "change gitlab registry name if not registry.<gitlab_instance>. By default, it's registry.gitlab.com")
+	rootCmd.PersistentFlags().StringSlice(config.INCLUDE, []string{}, "glob or re:regexp patterns on the project path with namespace, selecting projects to move (comma-separated)")
+	rootCmd.PersistentFlags().StringSlice(config.EXCLUDE, []string{}, "glob or re:regexp patterns on the project path with namespace, selecting projects to leave in place (comma-separated)")
+	rootCmd.PersistentFlags().StringSlice(config.TOPICS, []string{}, "move only projects having one of these topics, !topic leaves projects with this topic in place (comma-separated)")
+	rootCmd.PersistentFlags().String(config.ACTIVE_SINCE, "", "move only projects active since this date (2006-01-02) or duration (90d, 12w)")
+	rootCmd.PersistentFlags().String(config.INACTIVE_SINCE, "", "move only projects not active since this date (2006-01-02) or duration (90d, 12w)")
+	rootCmd.PersistentFlags().StringSlice(config.EXCLUDE_GROUPS, []string{}, "sub-groups of the old group to leave in place with their content, full or relative paths (comma-separated)")
+	rootCmd.PersistentFlags().String(config.ARCHIVED, "", "archived projects: include (default), exclude or only")
 	rootCmd.PersistentFlags().StringSliceP(config.TAGS_LIST, "t", []string{}, "filter tags to keep when moving images & registries (comma-separated)")
 	rootCmd.PersistentFlags().BoolP(config.VERBOSE, "v", false, "verbose mode to debug your migration")
+	rootCmd.PersistentFlags().String(config.PROFILE, "", "profile of gitlab-migraptor.yaml to use, overriding its top-level values")
+	rootCmd.PersistentFlags().String(config.TOKEN_FILE, "", "file holding your gitlab API token")
+	rootCmd.PersistentFlags().String(config.TOKEN_COMMAND, "", "command printing your gitlab API token, e.g. 'pass show gitlab/migraptor'")
+	rootCmd.PersistentFlags().String(config.DOCKER_PASSWORD_FILE, "", "file holding the password for registry")
+	rootCmd.PersistentFlags().String(config.DOCKER_PASSWORD_COMMAND, "", "command printing the password for registry")
+	rootCmd.PersistentFlags().Bool(config.KEYRING, false, "look up missing tokens in the keyring of the OS, under the migraptor service")
+	rootCmd.Flags().Bool(config.MIGRATE_MEMBERS, false, "add the members lost when projects are transferred individually to their new namespace")
+	rootCmd.Flags().Bool(config.REMOVE_LOCAL_IMAGES, false, "remove the local copies of the images of a project once their push is verified")
+	rootCmd.Flags().Bool(config.MIGRATE_PACKAGES, false, "download the npm, Maven, PyPI, generic packages and Terraform modules of the projects and publish them again after the transfer")
+	rootCmd.Flags().String(config.MANIFEST, "", "YAML manifest listing several groups to migrate, instead of the old and new groups")
+	rootCmd.Flags().Bool(interactiveFlag, false, "pick the projects, sub-groups and destination in the migration planner before migrating")
 
 	//rootCmd.SetHelpTemplate(ui.PrintUsage())
 
 	rootCmd.AddCommand(command.Clean)
+	rootCmd.AddCommand(command.Inventory)
+	rootCmd.AddCommand(command.Usage)
+	rootCmd.AddCommand(command.Config)
 }
 
 func runMigration(cmd *cobra.Command, args []string) {
@@ -63,200 +101,272 @@ func runMigration(cmd *cobra.Command, args []string) {
 	}
 	defer ui.Close()
 
-	gitlabClient, dockerClient, cfg, err := check.CheckBeforeStarting(currentUI, cmd)
+	gitlabClient, dockerClient, registryClient, cfg, err := check.CheckBeforeStarting(currentUI, cmd)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to check before starting: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Print start message
-	consoleUI.PrintMigrationStart(cfg)
-
-	// Initialize migrators
-	groupMigrator := migration.NewGroupMigrator(gitlabClient, cfg.DryRun, consoleUI)
-	projectMigrator := migration.NewProjectMigrator(gitlabClient, cfg.DryRun, consoleUI)
-	imageMigrator := migration.NewImageMigrator(gitlabClient, dockerClient, cfg.DryRun, consoleUI)
+	interactive, _ := cmd.Flags().GetBool(interactiveFlag)
+	if cfg.Manifest != "" {
+		if interactive {
+			consoleUI.Error("The migration planner cannot be used with a manifest")
+			os.Exit(1)
+		}
+		runBatch(cmd, gitlabClient, dockerClient, registryClient, cfg)
+		return
+	}
 
-	// Search for source group
-	consoleUI.Info("🔍 Searching for source group...")
-	groupFound, err := groupMigrator.SearchGroup(cfg.OldGroupName)
-	if err != nil {
-		consoleUI.Error("Failed to search for group: %v", err)
-		os.Exit(321)
+	if interactive {
+		migrate, err := runPlanner(cmd.Context(), cfg, gitlabClient)
+		if err != nil {
+			consoleUI.Error("Migration planner failed: %v", err)
+			os.Exit(1)
+		}
+		if !migrate {
+			consoleUI.Info("👋 Left the migration planner, nothing was migrated")
+			return
+		}
 	}
 
-	if groupFound == nil {
-		consoleUI.PrintGroupNotFound(cfg.OldGroupName)
-		os.Exit(321)
+	opts, err := engineOptions(cfg, gitlabClient, registryClient)
+	if err != nil {
+		consoleUI.Error("Invalid project rules: %v", err)
+		os.Exit(1)
 	}
 
-	consoleUI.Debug("Found group with ID %d", groupFound.ID)
+	// Print start message
+	consoleUI.PrintMigrationStart(cfg)
 
-	oldGroupFullPath := groupFound.FullPath
-	oldGroupPath := groupFound.Path
+	engine := migration.NewEngine(gitlabClient, dockerClient, opts, consoleUI)
 
-	// Build new group path
-	newGroupPath := strings.TrimPrefix(cfg.NewGroupName, "/")
-	consoleUI.Info("🛤️ Migrating group to new path: %s", newGroupPath)
+	ctx, cancel := context.WithCancel(cmd.Context())
+	defer cancel()
+	stop := handleInterrupts(engine.Stop, cancel)
+	defer stop()
 
-	// Create destination group structure
-	newGroup, err := groupMigrator.SearchGroup(newGroupPath)
+	_, err = engine.Run(ctx)
 	if err != nil {
-		consoleUI.Error("Failed to create groups: %v", err)
-		os.Exit(99)
+		consoleUI.Error("Migration stopped: %v", err)
+	}
+	// Projects whose registries or packages were deleted may be left with their backup only
+	if interrupted(err) || engine.HasPendingBackups() {
+		saveCheckpoint(engine, checkpointFile, cfg.DryRun, interrupted(err))
 	}
-
-	// List projects
-	projects, err := projectMigrator.ListProjects(groupFound.ID, cfg.ProjectsList)
 	if err != nil {
-		consoleUI.Error("Failed to list projects: %v", err)
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 
-	if len(projects) == 0 {
-		consoleUI.PrintNoProjectsFound()
-		os.Exit(1)
+	if cfg.DryRun {
+		consoleUI.PrintDryRunSuccess()
 	}
+}
 
-	allProjects := make(map[int]*migration.ProjectInfo)
-	for _, proj := range projects {
-		allProjects[proj.ID] = &proj
+// engineOptions returns the options of the migration described by cfg
+func engineOptions(cfg *config.Config, gitlabClient *gitlab.Client, registryClient *oci.Client) (migration.Options, error) {
+	selection, err := migration.SelectionFromConfig(cfg)
+	if err != nil {
+		return migration.Options{}, err
 	}
+	return migration.Options{
+		SourceGroup:       cfg.OldGroupName,
+		DestinationGroup:  cfg.NewGroupName,
+		KeepParent:        cfg.KeepParent,
+		Selection:         selection,
+		TagsList:          cfg.TagsList,
+		DryRun:            cfg.DryRun,
+		MigrateMembers:    cfg.MigrateMembers,
+		RemoveLocalImages: cfg.RemoveLocalImages,
+		MigratePackages:   cfg.MigratePackages,
+		PackageDir:        packageDir,
+		GroupTemplate:     cfg.GroupTemplate,
+		ImageArchive:      oci.NewArchive(registryClient, imageArchiveDir),
+		// Preflight phase: verify permissions and feasibility before any destructive action
+		Preflight: func(ctx context.Context, plan *migration.Plan) bool {
+			report := check.RunPreflight(ctx, gitlabClient, &check.PreflightPlan{
+				SourceGroup:      plan.SourceGroup,
+				DestinationGroup: plan.DestinationGroup,
+				DestinationPath:  plan.DestinationPath,
+				Projects:         plan.Projects,
+				KeepParent:       plan.KeepParent,
+				TransferGroup:    plan.TransferGroup,
+				MigratePackages:  cfg.MigratePackages,
+			}, consoleUI)
+			return report.HasBlockingIssues()
+		},
+	}, nil
+}
 
-	subGroups, subProjects, err := groupMigrator.GetSubGroupsAndProjects(groupFound.ID, cfg.ProjectsList)
-
-	maps.Copy(allProjects, subProjects)
-
-	if len(subGroups) > 0 {
-		consoleUI.Info("📂 Found %d sub-groups to consider", len(subGroups))
+// runBatch runs the migrations of the manifest in dependency order and prints their summary
+func runBatch(cmd *cobra.Command, gitlabClient *gitlab.Client, containerEngine container.Engine, registryClient *oci.Client, cfg *config.Config) {
+	manifest, err := config.LoadManifest(cfg.Manifest)
+	if err != nil {
+		consoleUI.Error("%v", err)
+		os.Exit(1)
 	}
-	consoleUI.Info("📦 Found %d projects to migrate", len(allProjects))
-
-	// Store image lists per project
-	projectImages := make(map[int][]string)
-
-	// Backup phase: For each project
-	for _, project := range allProjects {
-		if !migration.ShouldMigrateProject(*project, cfg.ProjectsList, cfg.KeepParent) {
-			consoleUI.Info("Not migrating %s, not in filter list", project.Path)
-			continue
+	var migrations []migration.Options
+	for _, migrationCfg := range manifest.Configs(cfg) {
+		opts, err := engineOptions(migrationCfg, gitlabClient, registryClient)
+		if err != nil {
+			consoleUI.Error("Invalid project rules of migration %s: %v", migrationCfg.OldGroupName, err)
+			os.Exit(1)
 		}
-
-		consoleUI.PrintProjectHeader(project.Path, "💾 Backup")
-
-		// Unarchive if needed
-		if project.Archived {
-			if err := projectMigrator.UnarchiveProject(project.Path, project.ID); err != nil {
-				consoleUI.Error("Failed to unarchive project: %v", err)
-				continue
-			}
+		migrations = append(migrations, opts)
+	}
+	batch, err := migration.NewBatch(migrations, manifest.ContinueOnError, consoleUI)
+	if err != nil {
+		consoleUI.Error("Invalid manifest %s: %v", cfg.Manifest, err)
+		os.Exit(1)
+	}
+	consoleUI.Info("📋 %d migrations to run from %s", len(batch.Migrations), cfg.Manifest)
+
+	ctx, cancel := context.WithCancel(cmd.Context())
+	defer cancel()
+	// The engine running is replaced for each migration, an interrupt also prevents the next ones from starting
+	var current atomic.Pointer[migration.Engine]
+	var stopping atomic.Bool
+	stop := handleInterrupts(func() {
+		stopping.Store(true)
+		if engine := current.Load(); engine != nil {
+			engine.Stop()
 		}
+	}, cancel)
+	defer stop()
 
-		// Backup images if registry is enabled
-		if project.ContainerRegistryEnabled {
-			images, repos, err := imageMigrator.BackupImages(project, cfg.TagsList)
-			consoleUI.Info("👀 Found %d registries in project %s", len(project.RegistryRepositoriesIDs), project.Path)
-			consoleUI.PrintRemovingRegistry()
-			err = imageMigrator.DeleteRegistries(project, repos)
-			if err != nil {
-				consoleUI.Error("Failed to backup images: %v", err)
-				os.Exit(99)
-			}
-			projectImages[project.ID] = images
+	results := batch.Run(ctx, func(ctx context.Context, opts migration.Options) (*migration.Result, error) {
+		if stopping.Load() {
+			return nil, migration.ErrInterrupted
 		}
-	}
-	if len(projectImages) > 0 {
-		if err := imageMigrator.CheckIfRemainingImages(allProjects, cfg.TagsList); err != nil {
-			consoleUI.Error("Failed to check if remaining images: %v", err)
-			os.Exit(99)
+		engine := migration.NewEngine(gitlabClient, containerEngine, opts, consoleUI)
+		current.Store(engine)
+		result, err := engine.Run(ctx)
+		// With continue_on_error, several migrations may leave backups to restore: each one has its own checkpoint
+		if interrupted(err) || engine.HasPendingBackups() {
+			saveCheckpoint(engine, batchCheckpointFile(opts.SourceGroup), cfg.DryRun, interrupted(err))
 		}
-	}
-
-	// Transfer group if keep-parent
-	if cfg.KeepParent {
-		if len(cfg.ProjectsList) == 0 {
-			consoleUI.PrintTransferringGroup(cfg.OldGroupName, cfg.NewGroupName)
-			if err := groupMigrator.TransferGroup(groupFound.ID, int(newGroup.ID)); err != nil {
-				consoleUI.Error("Failed to transfer group: %v", err)
-				os.Exit(99)
-			}
-
-			// Wait a bit after transfer
-			if !cfg.DryRun {
-				consoleUI.SleepWithLog(10 * time.Second)
-			}
-		} else {
-			// Only migrate some projects, cannot use transfer group
-			// Get the last part of old path to use as new group name
-			oldPathParts := strings.Split(oldGroupPath, "/")
-			simplePath := oldPathParts[len(oldPathParts)-1]
-
-			newGroupFullPath := fmt.Sprintf("%s/%s", newGroupPath, simplePath)
-			newGroupAlreadyExists, err := groupMigrator.SearchGroup(newGroupFullPath)
-			if err != nil {
-				consoleUI.Info("🪄 New group %s does not exist yet, creating it...", newGroupFullPath)
-				newGroupID := int(newGroup.ID)
-				newGroupCreated, _, err := gitlabClient.CreateGroup(simplePath, &newGroupID)
-				if err != nil {
-					consoleUI.Error("Failed to create new group: %v", err)
-					os.Exit(99)
+		return result, err
+	})
+
+	var firstErr error
+	lines := make([]ui.BatchLine, len(results))
+	for i, result := range results {
+		lines[i] = ui.BatchLine{
+			Source:      result.Options.SourceGroup,
+			Destination: result.Options.DestinationGroup,
+			Status:      string(result.Status),
+			Err:         result.Err,
+		}
+		if result.Result != nil && result.Result.Plan != nil {
+			lines[i].Projects = len(result.Result.Plan.Projects)
+			for _, project := range result.Result.Projects {
+				if project.Transferred && project.Err == nil {
+					lines[i].Migrated++
 				}
-				newGroup = newGroupCreated
-			} else {
-				consoleUI.Info("ℹ️ New group %s already exists, using it...", newGroupFullPath)
-				newGroup = newGroupAlreadyExists
 			}
 		}
-	}
-
-	// Restore phase: For each project
-	for _, project := range allProjects {
-		if !migration.ShouldMigrateProject(*project, cfg.ProjectsList, cfg.KeepParent) {
+		if result.Err == nil {
 			continue
 		}
+		if firstErr == nil {
+			firstErr = result.Err
+		}
+	}
+	consoleUI.PrintBatchSummary(lines)
 
-		consoleUI.PrintProjectHeader(project.Path, "🪄 Restore")
+	if firstErr != nil {
+		os.Exit(exitCode(firstErr))
+	}
+	if cfg.DryRun {
+		consoleUI.PrintDryRunSuccess()
+	}
+}
 
-		// Transfer project if not keep-parent or if keep-parent and project is in filter list
-		if !cfg.KeepParent || len(cfg.ProjectsList) > 0 {
-			if err := projectMigrator.TransferProject(project.Path, project.ID, int(newGroup.ID)); err != nil {
-				consoleUI.Error("Failed to transfer project: %v", err)
-				continue
-			}
+// handleInterrupts calls stop to end the migration once the current step is finished on the first interrupt signal,
+// and cancels the running operations on the second one. The returned function stops listening.
+func handleInterrupts(stop func(), cancel context.CancelFunc) func() {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case <-signals:
+		case <-done:
+			return
+		}
+		consoleUI.Warning("⏸️  Interrupt received, finishing the current step... Press Ctrl-C again to abort immediately")
+		stop()
 
-			// Wait a bit after transfer
-			if !cfg.DryRun {
-				consoleUI.SleepWithLog(10 * time.Second)
-			}
+		select {
+		case <-signals:
+		case <-done:
+			return
 		}
+		consoleUI.Error("Aborting, the current step may be incomplete")
+		cancel()
+	}()
 
-		// Restore images
-		if images, ok := projectImages[project.ID]; ok && len(images) > 0 {
-			var newPath string
-			if cfg.KeepParent {
-				newPath = fmt.Sprintf("%s/%s", newGroupPath, oldGroupPath)
-			} else {
-				newPath = newGroupPath
-			}
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
 
-			if err := imageMigrator.RestoreImages(images, oldGroupFullPath, newPath, cfg.KeepParent); err != nil {
-				consoleUI.Error("Failed to restore images: %v", err)
-				continue
-			}
-		}
+// interrupted returns true if err stopped the migration because of an interrupt signal
+func interrupted(err error) bool {
+	return errors.Is(err, migration.ErrInterrupted) || errors.Is(err, context.Canceled)
+}
 
-		// Re-archive if needed
-		if project.Archived {
-			if err := projectMigrator.ArchiveProject(project.Path, project.ID); err != nil {
-				consoleUI.Error("Failed to archive project: %v", err)
-				continue
-			}
+// batchCheckpointFile returns the checkpoint file of the migration of a batch moving sourceGroup
+func batchCheckpointFile(sourceGroup string) string {
+	name := strings.TrimSuffix(checkpointFile, ".json")
+	return fmt.Sprintf("%s-%s.json", name, strings.ReplaceAll(strings.Trim(sourceGroup, "/"), "/", "-"))
+}
+
+// saveCheckpoint writes what remains of an interrupted or incomplete migration to path and prints it
+func saveCheckpoint(engine *migration.Engine, path string, dryRun, interrupted bool) {
+	checkpoint := engine.Checkpoint()
+	if checkpoint == nil {
+		return
+	}
+	if !dryRun {
+		if err := checkpoint.Save(path); err != nil {
+			consoleUI.Error("Failed to save checkpoint: %v", err)
 		}
+	}
 
-		consoleUI.PrintMigrationComplete(project.Path)
+	if interrupted {
+		consoleUI.PrintInterrupted()
+	} else {
+		consoleUI.PrintIncomplete()
+	}
+	for _, project := range checkpoint.Projects {
+		steps := make([]string, len(project.Remaining))
+		for i, phase := range project.Remaining {
+			steps[i] = string(phase)
+		}
+		consoleUI.PrintRemainingSteps(project.Path, strings.Join(steps, ", "), project.Images, project.Packages)
 	}
+	if !dryRun {
+		consoleUI.Info("📝 Checkpoint written to %s", path)
+	}
+}
 
-	if cfg.DryRun {
-		consoleUI.PrintDryRunSuccess()
+// exitCode returns the exit status matching the error stopping a migration
+func exitCode(err error) int {
+	var phaseErr *migration.PhaseError
+	switch {
+	case interrupted(err):
+		return 130
+	case errors.Is(err, migration.ErrGroupNotFound):
+		return 321
+	case errors.Is(err, migration.ErrPreflightFailed):
+		return 98
+	case errors.Is(err, migration.ErrDiskSpace):
+		return 97
+	case errors.As(err, &phaseErr) && (phaseErr.Phase == migration.PhaseBackup || phaseErr.Phase == migration.PhaseTransfer):
+		return 99
+	default:
+		return 1
 	}
 }
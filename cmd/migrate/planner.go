@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"migraptor/internal/config"
+	"migraptor/internal/gitlab"
+	"migraptor/internal/inventory"
+	"migraptor/internal/migration"
+	"migraptor/internal/ui"
+
+	tea "github.com/charmbracelet/bubbletea"
+	gitlabCore "gitlab.com/gitlab-org/api/client-go"
+)
+
+// interactiveFlag opens the migration planner before migrating
+const interactiveFlag = "interactive"
+
+// runPlanner shows the tree of the old group in the migration planner and applies the projects, sub-groups
+// and destination chosen to cfg. It returns false when the planner is left without migrating.
+func runPlanner(ctx context.Context, cfg *config.Config, gitlabClient *gitlab.Client) (bool, error) {
+	selection, err := migration.SelectionFromConfig(cfg)
+	if err != nil {
+		return false, fmt.Errorf("invalid project rules: %w", err)
+	}
+	// Every project is listed, the project rules only tick the ones selected at start
+	inv, err := inventory.NewCollector(gitlabClient, consoleUI).Collect(ctx, cfg.OldGroupName, nil, cfg.TagsList)
+	if err != nil {
+		return false, err
+	}
+
+	model := ui.NewPlannerModel(inv.PlannerTree(selection), ui.PlannerOptions{
+		Destination:     cfg.NewGroupName,
+		Destinations:    plannerDestinations(ctx, gitlabClient, inv.Group),
+		KeepParent:      cfg.KeepParent,
+		Registry:        cfg.GitLabRegistry,
+		DestinationPath: inv.PlannerDestination,
+		DryRun:          cfg.DryRun,
+	})
+	if _, err := tea.NewProgram(model, tea.WithAltScreen()).Run(); err != nil {
+		return false, fmt.Errorf("failed to run migration planner: %w", err)
+	}
+
+	choice := model.Choice()
+	if !choice.Confirmed {
+		return false, nil
+	}
+	choice.Apply(cfg)
+	return true, nil
+}
+
+// plannerDestinations returns the groups the user may move projects into, except the source group and its sub-groups
+func plannerDestinations(ctx context.Context, gitlabClient *gitlab.Client, sourceGroup string) []string {
+	groups, err := gitlabClient.ListGroups(ctx, gitlabCore.MaintainerPermissions)
+	if err != nil {
+		consoleUI.Warning("Cannot list the destination groups, type the destination instead: %v", err)
+		return nil
+	}
+	var destinations []string
+	for _, group := range groups {
+		if group.FullPath != sourceGroup && !strings.HasPrefix(group.FullPath, sourceGroup+"/") {
+			destinations = append(destinations, group.FullPath)
+		}
+	}
+	return destinations
+}
@@ -2,20 +2,27 @@ package check
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"migraptor/internal/config"
+	"migraptor/internal/container"
 	"migraptor/internal/docker"
 	"migraptor/internal/gitlab"
+	"migraptor/internal/oci"
 	"migraptor/internal/ui"
 	"os"
 	"strings"
 
+	"github.com/docker/docker/api/types/registry"
 	"github.com/spf13/cobra"
 )
 
-func CheckBeforeStarting(currentUI *ui.UI, cmd *cobra.Command) (*gitlab.Client, *docker.Client, *config.Config, error) {
+// CheckBeforeStarting loads the configuration, then creates and checks the GitLab client, the container engine
+// and the registry client, logged in with the same credentials as the container engine
+func CheckBeforeStarting(currentUI *ui.UI, cmd *cobra.Command) (*gitlab.Client, container.Engine, *oci.Client, *config.Config, error) {
 	// Initialize UI
 	consoleUI := currentUI
+	ctx := cmd.Context()
 
 	consoleUI.Info("🛂 Doing some prechecks...")
 	consoleUI.Info("----------------------------------------")
@@ -23,66 +30,148 @@ func CheckBeforeStarting(currentUI *ui.UI, cmd *cobra.Command) (*gitlab.Client,
 	// Load config from all sources (flags, env, config file)
 	cfg, err := LoadConfig(cmd, consoleUI)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("failed to load config: %w", err)
+		return nil, nil, nil, nil, fmt.Errorf("failed to load config: %w", err)
 	}
 
 	// Validate configuration
 	if err := cfg.Validate(); err != nil {
 		consoleUI.Error("Configuration error: %v", err)
 		ui.PrintUsage()
-		return nil, nil, nil, fmt.Errorf("configuration validation failed: %w", err)
+		return nil, nil, nil, nil, fmt.Errorf("configuration validation failed: %w", err)
 	}
 
-	// Initialize GitLab client
-	consoleUI.Info("🦊 Creating GitLab client...")
-	gitlabClient, err := gitlab.NewClient(cfg.GitLabToken, cfg.GitLabInstance)
+	gitlabClient, err := newGitLabClient(ctx, cfg, consoleUI)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("failed to create GitLab client: %w", err)
+		return nil, nil, nil, nil, err
 	}
 
-	// Check GitLab connection
-	if err := gitlabClient.CheckConnection(); err != nil {
-		return nil, nil, nil, fmt.Errorf("failed to connect to GitLab: %w", err)
-	}
-	consoleUI.Success("GitLab client created successfully")
-
-	// Initialize Docker client
-	consoleUI.Info("🐳 Creating Docker client...")
-	dockerClient, err := docker.NewClient()
+	// Initialize container engine
+	consoleUI.Info("🐳 Creating %s client...", cfg.ContainerEngine)
+	containerEngine, err := container.New(cfg.ContainerEngine)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("failed to create Docker client: %w", err)
+		return nil, nil, nil, nil, fmt.Errorf("failed to create container engine client: %w", err)
 	}
-	defer dockerClient.Close()
-	consoleUI.Success("Docker client created successfully")
+	containerEngine.SetProgress(consoleUI.NewProgressBars())
+	consoleUI.Success("%s client created successfully", containerEngine.Name())
 
-	// Check Docker is running
-	if err := dockerClient.CheckDockerRunning(); err != nil {
-		consoleUI.PrintDockerNotStarted()
-		return nil, nil, nil, fmt.Errorf("Docker is not running: %w", err)
+	// Check the container engine is running
+	if err := containerEngine.CheckRunning(ctx); err != nil {
+		consoleUI.PrintDockerNotStarted(containerEngine.Name())
+		return nil, nil, nil, nil, fmt.Errorf("%s is not running: %w", containerEngine.Name(), err)
 	}
-	consoleUI.Success("Docker is running")
+	consoleUI.Success("%s is running", containerEngine.Name())
 
 	// Check Docker registry login
 	consoleUI.Info("🔑 Checking registry login...")
 
-	// Try to login automatically
-	user, _, err := gitlabClient.GetCurrentUser()
+	auth, err := registryLogin(ctx, gitlabClient, containerEngine, cfg, consoleUI)
 	if err != nil {
 		consoleUI.PrintDockerLoginFailed()
-		return nil, nil, nil, fmt.Errorf("failed to get current user: %w", err)
+		return nil, nil, nil, nil, err
 	}
+	consoleUI.PrintDockerLoginSuccess()
+
+	consoleUI.Success("Registry login checked successfully")
 
-	authInfo, err := dockerClient.Login(cfg.GitLabRegistry, user.Username, cfg.DockerToken)
+	return gitlabClient, containerEngine, oci.NewClient(cfg.GitLabRegistry, auth), cfg, nil
+}
+
+// CheckGitLab loads the configuration, then creates and checks the GitLab client. It is used by the commands
+// which only read GitLab, so the destination group and the container engine are not needed.
+func CheckGitLab(currentUI *ui.UI, cmd *cobra.Command) (*gitlab.Client, *config.Config, error) {
+	consoleUI := currentUI
+
+	consoleUI.Info("🛂 Doing some prechecks...")
+	consoleUI.Info("----------------------------------------")
+
+	cfg, err := config.LoadConfig(cmd)
 	if err != nil {
-		consoleUI.PrintDockerLoginFailed()
-		return nil, nil, nil, fmt.Errorf("failed to login to Docker registry: %w", err)
+		return nil, nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg.Profile != "" {
+		consoleUI.Info("👤 Using profile %s", cfg.Profile)
+	}
+	if err := cfg.ValidateSource(); err != nil {
+		consoleUI.Error("Configuration error: %v", err)
+		return nil, nil, fmt.Errorf("configuration validation failed: %w", err)
+	}
+	ui.MaskSecrets(cfg.GitLabToken, cfg.DockerToken)
+	// Only the warnings of the config file matter to commands which do not migrate
+	for _, issue := range cfg.Conflicts() {
+		if issue.Severity == config.SeverityWarning && issue.File != "" {
+			consoleUI.Warning("%s", issue)
+		}
 	}
-	dockerClient.SetAuthInfo(authInfo)
-	consoleUI.PrintDockerLoginSuccess()
 
-	consoleUI.Success("Registry login checked successfully")
+	gitlabClient, err := newGitLabClient(cmd.Context(), cfg, consoleUI)
+	if err != nil {
+		return nil, nil, err
+	}
+	return gitlabClient, cfg, nil
+}
+
+// newGitLabClient creates the GitLab client and checks the connection to the instance
+func newGitLabClient(ctx context.Context, cfg *config.Config, consoleUI *ui.UI) (*gitlab.Client, error) {
+	consoleUI.Info("🦊 Creating GitLab client...")
+	gitlabClient, err := gitlab.NewClient(cfg.GitLabToken, cfg.GitLabInstance)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GitLab client: %w", err)
+	}
+
+	if err := gitlabClient.CheckConnection(ctx); err != nil {
+		return nil, fmt.Errorf("failed to connect to GitLab: %w", err)
+	}
+	consoleUI.Success("GitLab client created successfully")
+	return gitlabClient, nil
+}
+
+// registryLogin logs in to the registry with, in order, the registry credentials given in the config,
+// the credentials already stored by the Docker CLI and the GitLab user with the GitLab token.
+// It returns the credentials used.
+func registryLogin(ctx context.Context, gitlabClient *gitlab.Client, containerEngine container.Engine, cfg *config.Config, consoleUI *ui.UI) (registry.AuthConfig, error) {
+	if cfg.DockerUser != "" {
+		consoleUI.Debug("Using registry credentials of %s", cfg.DockerUser)
+		auth := registryAuth(cfg, cfg.DockerUser)
+		if err := containerEngine.Login(ctx, auth); err != nil {
+			return auth, fmt.Errorf("failed to login to registry: %w", err)
+		}
+		return auth, nil
+	}
 
-	return gitlabClient, dockerClient, cfg, nil
+	// A registry password different from the GitLab token is used as is, stored credentials are ignored
+	if cfg.DockerToken == cfg.GitLabToken {
+		stored, err := docker.LoadCredentials(ctx, docker.ConfigDir(), cfg.GitLabRegistry)
+		switch {
+		case err != nil:
+			consoleUI.Warning("Failed to read stored registry credentials: %v", err)
+		case stored != nil:
+			err := containerEngine.Login(ctx, *stored)
+			if err == nil {
+				consoleUI.Debug("Reusing existing login to %s", cfg.GitLabRegistry)
+				return *stored, nil
+			}
+			consoleUI.Warning("Existing login to %s cannot be used: %v", cfg.GitLabRegistry, err)
+		}
+	}
+
+	user, _, err := gitlabClient.GetCurrentUser(ctx)
+	if err != nil {
+		return registry.AuthConfig{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	auth := registryAuth(cfg, user.Username)
+	if err := containerEngine.Login(ctx, auth); err != nil {
+		return auth, fmt.Errorf("failed to login to registry: %w", err)
+	}
+	return auth, nil
+}
+
+// registryAuth returns the credentials of username with the registry password of the config
+func registryAuth(cfg *config.Config, username string) registry.AuthConfig {
+	return registry.AuthConfig{
+		Username:      username,
+		Password:      cfg.DockerToken,
+		ServerAddress: cfg.GitLabRegistry,
+	}
 }
 
 // LoadConfig loads configuration from multiple sources with priority:
@@ -92,6 +181,30 @@ func CheckBeforeStarting(currentUI *ui.UI, cmd *cobra.Command) (*gitlab.Client,
 // 4. Defaults
 // 5. Interactive prompts (for missing mandatory values)
 func LoadConfig(cmd *cobra.Command, consoleUI *ui.UI) (*config.Config, error) {
+	cfg, err := ReadConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Profile != "" {
+		consoleUI.Info("👤 Using profile %s", cfg.Profile)
+	}
+
+	// Interactive prompts for missing mandatory values
+	if err := promptMissingValues(cfg, consoleUI); err != nil {
+		return nil, err
+	}
+	ui.MaskSecrets(cfg.GitLabToken, cfg.DockerToken)
+	for _, issue := range cfg.Conflicts() {
+		if issue.Severity == config.SeverityWarning {
+			consoleUI.Warning("%s", issue)
+		}
+	}
+
+	return cfg, nil
+}
+
+// ReadConfig loads the configuration like LoadConfig, without prompting for missing values
+func ReadConfig(cmd *cobra.Command) (*config.Config, error) {
 	// Load config using unified Viper-based loader
 	cfg, err := config.LoadConfig(cmd)
 	if err != nil {
@@ -115,17 +228,14 @@ func LoadConfig(cmd *cobra.Command, consoleUI *ui.UI) (*config.Config, error) {
 		// Viper default is already set to true in config.LoadConfig
 	}
 
-	// Interactive prompts for missing mandatory values
-	if err := promptMissingValues(cfg, consoleUI); err != nil {
-		return nil, err
-	}
-
 	return cfg, nil
 }
 
 // promptMissingValues prompts user for missing mandatory configuration values
 func promptMissingValues(cfg *config.Config, consoleUI *ui.UI) error {
-	if cfg.GitLabToken != "" && cfg.OldGroupName != "" && cfg.NewGroupName != "" {
+	// Groups are given by the manifest when there is one
+	groupsSet := cfg.Manifest != "" || (cfg.OldGroupName != "" && cfg.NewGroupName != "")
+	if cfg.GitLabToken != "" && groupsSet {
 		return nil
 	}
 	consoleUI.Warning("========================================\n")
@@ -141,7 +251,7 @@ func promptMissingValues(cfg *config.Config, consoleUI *ui.UI) error {
 		cfg.GitLabToken = strings.TrimSpace(token)
 	}
 
-	if cfg.OldGroupName == "" {
+	if cfg.OldGroupName == "" && cfg.Manifest == "" {
 		consoleUI.Question("🏚️ Old Group Name (source): ")
 		oldGroup, err := reader.ReadString('\n')
 		if err != nil {
@@ -150,7 +260,7 @@ func promptMissingValues(cfg *config.Config, consoleUI *ui.UI) error {
 		cfg.OldGroupName = strings.TrimSpace(oldGroup)
 	}
 
-	if cfg.NewGroupName == "" {
+	if cfg.NewGroupName == "" && cfg.Manifest == "" {
 		consoleUI.Question("🏡 New Group Name (destination): ")
 		newGroup, err := reader.ReadString('\n')
 		if err != nil {
@@ -0,0 +1,297 @@
+package check
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"migraptor/internal/config"
+	"migraptor/internal/gitlab"
+	"migraptor/internal/migration"
+	"migraptor/internal/ui"
+
+	gitlabCore "gitlab.com/gitlab-org/api/client-go"
+)
+
+// requiredTokenScopes lists the token scopes needed to migrate projects and their registries
+var requiredTokenScopes = []string{"api", "read_registry", "write_registry"}
+
+// PreflightClient is the subset of the GitLab API used by preflight checks
+type PreflightClient interface {
+	GetCurrentUser(ctx context.Context) (*gitlabCore.User, *gitlabCore.Response, error)
+	GetTokenScopes(ctx context.Context) ([]string, error)
+	GetGroupAccessLevel(ctx context.Context, groupID int, userID int64) (gitlabCore.AccessLevelValue, error)
+	GetProjectAccessLevel(ctx context.Context, projectID int, userID int64) (gitlabCore.AccessLevelValue, error)
+	SearchGroup(ctx context.Context, name string) (*gitlabCore.Group, error)
+	GetProjectByPath(ctx context.Context, fullPath string) (*gitlabCore.Project, error)
+	GetNamespaceStorage(ctx context.Context, fullPath string) (*gitlab.NamespaceStorage, error)
+	GetProjectStorageSize(ctx context.Context, projectID int) (int64, error)
+	ListActivePipelines(ctx context.Context, projectID int) ([]*gitlabCore.PipelineInfo, error)
+	ListRegistryRepositories(ctx context.Context, projectID int) ([]*gitlabCore.RegistryRepository, *gitlabCore.Response, error)
+	ListProjectPackages(ctx context.Context, projectID int) ([]*gitlabCore.Package, error)
+}
+
+// PreflightPlan describes the migration to verify before any destructive action
+type PreflightPlan struct {
+	SourceGroup      *gitlabCore.Group
+	DestinationGroup *gitlabCore.Group
+	DestinationPath  string
+	Projects         []*migration.ProjectInfo
+	KeepParent       bool
+	// TransferGroup is true when the whole source group is transferred instead of projects one by one
+	TransferGroup bool
+	// MigratePackages is true when the packages are deleted before the transfer and published again afterwards
+	MigratePackages bool
+}
+
+// PreflightIssue describes a problem found during preflight checks
+type PreflightIssue struct {
+	Blocking bool
+	Subject  string
+	Message  string
+}
+
+// PreflightReport holds the issues found during preflight checks
+type PreflightReport struct {
+	Issues []PreflightIssue
+}
+
+func (r *PreflightReport) block(subject, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, PreflightIssue{Blocking: true, Subject: subject, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *PreflightReport) warn(subject, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, PreflightIssue{Blocking: false, Subject: subject, Message: fmt.Sprintf(format, args...)})
+}
+
+// HasBlockingIssues returns true if at least one issue prevents the migration
+func (r *PreflightReport) HasBlockingIssues() bool {
+	for _, issue := range r.Issues {
+		if issue.Blocking {
+			return true
+		}
+	}
+	return false
+}
+
+// RunPreflight verifies permissions and feasibility of the migration before anything is touched:
+// token scopes, Owner/Maintainer rights on source and destination, destination path collisions,
+// namespace storage quota, running pipelines, pending registry deletions and packages blocking the transfer
+func RunPreflight(ctx context.Context, gitlabClient PreflightClient, plan *PreflightPlan, consoleUI *ui.UI) *PreflightReport {
+	report := &PreflightReport{}
+
+	consoleUI.Info("🛂 Running preflight checks...")
+
+	checkTokenScopes(ctx, gitlabClient, report)
+	checkPermissions(ctx, gitlabClient, plan, report)
+	checkCollisions(ctx, gitlabClient, plan, report)
+	checkStorageQuota(ctx, gitlabClient, plan, report)
+	checkProjectsActivity(ctx, gitlabClient, plan, report)
+	checkPackages(ctx, gitlabClient, plan, report)
+
+	for _, issue := range report.Issues {
+		consoleUI.PrintPreflightIssue(issue.Blocking, issue.Subject, issue.Message)
+	}
+	if len(report.Issues) == 0 {
+		consoleUI.Success("Preflight checks passed")
+	}
+
+	return report
+}
+
+// checkTokenScopes verifies the token has the scopes needed to handle projects and registries
+func checkTokenScopes(ctx context.Context, gitlabClient PreflightClient, report *PreflightReport) {
+	scopes, err := gitlabClient.GetTokenScopes(ctx)
+	if err != nil {
+		report.warn("token", "cannot read token scopes (not a personal access token?): %v", err)
+		return
+	}
+
+	if missing := missingTokenScopes(scopes); len(missing) > 0 {
+		report.block("token", "missing scopes %s (token has %s)", strings.Join(missing, ", "), strings.Join(scopes, ", "))
+	}
+}
+
+// missingTokenScopes returns the required scopes a token lacks
+func missingTokenScopes(scopes []string) []string {
+	var missing []string
+	for _, scope := range requiredTokenScopes {
+		// api scope grants registry read & write access
+		if !slices.Contains(scopes, scope) && !(scope != "api" && slices.Contains(scopes, "api")) {
+			missing = append(missing, scope)
+		}
+	}
+	return missing
+}
+
+// checkPermissions verifies the current user is Owner of what is transferred and at least Maintainer of the destination
+func checkPermissions(ctx context.Context, gitlabClient PreflightClient, plan *PreflightPlan, report *PreflightReport) {
+	user, _, err := gitlabClient.GetCurrentUser(ctx)
+	if err != nil {
+		report.block("permissions", "cannot get current user: %v", err)
+		return
+	}
+	if user.IsAdmin {
+		return
+	}
+
+	sourceLevel, err := gitlabClient.GetGroupAccessLevel(ctx, int(plan.SourceGroup.ID), user.ID)
+	if err != nil {
+		report.block(plan.SourceGroup.FullPath, "cannot get access level: %v", err)
+		return
+	}
+
+	if plan.TransferGroup {
+		if sourceLevel < gitlabCore.OwnerPermissions {
+			report.block(plan.SourceGroup.FullPath, "Owner role required to transfer the group, current role is %s", migration.AccessLevelName(sourceLevel))
+		}
+	} else if sourceLevel < gitlabCore.OwnerPermissions {
+		for _, project := range plan.Projects {
+			level, err := gitlabClient.GetProjectAccessLevel(ctx, project.ID, user.ID)
+			if err != nil {
+				report.block(project.Path, "cannot get access level: %v", err)
+				continue
+			}
+			if level < gitlabCore.OwnerPermissions {
+				report.block(project.Path, "Owner role required to transfer the project, current role is %s", migration.AccessLevelName(level))
+			}
+		}
+	}
+
+	if plan.DestinationGroup == nil || plan.DestinationGroup.ID == 0 {
+		report.block(plan.DestinationPath, "destination group does not exist")
+		return
+	}
+	destLevel, err := gitlabClient.GetGroupAccessLevel(ctx, int(plan.DestinationGroup.ID), user.ID)
+	if err != nil {
+		report.block(plan.DestinationGroup.FullPath, "cannot get access level: %v", err)
+		return
+	}
+	if destLevel < gitlabCore.MaintainerPermissions {
+		report.block(plan.DestinationGroup.FullPath, "at least Maintainer role required on destination, current role is %s", migration.AccessLevelName(destLevel))
+	}
+}
+
+// checkCollisions verifies nothing already exists at the paths the migration will create
+func checkCollisions(ctx context.Context, gitlabClient PreflightClient, plan *PreflightPlan, report *PreflightReport) {
+	destPath := strings.Trim(plan.DestinationPath, "/")
+
+	if plan.TransferGroup {
+		groupPath := fmt.Sprintf("%s/%s", destPath, plan.SourceGroup.Path)
+		if _, err := gitlabClient.SearchGroup(ctx, groupPath); err == nil {
+			report.block(groupPath, "a group already exists at destination path")
+		}
+		if project, err := gitlabClient.GetProjectByPath(ctx, groupPath); err == nil && project != nil {
+			report.block(groupPath, "a project already exists at destination path")
+		}
+		return
+	}
+
+	planned := make(map[string]string)
+	for _, project := range plan.Projects {
+		projectPath := migration.DestinationProjectPath(*project, plan.SourceGroup.FullPath, destPath, plan.KeepParent)
+		if other, ok := planned[projectPath]; ok {
+			report.block(projectPath, "projects %s and %s would both be moved to this path", other, project.PathWithNamespace)
+			continue
+		}
+		planned[projectPath] = project.PathWithNamespace
+
+		existing, err := gitlabClient.GetProjectByPath(ctx, projectPath)
+		if err != nil {
+			report.warn(projectPath, "cannot check destination path: %v", err)
+			continue
+		}
+		if existing != nil {
+			report.block(projectPath, "a project already exists at destination path")
+		}
+	}
+}
+
+// checkStorageQuota verifies the destination root namespace can hold the migrated projects
+func checkStorageQuota(ctx context.Context, gitlabClient PreflightClient, plan *PreflightPlan, report *PreflightReport) {
+	sourceRoot := strings.Split(plan.SourceGroup.FullPath, "/")[0]
+	destRoot := strings.Split(strings.Trim(plan.DestinationPath, "/"), "/")[0]
+	if sourceRoot == destRoot {
+		// Storage stays within the same root namespace
+		return
+	}
+
+	storage, err := gitlabClient.GetNamespaceStorage(ctx, destRoot)
+	if err != nil {
+		report.warn(destRoot, "cannot check namespace storage quota: %v", err)
+		return
+	}
+	if storage.Limit == 0 {
+		return
+	}
+
+	var required int64
+	for _, project := range plan.Projects {
+		size, err := gitlabClient.GetProjectStorageSize(ctx, project.ID)
+		if err != nil {
+			report.warn(project.Path, "cannot get storage size: %v", err)
+			continue
+		}
+		required += size
+	}
+
+	if storage.Used+required > storage.Limit {
+		report.block(destRoot, "storage quota exceeded: %s used + %s to migrate > %s limit",
+			ui.FormatBytes(storage.Used), ui.FormatBytes(required), ui.FormatBytes(storage.Limit))
+	}
+}
+
+// checkProjectsActivity flags projects with running pipelines or registry repositories being deleted
+func checkProjectsActivity(ctx context.Context, gitlabClient PreflightClient, plan *PreflightPlan, report *PreflightReport) {
+	for _, project := range plan.Projects {
+		pipelines, err := gitlabClient.ListActivePipelines(ctx, project.ID)
+		if err != nil {
+			report.warn(project.Path, "cannot list pipelines: %v", err)
+		} else if len(pipelines) > 0 {
+			report.warn(project.Path, "%d running or pending pipelines, they may push images or fail during transfer", len(pipelines))
+		}
+
+		if !project.ContainerRegistryEnabled {
+			continue
+		}
+		repositories, _, err := gitlabClient.ListRegistryRepositories(ctx, project.ID)
+		if err != nil {
+			report.warn(project.Path, "cannot list registry repositories: %v", err)
+			continue
+		}
+		for _, repo := range repositories {
+			if repo.Status != nil && *repo.Status != "" {
+				report.block(project.Path, "registry repository %s has a pending deletion (%s)", repo.Path, *repo.Status)
+			}
+		}
+	}
+}
+
+// checkPackages flags projects whose npm packages or Terraform modules prevent a transfer to another root namespace,
+// unless packages are migrated
+func checkPackages(ctx context.Context, gitlabClient PreflightClient, plan *PreflightPlan, report *PreflightReport) {
+	sourceRoot := strings.Split(plan.SourceGroup.FullPath, "/")[0]
+	destRoot := strings.Split(strings.Trim(plan.DestinationPath, "/"), "/")[0]
+	if plan.MigratePackages || sourceRoot == destRoot {
+		return
+	}
+
+	for _, project := range plan.Projects {
+		packages, err := gitlabClient.ListProjectPackages(ctx, project.ID)
+		if err != nil {
+			report.warn(project.Path, "cannot list packages: %v", err)
+			continue
+		}
+		scoped := 0
+		for _, pkg := range packages {
+			if pkg.PackageType == gitlab.PackageTypeNpm || pkg.PackageType == gitlab.PackageTypeTerraformModule {
+				scoped++
+			}
+		}
+		if scoped > 0 {
+			report.block(project.Path, "%d npm packages or Terraform modules are scoped to %s and prevent the transfer, use --%s to move them",
+				scoped, sourceRoot, config.MIGRATE_PACKAGES)
+		}
+	}
+}
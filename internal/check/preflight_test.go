@@ -0,0 +1,219 @@
+package check
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"migraptor/internal/fake"
+	"migraptor/internal/migration"
+	"migraptor/internal/ui"
+
+	gitlabCore "gitlab.com/gitlab-org/api/client-go"
+)
+
+var _ PreflightClient = (*fake.GitLab)(nil)
+
+// newPreflightPlan plans the migration of the projects of team to platform, project by project
+func newPreflightPlan(t *testing.T, gl *fake.GitLab, projectPaths ...string) *PreflightPlan {
+	t.Helper()
+	plan := &PreflightPlan{
+		SourceGroup:      gl.Group("team"),
+		DestinationGroup: gl.Group("platform"),
+		DestinationPath:  "platform",
+	}
+	for _, path := range projectPaths {
+		project := gl.Project(path)
+		if project == nil {
+			t.Fatalf("project %s not found", path)
+		}
+		info := migration.FilterProjects([]*gitlabCore.Project{project}, nil)[0]
+		plan.Projects = append(plan.Projects, &info)
+	}
+	return plan
+}
+
+// findIssue returns the first issue whose subject and message contain the given strings
+func findIssue(report *PreflightReport, subject, message string) *PreflightIssue {
+	for _, issue := range report.Issues {
+		if strings.Contains(issue.Subject, subject) && strings.Contains(issue.Message, message) {
+			return &issue
+		}
+	}
+	return nil
+}
+
+func TestRunPreflight_Passes(t *testing.T) {
+	gl := fake.NewGitLab("registry.example.com")
+	gl.AddProject("team/app")
+	gl.AddGroup("platform")
+
+	report := RunPreflight(t.Context(), gl, newPreflightPlan(t, gl, "team/app"), ui.New(false, io.Discard))
+	if len(report.Issues) != 0 {
+		t.Errorf("Expected no issue, got %v", report.Issues)
+	}
+}
+
+func TestRunPreflight_TokenScopes(t *testing.T) {
+	gl := fake.NewGitLab("registry.example.com")
+	gl.AddProject("team/app")
+	gl.AddGroup("platform")
+	gl.TokenScopes = []string{"read_api", "read_registry"}
+
+	report := RunPreflight(t.Context(), gl, newPreflightPlan(t, gl, "team/app"), ui.New(false, io.Discard))
+	issue := findIssue(report, "token", "write_registry")
+	if issue == nil || !issue.Blocking {
+		t.Errorf("Expected a blocking issue on missing scopes, got %v", report.Issues)
+	}
+	if findIssue(report, "token", "missing scopes api, write_registry") == nil {
+		t.Errorf("Expected api and write_registry to be reported missing, got %v", report.Issues)
+	}
+}
+
+func TestRunPreflight_Permissions(t *testing.T) {
+	gl := fake.NewGitLab("registry.example.com")
+	team := gl.AddGroup("team")
+	gl.AddProject("team/app")
+	platform := gl.AddGroup("platform")
+	user := gl.AddUser("developer")
+	gl.CurrentUser = user
+	if err := gl.AddGroupMember(t.Context(), int(team.ID), user.ID, gitlabCore.MaintainerPermissions, ""); err != nil {
+		t.Fatalf("AddGroupMember failed: %v", err)
+	}
+	if err := gl.AddGroupMember(t.Context(), int(platform.ID), user.ID, gitlabCore.DeveloperPermissions, ""); err != nil {
+		t.Fatalf("AddGroupMember failed: %v", err)
+	}
+
+	report := RunPreflight(t.Context(), gl, newPreflightPlan(t, gl, "team/app"), ui.New(false, io.Discard))
+	if findIssue(report, "app", "Owner role required to transfer the project") == nil {
+		t.Errorf("Expected the project to require Owner role, got %v", report.Issues)
+	}
+	if findIssue(report, "platform", "at least Maintainer role required") == nil {
+		t.Errorf("Expected the destination to require Maintainer role, got %v", report.Issues)
+	}
+	if !report.HasBlockingIssues() {
+		t.Error("Expected blocking issues")
+	}
+}
+
+func TestRunPreflight_Collisions(t *testing.T) {
+	gl := fake.NewGitLab("registry.example.com")
+	gl.AddProject("team/app")
+	gl.AddProject("team/backend/app")
+	gl.AddProject("team/api")
+	gl.AddProject("platform/api")
+
+	report := RunPreflight(t.Context(), gl, newPreflightPlan(t, gl, "team/app", "team/backend/app", "team/api"), ui.New(false, io.Discard))
+	if findIssue(report, "platform/app", "would both be moved to this path") == nil {
+		t.Errorf("Expected a collision between the two app projects, got %v", report.Issues)
+	}
+	if findIssue(report, "platform/api", "a project already exists") == nil {
+		t.Errorf("Expected a collision with the existing api project, got %v", report.Issues)
+	}
+
+	// With keep-parent, projects keep their sub-group
+	plan := newPreflightPlan(t, gl, "team/app", "team/backend/app")
+	plan.KeepParent = true
+	if report := RunPreflight(t.Context(), gl, plan, ui.New(false, io.Discard)); report.HasBlockingIssues() {
+		t.Errorf("Expected no collision with keep-parent, got %v", report.Issues)
+	}
+}
+
+func TestRunPreflight_StorageQuota(t *testing.T) {
+	gl := fake.NewGitLab("registry.example.com")
+	app := gl.AddProject("team/app")
+	gl.AddGroup("platform")
+	gl.SetProjectStorageSize(app.ID, 600)
+	gl.SetNamespaceStorage("platform", 500, 1000)
+
+	report := RunPreflight(t.Context(), gl, newPreflightPlan(t, gl, "team/app"), ui.New(false, io.Discard))
+	if issue := findIssue(report, "platform", "storage quota exceeded"); issue == nil || !issue.Blocking {
+		t.Errorf("Expected a blocking storage quota issue, got %v", report.Issues)
+	}
+}
+
+func TestRunPreflight_ProjectsActivity(t *testing.T) {
+	gl := fake.NewGitLab("registry.example.com")
+	app := gl.AddProject("team/app")
+	gl.AddGroup("platform")
+	gl.AddPipeline(app.ID, "running")
+	gl.AddPipeline(app.ID, "success")
+	gl.RegistryDeletionDelay = 5
+	if err := gl.AddImage("registry.example.com/team/app:1.0", 10); err != nil {
+		t.Fatalf("AddImage failed: %v", err)
+	}
+	repositories, _, _ := gl.ListRegistryRepositories(t.Context(), int(app.ID))
+	if _, err := gl.DeleteRegistryRepository(t.Context(), int(app.ID), int(repositories[0].ID)); err != nil {
+		t.Fatalf("DeleteRegistryRepository failed: %v", err)
+	}
+
+	report := RunPreflight(t.Context(), gl, newPreflightPlan(t, gl, "team/app"), ui.New(false, io.Discard))
+	if issue := findIssue(report, "app", "1 running or pending pipelines"); issue == nil || issue.Blocking {
+		t.Errorf("Expected a warning on running pipelines, got %v", report.Issues)
+	}
+	if issue := findIssue(report, "app", "pending deletion"); issue == nil || !issue.Blocking {
+		t.Errorf("Expected a blocking issue on pending registry deletion, got %v", report.Issues)
+	}
+}
+
+func TestRunPreflight_Packages(t *testing.T) {
+	gl := fake.NewGitLab("registry.example.com")
+	gl.AddProject("team/app")
+	gl.AddProject("team/lib")
+	gl.AddGroup("platform")
+	if err := gl.AddPackage("team/app", "npm", "@team/app", "1.0.0", map[string][]byte{"app-1.0.0.tgz": []byte("tarball")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := gl.AddPackage("team/lib", "maven", "com/example/lib", "1.0", map[string][]byte{"lib-1.0.jar": []byte("jar")}); err != nil {
+		t.Fatal(err)
+	}
+
+	plan := newPreflightPlan(t, gl, "team/app", "team/lib")
+	report := RunPreflight(t.Context(), gl, plan, ui.New(false, io.Discard))
+	if issue := findIssue(report, "app", "npm packages or Terraform modules"); issue == nil || !issue.Blocking {
+		t.Errorf("Expected a blocking issue on npm packages, got %v", report.Issues)
+	}
+	if issue := findIssue(report, "lib", "packages"); issue != nil {
+		t.Errorf("Expected Maven packages not to block the transfer, got %v", issue)
+	}
+
+	plan.MigratePackages = true
+	report = RunPreflight(t.Context(), gl, plan, ui.New(false, io.Discard))
+	if len(report.Issues) != 0 {
+		t.Errorf("Expected no issue when packages are migrated, got %v", report.Issues)
+	}
+}
+
+func TestPreflightReport(t *testing.T) {
+	report := &PreflightReport{}
+	report.warn("team/app", "%d running pipelines", 2)
+	if report.HasBlockingIssues() {
+		t.Error("Expected warnings not to block the migration")
+	}
+	report.block("platform", "destination group does not exist")
+	if !report.HasBlockingIssues() {
+		t.Error("Expected a blocking issue")
+	}
+	if len(report.Issues) != 2 || report.Issues[0].Message != "2 running pipelines" {
+		t.Errorf("Unexpected issues %v", report.Issues)
+	}
+}
+
+func TestMissingTokenScopes(t *testing.T) {
+	tests := []struct {
+		scopes   []string
+		expected string
+	}{
+		{[]string{"api"}, ""},
+		{[]string{"api", "read_user"}, ""},
+		{[]string{"read_registry", "write_registry"}, "api"},
+		{[]string{"read_api", "read_registry"}, "api,write_registry"},
+		{nil, "api,read_registry,write_registry"},
+	}
+
+	for _, tt := range tests {
+		if got := strings.Join(missingTokenScopes(tt.scopes), ","); got != tt.expected {
+			t.Errorf("Expected %q missing for %v, got %q", tt.expected, tt.scopes, got)
+		}
+	}
+}
@@ -33,8 +33,9 @@ func cleanImages(cmd *cobra.Command) {
 		os.Exit(1)
 	}
 	defer ui.Close()
+	ctx := cmd.Context()
 
-	gitlabClient, dockerClient, cfg, err := check.CheckBeforeStarting(consoleUI, cmd)
+	gitlabClient, dockerClient, _, cfg, err := check.CheckBeforeStarting(consoleUI, cmd)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to check before starting: %v\n", err)
 		os.Exit(1)
@@ -50,7 +51,7 @@ func cleanImages(cmd *cobra.Command) {
 
 	// Search for source group
 	consoleUI.Info("🔍 Searching for source group...")
-	groupFound, err := groupMigrator.SearchGroup(cfg.OldGroupName)
+	groupFound, err := groupMigrator.SearchGroup(ctx, cfg.OldGroupName)
 	if err != nil {
 		consoleUI.Error("Failed to search for group: %v", err)
 		os.Exit(321)
@@ -63,8 +64,14 @@ func cleanImages(cmd *cobra.Command) {
 
 	consoleUI.Debug("Found group with ID %d", groupFound.ID)
 
+	selection, err := migration.SelectionFromConfig(cfg)
+	if err != nil {
+		consoleUI.Error("Invalid project rules: %v", err)
+		os.Exit(1)
+	}
+
 	// List projects
-	projects, err := projectMigrator.ListProjects(groupFound.ID, cfg.ProjectsList)
+	projects, err := projectMigrator.ListProjects(ctx, groupFound.ID, selection)
 	if err != nil {
 		consoleUI.Error("Failed to list projects: %v", err)
 		os.Exit(1)
@@ -80,7 +87,7 @@ func cleanImages(cmd *cobra.Command) {
 		allProjects[proj.ID] = &proj
 	}
 
-	subGroups, subProjects, err := groupMigrator.GetSubGroupsAndProjects(groupFound.ID, cfg.ProjectsList)
+	subGroups, subProjects, err := groupMigrator.GetSubGroupsAndProjects(ctx, groupFound.ID, selection)
 
 	maps.Copy(allProjects, subProjects)
 
@@ -91,7 +98,7 @@ func cleanImages(cmd *cobra.Command) {
 
 	// Collect all images from all projects
 	consoleUI.Info("🔍 Collecting images from all registries...")
-	allImagesPtr, err := imageMigrator.GetAllImagesFromProjects(allProjects, cfg.TagsList)
+	allImagesPtr, err := imageMigrator.GetAllImagesFromProjects(ctx, allProjects, cfg.TagsList)
 	if err != nil {
 		consoleUI.Error("Failed to collect images: %v", err)
 		os.Exit(1)
@@ -196,7 +203,7 @@ func cleanImages(cmd *cobra.Command) {
 				continue
 			}
 
-			_, _, err := imageMigrator.BackupImages(proj, projectSelectedImages)
+			_, _, err := imageMigrator.BackupImages(ctx, proj, projectSelectedImages)
 			if err != nil {
 				consoleUI.Error("Failed to backup images: %v", err)
 				os.Exit(1)
@@ -209,29 +216,7 @@ func cleanImages(cmd *cobra.Command) {
 	// Delete selected images
 	consoleUI.Info("🗑️  Starting deletion of %d images...", len(selectedImages))
 
-	deletedCount := 0
-	failedCount := 0
-	totalImages := len(selectedImages)
-
-	for i, img := range selectedImages {
-		imageNum := i + 1
-		if cfg.DryRun {
-			consoleUI.Info("🌵 DRY RUN: Would delete image %d of %d: %s (Project: %s, Registry: %s)",
-				imageNum, totalImages, img.ImageInfo.Name, img.ProjectName, img.RegistryPath)
-			deletedCount++
-		} else {
-			consoleUI.Info("🗑️  Deleting image %d of %d: %s (Project: %s, Registry: %s)",
-				imageNum, totalImages, img.ImageInfo.Name, img.ProjectName, img.RegistryPath)
-
-			_, err := gitlabClient.DeleteRegistryRepositoryTag(img.ProjectID, img.RegistryID, img.ImageInfo.Name)
-			if err != nil {
-				consoleUI.Error("Failed to delete image %s: %v", img.ImageInfo.Name, err)
-				failedCount++
-			} else {
-				deletedCount++
-			}
-		}
-	}
+	deletedCount, failedCount := imageMigrator.DeleteImages(ctx, selectedImages)
 
 	// Display final summary
 	if cfg.DryRun {
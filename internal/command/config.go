@@ -0,0 +1,305 @@
+package command
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"migraptor/internal/check"
+	"migraptor/internal/config"
+	"migraptor/internal/ui"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	configInitOutput = "output"
+	configInitForce  = "force"
+)
+
+var Config = &cobra.Command{
+	Use:   "config",
+	Short: "Write, show and check the configuration",
+}
+
+var configInit = &cobra.Command{
+	Use:   "init",
+	Short: "Write a gitlab-migraptor.yaml config file by answering a few questions",
+	Run: func(cmd *cobra.Command, args []string) {
+		initConfig(cmd)
+	},
+}
+
+var configShow = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective configuration and where each value comes from",
+	Long: `Print the configuration merged from the flags, the environment, the config file and its profile,
+with the source of each value. Tokens are masked.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		showConfig(cmd)
+	},
+}
+
+var configValidate = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the config file, the options and the manifest without connecting to GitLab",
+	Long: `Check the keys and the values of gitlab-migraptor.yaml, reporting unknown keys and values of the wrong type
+with their line, then the options which contradict each other, given by the file, the environment and the flags.
+It exits with an error status when the configuration cannot be used.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		validateConfig(cmd)
+	},
+}
+
+func init() {
+	configInit.Flags().String(configInitOutput, "gitlab-migraptor.yaml", "config file to write")
+	configInit.Flags().Bool(configInitForce, false, "overwrite the config file if it exists")
+	Config.AddCommand(configInit, configShow, configValidate)
+}
+
+// readConfig loads the configuration without prompting, and exits listing the errors of the config file if any
+func readConfig(cmd *cobra.Command, consoleUI *ui.UI) *config.Config {
+	cfg, err := check.ReadConfig(cmd)
+	var validationErr *config.ValidationError
+	if errors.As(err, &validationErr) {
+		for _, issue := range validationErr.Issues {
+			consoleUI.Error("%s", issue)
+		}
+		consoleUI.Error("Configuration has %d errors", len(validationErr.Issues))
+		os.Exit(1)
+	}
+	if err != nil {
+		consoleUI.Error("Failed to load config: %v", err)
+		os.Exit(1)
+	}
+	ui.MaskSecrets(cfg.GitLabToken, cfg.DockerToken)
+	return cfg
+}
+
+func showConfig(cmd *cobra.Command) {
+	// Messages go to stderr so that the configuration can be piped
+	ui.SetConsoleOutput(os.Stderr)
+	consoleUI, err := ui.Init(false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize UI: %v\n", err)
+		os.Exit(1)
+	}
+	defer ui.Close()
+
+	cfg := readConfig(cmd, consoleUI)
+	if file := config.FileUsed(); file != "" {
+		consoleUI.Info("📄 Config file: %s", file)
+	} else {
+		consoleUI.Info("📄 No config file found")
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+	for _, setting := range cfg.Settings() {
+		value, source := setting.Value, setting.Source
+		if source == "" {
+			value, source = "-", "not set"
+		} else if setting.Secret && value != "" {
+			value = ui.MaskSecret(value)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", setting.Key, value, source)
+	}
+	w.Flush()
+}
+
+func initConfig(cmd *cobra.Command) {
+	output, _ := cmd.Flags().GetString(configInitOutput)
+	force, _ := cmd.Flags().GetBool(configInitForce)
+
+	consoleUI, err := ui.Init(false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize UI: %v\n", err)
+		os.Exit(1)
+	}
+	defer ui.Close()
+
+	if _, err := os.Stat(output); err == nil && !force {
+		consoleUI.Error("%s already exists, use --%s to overwrite it", output, configInitForce)
+		os.Exit(1)
+	}
+
+	settings, err := askSettings(consoleUI, bufio.NewReader(os.Stdin))
+	if err != nil {
+		consoleUI.Error("%v", err)
+		os.Exit(1)
+	}
+	content, err := config.RenderFile("MigRaptor configuration written by migraptor config init\nSee gitlab-migraptor-sample.yaml for all the settings", settings)
+	if err != nil {
+		consoleUI.Error("%v", err)
+		os.Exit(1)
+	}
+	// The file may hold a token
+	if err := os.WriteFile(output, content, 0600); err != nil {
+		consoleUI.Error("Failed to write %s: %v", output, err)
+		os.Exit(1)
+	}
+	consoleUI.Success("Configuration written to %s, check it with: migraptor config validate", output)
+}
+
+// askSettings asks the settings of a new config file
+func askSettings(consoleUI *ui.UI, reader *bufio.Reader) ([]config.FileSetting, error) {
+	ask := func(question, defaultValue string) (string, error) {
+		if defaultValue != "" {
+			consoleUI.Question("%s [%s]: ", question, defaultValue)
+		} else {
+			consoleUI.Question("%s: ", question)
+		}
+		answer, err := reader.ReadString('\n')
+		if err != nil {
+			return "", fmt.Errorf("failed to read answer: %w", err)
+		}
+		if answer = strings.TrimSpace(answer); answer == "" {
+			return defaultValue, nil
+		}
+		return answer, nil
+	}
+	askBool := func(question string, defaultValue bool) (bool, error) {
+		choices := "y/N"
+		if defaultValue {
+			choices = "Y/n"
+		}
+		answer, err := ask(question+" ("+choices+")", "")
+		if err != nil || answer == "" {
+			return defaultValue, err
+		}
+		return strings.EqualFold(answer, "y") || strings.EqualFold(answer, "yes"), nil
+	}
+
+	var settings []config.FileSetting
+	instance, err := ask("🦊 GitLab instance", "gitlab.com")
+	if err != nil {
+		return nil, err
+	}
+	settings = append(settings, config.FileSetting{Key: "gitlab_instance", Value: instance, Comment: "GitLab instance"})
+	registry, err := ask("🐳 Container registry", "registry."+strings.TrimPrefix(strings.TrimPrefix(instance, "https://"), "http://"))
+	if err != nil {
+		return nil, err
+	}
+	settings = append(settings, config.FileSetting{Key: "gitlab_registry", Value: registry, Comment: "Container registry of the instance"})
+
+	consoleUI.Info("🔑 How should the GitLab token be read?")
+	consoleUI.Info("   1) from a file")
+	consoleUI.Info("   2) from a command, e.g. pass show gitlab/migraptor")
+	consoleUI.Info("   3) from the keyring of the OS")
+	consoleUI.Info("   4) written in clear text in the config file")
+	consoleUI.Info("   5) not saved, given by GITLAB_TOKEN or asked when running")
+	choice, err := ask("Choice", "1")
+	if err != nil {
+		return nil, err
+	}
+	switch choice {
+	case "1":
+		file, err := ask("Token file", "~/.config/migraptor/token")
+		if err != nil {
+			return nil, err
+		}
+		settings = append(settings, config.FileSetting{Key: "token_file", Value: file, Comment: "File holding the GitLab token"})
+	case "2":
+		command, err := ask("Command printing the token", "pass show gitlab/migraptor")
+		if err != nil {
+			return nil, err
+		}
+		settings = append(settings, config.FileSetting{Key: "token_command", Value: command, Comment: "Command printing the GitLab token on its first line"})
+	case "3":
+		settings = append(settings, config.FileSetting{Key: "keyring", Value: true, Comment: "Look up the tokens in the keyring of the OS"})
+		consoleUI.Info("Store the token under the %s service with the instance host as account, e.g.:", config.KeyringService)
+		consoleUI.Info("   secret-tool store --label=MigRaptor service %s account %s", config.KeyringService, instance)
+		consoleUI.Info("   security add-generic-password -s %s -a %s -w", config.KeyringService, instance)
+	case "4":
+		token, err := ask("GitLab token", "")
+		if err != nil {
+			return nil, err
+		}
+		settings = append(settings, config.FileSetting{Key: "gitlab_token", Value: token, Comment: "GitLab API token, needs the api, read_registry and write_registry scopes"})
+	case "5":
+	default:
+		return nil, fmt.Errorf("invalid choice %s", choice)
+	}
+
+	engine, err := ask("🐳 Container engine (docker, podman or containerd)", "docker")
+	if err != nil {
+		return nil, err
+	}
+	settings = append(settings, config.FileSetting{Key: "container_engine", Value: engine, Comment: "Container engine holding images locally"})
+	oldGroup, err := ask("🏚️ Old group name (source), empty to give it when running", "")
+	if err != nil {
+		return nil, err
+	}
+	if oldGroup != "" {
+		settings = append(settings, config.FileSetting{Key: "old_group_name", Value: oldGroup, Comment: "Group containing the projects to migrate"})
+	}
+	newGroup, err := ask("🏡 New group name (destination), empty to give it when running", "")
+	if err != nil {
+		return nil, err
+	}
+	if newGroup != "" {
+		settings = append(settings, config.FileSetting{Key: "new_group_name", Value: newGroup, Comment: "Group receiving the migrated projects"})
+	}
+	keepParent, err := askBool("📦 Move the old group itself below the new group", true)
+	if err != nil {
+		return nil, err
+	}
+	settings = append(settings, config.FileSetting{Key: "keep_parent", Value: keepParent, Comment: "true: the old group moves below the new group, false: only its projects move"})
+	dryRun, err := askBool("🌵 Simulate migrations until dry_run is set to false", true)
+	if err != nil {
+		return nil, err
+	}
+	settings = append(settings, config.FileSetting{Key: "dry_run", Value: dryRun, Comment: "Show what would happen without changing anything"})
+	return settings, nil
+}
+
+func validateConfig(cmd *cobra.Command) {
+	consoleUI, err := ui.Init(false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize UI: %v\n", err)
+		os.Exit(1)
+	}
+	defer ui.Close()
+
+	cfg := readConfig(cmd, consoleUI)
+	if file := config.FileUsed(); file != "" {
+		consoleUI.Info("📄 Config file: %s", file)
+	} else {
+		consoleUI.Info("📄 No config file found, only the environment and the flags are checked")
+	}
+	if cfg.Profile != "" {
+		consoleUI.Info("👤 Profile: %s", cfg.Profile)
+	}
+
+	errorCount := 0
+	for _, issue := range cfg.Conflicts() {
+		if issue.Severity == config.SeverityError {
+			errorCount++
+			consoleUI.Error("%s", issue)
+		} else {
+			consoleUI.Warning("%s", issue)
+		}
+	}
+	if errorCount == 0 {
+		// Missing values are asked when running
+		if err := cfg.Validate(); err != nil {
+			consoleUI.Warning("%v, it will be asked when migrating", err)
+		}
+	}
+	if cfg.Manifest != "" {
+		if manifest, err := config.LoadManifest(cfg.Manifest); err != nil {
+			errorCount++
+			consoleUI.Error("%v", err)
+		} else {
+			consoleUI.Info("📋 Manifest %s lists %d migrations", cfg.Manifest, len(manifest.Migrations))
+		}
+	}
+
+	if errorCount > 0 {
+		consoleUI.Error("Configuration has %d errors", errorCount)
+		os.Exit(1)
+	}
+	consoleUI.Success("Configuration is valid")
+}
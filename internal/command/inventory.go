@@ -0,0 +1,91 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"migraptor/internal/check"
+	"migraptor/internal/inventory"
+	"migraptor/internal/migration"
+	"migraptor/internal/ui"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	inventoryFormat = "format"
+	inventoryOutput = "output"
+)
+
+var Inventory = &cobra.Command{
+	Use:     "inventory",
+	Aliases: []string{"inv"},
+	Short:   "List the projects and registries of a group without changing anything",
+	Long: `List the projects of the source group and its sub-groups with their archived state, last activity,
+container registry repositories, tag counts, sizes and last updates, as a table, JSON or CSV.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		listInventory(cmd)
+	},
+}
+
+func init() {
+	Inventory.Flags().String(inventoryFormat, inventory.FormatTable, "output format: "+strings.Join(inventory.Formats, ", "))
+	Inventory.Flags().String(inventoryOutput, "", "write the inventory to this file instead of stdout")
+}
+
+func listInventory(cmd *cobra.Command) {
+	format, _ := cmd.Flags().GetString(inventoryFormat)
+	output, _ := cmd.Flags().GetString(inventoryOutput)
+	if !slices.Contains(inventory.Formats, format) {
+		fmt.Fprintf(os.Stderr, "Unknown format %s, expected one of %s\n", format, strings.Join(inventory.Formats, ", "))
+		os.Exit(1)
+	}
+
+	// Messages go to stderr so that the inventory can be piped
+	ui.SetConsoleOutput(os.Stderr)
+	consoleUI, err := ui.Init(false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize UI: %v\n", err)
+		os.Exit(1)
+	}
+	defer ui.Close()
+	ctx := cmd.Context()
+
+	gitlabClient, cfg, err := check.CheckGitLab(consoleUI, cmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to check before starting: %v\n", err)
+		os.Exit(1)
+	}
+
+	selection, err := migration.SelectionFromConfig(cfg)
+	if err != nil {
+		consoleUI.Error("Invalid project rules: %v", err)
+		os.Exit(1)
+	}
+
+	inv, err := inventory.NewCollector(gitlabClient, consoleUI).Collect(ctx, cfg.OldGroupName, selection, cfg.TagsList)
+	if err != nil {
+		consoleUI.Error("Failed to build inventory: %v", err)
+		os.Exit(1)
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			consoleUI.Error("Failed to create %s: %v", output, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := inventory.Write(w, inv.Entries, format); err != nil {
+		consoleUI.Error("Failed to write inventory: %v", err)
+		os.Exit(1)
+	}
+	if output != "" {
+		consoleUI.Success("Inventory of %d entries written to %s", len(inv.Entries), output)
+	}
+}
@@ -0,0 +1,104 @@
+package command
+
+import (
+	"fmt"
+	"migraptor/internal/check"
+	"migraptor/internal/inventory"
+	"migraptor/internal/migration"
+	"migraptor/internal/ui"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	usageTop          = "top"
+	usageSnapshotFile = "snapshot-file"
+)
+
+var Usage = &cobra.Command{
+	Use:   "usage",
+	Short: "Report the registry storage used by a group, its projects and repositories",
+	Long: `Aggregate the container registry size of the source group by sub-group, project and repository,
+and list the largest repositories and tags. With a snapshot file, the report shows the growth since
+the previous run and saves a new snapshot.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		reportUsage(cmd)
+	},
+}
+
+func init() {
+	Usage.Flags().Int(usageTop, 10, "number of largest repositories and tags to list")
+	Usage.Flags().String(usageSnapshotFile, "", "file keeping the snapshots of previous runs, to show the growth since the last one")
+	Usage.Flags().String(inventoryFormat, inventory.FormatTable, "output format: table or json")
+}
+
+func reportUsage(cmd *cobra.Command) {
+	top, _ := cmd.Flags().GetInt(usageTop)
+	snapshotFile, _ := cmd.Flags().GetString(usageSnapshotFile)
+	format, _ := cmd.Flags().GetString(inventoryFormat)
+	if format != inventory.FormatTable && format != inventory.FormatJSON {
+		fmt.Fprintf(os.Stderr, "Unknown format %s, expected table or json\n", format)
+		os.Exit(1)
+	}
+	if top < 0 {
+		fmt.Fprintf(os.Stderr, "Invalid --%s %d, expected 0 or more\n", usageTop, top)
+		os.Exit(1)
+	}
+
+	// Messages go to stderr so that the report can be piped
+	ui.SetConsoleOutput(os.Stderr)
+	consoleUI, err := ui.Init(false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize UI: %v\n", err)
+		os.Exit(1)
+	}
+	defer ui.Close()
+	ctx := cmd.Context()
+
+	gitlabClient, cfg, err := check.CheckGitLab(consoleUI, cmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to check before starting: %v\n", err)
+		os.Exit(1)
+	}
+
+	var snapshots []*inventory.Snapshot
+	if snapshotFile != "" {
+		if snapshots, err = inventory.LoadSnapshots(snapshotFile); err != nil {
+			consoleUI.Error("%v", err)
+			os.Exit(1)
+		}
+	}
+
+	selection, err := migration.SelectionFromConfig(cfg)
+	if err != nil {
+		consoleUI.Error("Invalid project rules: %v", err)
+		os.Exit(1)
+	}
+
+	inv, err := inventory.NewCollector(gitlabClient, consoleUI).Collect(ctx, cfg.OldGroupName, selection, cfg.TagsList)
+	if err != nil {
+		consoleUI.Error("Failed to collect registry usage: %v", err)
+		os.Exit(1)
+	}
+
+	snapshot := inventory.NewSnapshot(inv, time.Now().UTC())
+	previous := inventory.LastSnapshot(snapshots, inv.Group)
+	if snapshotFile != "" && previous == nil {
+		consoleUI.Info("📸 No previous snapshot of %s in %s, growth will be shown from the next run", inv.Group, snapshotFile)
+	}
+
+	if err := inventory.NewUsage(inv, snapshot, previous, top).Write(os.Stdout, format); err != nil {
+		consoleUI.Error("Failed to write usage report: %v", err)
+		os.Exit(1)
+	}
+
+	if snapshotFile != "" {
+		if err := inventory.SaveSnapshots(snapshotFile, append(snapshots, snapshot)); err != nil {
+			consoleUI.Error("%v", err)
+			os.Exit(1)
+		}
+		consoleUI.Success("Snapshot saved to %s", snapshotFile)
+	}
+}
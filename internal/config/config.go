@@ -1,9 +1,14 @@
 package config
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"maps"
 	"os"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -12,25 +17,93 @@ import (
 
 // Config holds all configuration for the migration tool
 type Config struct {
-	GitLabToken    string   `mapstructure:"token"`
-	GitLabInstance string   `mapstructure:"instance"`
-	GitLabRegistry string   `mapstructure:"registry"`
-	DockerToken    string   `mapstructure:"docker-password"`
-	OldGroupName   string   `mapstructure:"old-group"`
-	NewGroupName   string   `mapstructure:"new-group"`
-	ParentGroupID  int      `mapstructure:"parent-group-id"`
-	ProjectsList   []string `mapstructure:"projects"`
-	TagsList       []string `mapstructure:"tags"`
+	GitLabToken    string `mapstructure:"token"`
+	GitLabInstance string `mapstructure:"instance"`
+	GitLabRegistry string `mapstructure:"registry"`
+	DockerToken    string `mapstructure:"docker-password"`
+	DockerUser     string `mapstructure:"docker-user"`
+	// GitLabTokenFile and GitLabTokenCommand give the GitLab token when it is not set:
+	// the content of the file, or the first line printed by the command
+	GitLabTokenFile    string `mapstructure:"token-file"`
+	GitLabTokenCommand string `mapstructure:"token-command"`
+	// DockerTokenFile and DockerTokenCommand give the registry password the same way
+	DockerTokenFile    string `mapstructure:"docker-password-file"`
+	DockerTokenCommand string `mapstructure:"docker-password-command"`
+	// Keyring looks up the tokens still missing in the keyring of the OS
+	Keyring bool `mapstructure:"keyring"`
+	// ContainerEngine is the engine holding images locally: docker, podman or containerd
+	ContainerEngine string   `mapstructure:"container-engine"`
+	OldGroupName    string   `mapstructure:"old-group"`
+	NewGroupName    string   `mapstructure:"new-group"`
+	ParentGroupID   int      `mapstructure:"parent-group-id"`
+	ProjectsList    []string `mapstructure:"projects"`
+	TagsList        []string `mapstructure:"tags"`
+	// Include, Exclude, Topics, ActiveSince, InactiveSince and Archived select the projects to migrate
+	// along with ProjectsList, see migration.SelectionRules
+	Include       []string `mapstructure:"include"`
+	Exclude       []string `mapstructure:"exclude"`
+	Topics        []string `mapstructure:"topics"`
+	ActiveSince   string   `mapstructure:"active-since"`
+	InactiveSince string   `mapstructure:"inactive-since"`
+	Archived      string   `mapstructure:"archived"`
+	// ExcludeGroups are sub-groups of the old group left in place with their sub-groups and projects
+	ExcludeGroups  []string `mapstructure:"exclude-groups"`
 	KeepParent     bool     `mapstructure:"keep-parent"`
 	DryRun         bool     `mapstructure:"dry-run"`
 	Verbose        bool     `mapstructure:"verbose"`
 	BackupImages   bool     `mapstructure:"backup-images"`
+	MigrateMembers bool     `mapstructure:"migrate-members"`
+	// RemoveLocalImages removes the local copies of the images of a project once their push is verified
+	RemoveLocalImages bool `mapstructure:"remove-local-images"`
+	// MigratePackages downloads the packages of the projects before the transfer and publishes them again afterwards
+	MigratePackages bool `mapstructure:"migrate-packages"`
+	// GroupTemplate overrides the settings copied from the source group when creating destination groups
+	GroupTemplate *GroupTemplate `mapstructure:"group-template"`
+	// Manifest is a file listing several migrations to run instead of the old and new groups
+	Manifest string `mapstructure:"manifest"`
+	// Profile is the profile of the config file applied over its top-level values
+	Profile string `mapstructure:"profile"`
+
+	// fileWarnings are the warnings found when validating the config file
+	fileWarnings []Issue
+	// sources tell where the value of each key comes from, by viper key
+	sources map[string]string
+}
+
+// GroupTemplate holds group settings applied to the groups created by the migration.
+// Empty values fall back to the settings of the source group.
+type GroupTemplate struct {
+	Visibility              string                    `mapstructure:"visibility"`
+	Description             string                    `mapstructure:"description"`
+	Avatar                  string                    `mapstructure:"avatar"` // Path to a local image file
+	ProjectCreationLevel    string                    `mapstructure:"project_creation_level"`
+	SharedRunnersSetting    string                    `mapstructure:"shared_runners_setting"`
+	DefaultBranchProtection *BranchProtectionTemplate `mapstructure:"default_branch_protection"`
+	Labels                  []LabelTemplate           `mapstructure:"labels"`
+}
+
+// BranchProtectionTemplate holds the default branch protection applied to created groups.
+// Access levels are names: "no_one", "developer", "maintainer", "owner" or "admin".
+type BranchProtectionTemplate struct {
+	AllowedToPush           []string `mapstructure:"allowed_to_push"`
+	AllowedToMerge          []string `mapstructure:"allowed_to_merge"`
+	AllowForcePush          bool     `mapstructure:"allow_force_push"`
+	DeveloperCanInitialPush bool     `mapstructure:"developer_can_initial_push"`
+}
+
+// LabelTemplate holds a group label created in addition to the labels of the source group
+type LabelTemplate struct {
+	Name        string `mapstructure:"name"`
+	Color       string `mapstructure:"color"`
+	Description string `mapstructure:"description"`
 }
 
 const GITLAB_TOKEN = "token"
 const GITLAB_INSTANCE = "instance"
 const GITLAB_REGISTRY = "registry"
 const DOCKER_PASSWORD = "docker-password"
+const DOCKER_USER = "docker-user"
+const CONTAINER_ENGINE = "container-engine"
 const OLD_GROUP_NAME = "old-group"
 const NEW_GROUP_NAME = "new-group"
 const PROJECTS_LIST = "projects"
@@ -39,23 +112,60 @@ const KEEP_PARENT = "keep-parent"
 const DRY_RUN = "dry-run"
 const VERBOSE = "verbose"
 const BACKUP_IMAGES = "backup-images"
+const GROUP_TEMPLATE = "group-template"
+const MIGRATE_MEMBERS = "migrate-members"
+const REMOVE_LOCAL_IMAGES = "remove-local-images"
+const MIGRATE_PACKAGES = "migrate-packages"
+const MANIFEST = "manifest"
+const PROFILE = "profile"
+const TOKEN_FILE = "token-file"
+const TOKEN_COMMAND = "token-command"
+const DOCKER_PASSWORD_FILE = "docker-password-file"
+const DOCKER_PASSWORD_COMMAND = "docker-password-command"
+const KEYRING = "keyring"
+const INCLUDE = "include"
+const EXCLUDE = "exclude"
+const TOPICS = "topics"
+const ACTIVE_SINCE = "active-since"
+const INACTIVE_SINCE = "inactive-since"
+const ARCHIVED = "archived"
+const EXCLUDE_GROUPS = "exclude-groups"
 
 // getFlagNameForViperKey returns the flag name (constant) for a given viper key
 func getFlagNameForViperKey(viperKey string) string {
 	flagMap := map[string]string{
-		"token":           GITLAB_TOKEN,
-		"instance":        GITLAB_INSTANCE,
-		"registry":        GITLAB_REGISTRY,
-		"docker-password": DOCKER_PASSWORD,
-		"old-group":       OLD_GROUP_NAME,
-		"new-group":       NEW_GROUP_NAME,
-		"parent-group-id": "parent-group-id", // No constant for this, use key directly
-		"projects":        PROJECTS_LIST,
-		"tags":            TAGS_LIST,
-		"keep-parent":     KEEP_PARENT,
-		"dry-run":         DRY_RUN,
-		"verbose":         VERBOSE,
-		"backup-images":   BACKUP_IMAGES,
+		"token":                   GITLAB_TOKEN,
+		"instance":                GITLAB_INSTANCE,
+		"registry":                GITLAB_REGISTRY,
+		"docker-password":         DOCKER_PASSWORD,
+		"docker-user":             DOCKER_USER,
+		"container-engine":        CONTAINER_ENGINE,
+		"old-group":               OLD_GROUP_NAME,
+		"new-group":               NEW_GROUP_NAME,
+		"parent-group-id":         "parent-group-id", // No constant for this, use key directly
+		"projects":                PROJECTS_LIST,
+		"tags":                    TAGS_LIST,
+		"keep-parent":             KEEP_PARENT,
+		"dry-run":                 DRY_RUN,
+		"verbose":                 VERBOSE,
+		"backup-images":           BACKUP_IMAGES,
+		"migrate-members":         MIGRATE_MEMBERS,
+		"remove-local-images":     REMOVE_LOCAL_IMAGES,
+		"migrate-packages":        MIGRATE_PACKAGES,
+		"manifest":                MANIFEST,
+		"profile":                 PROFILE,
+		"token-file":              TOKEN_FILE,
+		"token-command":           TOKEN_COMMAND,
+		"docker-password-file":    DOCKER_PASSWORD_FILE,
+		"docker-password-command": DOCKER_PASSWORD_COMMAND,
+		"keyring":                 KEYRING,
+		"include":                 INCLUDE,
+		"exclude":                 EXCLUDE,
+		"topics":                  TOPICS,
+		"active-since":            ACTIVE_SINCE,
+		"inactive-since":          INACTIVE_SINCE,
+		"archived":                ARCHIVED,
+		"exclude-groups":          EXCLUDE_GROUPS,
 	}
 	if flagName, ok := flagMap[viperKey]; ok {
 		return flagName
@@ -73,6 +183,35 @@ func isFlagSet(cmd *cobra.Command, viperKey string) bool {
 	return flag.Changed
 }
 
+// aliasMap maps the keys of the config file (snake_case) to the actual keys (kebab-case)
+var aliasMap = map[string]string{
+	"gitlab_token":         "token",
+	"gitlab_instance":      "instance",
+	"gitlab_registry":      "registry",
+	"docker_token":         "docker-password",
+	"docker_user":          "docker-user",
+	"container_engine":     "container-engine",
+	"old_group_name":       "old-group",
+	"new_group_name":       "new-group",
+	"parent_group_id":      "parent-group-id",
+	"projects_list":        "projects",
+	"tags_list":            "tags",
+	"keep_parent":          "keep-parent",
+	"dry_run":              "dry-run",
+	"backup_images":        "backup-images",
+	"group_template":       "group-template",
+	"migrate_members":      "migrate-members",
+	"remove_local_images":  "remove-local-images",
+	"migrate_packages":     "migrate-packages",
+	"token_file":           "token-file",
+	"token_command":        "token-command",
+	"docker_token_file":    "docker-password-file",
+	"docker_token_command": "docker-password-command",
+	"active_since":         "active-since",
+	"inactive_since":       "inactive-since",
+	"exclude_groups":       "exclude-groups",
+}
+
 // copyAliasedValues copies values from aliased keys (snake_case from config file) to actual keys (kebab-case)
 // This is needed because:
 // 1. viper.Unmarshal() doesn't use aliases
@@ -80,21 +219,6 @@ func isFlagSet(cmd *cobra.Command, viperKey string) bool {
 // So we check if the snake_case keys exist in the config file and copy them to kebab-case keys
 // It skips copying if a flag was already set for that key (flags have highest priority)
 func copyAliasedValues(cmd *cobra.Command) {
-	aliasMap := map[string]string{
-		"gitlab_token":    "token",
-		"gitlab_instance": "instance",
-		"gitlab_registry": "registry",
-		"docker_token":    "docker-password",
-		"old_group_name":  "old-group",
-		"new_group_name":  "new-group",
-		"parent_group_id": "parent-group-id",
-		"projects_list":   "projects",
-		"tags_list":       "tags",
-		"keep_parent":     "keep-parent",
-		"dry_run":         "dry-run",
-		"backup_images":   "backup-images",
-	}
-
 	// Try to read the config file directly to get raw keys
 	// This is more reliable than AllSettings() which might process aliases
 	configFile := viper.ConfigFileUsed()
@@ -109,7 +233,7 @@ func copyAliasedValues(cmd *cobra.Command) {
 						continue
 					}
 					if value, exists := rawConfig[aliasKey]; exists && value != nil {
-						viper.Set(actualKey, value)
+						setValue(actualKey, value, "file "+aliasKey)
 					}
 				}
 				return // Successfully processed raw config file
@@ -127,28 +251,107 @@ func copyAliasedValues(cmd *cobra.Command) {
 		// Check AllSettings first (might have raw keys)
 		if allSettings != nil {
 			if value, exists := allSettings[aliasKey]; exists && value != nil {
-				viper.Set(actualKey, value)
+				setValue(actualKey, value, "file "+aliasKey)
 				continue
 			}
 		}
 		// Fallback: Try viper.Get() with alias key
 		if !viper.IsSet(actualKey) {
 			if value := viper.Get(aliasKey); value != nil {
-				viper.Set(actualKey, value)
+				setValue(actualKey, value, "file "+aliasKey)
+			}
+		}
+	}
+}
+
+// valueSources records where the values set by LoadConfig come from, by viper key
+var valueSources = map[string]string{}
+
+// setValue sets the value of a viper key and records its source
+func setValue(key string, value interface{}, source string) {
+	viper.Set(key, value)
+	valueSources[key] = source
+}
+
+// recordFileSources records the config file as source of the keys it gives under their viper name,
+// which are not copied by copyAliasedValues
+func recordFileSources() {
+	for name := range fileFields {
+		viperKey := name
+		if actualKey, isAlias := aliasMap[name]; isAlias {
+			viperKey = actualKey
+		}
+		if _, recorded := valueSources[viperKey]; !recorded && viper.InConfig(viperKey) {
+			valueSources[viperKey] = "file " + viperKey
+		}
+	}
+}
+
+// applyProfile copies the values of a profile of the config file over its top-level values.
+// Keys use the same names as the top level of the file. Values of flags set by the user are kept.
+func applyProfile(cmd *cobra.Command, name string) error {
+	configFile := viper.ConfigFileUsed()
+	if configFile == "" {
+		return fmt.Errorf("profile %s requested but no gitlab-migraptor.yaml config file was found", name)
+	}
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to read config file %s: %w", configFile, err)
+	}
+	var rawConfig struct {
+		Profiles map[string]map[string]interface{} `yaml:"profiles"`
+	}
+	if err := yaml.Unmarshal(data, &rawConfig); err != nil {
+		return fmt.Errorf("failed to read profiles of %s: %w", configFile, err)
+	}
+
+	profile, exists := rawConfig.Profiles[name]
+	if !exists {
+		available := slices.Sorted(maps.Keys(rawConfig.Profiles))
+		return fmt.Errorf("profile %s not found in %s (available: %s)", name, configFile, strings.Join(available, ", "))
+	}
+	for key, value := range profile {
+		viperKey := key
+		if actualKey, isAlias := aliasMap[key]; isAlias {
+			viperKey = actualKey
+		}
+		if viperKey == PROFILE || value == nil {
+			continue
+		}
+		// Skip if flag was already set (flags have highest priority)
+		if cmd != nil && isFlagSet(cmd, viperKey) {
+			continue
+		}
+		setValue(viperKey, value, "profile "+name)
+	}
+
+	// A secret given by the profile replaces the one of the top level, whatever its source
+	for _, sources := range secretSources {
+		if !slices.ContainsFunc(sources, func(source string) bool { return profile[source] != nil || profile[aliasMap[source]] != nil }) {
+			continue
+		}
+		for _, source := range sources {
+			if profile[source] == nil && profile[aliasMap[source]] == nil && !(cmd != nil && isFlagSet(cmd, aliasMap[source])) {
+				viper.Set(aliasMap[source], "")
+				delete(valueSources, aliasMap[source])
 			}
 		}
 	}
+	return nil
 }
 
 // LoadConfig loads configuration from multiple sources with proper precedence:
 // 1. Command-line flags (highest priority)
 // 2. Environment variables
-// 3. Config file
+// 3. Config file, the selected profile overriding its top-level values
 // 4. Defaults
 func LoadConfig(cmd *cobra.Command) (*Config, error) {
+	valueSources = map[string]string{}
+
 	// Set defaults
 	viper.SetDefault("instance", "gitlab.com")
 	viper.SetDefault("keep-parent", true)
+	viper.SetDefault("container-engine", "docker")
 
 	// Set up aliases for config file keys (snake_case) to flag keys (kebab-case)
 	// This allows the config file to use keys like "gitlab_token", "old_group_name", etc.
@@ -157,6 +360,8 @@ func LoadConfig(cmd *cobra.Command) (*Config, error) {
 	viper.RegisterAlias("gitlab_instance", "instance")
 	viper.RegisterAlias("gitlab_registry", "registry")
 	viper.RegisterAlias("docker_token", "docker-password")
+	viper.RegisterAlias("docker_user", "docker-user")
+	viper.RegisterAlias("container_engine", "container-engine")
 	viper.RegisterAlias("old_group_name", "old-group")
 	viper.RegisterAlias("new_group_name", "new-group")
 	viper.RegisterAlias("parent_group_id", "parent-group-id")
@@ -165,26 +370,71 @@ func LoadConfig(cmd *cobra.Command) (*Config, error) {
 	viper.RegisterAlias("keep_parent", "keep-parent")
 	viper.RegisterAlias("dry_run", "dry-run")
 	viper.RegisterAlias("backup_images", "backup-images")
+	viper.RegisterAlias("group_template", "group-template")
+	viper.RegisterAlias("migrate_members", "migrate-members")
+	viper.RegisterAlias("remove_local_images", "remove-local-images")
+	viper.RegisterAlias("migrate_packages", "migrate-packages")
+	viper.RegisterAlias("token_file", "token-file")
+	viper.RegisterAlias("token_command", "token-command")
+	viper.RegisterAlias("docker_token_file", "docker-password-file")
+	viper.RegisterAlias("docker_token_command", "docker-password-command")
+	viper.RegisterAlias("active_since", "active-since")
+	viper.RegisterAlias("inactive_since", "inactive-since")
+	viper.RegisterAlias("exclude_groups", "exclude-groups")
 
 	// Enable automatic environment variable binding
 	viper.AutomaticEnv()
 	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
 
-	// Also support legacy env var names without prefix
-	// Map legacy env var names to viper keys
-	err := viper.BindEnv("token", "GITLAB_TOKEN")
-	err = viper.BindEnv("instance", "GITLAB_INSTANCE")
-	err = viper.BindEnv("registry", "GITLAB_REGISTRY")
-	err = viper.BindEnv("docker-password", "DOCKER_TOKEN")
-	err = viper.BindEnv("old-group", "OLD_GROUP_NAME")
-	err = viper.BindEnv("new-group", "NEW_GROUP_NAME")
-	err = viper.BindEnv("parent-group-id", "PARENT_GROUP_ID")
-	err = viper.BindEnv("projects", "PROJECTS_LIST")
-	err = viper.BindEnv("tags", "TAGS_LIST")
-	err = viper.BindEnv("keep-parent", "KEEP_PARENT")
-	err = viper.BindEnv("dry-run", "DRY_RUN")
-	err = viper.BindEnv("verbose", "VERBOSE")
-	err = viper.BindEnv("backup-images", "BACKUP_IMAGES")
+	// Support MIGRAPTOR_ prefixed env vars as well as legacy env var names without prefix
+	// Map both env var names to viper keys (prefixed name is checked first)
+	err := viper.BindEnv("token", "MIGRAPTOR_TOKEN", "GITLAB_TOKEN")
+	err = viper.BindEnv("instance", "MIGRAPTOR_INSTANCE", "GITLAB_INSTANCE")
+	err = viper.BindEnv("registry", "MIGRAPTOR_REGISTRY", "GITLAB_REGISTRY")
+	err = viper.BindEnv("docker-password", "MIGRAPTOR_DOCKER_PASSWORD", "DOCKER_TOKEN")
+	err = viper.BindEnv("docker-user", "MIGRAPTOR_DOCKER_USER", "DOCKER_USER")
+	err = viper.BindEnv("container-engine", "MIGRAPTOR_CONTAINER_ENGINE", "CONTAINER_ENGINE")
+	err = viper.BindEnv("old-group", "MIGRAPTOR_OLD_GROUP", "OLD_GROUP_NAME")
+	err = viper.BindEnv("new-group", "MIGRAPTOR_NEW_GROUP", "NEW_GROUP_NAME")
+	err = viper.BindEnv("parent-group-id", "MIGRAPTOR_PARENT_GROUP_ID", "PARENT_GROUP_ID")
+	err = viper.BindEnv("projects", "MIGRAPTOR_PROJECTS", "PROJECTS_LIST")
+	err = viper.BindEnv("tags", "MIGRAPTOR_TAGS", "TAGS_LIST")
+	err = viper.BindEnv("keep-parent", "MIGRAPTOR_KEEP_PARENT", "KEEP_PARENT")
+	err = viper.BindEnv("dry-run", "MIGRAPTOR_DRY_RUN", "DRY_RUN")
+	err = viper.BindEnv("verbose", "MIGRAPTOR_VERBOSE", "VERBOSE")
+	err = viper.BindEnv("backup-images", "MIGRAPTOR_BACKUP_IMAGES", "BACKUP_IMAGES")
+	err = viper.BindEnv("migrate-members", "MIGRAPTOR_MIGRATE_MEMBERS", "MIGRATE_MEMBERS")
+	err = viper.BindEnv("remove-local-images", "MIGRAPTOR_REMOVE_LOCAL_IMAGES", "REMOVE_LOCAL_IMAGES")
+	if err != nil {
+		return nil, err
+	}
+	err = viper.BindEnv("migrate-packages", "MIGRAPTOR_MIGRATE_PACKAGES", "MIGRATE_PACKAGES")
+	if err != nil {
+		return nil, err
+	}
+	err = viper.BindEnv("manifest", "MIGRAPTOR_MANIFEST", "MIGRATION_MANIFEST")
+	if err != nil {
+		return nil, err
+	}
+	err = viper.BindEnv("profile", "MIGRAPTOR_PROFILE")
+	if err != nil {
+		return nil, err
+	}
+	err = viper.BindEnv("token-file", "MIGRAPTOR_TOKEN_FILE", "GITLAB_TOKEN_FILE")
+	err = viper.BindEnv("token-command", "MIGRAPTOR_TOKEN_COMMAND", "GITLAB_TOKEN_COMMAND")
+	err = viper.BindEnv("docker-password-file", "MIGRAPTOR_DOCKER_PASSWORD_FILE", "DOCKER_TOKEN_FILE")
+	err = viper.BindEnv("docker-password-command", "MIGRAPTOR_DOCKER_PASSWORD_COMMAND", "DOCKER_TOKEN_COMMAND")
+	err = viper.BindEnv("keyring", "MIGRAPTOR_KEYRING")
+	if err != nil {
+		return nil, err
+	}
+	err = viper.BindEnv("include", "MIGRAPTOR_INCLUDE")
+	err = viper.BindEnv("exclude", "MIGRAPTOR_EXCLUDE")
+	err = viper.BindEnv("topics", "MIGRAPTOR_TOPICS")
+	err = viper.BindEnv("active-since", "MIGRAPTOR_ACTIVE_SINCE")
+	err = viper.BindEnv("inactive-since", "MIGRAPTOR_INACTIVE_SINCE")
+	err = viper.BindEnv("archived", "MIGRAPTOR_ARCHIVED")
+	err = viper.BindEnv("exclude-groups", "MIGRAPTOR_EXCLUDE_GROUPS")
 	if err != nil {
 		return nil, err
 	}
@@ -226,14 +476,88 @@ func LoadConfig(cmd *cobra.Command) (*Config, error) {
 	if err := bindFlag("registry", GITLAB_REGISTRY); err != nil {
 		return nil, fmt.Errorf("failed to bind flag %s: %w", GITLAB_REGISTRY, err)
 	}
+	if cmd.Flags().Lookup(DOCKER_USER) != nil {
+		if err := bindFlag("docker-user", DOCKER_USER); err != nil {
+			return nil, fmt.Errorf("failed to bind flag %s: %w", DOCKER_USER, err)
+		}
+	}
+	if cmd.Flags().Lookup(CONTAINER_ENGINE) != nil {
+		if err := bindFlag("container-engine", CONTAINER_ENGINE); err != nil {
+			return nil, fmt.Errorf("failed to bind flag %s: %w", CONTAINER_ENGINE, err)
+		}
+	}
 	if err := bindFlag("tags", TAGS_LIST); err != nil {
 		return nil, fmt.Errorf("failed to bind flag %s: %w", TAGS_LIST, err)
 	}
 	if err := bindFlag("verbose", VERBOSE); err != nil {
 		return nil, fmt.Errorf("failed to bind flag %s: %w", VERBOSE, err)
 	}
-	if err := bindFlag("backup-images", BACKUP_IMAGES); err != nil {
-		return nil, fmt.Errorf("failed to bind flag %s: %w", BACKUP_IMAGES, err)
+	// backup-images is only defined on the clean command
+	if cmd.Flags().Lookup(BACKUP_IMAGES) != nil {
+		if err := bindFlag("backup-images", BACKUP_IMAGES); err != nil {
+			return nil, fmt.Errorf("failed to bind flag %s: %w", BACKUP_IMAGES, err)
+		}
+	}
+	// migrate-members is only defined on the migrate command
+	if cmd.Flags().Lookup(MIGRATE_MEMBERS) != nil {
+		if err := bindFlag("migrate-members", MIGRATE_MEMBERS); err != nil {
+			return nil, fmt.Errorf("failed to bind flag %s: %w", MIGRATE_MEMBERS, err)
+		}
+	}
+	// remove-local-images is only defined on the migrate command
+	if cmd.Flags().Lookup(REMOVE_LOCAL_IMAGES) != nil {
+		if err := bindFlag("remove-local-images", REMOVE_LOCAL_IMAGES); err != nil {
+			return nil, fmt.Errorf("failed to bind flag %s: %w", REMOVE_LOCAL_IMAGES, err)
+		}
+	}
+	// migrate-packages is only defined on the migrate command
+	if cmd.Flags().Lookup(MIGRATE_PACKAGES) != nil {
+		if err := bindFlag("migrate-packages", MIGRATE_PACKAGES); err != nil {
+			return nil, fmt.Errorf("failed to bind flag %s: %w", MIGRATE_PACKAGES, err)
+		}
+	}
+	// manifest is only defined on the migrate command
+	if cmd.Flags().Lookup(MANIFEST) != nil {
+		if err := bindFlag("manifest", MANIFEST); err != nil {
+			return nil, fmt.Errorf("failed to bind flag %s: %w", MANIFEST, err)
+		}
+	}
+	// profile is a persistent flag of the root command, missing when commands are run alone
+	if cmd.Flags().Lookup(PROFILE) != nil {
+		if err := bindFlag("profile", PROFILE); err != nil {
+			return nil, fmt.Errorf("failed to bind flag %s: %w", PROFILE, err)
+		}
+	}
+	// secret sources are persistent flags of the root command, missing when commands are run alone
+	for key, flagName := range map[string]string{
+		"token-file":              TOKEN_FILE,
+		"token-command":           TOKEN_COMMAND,
+		"docker-password-file":    DOCKER_PASSWORD_FILE,
+		"docker-password-command": DOCKER_PASSWORD_COMMAND,
+		"keyring":                 KEYRING,
+	} {
+		if cmd.Flags().Lookup(flagName) != nil {
+			if err := bindFlag(key, flagName); err != nil {
+				return nil, fmt.Errorf("failed to bind flag %s: %w", flagName, err)
+			}
+		}
+	}
+
+	// project rules are persistent flags of the root command, missing when commands are run alone
+	for key, flagName := range map[string]string{
+		"include":        INCLUDE,
+		"exclude":        EXCLUDE,
+		"topics":         TOPICS,
+		"active-since":   ACTIVE_SINCE,
+		"inactive-since": INACTIVE_SINCE,
+		"archived":       ARCHIVED,
+		"exclude-groups": EXCLUDE_GROUPS,
+	} {
+		if cmd.Flags().Lookup(flagName) != nil {
+			if err := bindFlag(key, flagName); err != nil {
+				return nil, fmt.Errorf("failed to bind flag %s: %w", flagName, err)
+			}
+		}
 	}
 
 	// Explicitly set flag values in Viper if flags were changed
@@ -252,23 +576,23 @@ func LoadConfig(cmd *cobra.Command) (*Config, error) {
 
 		// Get the actual typed value from the flag based on viper key type
 		switch viperKey {
-		case "dry-run", "keep-parent", "verbose":
+		case "dry-run", "keep-parent", "verbose", "migrate-members", "remove-local-images", "migrate-packages", "keyring":
 			// Boolean flags
 			if boolVal, err := cmd.Flags().GetBool(flagName); err == nil {
-				viper.Set(viperKey, boolVal)
+				setValue(viperKey, boolVal, "flag --"+flagName)
 			}
-		case "projects", "tags":
+		case "projects", "tags", "include", "exclude", "topics", "exclude-groups":
 			// String slice flags
 			if sliceVal, err := cmd.Flags().GetStringSlice(flagName); err == nil {
-				viper.Set(viperKey, sliceVal)
+				setValue(viperKey, sliceVal, "flag --"+flagName)
 			}
 		default:
 			// String flags (and other types)
-			viper.Set(viperKey, flag.Value.String())
+			setValue(viperKey, flag.Value.String(), "flag --"+flagName)
 		}
 	}
 
-	flagKeys := []string{"token", "old-group", "new-group", "dry-run", "instance", "keep-parent", "projects", "docker-password", "registry", "tags", "verbose"}
+	flagKeys := []string{"token", "old-group", "new-group", "dry-run", "instance", "keep-parent", "projects", "docker-password", "docker-user", "container-engine", "registry", "tags", "verbose", "migrate-members", "remove-local-images", "migrate-packages", "manifest", "profile", "token-file", "token-command", "docker-password-file", "docker-password-command", "keyring", "include", "exclude", "topics", "active-since", "inactive-since", "archived", "exclude-groups"}
 	for _, viperKey := range flagKeys {
 		setFlagValue(viperKey)
 	}
@@ -293,8 +617,28 @@ func LoadConfig(cmd *cobra.Command) (*Config, error) {
 	// So we manually copy values from config file keys to the keys Unmarshal expects
 	// Only do this if a config file was actually read
 	// copyAliasedValues will skip copying if flags were already set
+	// Reject unknown keys and values of the wrong type, which viper silently ignores
+	var fileWarnings []Issue
 	if err == nil {
+		issues, err := validateConfigFile(viper.ConfigFileUsed())
+		if err != nil {
+			return nil, err
+		}
+		var errs []Issue
+		errs, fileWarnings = splitIssues(issues)
+		if len(errs) > 0 {
+			return nil, &ValidationError{Issues: errs}
+		}
 		copyAliasedValues(cmd)
+		recordFileSources()
+	}
+
+	// Apply the selected profile over the top-level values of the config file.
+	// The profile is selected by flag, env var or a top-level profile key of the config file.
+	if profile := viper.GetString("profile"); profile != "" {
+		if err := applyProfile(cmd, profile); err != nil {
+			return nil, err
+		}
 	}
 
 	// STEP 4: Ensure flags still override config file values (in case copyAliasedValues set something)
@@ -307,18 +651,37 @@ func LoadConfig(cmd *cobra.Command) (*Config, error) {
 	// This is needed because viper might cache config file values and not re-check env vars
 	// We check flags first - if a flag has a non-empty value, we skip env var override for that key
 	envVarOverrides := map[string]string{
-		"token":           "GITLAB_TOKEN",
-		"instance":        "GITLAB_INSTANCE",
-		"registry":        "GITLAB_REGISTRY",
-		"docker-password": "DOCKER_TOKEN",
-		"old-group":       "OLD_GROUP_NAME",
-		"new-group":       "NEW_GROUP_NAME",
-		"parent-group-id": "PARENT_GROUP_ID",
-		"projects":        "PROJECTS_LIST",
-		"tags":            "TAGS_LIST",
-		"keep-parent":     "KEEP_PARENT",
-		"dry-run":         "DRY_RUN",
-		"verbose":         "VERBOSE",
+		"token":                   "GITLAB_TOKEN",
+		"instance":                "GITLAB_INSTANCE",
+		"registry":                "GITLAB_REGISTRY",
+		"docker-password":         "DOCKER_TOKEN",
+		"docker-user":             "DOCKER_USER",
+		"container-engine":        "CONTAINER_ENGINE",
+		"old-group":               "OLD_GROUP_NAME",
+		"new-group":               "NEW_GROUP_NAME",
+		"parent-group-id":         "PARENT_GROUP_ID",
+		"projects":                "PROJECTS_LIST",
+		"tags":                    "TAGS_LIST",
+		"keep-parent":             "KEEP_PARENT",
+		"dry-run":                 "DRY_RUN",
+		"verbose":                 "VERBOSE",
+		"migrate-members":         "MIGRATE_MEMBERS",
+		"remove-local-images":     "REMOVE_LOCAL_IMAGES",
+		"migrate-packages":        "MIGRATE_PACKAGES",
+		"manifest":                "MIGRATION_MANIFEST",
+		"profile":                 "MIGRAPTOR_PROFILE",
+		"token-file":              "GITLAB_TOKEN_FILE",
+		"token-command":           "GITLAB_TOKEN_COMMAND",
+		"docker-password-file":    "DOCKER_TOKEN_FILE",
+		"docker-password-command": "DOCKER_TOKEN_COMMAND",
+		"keyring":                 "MIGRAPTOR_KEYRING",
+		"include":                 "MIGRAPTOR_INCLUDE",
+		"exclude":                 "MIGRAPTOR_EXCLUDE",
+		"topics":                  "MIGRAPTOR_TOPICS",
+		"active-since":            "MIGRAPTOR_ACTIVE_SINCE",
+		"inactive-since":          "MIGRAPTOR_INACTIVE_SINCE",
+		"archived":                "MIGRAPTOR_ARCHIVED",
+		"exclude-groups":          "MIGRAPTOR_EXCLUDE_GROUPS",
 	}
 
 	// STEP 5: Override config file values with env vars, but only if flags haven't been set
@@ -328,9 +691,23 @@ func LoadConfig(cmd *cobra.Command) (*Config, error) {
 		if isFlagSet(cmd, viperKey) {
 			continue // Flag was set, skip env var override
 		}
-		// Check if env var is set and override config file value
-		if envValue := os.Getenv(envVarName); envValue != "" {
-			viper.Set(viperKey, envValue)
+		// Check if env var is set and override config file value, prefixed name first
+		prefixedName := "MIGRAPTOR_" + strings.ToUpper(strings.ReplaceAll(viperKey, "-", "_"))
+		if envValue := os.Getenv(prefixedName); envValue != "" {
+			setValue(viperKey, envValue, "env "+prefixedName)
+		} else if envValue := os.Getenv(envVarName); envValue != "" {
+			setValue(viperKey, envValue, "env "+envVarName)
+		}
+	}
+
+	// YAML reads unquoted dates as timestamps, activity dates are kept as strings
+	for _, key := range []string{"active-since", "inactive-since"} {
+		if date, isTime := viper.Get(key).(time.Time); isTime {
+			layout := time.RFC3339
+			if date.Equal(date.Truncate(24 * time.Hour)) {
+				layout = time.DateOnly
+			}
+			viper.Set(key, date.Format(layout))
 		}
 	}
 
@@ -339,6 +716,13 @@ func LoadConfig(cmd *cobra.Command) (*Config, error) {
 	if err := viper.Unmarshal(&cfg); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
+	cfg.fileWarnings = fileWarnings
+	cfg.sources = maps.Clone(valueSources)
+	for _, key := range []string{"instance", "keep-parent", "container-engine"} {
+		if _, recorded := cfg.sources[key]; !recorded {
+			cfg.sources[key] = "default"
+		}
+	}
 
 	// Handle legacy comma-separated env vars for lists
 	if projectsEnv := os.Getenv("PROJECTS_LIST"); projectsEnv != "" && len(cfg.ProjectsList) == 0 {
@@ -356,25 +740,62 @@ func LoadConfig(cmd *cobra.Command) (*Config, error) {
 
 	// Apply post-processing defaults
 	if cfg.GitLabRegistry == "" {
-		cfg.GitLabRegistry = "registry." + cfg.GitLabInstance
+		cfg.GitLabRegistry = "registry." + hostOf(cfg.GitLabInstance)
+		cfg.sources["registry"] = "default, from gitlab_instance"
+	}
+	// Read the tokens given by a file, a command or the keyring
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := cfg.resolveSecrets(ctx); err != nil {
+		return nil, err
 	}
 	if cfg.DockerToken == "" {
 		cfg.DockerToken = cfg.GitLabToken
+		if cfg.DockerToken != "" {
+			cfg.sources["docker-password"] = "default, gitlab_token"
+		}
 	}
 
 	return &cfg, nil
 }
 
+// FileUsed returns the path of the config file read by LoadConfig, empty if there is none
+func FileUsed() string {
+	return viper.ConfigFileUsed()
+}
+
 // Validate checks that all required configuration values are set
 func (c *Config) Validate() error {
+	// Groups are given by the manifest
+	if c.Manifest != "" {
+		if c.GitLabToken == "" {
+			return fmt.Errorf("GitLab token is required")
+		}
+		return nil
+	}
+	if err := c.ValidateSource(); err != nil {
+		return err
+	}
+	if c.NewGroupName == "" {
+		return fmt.Errorf("new group name is required")
+	}
+	for _, issue := range c.Conflicts() {
+		if issue.Severity == SeverityError {
+			return errors.New(issue.Message)
+		}
+	}
+	return nil
+}
+
+// ValidateSource checks the values needed to read the source group, for commands which do not migrate
+func (c *Config) ValidateSource() error {
 	if c.GitLabToken == "" {
 		return fmt.Errorf("GitLab token is required")
 	}
 	if c.OldGroupName == "" {
 		return fmt.Errorf("old group name is required")
 	}
-	if c.NewGroupName == "" {
-		return fmt.Errorf("new group name is required")
-	}
 	return nil
 }
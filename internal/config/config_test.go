@@ -3,6 +3,8 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/spf13/cobra"
@@ -25,6 +27,7 @@ func setupTestCommand() *cobra.Command {
 	cmd.Flags().StringP(GITLAB_REGISTRY, "r", "", "change gitlab registry name if not registry.<gitlab_instance>. By default, it's registry.gitlab.com")
 	cmd.Flags().StringSliceP(TAGS_LIST, "t", []string{}, "filter tags to keep when moving images & registries (comma-separated)")
 	cmd.Flags().BoolP(VERBOSE, "v", false, "verbose mode to debug your migration")
+	cmd.Flags().String(PROFILE, "", "profile of gitlab-migraptor.yaml to use")
 	return cmd
 }
 
@@ -543,6 +546,147 @@ verbose: true
 	}
 }
 
+// profilesConfig is a config file with top-level values overridden by two profiles
+const profilesConfig = `
+gitlab_token: default-token
+old_group_name: org/team
+dry_run: true
+profiles:
+  staging:
+    gitlab_token: staging-token
+    gitlab_instance: staging.example.com
+  prod:
+    gitlab_token: prod-token
+    gitlab_instance: gitlab.example.com
+    gitlab_registry: registry.example.com
+    projects_list: [app, api]
+    dry_run: false
+`
+
+func writeConfigFile(t *testing.T, content string) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "gitlab-migraptor.yaml"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+	t.Chdir(tmpDir)
+}
+
+func TestLoadConfig_Profile(t *testing.T) {
+	resetViper()
+	cmd := setupTestCommand()
+	writeConfigFile(t, profilesConfig)
+	cmd.Flags().Set("profile", "prod")
+
+	cfg, err := LoadConfig(cmd)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	// Profile values override the top-level values
+	if cfg.Profile != "prod" || cfg.GitLabToken != "prod-token" || cfg.GitLabInstance != "gitlab.example.com" {
+		t.Errorf("Expected values of the prod profile, got %+v", cfg)
+	}
+	if cfg.GitLabRegistry != "registry.example.com" || len(cfg.ProjectsList) != 2 || cfg.DryRun {
+		t.Errorf("Expected values of the prod profile, got %+v", cfg)
+	}
+	// Values missing from the profile come from the top level
+	if cfg.OldGroupName != "org/team" {
+		t.Errorf("Expected OldGroupName to be 'org/team', got '%s'", cfg.OldGroupName)
+	}
+}
+
+func TestLoadConfig_ProfileFromEnv(t *testing.T) {
+	resetViper()
+	cmd := setupTestCommand()
+	writeConfigFile(t, profilesConfig)
+	t.Setenv("MIGRAPTOR_PROFILE", "staging")
+
+	cfg, err := LoadConfig(cmd)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.GitLabToken != "staging-token" || cfg.GitLabInstance != "staging.example.com" {
+		t.Errorf("Expected values of the staging profile, got %+v", cfg)
+	}
+	// Registry is derived from the instance of the profile
+	if cfg.GitLabRegistry != "registry.staging.example.com" || !cfg.DryRun {
+		t.Errorf("Expected top-level values for what the profile does not set, got %+v", cfg)
+	}
+}
+
+func TestLoadConfig_ProfileFromConfigFile(t *testing.T) {
+	resetViper()
+	cmd := setupTestCommand()
+	writeConfigFile(t, "profile: staging\n"+profilesConfig)
+
+	cfg, err := LoadConfig(cmd)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.GitLabToken != "staging-token" {
+		t.Errorf("Expected the default profile of the config file, got '%s'", cfg.GitLabToken)
+	}
+
+	// The flag selects another profile
+	resetViper()
+	cmd = setupTestCommand()
+	cmd.Flags().Set("profile", "prod")
+	cfg, err = LoadConfig(cmd)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.GitLabToken != "prod-token" {
+		t.Errorf("Expected the profile of the flag, got '%s'", cfg.GitLabToken)
+	}
+}
+
+func TestLoadConfig_Precedence_FlagsAndEnvVarsOverrideProfile(t *testing.T) {
+	resetViper()
+	cmd := setupTestCommand()
+	writeConfigFile(t, profilesConfig)
+	cmd.Flags().Set("profile", "prod")
+	cmd.Flags().Set("instance", "flag.gitlab.com")
+	t.Setenv("MIGRAPTOR_TOKEN", "env-token")
+	t.Setenv("GITLAB_REGISTRY", "registry.env.com")
+
+	cfg, err := LoadConfig(cmd)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.GitLabInstance != "flag.gitlab.com" {
+		t.Errorf("Expected GitLabInstance to be 'flag.gitlab.com' (from flag), got '%s'", cfg.GitLabInstance)
+	}
+	if cfg.GitLabToken != "env-token" {
+		t.Errorf("Expected GitLabToken to be 'env-token' (from env var), got '%s'", cfg.GitLabToken)
+	}
+	if cfg.GitLabRegistry != "registry.env.com" {
+		t.Errorf("Expected GitLabRegistry to be 'registry.env.com' (from env var), got '%s'", cfg.GitLabRegistry)
+	}
+}
+
+func TestLoadConfig_UnknownProfile(t *testing.T) {
+	resetViper()
+	cmd := setupTestCommand()
+	writeConfigFile(t, profilesConfig)
+	cmd.Flags().Set("profile", "qa")
+
+	_, err := LoadConfig(cmd)
+	if err == nil || !strings.Contains(err.Error(), "available: prod, staging") {
+		t.Errorf("Expected an unknown profile error listing profiles, got %v", err)
+	}
+
+	// A profile needs a config file
+	resetViper()
+	cmd = setupTestCommand()
+	t.Chdir(t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	cmd.Flags().Set("profile", "prod")
+	if _, err := LoadConfig(cmd); err == nil {
+		t.Error("Expected a profile without config file to fail")
+	}
+}
+
 func TestLoadConfig_MissingFlag(t *testing.T) {
 	resetViper()
 	// Create a command without all flags
@@ -674,3 +818,107 @@ func TestLoadConfig_AllFlagsBound(t *testing.T) {
 		t.Error("Verbose should be loaded from flag")
 	}
 }
+
+func TestLoadConfig_GroupTemplate(t *testing.T) {
+	resetViper()
+	cmd := setupTestCommand()
+
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "gitlab-migraptor.yaml")
+	configContent := `
+gitlab_token: template-token
+group_template:
+  visibility: internal
+  project_creation_level: maintainer
+  shared_runners_setting: disabled_and_overridable
+  default_branch_protection:
+    allowed_to_push:
+      - maintainer
+    allow_force_push: false
+  labels:
+    - name: team::a
+      color: "#ff0000"
+`
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+
+	originalDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	defer os.Chdir(originalDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+
+	cfg, err := LoadConfig(cmd)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if cfg.GroupTemplate == nil {
+		t.Fatal("Expected GroupTemplate to be loaded from config file")
+	}
+	if cfg.GroupTemplate.Visibility != "internal" {
+		t.Errorf("Expected Visibility to be 'internal', got '%s'", cfg.GroupTemplate.Visibility)
+	}
+	if cfg.GroupTemplate.ProjectCreationLevel != "maintainer" {
+		t.Errorf("Expected ProjectCreationLevel to be 'maintainer', got '%s'", cfg.GroupTemplate.ProjectCreationLevel)
+	}
+	if cfg.GroupTemplate.SharedRunnersSetting != "disabled_and_overridable" {
+		t.Errorf("Expected SharedRunnersSetting to be 'disabled_and_overridable', got '%s'", cfg.GroupTemplate.SharedRunnersSetting)
+	}
+	if cfg.GroupTemplate.DefaultBranchProtection == nil || len(cfg.GroupTemplate.DefaultBranchProtection.AllowedToPush) != 1 {
+		t.Errorf("Expected DefaultBranchProtection with one allowed_to_push level, got %+v", cfg.GroupTemplate.DefaultBranchProtection)
+	}
+	if len(cfg.GroupTemplate.Labels) != 1 || cfg.GroupTemplate.Labels[0].Name != "team::a" {
+		t.Errorf("Expected one label 'team::a', got %+v", cfg.GroupTemplate.Labels)
+	}
+}
+
+func TestLoadConfig_ProjectRules(t *testing.T) {
+	resetViper()
+	cmd := setupTestCommand()
+	cmd.Flags().StringSlice(EXCLUDE, []string{}, "patterns selecting projects to leave in place")
+	cmd.Flags().String(ARCHIVED, "", "archived projects: include, exclude or only")
+	writeConfigFile(t, `
+include: ["org/team/**", "re:-api$"]
+exclude: [legacy-*]
+topics: [backend]
+active_since: 90d
+inactive_since: 2026-02-01
+archived: only
+exclude_groups: [backend/legacy]
+`)
+	t.Setenv("MIGRAPTOR_TOPICS", "backend,!deprecated")
+	cmd.Flags().Set(ARCHIVED, "exclude")
+
+	cfg, err := LoadConfig(cmd)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if !slices.Equal(cfg.Include, []string{"org/team/**", "re:-api$"}) || !slices.Equal(cfg.Exclude, []string{"legacy-*"}) {
+		t.Errorf("Expected patterns of the config file, got %v and %v", cfg.Include, cfg.Exclude)
+	}
+	if !slices.Equal(cfg.Topics, []string{"backend", "!deprecated"}) || cfg.Source(TOPICS) != "env MIGRAPTOR_TOPICS" {
+		t.Errorf("Expected topics of the env var, got %v from %s", cfg.Topics, cfg.Source(TOPICS))
+	}
+	if cfg.ActiveSince != "90d" || cfg.Source(ACTIVE_SINCE) != "file active_since" {
+		t.Errorf("Expected active_since of the config file, got %s from %s", cfg.ActiveSince, cfg.Source(ACTIVE_SINCE))
+	}
+	// Unquoted dates are read as timestamps by YAML
+	if cfg.InactiveSince != "2026-02-01" {
+		t.Errorf("Expected inactive_since to be kept as a date, got %s", cfg.InactiveSince)
+	}
+	if cfg.Archived != "exclude" {
+		t.Errorf("Expected the archived flag to override the config file, got %s", cfg.Archived)
+	}
+	if !slices.Equal(cfg.ExcludeGroups, []string{"backend/legacy"}) {
+		t.Errorf("Expected excluded sub-groups of the config file, got %v", cfg.ExcludeGroups)
+	}
+	if !cfg.SelectsProjects() {
+		t.Error("Expected project rules to restrict the projects to migrate")
+	}
+}
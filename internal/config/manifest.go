@@ -0,0 +1,91 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest lists several migrations run by a single invocation
+type Manifest struct {
+	// ContinueOnError runs the next migrations when one fails, except those depending on the failed one
+	ContinueOnError bool                `yaml:"continue_on_error"`
+	Migrations      []ManifestMigration `yaml:"migrations"`
+}
+
+// ManifestMigration is a source and destination pair of a manifest, with its own filters.
+// Unset values fall back to the configuration.
+type ManifestMigration struct {
+	OldGroupName string   `yaml:"old_group_name"`
+	NewGroupName string   `yaml:"new_group_name"`
+	ProjectsList []string `yaml:"projects_list"`
+	TagsList     []string `yaml:"tags_list"`
+	KeepParent   *bool    `yaml:"keep_parent"`
+}
+
+// LoadManifest reads and validates a migration manifest. Unknown keys are rejected to catch typos.
+func LoadManifest(path string) (*Manifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	defer f.Close()
+
+	var manifest Manifest
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&manifest); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+	if err := manifest.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+	return &manifest, nil
+}
+
+// Validate checks that the manifest lists migrations with a source and a distinct destination
+func (m *Manifest) Validate() error {
+	if len(m.Migrations) == 0 {
+		return errors.New("no migration listed")
+	}
+	for i, migration := range m.Migrations {
+		source := strings.Trim(migration.OldGroupName, "/")
+		destination := strings.Trim(migration.NewGroupName, "/")
+		switch {
+		case source == "":
+			return fmt.Errorf("migration %d: old group name is required", i+1)
+		case destination == "":
+			return fmt.Errorf("migration %d: new group name is required", i+1)
+		case source == destination:
+			return fmt.Errorf("migration %d: %s is both the old and the new group", i+1, source)
+		}
+	}
+	return nil
+}
+
+// Configs returns the configuration of each migration of the manifest, in manifest order,
+// based on cfg for everything the manifest does not set
+func (m *Manifest) Configs(cfg *Config) []*Config {
+	configs := make([]*Config, len(m.Migrations))
+	for i, migration := range m.Migrations {
+		c := *cfg
+		c.Manifest = ""
+		c.OldGroupName = strings.Trim(migration.OldGroupName, "/")
+		c.NewGroupName = strings.Trim(migration.NewGroupName, "/")
+		if migration.ProjectsList != nil {
+			c.ProjectsList = migration.ProjectsList
+		}
+		if migration.TagsList != nil {
+			c.TagsList = migration.TagsList
+		}
+		if migration.KeepParent != nil {
+			c.KeepParent = *migration.KeepParent
+		}
+		configs[i] = &c
+	}
+	return configs
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func writeManifest(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "manifest.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+	return path
+}
+
+func TestLoadManifest(t *testing.T) {
+	path := writeManifest(t, `
+continue_on_error: true
+migrations:
+  - old_group_name: org/team-a
+    new_group_name: /platform/
+    projects_list: [app, api]
+    keep_parent: false
+  - old_group_name: org/team-b
+    new_group_name: platform
+    tags_list: []
+`)
+	manifest, err := LoadManifest(path)
+	if err != nil {
+		t.Fatalf("LoadManifest failed: %v", err)
+	}
+	if !manifest.ContinueOnError || len(manifest.Migrations) != 2 {
+		t.Fatalf("Unexpected manifest %+v", manifest)
+	}
+
+	base := &Config{GitLabToken: "token", KeepParent: true, ProjectsList: []string{"docs"}, TagsList: []string{"latest"}, Manifest: path}
+	configs := manifest.Configs(base)
+	if configs[0].OldGroupName != "org/team-a" || configs[0].NewGroupName != "platform" || configs[0].Manifest != "" {
+		t.Errorf("Expected trimmed groups without manifest, got %+v", configs[0])
+	}
+	if !slices.Equal(configs[0].ProjectsList, []string{"app", "api"}) || configs[0].KeepParent {
+		t.Errorf("Expected migration settings to override the configuration, got %+v", configs[0])
+	}
+	if !slices.Equal(configs[0].TagsList, []string{"latest"}) || configs[0].GitLabToken != "token" {
+		t.Errorf("Expected unset settings to come from the configuration, got %+v", configs[0])
+	}
+	if !slices.Equal(configs[1].ProjectsList, []string{"docs"}) || len(configs[1].TagsList) != 0 || !configs[1].KeepParent {
+		t.Errorf("Expected an empty tag list to clear the filter, got %+v", configs[1])
+	}
+	if base.OldGroupName != "" {
+		t.Error("Expected the base configuration to be left unchanged")
+	}
+}
+
+func TestLoadManifest_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"empty":          ``,
+		"unknown key":    "migrations:\n  - old_group: a\n    new_group_name: b\n",
+		"no destination": "migrations:\n  - old_group_name: a\n",
+		"same groups":    "migrations:\n  - old_group_name: a\n    new_group_name: a/\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadManifest(writeManifest(t, content))
+			if err == nil || !strings.Contains(err.Error(), "invalid manifest") {
+				t.Errorf("Expected an invalid manifest error, got %v", err)
+			}
+		})
+	}
+
+	if _, err := LoadManifest(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Expected a missing manifest to fail")
+	}
+}
+
+func TestValidate_Manifest(t *testing.T) {
+	cfg := &Config{GitLabToken: "token", Manifest: "manifest.yaml"}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected groups to be optional with a manifest, got %v", err)
+	}
+	cfg.GitLabToken = ""
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected the token to be required with a manifest")
+	}
+}
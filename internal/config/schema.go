@@ -0,0 +1,461 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Severity tells whether an issue of the configuration prevents running
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Issue is a problem found in the configuration, located in the config file when it comes from it
+type Issue struct {
+	Severity Severity
+	// File and Line locate the issue in the config file, empty for issues of the effective configuration
+	File    string
+	Line    int
+	Message string
+}
+
+func (i Issue) String() string {
+	if i.File == "" {
+		return i.Message
+	}
+	return fmt.Sprintf("%s:%d: %s", i.File, i.Line, i.Message)
+}
+
+// ValidationError lists the errors found in the config file
+type ValidationError struct {
+	Issues []Issue
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		lines[i] = issue.String()
+	}
+	return "invalid configuration:\n  " + strings.Join(lines, "\n  ")
+}
+
+// fieldKind is the type expected for a key of the config file
+type fieldKind int
+
+const (
+	kindString fieldKind = iota
+	kindBool
+	kindInt
+	// kindList is a list of strings, or a comma-separated string
+	kindList
+	kindObject
+	kindObjectList
+	// kindProfiles maps profile names to settings
+	kindProfiles
+)
+
+// field describes a key of the config file
+type field struct {
+	kind fieldKind
+	// values are the accepted values of a string, all values if empty
+	values []string
+	// fields are the keys of an object, or of the objects of a list
+	fields map[string]field
+}
+
+var accessLevels = []string{"no_one", "developer", "maintainer", "owner", "admin"}
+
+// groupTemplateFields are the keys of group_template, see GroupTemplate
+var groupTemplateFields = map[string]field{
+	"visibility":             {kind: kindString, values: []string{"private", "internal", "public"}},
+	"description":            {kind: kindString},
+	"avatar":                 {kind: kindString},
+	"project_creation_level": {kind: kindString, values: []string{"noone", "owner", "maintainer", "developer"}},
+	"shared_runners_setting": {kind: kindString, values: []string{"enabled", "disabled_and_overridable", "disabled_and_unoverridable"}},
+	"default_branch_protection": {kind: kindObject, fields: map[string]field{
+		"allowed_to_push":            {kind: kindList, values: accessLevels},
+		"allowed_to_merge":           {kind: kindList, values: accessLevels},
+		"allow_force_push":           {kind: kindBool},
+		"developer_can_initial_push": {kind: kindBool},
+	}},
+	"labels": {kind: kindObjectList, fields: map[string]field{
+		"name":        {kind: kindString},
+		"color":       {kind: kindString},
+		"description": {kind: kindString},
+	}},
+}
+
+// settingFields are the keys of the settings of the config file and of its profiles, by their snake_case name.
+// Their kebab-case name (aliasMap) is accepted as well.
+var settingFields = map[string]field{
+	"gitlab_token":         {kind: kindString},
+	"gitlab_instance":      {kind: kindString},
+	"gitlab_registry":      {kind: kindString},
+	"docker_token":         {kind: kindString},
+	"docker_user":          {kind: kindString},
+	"container_engine":     {kind: kindString, values: []string{"docker", "podman", "containerd"}}, // container.Engines
+	"old_group_name":       {kind: kindString},
+	"new_group_name":       {kind: kindString},
+	"parent_group_id":      {kind: kindInt},
+	"projects_list":        {kind: kindList},
+	"tags_list":            {kind: kindList},
+	"keep_parent":          {kind: kindBool},
+	"dry_run":              {kind: kindBool},
+	"verbose":              {kind: kindBool},
+	"backup_images":        {kind: kindBool},
+	"group_template":       {kind: kindObject, fields: groupTemplateFields},
+	"migrate_members":      {kind: kindBool},
+	"remove_local_images":  {kind: kindBool},
+	"migrate_packages":     {kind: kindBool},
+	"manifest":             {kind: kindString},
+	"token_file":           {kind: kindString},
+	"token_command":        {kind: kindString},
+	"docker_token_file":    {kind: kindString},
+	"docker_token_command": {kind: kindString},
+	"keyring":              {kind: kindBool},
+	"include":              {kind: kindList},
+	"exclude":              {kind: kindList},
+	"topics":               {kind: kindList},
+	"active_since":         {kind: kindString},
+	"inactive_since":       {kind: kindString},
+	"archived":             {kind: kindString, values: []string{"include", "exclude", "only"}}, // migration.Archived*
+	"exclude_groups":       {kind: kindList},
+}
+
+// fileFields are the keys of the top level of the config file
+var fileFields = func() map[string]field {
+	fields := map[string]field{
+		"profile":  {kind: kindString},
+		"profiles": {kind: kindProfiles},
+	}
+	for name, f := range settingFields {
+		fields[name] = f
+	}
+	return fields
+}()
+
+// secretSources are the keys giving the same secret, the first one set wins
+var secretSources = [][]string{
+	{"gitlab_token", "token_file", "token_command"},
+	{"docker_token", "docker_token_file", "docker_token_command"},
+}
+
+// ValidateFile checks the keys and the types of the values of a YAML or JSON config file.
+// It returns an error only if the file cannot be read or parsed.
+func ValidateFile(file string) ([]Issue, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", file, err)
+	}
+	if len(document.Content) == 0 {
+		return nil, nil
+	}
+
+	v := &validator{file: file}
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		v.errorf(root, "expected keys and values, got %s", describe(root))
+		return v.issues, nil
+	}
+	v.checkMapping(root, "", fileFields)
+	return v.issues, nil
+}
+
+// validator collects the issues of a config file
+type validator struct {
+	file   string
+	issues []Issue
+}
+
+func (v *validator) errorf(node *yaml.Node, format string, args ...interface{}) {
+	v.issues = append(v.issues, Issue{Severity: SeverityError, File: v.file, Line: node.Line, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) warnf(node *yaml.Node, format string, args ...interface{}) {
+	v.issues = append(v.issues, Issue{Severity: SeverityWarning, File: v.file, Line: node.Line, Message: fmt.Sprintf(format, args...)})
+}
+
+// checkMapping checks the keys of a mapping node and their values. prefix is the path of the mapping.
+func (v *validator) checkMapping(node *yaml.Node, prefix string, fields map[string]field) {
+	set := map[string]*yaml.Node{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], node.Content[i+1]
+		name := canonicalKey(keyNode.Value, fields)
+		f, known := fields[name]
+		if !known {
+			message := fmt.Sprintf("unknown key %q", prefix+keyNode.Value)
+			if suggestion := suggest(keyNode.Value, slices.Collect(maps.Keys(fields))); suggestion != "" {
+				message += fmt.Sprintf(", did you mean %q?", prefix+suggestion)
+			}
+			v.errorf(keyNode, "%s", message)
+			continue
+		}
+		if !isEmpty(valueNode) {
+			set[name] = keyNode
+		}
+		v.checkValue(valueNode, prefix+keyNode.Value, f)
+	}
+
+	for _, sources := range secretSources {
+		var given []string
+		for _, source := range sources {
+			if _, ok := set[source]; ok {
+				given = append(given, source)
+			}
+		}
+		if len(given) > 1 {
+			v.warnf(set[given[1]], "%s is ignored, %s is used", strings.Join(prefixAll(prefix, given[1:]), " and "), prefix+given[0])
+		}
+	}
+}
+
+// checkValue checks the type of a value. Null values are unset and always accepted.
+func (v *validator) checkValue(node *yaml.Node, key string, f field) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return
+	}
+
+	switch f.kind {
+	case kindString:
+		if node.Kind != yaml.ScalarNode {
+			v.errorf(node, "%s: expected a string, got %s", key, describe(node))
+			return
+		}
+		v.checkAllowed(node, key, f.values)
+	case kindBool:
+		if node.Tag != "!!bool" {
+			v.errorf(node, "%s: expected true or false, got %s", key, describe(node))
+		}
+	case kindInt:
+		if node.Tag != "!!int" {
+			v.errorf(node, "%s: expected a number, got %s", key, describe(node))
+		}
+	case kindList:
+		switch node.Kind {
+		case yaml.ScalarNode:
+			for _, value := range strings.Split(node.Value, ",") {
+				v.checkAllowed(&yaml.Node{Value: strings.TrimSpace(value), Line: node.Line}, key, f.values)
+			}
+		case yaml.SequenceNode:
+			for _, item := range node.Content {
+				if item.Kind != yaml.ScalarNode {
+					v.errorf(item, "%s: expected a list of strings, got %s in the list", key, describe(item))
+					continue
+				}
+				v.checkAllowed(item, key, f.values)
+			}
+		default:
+			v.errorf(node, "%s: expected a list of strings, got %s", key, describe(node))
+		}
+	case kindObject:
+		if node.Kind != yaml.MappingNode {
+			v.errorf(node, "%s: expected keys and values, got %s", key, describe(node))
+			return
+		}
+		v.checkMapping(node, key+".", f.fields)
+	case kindObjectList:
+		if node.Kind != yaml.SequenceNode {
+			v.errorf(node, "%s: expected a list, got %s", key, describe(node))
+			return
+		}
+		for i, item := range node.Content {
+			itemKey := fmt.Sprintf("%s[%d]", key, i)
+			if item.Kind != yaml.MappingNode {
+				v.errorf(item, "%s: expected keys and values, got %s", itemKey, describe(item))
+				continue
+			}
+			v.checkMapping(item, itemKey+".", f.fields)
+		}
+	case kindProfiles:
+		if node.Kind != yaml.MappingNode {
+			v.errorf(node, "%s: expected profiles by name, got %s", key, describe(node))
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			profileKey := key + "." + node.Content[i].Value
+			if profile := node.Content[i+1]; profile.Kind == yaml.MappingNode {
+				v.checkMapping(profile, profileKey+".", settingFields)
+			} else if profile.Tag != "!!null" {
+				v.errorf(profile, "%s: expected the settings of the profile, got %s", profileKey, describe(profile))
+			}
+		}
+	}
+}
+
+// checkAllowed reports a value which is not one of values, when values are restricted
+func (v *validator) checkAllowed(node *yaml.Node, key string, values []string) {
+	if len(values) == 0 || slices.Contains(values, node.Value) {
+		return
+	}
+	message := fmt.Sprintf("%s: %q is not one of %s", key, node.Value, strings.Join(values, ", "))
+	if suggestion := suggest(node.Value, values); suggestion != "" {
+		message += fmt.Sprintf(", did you mean %q?", suggestion)
+	}
+	v.errorf(node, "%s", message)
+}
+
+// canonicalKey returns the snake_case name of a key given by its kebab-case name
+func canonicalKey(key string, fields map[string]field) string {
+	if _, known := fields[key]; known {
+		return key
+	}
+	for alias, actualKey := range aliasMap {
+		if actualKey == key {
+			return alias
+		}
+	}
+	return key
+}
+
+// isEmpty returns true for a null or empty string value, which leaves its key unset
+func isEmpty(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && (node.Tag == "!!null" || node.Value == "")
+}
+
+// describe names the type of a node for error messages
+func describe(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		return "a list"
+	case yaml.MappingNode:
+		return "keys and values"
+	}
+	switch node.Tag {
+	case "!!bool":
+		return "a boolean"
+	case "!!int", "!!float":
+		return fmt.Sprintf("the number %s", node.Value)
+	}
+	return fmt.Sprintf("%q", node.Value)
+}
+
+// suggest returns the candidate closest to value, empty if none is close enough to be a typo
+func suggest(value string, candidates []string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(value, "-", "_"))
+	best, bestDistance := "", 0
+	for _, candidate := range candidates {
+		distance := levenshtein(normalized, candidate)
+		if best == "" || distance < bestDistance || (distance == bestDistance && candidate < best) {
+			best, bestDistance = candidate, distance
+		}
+	}
+	if best == "" || (bestDistance > 2 && bestDistance*3 > len(value)) {
+		return ""
+	}
+	return best
+}
+
+// levenshtein returns the number of single character edits to change a into b
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// Conflicts returns the issues of options which contradict each other or imply a behaviour which may not be expected,
+// with the warnings found in the config file
+func (c *Config) Conflicts() []Issue {
+	issues := slices.Clone(c.fileWarnings)
+	add := func(severity Severity, format string, args ...interface{}) {
+		issues = append(issues, Issue{Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+
+	source, destination := strings.Trim(c.OldGroupName, "/"), strings.Trim(c.NewGroupName, "/")
+	if c.Manifest != "" {
+		if source != "" || destination != "" {
+			add(SeverityWarning, "old_group_name and new_group_name are ignored, the migrations of the manifest %s are run", c.Manifest)
+		}
+	} else if source != "" && destination != "" {
+		switch {
+		case source == destination:
+			add(SeverityError, "old_group_name and new_group_name are both %s", source)
+		case strings.HasPrefix(destination, source+"/"):
+			add(SeverityError, "new_group_name %s is inside old_group_name %s, a group cannot be moved into itself", destination, source)
+		}
+	}
+
+	if c.KeepParent && c.SelectsProjects() {
+		add(SeverityWarning, "keep_parent with a projects_list or project rules: %s cannot be transferred as a whole, a new group %s is created with its settings and the selected projects are transferred into it one by one",
+			orPlaceholder(source, "the old group"), path.Join(orPlaceholder(destination, "<new group>"), path.Base(orPlaceholder(source, "<old group>"))))
+	}
+	if c.MigrateMembers && c.KeepParent && !c.SelectsProjects() && c.Manifest == "" {
+		add(SeverityWarning, "migrate_members has no effect with keep_parent and no projects_list nor project rules: the group is transferred with its members")
+	}
+	if c.DockerUser != "" && (c.DockerToken == "" || c.DockerToken == c.GitLabToken) {
+		add(SeverityWarning, "docker_user %s is set without docker_token: the GitLab token is used as registry password", c.DockerUser)
+	}
+	return issues
+}
+
+// SelectsProjects returns true when projects_list or a project rule restricts the projects to migrate
+func (c *Config) SelectsProjects() bool {
+	return len(c.ProjectsList) > 0 || len(c.Include) > 0 || len(c.Exclude) > 0 || len(c.Topics) > 0 ||
+		c.ActiveSince != "" || c.InactiveSince != "" || (c.Archived != "" && c.Archived != "include") || len(c.ExcludeGroups) > 0
+}
+
+// splitIssues returns the errors and the warnings of issues
+func splitIssues(issues []Issue) (errs, warnings []Issue) {
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			errs = append(errs, issue)
+		} else {
+			warnings = append(warnings, issue)
+		}
+	}
+	return errs, warnings
+}
+
+// validateConfigFile validates the config file read by viper, if it can be parsed as YAML
+func validateConfigFile(file string) ([]Issue, error) {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml", ".json":
+		return ValidateFile(file)
+	}
+	return nil, nil
+}
+
+func prefixAll(prefix string, keys []string) []string {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = prefix + key
+	}
+	return prefixed
+}
+
+func orPlaceholder(value, placeholder string) string {
+	if value == "" {
+		return placeholder
+	}
+	return value
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "gitlab-migraptor.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+	return path
+}
+
+func TestValidateFile(t *testing.T) {
+	path := writeConfig(t, `gitlab_tokn: abc
+old-group: org/team
+dry_run: "yes"
+parent_group_id: [1]
+container_engine: dokcer
+projects_list: app, api
+archived: no
+group_template:
+  visibilty: private
+  default_branch_protection:
+    allowed_to_push: [maintainers]
+profiles:
+  prod:
+    gitlab_instance: [gitlab.example.com]
+    keyrng: true
+  empty:
+`)
+	issues, err := ValidateFile(path)
+	if err != nil {
+		t.Fatalf("ValidateFile failed: %v", err)
+	}
+
+	expected := []string{
+		`:1: unknown key "gitlab_tokn", did you mean "gitlab_token"?`,
+		`:3: dry_run: expected true or false, got "yes"`,
+		`:4: parent_group_id: expected a number, got a list`,
+		`:5: container_engine: "dokcer" is not one of docker, podman, containerd, did you mean "docker"?`,
+		`:7: archived: "no" is not one of include, exclude, only`,
+		`:9: unknown key "group_template.visibilty", did you mean "group_template.visibility"?`,
+		`:11: group_template.default_branch_protection.allowed_to_push: "maintainers" is not one of no_one, developer, maintainer, owner, admin, did you mean "maintainer"?`,
+		`:14: profiles.prod.gitlab_instance: expected a string, got a list`,
+		`:15: unknown key "profiles.prod.keyrng", did you mean "profiles.prod.keyring"?`,
+	}
+	if len(issues) != len(expected) {
+		t.Fatalf("Expected %d issues, got %v", len(expected), issues)
+	}
+	for i, issue := range issues {
+		if issue.Severity != SeverityError || !strings.HasSuffix(issue.String(), expected[i]) || issue.File != path {
+			t.Errorf("Expected issue %q, got %q", expected[i], issue.String())
+		}
+	}
+}
+
+func TestValidateFile_Valid(t *testing.T) {
+	sample, err := os.ReadFile(filepath.Join("..", "..", "gitlab-migraptor-sample.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to read sample config: %v", err)
+	}
+	configs := map[string]string{
+		"sample": string(sample),
+		"complete": `
+token: abc
+gitlab_instance: gitlab.example.com
+parent_group_id: 42
+projects_list: [app, api]
+tags_list: latest, stable
+include: ["org/team/**"]
+exclude: [legacy-*]
+topics: [backend, "!deprecated"]
+active_since: 2026-01-01
+archived: exclude
+exclude_groups: [backend/legacy]
+keep-parent: false
+keyring: true
+group_template:
+  visibility: internal
+  default_branch_protection:
+    allowed_to_push: [maintainer]
+    allow_force_push: false
+  labels:
+    - name: migrated
+      color: "#428BCA"
+profile: prod
+profiles:
+  prod:
+    token_command: pass show gitlab
+    dry_run: false
+  staging:
+`,
+	}
+	for name, content := range configs {
+		issues, err := ValidateFile(writeConfig(t, content))
+		if err != nil || len(issues) != 0 {
+			t.Errorf("Expected the %s config to be valid, got %v (%v)", name, issues, err)
+		}
+	}
+}
+
+func TestValidateFile_SecretSources(t *testing.T) {
+	issues, err := ValidateFile(writeConfig(t, `gitlab_token: ""
+token_file: ~/token
+docker_token: abc
+docker_token_command: pass show registry
+`))
+	if err != nil {
+		t.Fatalf("ValidateFile failed: %v", err)
+	}
+	if len(issues) != 1 || issues[0].Severity != SeverityWarning || issues[0].Line != 4 ||
+		issues[0].Message != "docker_token_command is ignored, docker_token is used" {
+		t.Errorf("Expected a warning for the ignored registry password command only, got %v", issues)
+	}
+}
+
+func TestLoadConfig_InvalidFile(t *testing.T) {
+	resetViper()
+	cmd := setupTestCommand()
+	writeConfigFile(t, "gitlab_token: abc\nkeep_parent: maybe\n")
+
+	_, err := LoadConfig(cmd)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Issues) != 1 || validationErr.Issues[0].Line != 2 {
+		t.Errorf("Expected a validation error on line 2, got %v", err)
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      Config
+		severity Severity
+		message  string
+	}{
+		{"same groups", Config{OldGroupName: "org/team", NewGroupName: "/org/team/"}, SeverityError, "both org/team"},
+		{"moved into itself", Config{OldGroupName: "org", NewGroupName: "org/team"}, SeverityError, "cannot be moved into itself"},
+		{"keep parent with projects", Config{OldGroupName: "org/team", NewGroupName: "platform", KeepParent: true, ProjectsList: []string{"app"}}, SeverityWarning, "a new group platform/team is created"},
+		{"members with group transfer", Config{OldGroupName: "org/team", NewGroupName: "platform", KeepParent: true, MigrateMembers: true}, SeverityWarning, "migrate_members has no effect"},
+		{"groups with manifest", Config{OldGroupName: "org/team", Manifest: "manifest.yaml"}, SeverityWarning, "are ignored"},
+		{"registry user without password", Config{GitLabToken: "abc", DockerToken: "abc", DockerUser: "deployer"}, SeverityWarning, "GitLab token is used as registry password"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := tt.cfg.Conflicts()
+			if len(issues) != 1 || issues[0].Severity != tt.severity || !strings.Contains(issues[0].Message, tt.message) {
+				t.Errorf("Expected a %s containing %q, got %v", tt.severity, tt.message, issues)
+			}
+		})
+	}
+
+	if issues := (&Config{OldGroupName: "org/team", NewGroupName: "platform", KeepParent: true}).Conflicts(); len(issues) != 0 {
+		t.Errorf("Expected no conflict, got %v", issues)
+	}
+	cfg := &Config{GitLabToken: "abc", OldGroupName: "org", NewGroupName: "org/team"}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "into itself") {
+		t.Errorf("Expected Validate to report the conflict, got %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// KeyringService is the service of the keyring entries holding the tokens.
+// The account of an entry is the host of the GitLab instance for the GitLab token,
+// and the host of the registry for the registry password.
+const KeyringService = "migraptor"
+
+// lookupKeyring returns the secret of a keyring entry, empty if there is none. It is replaced in tests.
+var lookupKeyring = keyringLookup
+
+// resolveSecrets sets the tokens not given as values from, in order, their file, their command and the keyring
+func (c *Config) resolveSecrets(ctx context.Context) error {
+	token, source, err := readSecret(ctx, "GitLab token", c.GitLabToken, c.GitLabTokenFile, c.GitLabTokenCommand)
+	if err != nil {
+		return err
+	}
+	if token == "" && c.Keyring {
+		if token, err = lookupKeyring(ctx, KeyringService, hostOf(c.GitLabInstance)); err != nil {
+			return fmt.Errorf("failed to read GitLab token from keyring: %w", err)
+		}
+		source = "keyring"
+	}
+	c.GitLabToken = token
+	c.setSource(GITLAB_TOKEN, token, "token_", source)
+
+	password, source, err := readSecret(ctx, "registry password", c.DockerToken, c.DockerTokenFile, c.DockerTokenCommand)
+	if err != nil {
+		return err
+	}
+	if password == "" && c.Keyring {
+		if password, err = lookupKeyring(ctx, KeyringService, hostOf(c.GitLabRegistry)); err != nil {
+			return fmt.Errorf("failed to read registry password from keyring: %w", err)
+		}
+		source = "keyring"
+	}
+	c.DockerToken = password
+	c.setSource(DOCKER_PASSWORD, password, "docker_token_", source)
+	return nil
+}
+
+// setSource records the source of a secret read from its file, its command or the keyring.
+// prefix is the prefix of the keys of the file and the command of the secret.
+func (c *Config) setSource(key, secret, prefix, source string) {
+	if secret == "" || source == "" {
+		return
+	}
+	if c.sources == nil {
+		c.sources = map[string]string{}
+	}
+	if source != "keyring" {
+		source = prefix + source
+	}
+	c.sources[key] = source
+}
+
+// readSecret returns value if set, else the content of file, else the first line printed by command,
+// with the source used: empty for the value, "file" or "command"
+func readSecret(ctx context.Context, name, value, file, command string) (string, string, error) {
+	switch {
+	case value != "":
+		return value, "", nil
+	case file != "":
+		data, err := os.ReadFile(expandPath(file))
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read %s file: %w", name, err)
+		}
+		secret := strings.TrimSpace(string(data))
+		if secret == "" {
+			return "", "", fmt.Errorf("%s file %s is empty", name, file)
+		}
+		return secret, "file", nil
+	case command != "":
+		output, err := runSecretCommand(ctx, command)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to get %s from command: %w", name, err)
+		}
+		secret, _, _ := strings.Cut(strings.TrimSpace(output), "\n")
+		secret = strings.TrimSpace(secret)
+		if secret == "" {
+			return "", "", fmt.Errorf("%s command printed nothing", name)
+		}
+		return secret, "command", nil
+	}
+	return "", "", nil
+}
+
+// runSecretCommand runs command with the shell and returns its output.
+// The command shares the terminal, so that it can ask for a passphrase.
+func runSecretCommand(ctx context.Context, command string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	var stdout bytes.Buffer
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+// keyringLookup reads a generic password of the macOS keychain, or a secret of the Secret Service
+// (GNOME Keyring, KWallet) elsewhere, with the command line tool of the OS
+func keyringLookup(ctx context.Context, service, account string) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.CommandContext(ctx, "security", "find-generic-password", "-s", service, "-a", account, "-w")
+	case "windows":
+		return "", errors.New("keyring is not supported on Windows, use a token file or command instead")
+	default:
+		cmd = exec.CommandContext(ctx, "secret-tool", "lookup", "service", service, "account", account)
+	}
+	output, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// Both tools fail when there is no such entry
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// expandPath expands the environment variables of path and a leading ~ to the home directory
+func expandPath(path string) string {
+	path = os.ExpandEnv(path)
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
+
+// hostOf strips the scheme and the trailing slash of an address
+func hostOf(address string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(address, "https://"), "http://"), "/")
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestResolveSecrets_File(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("  file-token\n"), 0600); err != nil {
+		t.Fatalf("Failed to write token file: %v", err)
+	}
+	t.Setenv("TOKEN_DIR", filepath.Dir(tokenFile))
+
+	cfg := &Config{GitLabTokenFile: "$TOKEN_DIR/token", DockerTokenFile: tokenFile}
+	if err := cfg.resolveSecrets(t.Context()); err != nil {
+		t.Fatalf("resolveSecrets failed: %v", err)
+	}
+	if cfg.GitLabToken != "file-token" || cfg.DockerToken != "file-token" {
+		t.Errorf("Expected tokens from file, got '%s' and '%s'", cfg.GitLabToken, cfg.DockerToken)
+	}
+
+	// A value given directly wins over the file
+	cfg = &Config{GitLabToken: "value-token", GitLabTokenFile: filepath.Join(t.TempDir(), "missing")}
+	if err := cfg.resolveSecrets(t.Context()); err != nil || cfg.GitLabToken != "value-token" {
+		t.Errorf("Expected the token value to be kept, got '%s' (%v)", cfg.GitLabToken, err)
+	}
+
+	cfg = &Config{GitLabTokenFile: filepath.Join(t.TempDir(), "missing")}
+	if err := cfg.resolveSecrets(t.Context()); err == nil {
+		t.Error("Expected a missing token file to fail")
+	}
+}
+
+func TestResolveSecrets_Command(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("commands are run with sh")
+	}
+	cfg := &Config{GitLabTokenCommand: "printf 'command-token\\nlogin: me\\n'"}
+	if err := cfg.resolveSecrets(t.Context()); err != nil {
+		t.Fatalf("resolveSecrets failed: %v", err)
+	}
+	if cfg.GitLabToken != "command-token" {
+		t.Errorf("Expected the first line printed by the command, got '%s'", cfg.GitLabToken)
+	}
+
+	for _, command := range []string{"true", "exit 1"} {
+		cfg := &Config{DockerTokenCommand: command}
+		err := cfg.resolveSecrets(t.Context())
+		if err == nil || !strings.Contains(err.Error(), "registry password") {
+			t.Errorf("Expected command %q to fail, got %v", command, err)
+		}
+	}
+}
+
+func TestResolveSecrets_Keyring(t *testing.T) {
+	lookups := map[string]string{}
+	original := lookupKeyring
+	lookupKeyring = func(ctx context.Context, service, account string) (string, error) {
+		lookups[account] = service
+		if account == "gitlab.example.com" {
+			return "keyring-token", nil
+		}
+		return "", nil
+	}
+	defer func() { lookupKeyring = original }()
+
+	cfg := &Config{GitLabInstance: "https://gitlab.example.com/", GitLabRegistry: "registry.example.com", Keyring: true}
+	if err := cfg.resolveSecrets(t.Context()); err != nil {
+		t.Fatalf("resolveSecrets failed: %v", err)
+	}
+	if cfg.GitLabToken != "keyring-token" || cfg.DockerToken != "" {
+		t.Errorf("Expected the GitLab token from the keyring only, got '%s' and '%s'", cfg.GitLabToken, cfg.DockerToken)
+	}
+	if lookups["gitlab.example.com"] != KeyringService || lookups["registry.example.com"] != KeyringService {
+		t.Errorf("Expected lookups of the instance and registry hosts, got %v", lookups)
+	}
+
+	// The keyring is only read when enabled
+	lookups = map[string]string{}
+	cfg = &Config{GitLabInstance: "gitlab.example.com"}
+	if err := cfg.resolveSecrets(t.Context()); err != nil || len(lookups) != 0 {
+		t.Errorf("Expected no keyring lookup, got %v (%v)", lookups, err)
+	}
+}
+
+func TestLoadConfig_TokenFileFromEnv(t *testing.T) {
+	resetViper()
+	cmd := setupTestCommand()
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("file-token\n"), 0600); err != nil {
+		t.Fatalf("Failed to write token file: %v", err)
+	}
+	t.Setenv("GITLAB_TOKEN_FILE", tokenFile)
+
+	cfg, err := LoadConfig(cmd)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.GitLabToken != "file-token" || cfg.DockerToken != "file-token" {
+		t.Errorf("Expected tokens from the file, got '%s' and '%s'", cfg.GitLabToken, cfg.DockerToken)
+	}
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Setting is a key of the effective configuration, named as in the config file, with the source of its value
+type Setting struct {
+	Key   string
+	Value string
+	// Source tells where the value comes from: a flag, an env var, the config file, a profile, a default, ...
+	// It is empty for keys which are not set.
+	Source string
+	// Secret is true for the tokens, to mask when displayed
+	Secret bool
+}
+
+// Source returns where the value of a key comes from, empty if it is not set
+func (c *Config) Source(key string) string {
+	return c.sources[key]
+}
+
+// Settings returns the keys of the effective configuration, in the order of the sample config file
+func (c *Config) Settings() []Setting {
+	boolValue := strconv.FormatBool
+	listValue := func(values []string) string { return strings.Join(values, ",") }
+	settings := []Setting{
+		{Key: "gitlab_token", Value: c.GitLabToken, Source: c.Source(GITLAB_TOKEN), Secret: true},
+		{Key: "token_file", Value: c.GitLabTokenFile, Source: c.Source(TOKEN_FILE)},
+		{Key: "token_command", Value: c.GitLabTokenCommand, Source: c.Source(TOKEN_COMMAND)},
+		{Key: "gitlab_instance", Value: c.GitLabInstance, Source: c.Source(GITLAB_INSTANCE)},
+		{Key: "gitlab_registry", Value: c.GitLabRegistry, Source: c.Source(GITLAB_REGISTRY)},
+		{Key: "docker_user", Value: c.DockerUser, Source: c.Source(DOCKER_USER)},
+		{Key: "docker_token", Value: c.DockerToken, Source: c.Source(DOCKER_PASSWORD), Secret: true},
+		{Key: "docker_token_file", Value: c.DockerTokenFile, Source: c.Source(DOCKER_PASSWORD_FILE)},
+		{Key: "docker_token_command", Value: c.DockerTokenCommand, Source: c.Source(DOCKER_PASSWORD_COMMAND)},
+		{Key: "keyring", Value: boolValue(c.Keyring), Source: c.Source(KEYRING)},
+		{Key: "container_engine", Value: c.ContainerEngine, Source: c.Source(CONTAINER_ENGINE)},
+		{Key: "old_group_name", Value: c.OldGroupName, Source: c.Source(OLD_GROUP_NAME)},
+		{Key: "new_group_name", Value: c.NewGroupName, Source: c.Source(NEW_GROUP_NAME)},
+		{Key: "parent_group_id", Value: strconv.Itoa(c.ParentGroupID), Source: c.Source("parent-group-id")},
+		{Key: "projects_list", Value: listValue(c.ProjectsList), Source: c.Source(PROJECTS_LIST)},
+		{Key: "tags_list", Value: listValue(c.TagsList), Source: c.Source(TAGS_LIST)},
+		{Key: "include", Value: listValue(c.Include), Source: c.Source(INCLUDE)},
+		{Key: "exclude", Value: listValue(c.Exclude), Source: c.Source(EXCLUDE)},
+		{Key: "topics", Value: listValue(c.Topics), Source: c.Source(TOPICS)},
+		{Key: "active_since", Value: c.ActiveSince, Source: c.Source(ACTIVE_SINCE)},
+		{Key: "inactive_since", Value: c.InactiveSince, Source: c.Source(INACTIVE_SINCE)},
+		{Key: "archived", Value: c.Archived, Source: c.Source(ARCHIVED)},
+		{Key: "exclude_groups", Value: listValue(c.ExcludeGroups), Source: c.Source(EXCLUDE_GROUPS)},
+		{Key: "keep_parent", Value: boolValue(c.KeepParent), Source: c.Source(KEEP_PARENT)},
+		{Key: "dry_run", Value: boolValue(c.DryRun), Source: c.Source(DRY_RUN)},
+		{Key: "verbose", Value: boolValue(c.Verbose), Source: c.Source(VERBOSE)},
+		{Key: "backup_images", Value: boolValue(c.BackupImages), Source: c.Source(BACKUP_IMAGES)},
+		{Key: "migrate_members", Value: boolValue(c.MigrateMembers), Source: c.Source(MIGRATE_MEMBERS)},
+		{Key: "remove_local_images", Value: boolValue(c.RemoveLocalImages), Source: c.Source(REMOVE_LOCAL_IMAGES)},
+		{Key: "migrate_packages", Value: boolValue(c.MigratePackages), Source: c.Source(MIGRATE_PACKAGES)},
+		{Key: "group_template", Value: c.GroupTemplate.summary(), Source: c.Source(GROUP_TEMPLATE)},
+		{Key: "manifest", Value: c.Manifest, Source: c.Source(MANIFEST)},
+		{Key: "profile", Value: c.Profile, Source: c.Source(PROFILE)},
+	}
+	for i := range settings {
+		if settings[i].Source == "" {
+			settings[i].Value = ""
+		}
+	}
+	return settings
+}
+
+// summary lists the settings of a group template on a single line
+func (t *GroupTemplate) summary() string {
+	if t == nil {
+		return ""
+	}
+	var parts []string
+	add := func(key, value string) {
+		if value != "" {
+			parts = append(parts, key+"="+value)
+		}
+	}
+	add("visibility", t.Visibility)
+	add("description", t.Description)
+	add("avatar", t.Avatar)
+	add("project_creation_level", t.ProjectCreationLevel)
+	add("shared_runners_setting", t.SharedRunnersSetting)
+	if p := t.DefaultBranchProtection; p != nil {
+		add("allowed_to_push", strings.Join(p.AllowedToPush, ","))
+		add("allowed_to_merge", strings.Join(p.AllowedToMerge, ","))
+	}
+	for _, label := range t.Labels {
+		add("label", label.Name)
+	}
+	return strings.Join(parts, " ")
+}
+
+// FileSetting is a key written by RenderFile
+type FileSetting struct {
+	Key string
+	// Value is a string, a bool, an int or a list of strings
+	Value interface{}
+	// Comment is written above the key
+	Comment string
+}
+
+// RenderFile returns the content of a config file setting the given keys, in order, with their comments
+func RenderFile(header string, settings []FileSetting) ([]byte, error) {
+	root := &yaml.Node{Kind: yaml.MappingNode, HeadComment: header}
+	for _, setting := range settings {
+		if _, known := settingFields[setting.Key]; !known {
+			return nil, fmt.Errorf("unknown key %s", setting.Key)
+		}
+		var value yaml.Node
+		if err := value.Encode(setting.Value); err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", setting.Key, err)
+		}
+		key := &yaml.Node{Kind: yaml.ScalarNode, Value: setting.Key, HeadComment: setting.Comment}
+		root.Content = append(root.Content, key, &value)
+	}
+
+	var b strings.Builder
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(root); err != nil {
+		return nil, fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write config file: %w", err)
+	}
+	return []byte(b.String()), nil
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfig_Sources(t *testing.T) {
+	resetViper()
+	cmd := setupTestCommand()
+	writeConfigFile(t, `
+gitlab_token: file-token
+instance: gitlab.example.com
+old_group_name: org/team
+new_group_name: archive
+profiles:
+  prod:
+    new_group_name: platform
+`)
+	cmd.Flags().Set("profile", "prod")
+	cmd.Flags().Set("projects", "app,api")
+	t.Setenv("MIGRAPTOR_DRY_RUN", "true")
+	t.Setenv("TAGS_LIST", "latest")
+
+	cfg, err := LoadConfig(cmd)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"gitlab_token":     "file gitlab_token",
+		"gitlab_instance":  "file instance",
+		"gitlab_registry":  "default, from gitlab_instance",
+		"docker_token":     "default, gitlab_token",
+		"old_group_name":   "file old_group_name",
+		"new_group_name":   "profile prod",
+		"projects_list":    "flag --projects",
+		"tags_list":        "env TAGS_LIST",
+		"dry_run":          "env MIGRAPTOR_DRY_RUN",
+		"keep_parent":      "default",
+		"profile":          "flag --profile",
+		"token_file":       "",
+		"container_engine": "default",
+	}
+	for _, setting := range cfg.Settings() {
+		source, ok := expected[setting.Key]
+		if !ok {
+			continue
+		}
+		if setting.Source != source {
+			t.Errorf("Expected %s to come from %q, got %q", setting.Key, source, setting.Source)
+		}
+		if source == "" && setting.Value != "" {
+			t.Errorf("Expected %s to have no value, got %q", setting.Key, setting.Value)
+		}
+		delete(expected, setting.Key)
+	}
+	if len(expected) != 0 {
+		t.Errorf("Missing settings %v", expected)
+	}
+}
+
+func TestResolveSecrets_Sources(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("file-token\n"), 0600); err != nil {
+		t.Fatalf("Failed to write token file: %v", err)
+	}
+	cfg := &Config{GitLabTokenFile: tokenFile, DockerToken: "registry-password"}
+	if err := cfg.resolveSecrets(t.Context()); err != nil {
+		t.Fatalf("resolveSecrets failed: %v", err)
+	}
+	if cfg.Source(GITLAB_TOKEN) != "token_file" || cfg.Source(DOCKER_PASSWORD) != "" {
+		t.Errorf("Expected the token to come from token_file only, got %q and %q", cfg.Source(GITLAB_TOKEN), cfg.Source(DOCKER_PASSWORD))
+	}
+}
+
+func TestRenderFile(t *testing.T) {
+	content, err := RenderFile("Written by a test", []FileSetting{
+		{Key: "gitlab_instance", Value: "gitlab.example.com", Comment: "GitLab instance"},
+		{Key: "token_command", Value: "pass show gitlab/migraptor"},
+		{Key: "old_group_name", Value: "org/team"},
+		{Key: "projects_list", Value: []string{"app", "api"}},
+		{Key: "keep_parent", Value: false},
+	})
+	if err != nil {
+		t.Fatalf("RenderFile failed: %v", err)
+	}
+	path := writeConfig(t, string(content))
+	if issues, err := ValidateFile(path); err != nil || len(issues) != 0 {
+		t.Errorf("Expected a valid config file, got %v (%v)\n%s", issues, err, content)
+	}
+
+	resetViper()
+	cmd := setupTestCommand()
+	t.Chdir(filepath.Dir(path))
+	t.Setenv("GITLAB_TOKEN", "env-token")
+	cfg, err := LoadConfig(cmd)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.GitLabInstance != "gitlab.example.com" || cfg.OldGroupName != "org/team" || len(cfg.ProjectsList) != 2 || cfg.KeepParent {
+		t.Errorf("Expected the rendered values to be loaded, got %+v", cfg)
+	}
+
+	if _, err := RenderFile("", []FileSetting{{Key: "gitlab_tokn", Value: "abc"}}); err == nil {
+		t.Error("Expected an unknown key to be refused")
+	}
+}
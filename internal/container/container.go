@@ -0,0 +1,68 @@
+// Package container provides the container engines used to pull, tag and push images:
+// Docker, Podman and containerd.
+package container
+
+import (
+	"context"
+	"fmt"
+
+	"migraptor/internal/docker"
+
+	"github.com/docker/docker/api/types/registry"
+)
+
+const (
+	// EngineDocker uses the Docker Engine API, configured from DOCKER_HOST
+	EngineDocker = "docker"
+	// EnginePodman uses the Docker-compatible API of Podman
+	EnginePodman = "podman"
+	// EngineContainerd uses containerd through the nerdctl CLI
+	EngineContainerd = "containerd"
+)
+
+// Engines are the supported container engines
+var Engines = []string{EngineDocker, EnginePodman, EngineContainerd}
+
+// Engine is a container engine holding local images
+type Engine interface {
+	// Name returns the name of the engine, for messages
+	Name() string
+	// CheckRunning checks the engine can be reached
+	CheckRunning(ctx context.Context) error
+	// Login checks the credentials against the registry and keeps them for pull and push operations
+	Login(ctx context.Context, auth registry.AuthConfig) error
+	// SetProgress sets the reporter receiving the progress of pulls and pushes
+	SetProgress(progress docker.ProgressReporter)
+	PullImage(ctx context.Context, imageRef string) error
+	TagImage(ctx context.Context, sourceImage, targetImage string) error
+	PushImage(ctx context.Context, imageRef string) error
+	ImageExists(ctx context.Context, imageRef string) (bool, error)
+	RemoveImage(ctx context.Context, imageRef string) error
+	// LocalImageDigests returns the registry digests of local images with their size
+	LocalImageDigests(ctx context.Context) (map[string]int64, error)
+	// LocalLayers returns the uncompressed digests of the layers of local images
+	LocalLayers(ctx context.Context) (map[string]bool, error)
+	// AvailableSpace returns the free disk space where images are stored, and that directory
+	AvailableSpace(ctx context.Context) (int64, string, error)
+	Close() error
+}
+
+var (
+	_ Engine = (*docker.Client)(nil)
+	_ Engine = (*Podman)(nil)
+	_ Engine = (*Nerdctl)(nil)
+)
+
+// New creates the container engine of the given kind, Docker if empty
+func New(kind string) (Engine, error) {
+	switch kind {
+	case "", EngineDocker:
+		return docker.NewClient()
+	case EnginePodman:
+		return NewPodman()
+	case EngineContainerd:
+		return NewNerdctl()
+	default:
+		return nil, fmt.Errorf("unknown container engine %q, expected one of %v", kind, Engines)
+	}
+}
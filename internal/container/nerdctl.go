@@ -0,0 +1,235 @@
+package container
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"migraptor/internal/docker"
+
+	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/go-units"
+)
+
+// systemContainerdRoot is the data root of the rootful containerd daemon
+const systemContainerdRoot = "/var/lib/containerd"
+
+// Nerdctl drives containerd through the nerdctl CLI. The namespace is taken from CONTAINERD_NAMESPACE
+// and registry credentials from the Docker CLI configuration, as nerdctl does.
+type Nerdctl struct {
+	binary   string
+	progress docker.ProgressReporter
+}
+
+// nerdctlImage is a line of the JSON output of nerdctl images
+type nerdctlImage struct {
+	Repository string
+	Tag        string
+	Digest     string
+	Size       string
+}
+
+// NewNerdctl creates a containerd engine using the nerdctl binary found in PATH
+func NewNerdctl() (*Nerdctl, error) {
+	binary, err := exec.LookPath("nerdctl")
+	if err != nil {
+		return nil, fmt.Errorf("nerdctl is required to use containerd: %w", err)
+	}
+	return &Nerdctl{binary: binary}, nil
+}
+
+// Name returns the name of the engine
+func (n *Nerdctl) Name() string {
+	return "containerd"
+}
+
+// Close does nothing, nerdctl runs a process per operation
+func (n *Nerdctl) Close() error {
+	return nil
+}
+
+// run runs nerdctl with the given arguments and returns its standard output
+func (n *Nerdctl) run(ctx context.Context, stdin io.Reader, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, n.binary, args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("nerdctl %s: %s", args[0], message)
+		}
+		return nil, fmt.Errorf("nerdctl %s: %w", args[0], err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// SetProgress sets the reporter notified of pulls and pushes. nerdctl progress is not parsed,
+// the reporter receives no layer.
+func (n *Nerdctl) SetProgress(progress docker.ProgressReporter) {
+	n.progress = progress
+}
+
+// track notifies the progress reporter of the start of a pull or push, and returns the function notifying its end
+func (n *Nerdctl) track(action, imageRef string) func() {
+	if n.progress == nil {
+		return func() {}
+	}
+	n.progress.Start(action, imageRef)
+	return n.progress.Finish
+}
+
+// CheckRunning checks containerd can be reached
+func (n *Nerdctl) CheckRunning(ctx context.Context) error {
+	if _, err := n.run(ctx, nil, "version"); err != nil {
+		return fmt.Errorf("containerd is not running: %w", err)
+	}
+	return nil
+}
+
+// Login logs in to the registry, nerdctl then stores the credentials in the Docker CLI configuration,
+// in plain text unless a credential store is configured there. They are kept after the migration.
+// Identity tokens cannot be given to nerdctl, they are already stored in that configuration.
+func (n *Nerdctl) Login(ctx context.Context, auth registry.AuthConfig) error {
+	if auth.Password == "" {
+		return nil
+	}
+	_, err := n.run(ctx, strings.NewReader(auth.Password), "login", "--username", auth.Username, "--password-stdin", auth.ServerAddress)
+	if err != nil {
+		return fmt.Errorf("failed to login to registry %s: %w", auth.ServerAddress, err)
+	}
+	return nil
+}
+
+// PullImage pulls an image from the registry
+func (n *Nerdctl) PullImage(ctx context.Context, imageRef string) error {
+	defer n.track("Pulling", imageRef)()
+	if _, err := n.run(ctx, nil, "pull", "--quiet", imageRef); err != nil {
+		return fmt.Errorf("failed to pull image %s: %w", imageRef, err)
+	}
+	return nil
+}
+
+// TagImage tags an image with a new name
+func (n *Nerdctl) TagImage(ctx context.Context, sourceImage, targetImage string) error {
+	if _, err := n.run(ctx, nil, "tag", sourceImage, targetImage); err != nil {
+		return fmt.Errorf("failed to tag image %s as %s: %w", sourceImage, targetImage, err)
+	}
+	return nil
+}
+
+// PushImage pushes an image to the registry
+func (n *Nerdctl) PushImage(ctx context.Context, imageRef string) error {
+	defer n.track("Pushing", imageRef)()
+	if _, err := n.run(ctx, nil, "push", "--quiet", imageRef); err != nil {
+		return fmt.Errorf("failed to push image %s: %w", imageRef, err)
+	}
+	return nil
+}
+
+// ImageExists checks if an image exists locally. nerdctl images lists nothing, without failing,
+// when no local image matches the reference.
+func (n *Nerdctl) ImageExists(ctx context.Context, imageRef string) (bool, error) {
+	output, err := n.run(ctx, nil, "images", "--quiet", imageRef)
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect image %s: %w", imageRef, err)
+	}
+	return len(bytes.TrimSpace(output)) > 0, nil
+}
+
+// RemoveImage removes a local image
+func (n *Nerdctl) RemoveImage(ctx context.Context, imageRef string) error {
+	if _, err := n.run(ctx, nil, "rmi", imageRef); err != nil {
+		return fmt.Errorf("failed to remove image %s: %w", imageRef, err)
+	}
+	return nil
+}
+
+// LocalImageDigests returns the registry digests of local images with their size
+func (n *Nerdctl) LocalImageDigests(ctx context.Context) (map[string]int64, error) {
+	output, err := n.run(ctx, nil, "images", "--digests", "--format", "{{json .}}")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+
+	digests := make(map[string]int64)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		var img nerdctlImage
+		if err := json.Unmarshal(scanner.Bytes(), &img); err != nil {
+			return nil, fmt.Errorf("invalid output of nerdctl images: %w", err)
+		}
+		if img.Digest == "" {
+			continue
+		}
+		// Sizes are human readable, an unknown size only makes the estimate larger
+		size, _ := units.RAMInBytes(img.Size)
+		digests[img.Digest] = size
+	}
+	return digests, scanner.Err()
+}
+
+// LocalLayers returns the uncompressed digests of the layers of local images
+func (n *Nerdctl) LocalLayers(ctx context.Context) (map[string]bool, error) {
+	output, err := n.run(ctx, nil, "images", "--quiet")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+	ids := strings.Fields(string(output))
+	layers := make(map[string]bool)
+	if len(ids) == 0 {
+		return layers, nil
+	}
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+
+	output, err = n.run(ctx, nil, append([]string{"image", "inspect", "--format", "{{json .RootFS.Layers}}"}, ids...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect images: %w", err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		var imageLayers []string
+		if err := json.Unmarshal(scanner.Bytes(), &imageLayers); err != nil {
+			return nil, fmt.Errorf("invalid output of nerdctl image inspect: %w", err)
+		}
+		for _, layer := range imageLayers {
+			layers[layer] = true
+		}
+	}
+	return layers, scanner.Err()
+}
+
+// AvailableSpace returns the free disk space on the containerd data root
+func (n *Nerdctl) AvailableSpace(ctx context.Context) (int64, string, error) {
+	root := containerdRoot()
+	free, err := docker.FreeDiskSpace(root)
+	if err != nil {
+		return 0, root, fmt.Errorf("cannot check disk space of %s: %w", root, err)
+	}
+	return free, root, nil
+}
+
+// containerdRoot returns the data root of containerd, the one of rootless containerd if it exists
+func containerdRoot() string {
+	if os.Geteuid() != 0 {
+		dataHome := os.Getenv("XDG_DATA_HOME")
+		if dataHome == "" {
+			home, _ := os.UserHomeDir()
+			dataHome = filepath.Join(home, ".local", "share")
+		}
+		root := filepath.Join(dataHome, "containerd")
+		if _, err := os.Stat(root); err == nil {
+			return root
+		}
+	}
+	return systemContainerdRoot
+}
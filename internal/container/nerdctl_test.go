@@ -0,0 +1,154 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fakeNerdctl is a nerdctl script logging its arguments, listing one image, knowing no image named missing
+// and failing to look up the image named broken. Inspected images are made of the layers sha256:l1 and sha256:l2
+const fakeNerdctl = `#!/bin/sh
+echo "$@" >> "$NERDCTL_LOG"
+case "$1" in
+images)
+	if [ "$2" = "--quiet" ]; then
+		case "$3" in
+		missing) ;;
+		broken)
+			echo "failed to connect to containerd" >&2
+			exit 1
+			;;
+		*) echo "0123456789ab" ;;
+		esac
+		exit 0
+	fi
+	echo '{"Repository":"registry.example.com/team/app","Tag":"1.0","Digest":"sha256:aaa","Size":"1.5 MiB"}'
+	echo '{"Repository":"<none>","Tag":"<none>","Digest":"","Size":"10 MiB"}'
+	;;
+image)
+	echo '["sha256:l1","sha256:l2"]'
+	echo '["sha256:l1"]'
+	;;
+login)
+	cat >> "$NERDCTL_LOG"
+	echo >> "$NERDCTL_LOG"
+	;;
+push)
+	echo "unauthorized" >&2
+	exit 1
+	;;
+esac
+`
+
+// newTestNerdctl installs the fake nerdctl in PATH and returns an engine using it with its log file
+func newTestNerdctl(t *testing.T) (*Nerdctl, string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "nerdctl"), []byte(fakeNerdctl), 0755); err != nil {
+		t.Fatalf("Failed to write nerdctl: %v", err)
+	}
+	log := filepath.Join(dir, "log")
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("NERDCTL_LOG", log)
+
+	engine, err := New(EngineContainerd)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return engine.(*Nerdctl), log
+}
+
+func TestNerdctl(t *testing.T) {
+	engine, log := newTestNerdctl(t)
+	ctx := t.Context()
+
+	if err := engine.CheckRunning(ctx); err != nil {
+		t.Errorf("CheckRunning failed: %v", err)
+	}
+	if err := engine.PullImage(ctx, "registry.example.com/team/app:1.0"); err != nil {
+		t.Errorf("PullImage failed: %v", err)
+	}
+	if err := engine.TagImage(ctx, "registry.example.com/team/app:1.0", "registry.example.com/platform/app:1.0"); err != nil {
+		t.Errorf("TagImage failed: %v", err)
+	}
+	if exists, err := engine.ImageExists(ctx, "registry.example.com/platform/app:1.0"); err != nil || !exists {
+		t.Errorf("Expected image to exist, got %v (%v)", exists, err)
+	}
+	if exists, err := engine.ImageExists(ctx, "missing"); err != nil || exists {
+		t.Errorf("Expected image to be missing, got %v (%v)", exists, err)
+	}
+	if _, err := engine.ImageExists(ctx, "broken"); err == nil || !strings.Contains(err.Error(), "failed to connect") {
+		t.Errorf("Expected lookup error, got %v", err)
+	}
+	if err := engine.PushImage(ctx, "registry.example.com/platform/app:1.0"); err == nil || !strings.Contains(err.Error(), "unauthorized") {
+		t.Errorf("Expected push to fail with the nerdctl error, got %v", err)
+	}
+
+	digests, err := engine.LocalImageDigests(ctx)
+	if err != nil {
+		t.Fatalf("LocalImageDigests failed: %v", err)
+	}
+	if len(digests) != 1 || digests["sha256:aaa"] != 1536*1024 {
+		t.Errorf("Expected 1.5 MiB for sha256:aaa, got %v", digests)
+	}
+
+	layers, err := engine.LocalLayers(ctx)
+	if err != nil {
+		t.Fatalf("LocalLayers failed: %v", err)
+	}
+	if len(layers) != 2 || !layers["sha256:l1"] || !layers["sha256:l2"] {
+		t.Errorf("Expected layers sha256:l1 and sha256:l2, got %v", layers)
+	}
+
+	data, _ := os.ReadFile(log)
+	expected := []string{
+		"version",
+		"pull --quiet registry.example.com/team/app:1.0",
+		"tag registry.example.com/team/app:1.0 registry.example.com/platform/app:1.0",
+		"images --quiet registry.example.com/platform/app:1.0",
+		"images --quiet missing",
+		"images --quiet broken",
+		"push --quiet registry.example.com/platform/app:1.0",
+		"images --digests --format {{json .}}",
+		"images --quiet",
+		"image inspect --format {{json .RootFS.Layers}} 0123456789ab",
+	}
+	if got := strings.Split(strings.TrimSpace(string(data)), "\n"); !slices.Equal(got, expected) {
+		t.Errorf("Expected commands %v, got %v", expected, got)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New("rkt"); err == nil {
+		t.Error("Expected unknown engine to fail")
+	}
+
+	t.Setenv("PATH", t.TempDir())
+	if _, err := New(EngineContainerd); err == nil {
+		t.Error("Expected containerd engine to fail without nerdctl")
+	}
+}
+
+func TestPodmanHost(t *testing.T) {
+	t.Setenv("CONTAINER_HOST", "")
+	runtimeDir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+	if host := PodmanHost(); host != "unix://"+systemPodmanSocket {
+		t.Errorf("Expected system socket, got %s", host)
+	}
+
+	socket := filepath.Join(runtimeDir, "podman", "podman.sock")
+	os.MkdirAll(filepath.Dir(socket), 0755)
+	os.WriteFile(socket, nil, 0644)
+	if host := PodmanHost(); host != "unix://"+socket {
+		t.Errorf("Expected rootless socket %s, got %s", socket, host)
+	}
+
+	t.Setenv("CONTAINER_HOST", "tcp://podman:8080")
+	if host := PodmanHost(); host != "tcp://podman:8080" {
+		t.Errorf("Expected CONTAINER_HOST, got %s", host)
+	}
+}
@@ -0,0 +1,45 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+
+	"migraptor/internal/docker"
+)
+
+// systemPodmanSocket is the socket of the rootful Podman service
+const systemPodmanSocket = "/run/podman/podman.sock"
+
+// Podman drives Podman through its Docker-compatible API
+type Podman struct {
+	*docker.Client
+}
+
+// NewPodman creates a Podman engine connected to the socket found by PodmanHost
+func NewPodman() (*Podman, error) {
+	client, err := docker.NewClientWithHost(PodmanHost())
+	if err != nil {
+		return nil, err
+	}
+	return &Podman{Client: client}, nil
+}
+
+// Name returns the name of the engine
+func (p *Podman) Name() string {
+	return "Podman"
+}
+
+// PodmanHost returns the address of the Podman API: $CONTAINER_HOST, the rootless socket of the user
+// if the service runs, then the rootful socket
+func PodmanHost() string {
+	if host := os.Getenv("CONTAINER_HOST"); host != "" {
+		return host
+	}
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		socket := filepath.Join(runtimeDir, "podman", "podman.sock")
+		if _, err := os.Stat(socket); err == nil {
+			return "unix://" + socket
+		}
+	}
+	return "unix://" + systemPodmanSocket
+}
@@ -5,10 +5,6 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"io"
-	"log"
-	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/docker/docker/api/types/image"
@@ -16,29 +12,36 @@ import (
 	dockerclient "github.com/docker/docker/client"
 )
 
-// Client wraps the Docker API client
+// Client wraps the Docker API client. It also drives Podman through its Docker-compatible API.
 type Client struct {
 	cli      *dockerclient.Client
-	ctx      context.Context
-	registry string
-	username string
-	password string
-	loggedIn bool
 	authInfo string
+	progress ProgressReporter
 }
 
-// NewClient creates a new Docker client
+// NewClient creates a new Docker client, configured from DOCKER_HOST and the other Docker environment variables
 func NewClient() (*Client, error) {
 	cli, err := dockerclient.NewClientWithOpts(dockerclient.FromEnv, dockerclient.WithAPIVersionNegotiation())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Docker client: %w", err)
 	}
 
-	return &Client{
-		cli:      cli,
-		ctx:      context.Background(),
-		loggedIn: false,
-	}, nil
+	return &Client{cli: cli}, nil
+}
+
+// NewClientWithHost creates a client for the Docker-compatible API listening on host, such as a Podman socket
+func NewClientWithHost(host string) (*Client, error) {
+	cli, err := dockerclient.NewClientWithOpts(dockerclient.WithHost(host), dockerclient.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client for %s: %w", host, err)
+	}
+
+	return &Client{cli: cli}, nil
+}
+
+// Name returns the name of the container engine
+func (c *Client) Name() string {
+	return "Docker"
 }
 
 // Close closes the Docker client
@@ -46,71 +49,34 @@ func (c *Client) Close() error {
 	return c.cli.Close()
 }
 
-// SetAuthInfo sets the authentication information
-func (c *Client) SetAuthInfo(authInfo string) {
-	c.authInfo = authInfo
-}
-
-// CheckDockerRunning checks if Docker daemon is running
-func (c *Client) CheckDockerRunning() error {
-	_, err := c.cli.Ping(c.ctx)
+// CheckRunning checks if the daemon is running
+func (c *Client) CheckRunning(ctx context.Context) error {
+	_, err := c.cli.Ping(ctx)
 	if err != nil {
-		return fmt.Errorf("docker daemon is not running: %w", err)
+		return fmt.Errorf("daemon is not running on %s: %w", c.cli.DaemonHost(), err)
 	}
 	return nil
 }
 
-// CheckRegistryLogin checks if already logged in to the registry
-func (c *Client) CheckRegistryLogin(registry string) bool {
-	configPath := filepath.Join(os.Getenv("HOME"), ".docker", "config.json")
-	if _, err := os.Stat(configPath); err != nil {
-		return false
-	}
-
-	configData, err := os.ReadFile(configPath)
-	if err != nil {
-		return false
-	}
-
-	return strings.Contains(string(configData), registry)
+// CheckRegistryLogin checks if the Docker CLI holds credentials for the registry
+func (c *Client) CheckRegistryLogin(ctx context.Context, registryHost string) bool {
+	auth, err := LoadCredentials(ctx, ConfigDir(), registryHost)
+	return err == nil && auth != nil
 }
 
-// Login logs in to the GitLab registry
-func (c *Client) Login(registryUrl, username, password string) (string, error) {
-	c.registry = registryUrl
-	c.username = username
-	c.password = password
-
-	// 1. Define your credentials
-	authConfig := registry.AuthConfig{
-		Username:      username,
-		Password:      password,
-		ServerAddress: registryUrl,
+// Login checks the credentials against the registry and keeps them for pull and push operations
+func (c *Client) Login(ctx context.Context, authConfig registry.AuthConfig) error {
+	if _, err := c.cli.RegistryLogin(ctx, authConfig); err != nil {
+		return fmt.Errorf("failed to login to registry %s: %w", authConfig.ServerAddress, err)
 	}
 
-	// 2. Perform the Login check
-	client, err := NewClient()
-	if err != nil {
-		return "", fmt.Errorf("failed to create Docker client: %w", err)
-	}
-	_, err = client.cli.RegistryLogin(client.ctx, authConfig)
-	if err != nil {
-		log.Fatalf("Login failed: %v", err)
-	}
-
-	// Attempt a login by trying to obtain a registry token
-	_, err = client.cli.RegistryLogin(client.ctx, authConfig)
-	if err != nil {
-		return "", fmt.Errorf("failed to login to registry %s: %w", registryUrl, err)
-	}
-	// Set as logged in if successful
-	// 3. Prepare the encoded string for Pull/Push operations
 	// Most SDK methods expect a base64-encoded JSON string of the AuthConfig
 	encodedAuth, err := encodeAuthToBase64(authConfig)
 	if err != nil {
-		return "", fmt.Errorf("failed to encode auth to base64: %w", err)
+		return fmt.Errorf("failed to encode auth to base64: %w", err)
 	}
-	return encodedAuth, nil
+	c.authInfo = encodedAuth
+	return nil
 }
 
 func encodeAuthToBase64(auth registry.AuthConfig) (string, error) {
@@ -122,28 +88,27 @@ func encodeAuthToBase64(auth registry.AuthConfig) (string, error) {
 }
 
 // PullImage pulls an image from the registry
-func (c *Client) PullImage(imageRef string) error {
-
+func (c *Client) PullImage(ctx context.Context, imageRef string) error {
 	// Options include the Base64 encoded auth string
 	options := image.PullOptions{
 		RegistryAuth: c.authInfo,
 	}
 
-	reader, err := c.cli.ImagePull(c.ctx, imageRef, options)
+	reader, err := c.cli.ImagePull(ctx, imageRef, options)
 	if err != nil {
 		return fmt.Errorf("failed to pull image %s: %w", imageRef, err)
 	}
 	defer reader.Close()
 
-	// Consume the output to show progress (can be made verbose)
-	_, _ = io.Copy(io.Discard, reader)
-
+	if err := c.streamProgress(reader, "Pulling", imageRef); err != nil {
+		return fmt.Errorf("failed to pull image %s: %w", imageRef, err)
+	}
 	return nil
 }
 
 // TagImage tags an image with a new name
-func (c *Client) TagImage(sourceImage, targetImage string) error {
-	err := c.cli.ImageTag(c.ctx, sourceImage, targetImage)
+func (c *Client) TagImage(ctx context.Context, sourceImage, targetImage string) error {
+	err := c.cli.ImageTag(ctx, sourceImage, targetImage)
 	if err != nil {
 		return fmt.Errorf("failed to tag image %s as %s: %w", sourceImage, targetImage, err)
 	}
@@ -151,25 +116,25 @@ func (c *Client) TagImage(sourceImage, targetImage string) error {
 }
 
 // PushImage pushes an image to the registry
-func (c *Client) PushImage(imageRef string) error {
+func (c *Client) PushImage(ctx context.Context, imageRef string) error {
 	options := image.PushOptions{
 		RegistryAuth: c.authInfo,
 	}
-	reader, err := c.cli.ImagePush(c.ctx, imageRef, options)
+	reader, err := c.cli.ImagePush(ctx, imageRef, options)
 	if err != nil {
 		return fmt.Errorf("failed to push image %s: %w", imageRef, err)
 	}
 	defer reader.Close()
 
-	// Consume the output to show progress (can be made verbose)
-	_, _ = io.Copy(io.Discard, reader)
-
+	if err := c.streamProgress(reader, "Pushing", imageRef); err != nil {
+		return fmt.Errorf("failed to push image %s: %w", imageRef, err)
+	}
 	return nil
 }
 
 // ImageExists checks if an image exists locally
-func (c *Client) ImageExists(imageRef string) (bool, error) {
-	_, err := c.cli.ImageInspect(c.ctx, imageRef)
+func (c *Client) ImageExists(ctx context.Context, imageRef string) (bool, error) {
+	_, err := c.cli.ImageInspect(ctx, imageRef)
 	if err != nil {
 		if dockerclient.IsErrNotFound(err) {
 			return false, nil
@@ -180,8 +145,8 @@ func (c *Client) ImageExists(imageRef string) (bool, error) {
 }
 
 // ListImages lists all local images
-func (c *Client) ListImages() ([]image.Summary, error) {
-	images, err := c.cli.ImageList(c.ctx, image.ListOptions{})
+func (c *Client) ListImages(ctx context.Context) ([]image.Summary, error) {
+	images, err := c.cli.ImageList(ctx, image.ListOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list images: %w", err)
 	}
@@ -189,10 +154,66 @@ func (c *Client) ListImages() ([]image.Summary, error) {
 }
 
 // RemoveImage removes a local image
-func (c *Client) RemoveImage(imageRef string) error {
-	_, err := c.cli.ImageRemove(c.ctx, imageRef, image.RemoveOptions{})
+func (c *Client) RemoveImage(ctx context.Context, imageRef string) error {
+	_, err := c.cli.ImageRemove(ctx, imageRef, image.RemoveOptions{})
 	if err != nil {
 		return fmt.Errorf("failed to remove image %s: %w", imageRef, err)
 	}
 	return nil
 }
+
+// LocalImageDigests returns the registry digests of local images with their size
+func (c *Client) LocalImageDigests(ctx context.Context) (map[string]int64, error) {
+	images, err := c.ListImages(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	digests := make(map[string]int64)
+	for _, img := range images {
+		for _, repoDigest := range img.RepoDigests {
+			if _, digest, found := strings.Cut(repoDigest, "@"); found {
+				digests[digest] = img.Size
+			}
+		}
+	}
+	return digests, nil
+}
+
+// LocalLayers returns the uncompressed digests of the layers of local images
+func (c *Client) LocalLayers(ctx context.Context) (map[string]bool, error) {
+	images, err := c.ListImages(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	layers := make(map[string]bool)
+	for _, img := range images {
+		inspect, err := c.cli.ImageInspect(ctx, img.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect image %s: %w", img.ID, err)
+		}
+		for _, layer := range inspect.RootFS.Layers {
+			layers[layer] = true
+		}
+	}
+	return layers, nil
+}
+
+// AvailableSpace returns the free disk space on the Docker data root
+func (c *Client) AvailableSpace(ctx context.Context) (int64, string, error) {
+	if host := c.cli.DaemonHost(); !strings.HasPrefix(host, "unix://") {
+		return 0, "", fmt.Errorf("daemon is remote (%s), cannot check its disk space", host)
+	}
+
+	info, err := c.cli.Info(ctx)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to get docker info: %w", err)
+	}
+
+	free, err := FreeDiskSpace(info.DockerRootDir)
+	if err != nil {
+		return 0, info.DockerRootDir, fmt.Errorf("cannot check disk space of %s (docker may run in a VM): %w", info.DockerRootDir, err)
+	}
+	return free, info.DockerRootDir, nil
+}
@@ -0,0 +1,143 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types/registry"
+)
+
+// tokenUsername is the username returned by credential helpers for identity tokens
+const tokenUsername = "<token>"
+
+// configFile is the part of the Docker CLI configuration holding registry credentials
+type configFile struct {
+	Auths       map[string]authEntry `json:"auths"`
+	CredsStore  string               `json:"credsStore"`
+	CredHelpers map[string]string    `json:"credHelpers"`
+}
+
+// authEntry is a registry entry of the auths section of the Docker CLI configuration
+type authEntry struct {
+	Auth          string `json:"auth"`
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	IdentityToken string `json:"identitytoken"`
+}
+
+// helperCredentials is the output of the get command of a credential helper
+type helperCredentials struct {
+	ServerURL string
+	Username  string
+	Secret    string
+}
+
+// ConfigDir returns the directory of the Docker CLI configuration, $DOCKER_CONFIG or ~/.docker
+func ConfigDir() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = os.Getenv("HOME")
+	}
+	return filepath.Join(home, ".docker")
+}
+
+// LoadCredentials returns the credentials stored by the Docker CLI for a registry, nil if there is none.
+// Like the Docker CLI, it uses the credential helper of the registry (credHelpers), then the default
+// credential store (credsStore), then the auths entries.
+func LoadCredentials(ctx context.Context, configDir, registryHost string) (*registry.AuthConfig, error) {
+	data, err := os.ReadFile(filepath.Join(configDir, "config.json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read docker config: %w", err)
+	}
+	var config configFile
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse docker config: %w", err)
+	}
+
+	host := registryHostname(registryHost)
+	if helper, ok := config.CredHelpers[host]; ok && helper != "" {
+		return helperGet(ctx, helper, host)
+	}
+	if config.CredsStore != "" {
+		return helperGet(ctx, config.CredsStore, host)
+	}
+	for key, entry := range config.Auths {
+		if registryHostname(key) == host {
+			return entry.authConfig(host)
+		}
+	}
+	return nil, nil
+}
+
+// authConfig decodes an auths entry, which holds base64 encoded "username:password" credentials
+func (e authEntry) authConfig(host string) (*registry.AuthConfig, error) {
+	auth := &registry.AuthConfig{
+		Username:      e.Username,
+		Password:      e.Password,
+		IdentityToken: e.IdentityToken,
+		ServerAddress: host,
+	}
+	if e.Auth != "" {
+		decoded, err := base64.StdEncoding.DecodeString(e.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("invalid auth entry for %s: %w", host, err)
+		}
+		username, password, found := strings.Cut(string(decoded), ":")
+		if !found {
+			return nil, fmt.Errorf("invalid auth entry for %s: missing password", host)
+		}
+		auth.Username, auth.Password = username, password
+	}
+	if auth.Username == "" && auth.IdentityToken == "" {
+		return nil, nil
+	}
+	return auth, nil
+}
+
+// helperGet runs the get command of the docker-credential-<helper> binary for a registry
+func helperGet(ctx context.Context, helper, host string) (*registry.AuthConfig, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(host)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		output := strings.TrimSpace(stdout.String() + stderr.String())
+		if strings.Contains(output, "credentials not found") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("credential helper %s failed: %w (%s)", helper, err, output)
+	}
+
+	var creds helperCredentials
+	if err := json.Unmarshal(stdout.Bytes(), &creds); err != nil {
+		return nil, fmt.Errorf("invalid output of credential helper %s: %w", helper, err)
+	}
+	auth := &registry.AuthConfig{ServerAddress: host}
+	if creds.Username == tokenUsername {
+		auth.IdentityToken = creds.Secret
+	} else {
+		auth.Username, auth.Password = creds.Username, creds.Secret
+	}
+	return auth, nil
+}
+
+// registryHostname strips the scheme and the path of a registry address, as found in auths keys
+func registryHostname(address string) string {
+	address = strings.TrimPrefix(strings.TrimPrefix(address, "https://"), "http://")
+	host, _, _ := strings.Cut(address, "/")
+	return host
+}
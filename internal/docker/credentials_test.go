@@ -0,0 +1,109 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// writeDockerConfig writes a Docker CLI configuration in a temporary directory and points DOCKER_CONFIG to it
+func writeDockerConfig(t *testing.T, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write docker config: %v", err)
+	}
+	t.Setenv("DOCKER_CONFIG", dir)
+	return dir
+}
+
+// installHelper installs a docker-credential-<name> script printing output and exiting with status
+func installHelper(t *testing.T, name, output string, status int) {
+	t.Helper()
+	dir := t.TempDir()
+	script := "#!/bin/sh\ncat > /dev/null\necho '" + output + "'\nexit " + strconv.Itoa(status) + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "docker-credential-"+name), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write credential helper: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestConfigDir(t *testing.T) {
+	t.Setenv("DOCKER_CONFIG", "/etc/docker-cli")
+	if dir := ConfigDir(); dir != "/etc/docker-cli" {
+		t.Errorf("Expected /etc/docker-cli, got %s", dir)
+	}
+
+	t.Setenv("DOCKER_CONFIG", "")
+	t.Setenv("HOME", "/home/alice")
+	if dir := ConfigDir(); dir != "/home/alice/.docker" {
+		t.Errorf("Expected /home/alice/.docker, got %s", dir)
+	}
+}
+
+func TestLoadCredentials_Auths(t *testing.T) {
+	// "deploy:secret" base64 encoded
+	dir := writeDockerConfig(t, `{"auths": {
+		"https://registry.gitlab.com": {"auth": "ZGVwbG95OnNlY3JldA=="},
+		"registry.example.com": {"identitytoken": "token"}
+	}}`)
+
+	auth, err := LoadCredentials(t.Context(), dir, "registry.gitlab.com")
+	if err != nil {
+		t.Fatalf("LoadCredentials failed: %v", err)
+	}
+	if auth == nil || auth.Username != "deploy" || auth.Password != "secret" || auth.ServerAddress != "registry.gitlab.com" {
+		t.Errorf("Expected deploy credentials for registry.gitlab.com, got %+v", auth)
+	}
+
+	auth, err = LoadCredentials(t.Context(), dir, "registry.example.com")
+	if err != nil || auth == nil || auth.IdentityToken != "token" {
+		t.Errorf("Expected identity token, got %+v (%v)", auth, err)
+	}
+
+	auth, err = LoadCredentials(t.Context(), dir, "registry.unknown.com")
+	if err != nil || auth != nil {
+		t.Errorf("Expected no credentials, got %+v (%v)", auth, err)
+	}
+}
+
+func TestLoadCredentials_Helpers(t *testing.T) {
+	installHelper(t, "gitlab", `{"ServerURL": "registry.gitlab.com", "Username": "alice", "Secret": "s3cr3t"}`, 0)
+	installHelper(t, "desktop", "credentials not found in native keychain", 1)
+	dir := writeDockerConfig(t, `{
+		"credsStore": "desktop",
+		"credHelpers": {"registry.gitlab.com": "gitlab"},
+		"auths": {"registry.example.com": {"auth": "ZGVwbG95OnNlY3JldA=="}}
+	}`)
+
+	auth, err := LoadCredentials(t.Context(), dir, "registry.gitlab.com")
+	if err != nil {
+		t.Fatalf("LoadCredentials failed: %v", err)
+	}
+	if auth == nil || auth.Username != "alice" || auth.Password != "s3cr3t" {
+		t.Errorf("Expected credentials of alice from the gitlab helper, got %+v", auth)
+	}
+
+	// The credential store takes precedence over auths entries, which only hold the registries it knows
+	auth, err = LoadCredentials(t.Context(), dir, "registry.example.com")
+	if err != nil || auth != nil {
+		t.Errorf("Expected no credentials in the store, got %+v (%v)", auth, err)
+	}
+}
+
+func TestLoadCredentials_Errors(t *testing.T) {
+	if auth, err := LoadCredentials(t.Context(), t.TempDir(), "registry.gitlab.com"); err != nil || auth != nil {
+		t.Errorf("Expected no credentials without config, got %+v (%v)", auth, err)
+	}
+
+	dir := writeDockerConfig(t, `{"credsStore": "missing"}`)
+	if _, err := LoadCredentials(t.Context(), dir, "registry.gitlab.com"); err == nil {
+		t.Error("Expected an error when the credential helper is missing")
+	}
+
+	dir = writeDockerConfig(t, `{"auths": {"registry.gitlab.com": {"auth": "not base64"}}}`)
+	if _, err := LoadCredentials(t.Context(), dir, "registry.gitlab.com"); err == nil {
+		t.Error("Expected an error on an invalid auth entry")
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+
+package docker
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestFreeDiskSpace(t *testing.T) {
+	free, err := FreeDiskSpace(t.TempDir())
+	if err != nil {
+		t.Fatalf("FreeDiskSpace failed: %v", err)
+	}
+	if free <= 0 {
+		t.Errorf("Expected free space on the temporary directory, got %d", free)
+	}
+
+	if _, err := FreeDiskSpace(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Expected a missing path to fail")
+	}
+}
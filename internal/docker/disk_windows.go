@@ -0,0 +1,10 @@
+//go:build windows
+
+package docker
+
+import "errors"
+
+// FreeDiskSpace is not supported on Windows, where Docker runs in a VM
+func FreeDiskSpace(path string) (int64, error) {
+	return 0, errors.New("disk space check not supported on windows")
+}
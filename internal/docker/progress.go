@@ -0,0 +1,61 @@
+package docker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/docker/docker/pkg/jsonmessage"
+)
+
+// ProgressReporter receives the progress of an image pull or push, layer by layer
+type ProgressReporter interface {
+	// Start is called when the pull or push of an image starts
+	Start(action, imageRef string)
+	// Layer is called on each status or progress update of a layer
+	Layer(id, status string, current, total int64)
+	// Finish is called when the pull or push ends, successfully or not
+	Finish()
+}
+
+// SetProgress sets the reporter receiving the progress of pulls and pushes, none if nil
+func (c *Client) SetProgress(progress ProgressReporter) {
+	c.progress = progress
+}
+
+// readStream decodes the JSON message stream of a pull or push, reporting the progress of each layer.
+// Errors sent in the stream are returned, the daemon reports most pull and push failures this way.
+func readStream(stream io.Reader, progress ProgressReporter) error {
+	decoder := json.NewDecoder(stream)
+	for {
+		var message jsonmessage.JSONMessage
+		if err := decoder.Decode(&message); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("failed to read progress: %w", err)
+		}
+		if message.Error != nil {
+			return message.Error
+		}
+		if progress == nil || message.ID == "" {
+			continue
+		}
+
+		var current, total int64
+		if message.Progress != nil {
+			current, total = message.Progress.Current, message.Progress.Total
+		}
+		progress.Layer(message.ID, message.Status, current, total)
+	}
+}
+
+// streamProgress reports the progress of an image pull or push read from stream
+func (c *Client) streamProgress(stream io.Reader, action, imageRef string) error {
+	if c.progress != nil {
+		c.progress.Start(action, imageRef)
+		defer c.progress.Finish()
+	}
+	return readStream(stream, c.progress)
+}
@@ -0,0 +1,87 @@
+package docker
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// recordingProgress records the layer updates as strings
+type recordingProgress struct {
+	events []string
+}
+
+func (p *recordingProgress) Start(action, imageRef string) {
+	p.events = append(p.events, action+" "+imageRef)
+}
+
+func (p *recordingProgress) Layer(id, status string, current, total int64) {
+	p.events = append(p.events, fmt.Sprintf("%s %s %d/%d", id, status, current, total))
+}
+
+func (p *recordingProgress) Finish() {
+	p.events = append(p.events, "finish")
+}
+
+func TestReadStream(t *testing.T) {
+	stream := `{"status":"Pulling from team/app","id":"1.0"}
+{"status":"Pulling fs layer","progressDetail":{},"id":"a1b2c3"}
+{"status":"Downloading","progressDetail":{"current":512,"total":2048},"progress":"[==>   ]","id":"a1b2c3"}
+{"status":"Pull complete","progressDetail":{},"id":"a1b2c3"}
+{"status":"Digest: sha256:aaa"}
+`
+	progress := &recordingProgress{}
+	client := &Client{progress: progress}
+	if err := client.streamProgress(strings.NewReader(stream), "Pulling", "registry.example.com/team/app:1.0"); err != nil {
+		t.Fatalf("streamProgress failed: %v", err)
+	}
+
+	expected := []string{
+		"Pulling registry.example.com/team/app:1.0",
+		"1.0 Pulling from team/app 0/0",
+		"a1b2c3 Pulling fs layer 0/0",
+		"a1b2c3 Downloading 512/2048",
+		"a1b2c3 Pull complete 0/0",
+		"finish",
+	}
+	if !slices.Equal(progress.events, expected) {
+		t.Errorf("Expected events %v, got %v", expected, progress.events)
+	}
+}
+
+func TestReadStream_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		stream   string
+		expected string
+	}{
+		{
+			name: "error in stream",
+			stream: `{"status":"Preparing","id":"a1b2c3"}
+{"errorDetail":{"message":"denied: requested access to the resource is denied"},"error":"denied: requested access to the resource is denied"}
+`,
+			expected: "denied: requested access to the resource is denied",
+		},
+		{
+			name:     "truncated stream",
+			stream:   `{"status":"Pushing","id":"a1b2c3"`,
+			expected: "failed to read progress",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			progress := &recordingProgress{}
+			err := readStream(strings.NewReader(tt.stream), progress)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected error %q, got %v", tt.expected, err)
+			}
+		})
+	}
+
+	// Without reporter, the stream is still checked
+	if err := readStream(strings.NewReader(`{"errorDetail":{"message":"unauthorized"}}`), nil); err == nil {
+		t.Error("Expected error without progress reporter")
+	}
+}
@@ -0,0 +1,187 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// image is a local image, identified by the digest of its registry manifest
+type image struct {
+	digest string
+	size   int64
+	layers []string
+}
+
+// Engine is an in-memory container engine pulling from and pushing to the registry of a fake GitLab
+type Engine struct {
+	mu       sync.Mutex
+	registry *GitLab
+	images   map[string]image
+
+	// FreeSpace is the free disk space reported for the data root
+	FreeSpace int64
+	// DataRoot is the directory reported as data root
+	DataRoot string
+	// Pulls, Pushes and Removals record the references pulled, pushed and removed, in order
+	Pulls    []string
+	Pushes   []string
+	Removals []string
+	// PullErrors and PushErrors make the pulls and pushes of the given references fail with their error
+	PullErrors map[string]error
+	PushErrors map[string]error
+}
+
+// NewEngine creates an engine without local images, using the registry of the given GitLab instance
+func NewEngine(registry *GitLab) *Engine {
+	return &Engine{
+		registry:  registry,
+		images:    make(map[string]image),
+		FreeSpace: 100 << 30,
+		DataRoot:  "/var/lib/docker",
+	}
+}
+
+// PullImage copies an image from the registry
+func (e *Engine) PullImage(ctx context.Context, imageRef string) error {
+	e.mu.Lock()
+	pullErr := e.PullErrors[imageRef]
+	e.mu.Unlock()
+	if pullErr != nil {
+		return fmt.Errorf("failed to pull image %s: %w", imageRef, pullErr)
+	}
+
+	e.registry.mu.Lock()
+	digest, size, err := e.registry.lookupImage(imageRef)
+	e.registry.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to pull image %s: %w", imageRef, err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.images[imageRef] = image{digest: digest, size: size}
+	e.Pulls = append(e.Pulls, imageRef)
+	return nil
+}
+
+// TagImage adds a new reference to a local image
+func (e *Engine) TagImage(ctx context.Context, sourceImage, targetImage string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	img, ok := e.images[sourceImage]
+	if !ok {
+		return fmt.Errorf("failed to tag image %s as %s: no such image", sourceImage, targetImage)
+	}
+	e.images[targetImage] = img
+	return nil
+}
+
+// PushImage copies a local image to the registry
+func (e *Engine) PushImage(ctx context.Context, imageRef string) error {
+	e.mu.Lock()
+	img, ok := e.images[imageRef]
+	pushErr := e.PushErrors[imageRef]
+	e.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("failed to push image %s: no such image", imageRef)
+	}
+	if pushErr != nil {
+		return fmt.Errorf("failed to push image %s: %w", imageRef, pushErr)
+	}
+
+	e.registry.mu.Lock()
+	err := e.registry.pushImage(imageRef, img.digest, img.size)
+	e.registry.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to push image %s: %w", imageRef, err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.Pushes = append(e.Pushes, imageRef)
+	return nil
+}
+
+// ImageExists checks if an image was pulled or tagged locally
+func (e *Engine) ImageExists(ctx context.Context, imageRef string) (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	_, ok := e.images[imageRef]
+	return ok, nil
+}
+
+// RemoveImage removes a local reference
+func (e *Engine) RemoveImage(ctx context.Context, imageRef string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, ok := e.images[imageRef]; !ok {
+		return fmt.Errorf("failed to remove image %s: no such image", imageRef)
+	}
+	delete(e.images, imageRef)
+	e.Removals = append(e.Removals, imageRef)
+	return nil
+}
+
+// LocalImageDigests returns the registry digests of local images with their size
+func (e *Engine) LocalImageDigests(ctx context.Context) (map[string]int64, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	digests := make(map[string]int64)
+	for _, img := range e.images {
+		if img.digest != "" {
+			digests[img.digest] = img.size
+		}
+	}
+	return digests, nil
+}
+
+// LocalLayers returns the layers of the local images added by LoadImage
+func (e *Engine) LocalLayers(ctx context.Context) (map[string]bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	layers := make(map[string]bool)
+	for _, img := range e.images {
+		for _, layer := range img.layers {
+			layers[layer] = true
+		}
+	}
+	return layers, nil
+}
+
+// LoadImage adds a local image made of the given layers, as loaded from a file rather than pulled from the registry
+func (e *Engine) LoadImage(imageRef string, layers ...string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.images[imageRef] = image{layers: layers}
+}
+
+// AvailableSpace returns the configured free space and data root
+func (e *Engine) AvailableSpace(ctx context.Context) (int64, string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.FreeSpace, e.DataRoot, nil
+}
+
+// Images returns the sorted references of local images
+func (e *Engine) Images() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var refs []string
+	for ref := range e.images {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	return refs
+}
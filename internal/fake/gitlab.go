@@ -0,0 +1,1239 @@
+// Package fake provides in-memory implementations of the GitLab API and of a container engine.
+// They simulate groups, projects, registries, tags, packages and transfers so that migrations can be
+// tested without a live GitLab instance nor a Docker daemon. Server exposes the GitLab instance
+// over HTTP with an OCI registry, to run the whole CLI against it.
+package fake
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"migraptor/internal/gitlab"
+
+	gitlabCore "gitlab.com/gitlab-org/api/client-go"
+)
+
+// repository is a container registry repository with its tags
+type repository struct {
+	registry *gitlabCore.RegistryRepository
+	tags     map[string]*gitlabCore.RegistryRepositoryTag
+	// pendingListings is the number of listings a repository scheduled for deletion remains visible
+	pendingListings int
+}
+
+// GitLab is an in-memory GitLab instance implementing the client methods used by MigRaptor
+type GitLab struct {
+	mu           sync.Mutex
+	registryHost string
+	nextID       int64
+
+	groups         map[int64]*gitlabCore.Group
+	projects       map[int64]*gitlabCore.Project
+	repositories   map[int64][]*repository
+	packages       map[int64][]*storedPackage
+	labels         map[int64][]*gitlabCore.GroupLabel
+	avatars        map[int64][]byte
+	users          map[int64]*gitlabCore.User
+	groupMembers   map[int64]map[int64]*gitlabCore.GroupMember
+	projectMembers map[int64]map[int64]*gitlabCore.ProjectMember
+	pipelines      map[int64][]*gitlabCore.PipelineInfo
+	storage        map[string]*gitlab.NamespaceStorage
+	projectSizes   map[int64]int64
+
+	// CurrentUser is the owner of the token, an administrator by default
+	CurrentUser *gitlabCore.User
+	// TokenScopes are the scopes of the token
+	TokenScopes []string
+	// RegistryDeletionDelay is the number of listings a deleted registry repository remains visible,
+	// as on instances deleting repositories asynchronously
+	RegistryDeletionDelay int
+	// TagListErrors make the tag listings of the given registry repository paths fail with their error
+	TagListErrors map[string]error
+}
+
+// NewGitLab creates an empty GitLab instance whose container registry is served on registryHost
+func NewGitLab(registryHost string) *GitLab {
+	g := &GitLab{
+		registryHost:   registryHost,
+		groups:         make(map[int64]*gitlabCore.Group),
+		projects:       make(map[int64]*gitlabCore.Project),
+		repositories:   make(map[int64][]*repository),
+		packages:       make(map[int64][]*storedPackage),
+		labels:         make(map[int64][]*gitlabCore.GroupLabel),
+		avatars:        make(map[int64][]byte),
+		users:          make(map[int64]*gitlabCore.User),
+		groupMembers:   make(map[int64]map[int64]*gitlabCore.GroupMember),
+		projectMembers: make(map[int64]map[int64]*gitlabCore.ProjectMember),
+		pipelines:      make(map[int64][]*gitlabCore.PipelineInfo),
+		storage:        make(map[string]*gitlab.NamespaceStorage),
+		projectSizes:   make(map[int64]int64),
+		TokenScopes:    []string{"api"},
+	}
+	g.CurrentUser = g.AddUser("migraptor")
+	g.CurrentUser.IsAdmin = true
+	return g
+}
+
+// RegistryHost returns the host serving the container registry
+func (g *GitLab) RegistryHost() string {
+	return g.registryHost
+}
+
+func (g *GitLab) newID() int64 {
+	g.nextID++
+	return g.nextID
+}
+
+func response(statusCode int) *gitlabCore.Response {
+	return &gitlabCore.Response{Response: &http.Response{StatusCode: statusCode}}
+}
+
+func notFound(format string, args ...interface{}) error {
+	return fmt.Errorf("%s: %w", fmt.Sprintf(format, args...), gitlabCore.ErrNotFound)
+}
+
+// apiError is an error returned by the API with a status code other than 404
+type apiError struct {
+	statusCode int
+	message    string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.statusCode, http.StatusText(e.statusCode), e.message)
+}
+
+func badRequest(format string, args ...interface{}) error {
+	return &apiError{statusCode: http.StatusBadRequest, message: fmt.Sprintf(format, args...)}
+}
+
+func conflict(format string, args ...interface{}) error {
+	return &apiError{statusCode: http.StatusConflict, message: fmt.Sprintf(format, args...)}
+}
+
+// Seeding and inspection
+
+// AddUser creates an active user
+func (g *GitLab) AddUser(username string) *gitlabCore.User {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	user := &gitlabCore.User{ID: g.newID(), Username: username, Name: username, State: "active"}
+	g.users[user.ID] = user
+	return user
+}
+
+// AddGroup creates a group and its missing ancestors, and returns it
+func (g *GitLab) AddGroup(fullPath string) *gitlabCore.Group {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return copyGroup(g.ensureGroup(strings.Trim(fullPath, "/")))
+}
+
+func (g *GitLab) ensureGroup(fullPath string) *gitlabCore.Group {
+	if group := g.groupByPath(fullPath); group != nil {
+		return group
+	}
+
+	var parentID int64
+	path := fullPath
+	if idx := strings.LastIndex(fullPath, "/"); idx >= 0 {
+		parentID = g.ensureGroup(fullPath[:idx]).ID
+		path = fullPath[idx+1:]
+	}
+
+	group := &gitlabCore.Group{
+		ID:         g.newID(),
+		Name:       path,
+		Path:       path,
+		FullPath:   fullPath,
+		ParentID:   parentID,
+		Visibility: gitlabCore.PrivateVisibility,
+	}
+	g.groups[group.ID] = group
+	return group
+}
+
+// AddProject creates a project with its container registry enabled, and the missing groups of its namespace
+func (g *GitLab) AddProject(fullPath string) *gitlabCore.Project {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	fullPath = strings.Trim(fullPath, "/")
+	idx := strings.LastIndex(fullPath, "/")
+	namespace := g.ensureGroup(fullPath[:idx])
+	path := fullPath[idx+1:]
+
+	now := time.Now()
+	project := &gitlabCore.Project{
+		ID:                       g.newID(),
+		Name:                     path,
+		Path:                     path,
+		PathWithNamespace:        fullPath,
+		ContainerRegistryEnabled: true,
+		Namespace:                namespaceOf(namespace),
+		LastActivityAt:           &now,
+	}
+	g.projects[project.ID] = project
+	return copyProject(project)
+}
+
+// AddImage pushes an image of the given size to the registry, as if it was built by a pipeline
+func (g *GitLab) AddImage(imageRef string, size int64) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.pushImage(imageRef, fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(imageRef))), size)
+}
+
+// AddPipeline adds a pipeline with the given status on a project
+func (g *GitLab) AddPipeline(projectID int64, status string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.pipelines[projectID] = append(g.pipelines[projectID], &gitlabCore.PipelineInfo{ID: g.newID(), ProjectID: projectID, Status: status})
+}
+
+// SetNamespaceStorage sets the storage used by a root namespace and its limit
+func (g *GitLab) SetNamespaceStorage(fullPath string, used, limit int64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.storage[fullPath] = &gitlab.NamespaceStorage{Used: used, Limit: limit}
+}
+
+// SetProjectStorageSize sets the storage size of a project
+func (g *GitLab) SetProjectStorageSize(projectID int64, size int64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.projectSizes[projectID] = size
+}
+
+// SetProjectTopics sets the topics of a project
+func (g *GitLab) SetProjectTopics(projectID int64, topics ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if project, ok := g.projects[projectID]; ok {
+		project.Topics = topics
+	}
+}
+
+// Group returns the group at the given full path, or nil
+func (g *GitLab) Group(fullPath string) *gitlabCore.Group {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if group := g.groupByPath(fullPath); group != nil {
+		return copyGroup(group)
+	}
+	return nil
+}
+
+// Project returns the project at the given full path, or nil
+func (g *GitLab) Project(fullPath string) *gitlabCore.Project {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if project := g.projectByPath(fullPath); project != nil {
+		return copyProject(project)
+	}
+	return nil
+}
+
+// Images returns the sorted locations of the tags stored in the registry of a project
+func (g *GitLab) Images(projectFullPath string) []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	project := g.projectByPath(projectFullPath)
+	if project == nil {
+		return nil
+	}
+
+	var images []string
+	for _, repo := range g.repositories[project.ID] {
+		for _, tag := range repo.tags {
+			images = append(images, tag.Location)
+		}
+	}
+	sort.Strings(images)
+	return images
+}
+
+// Internal helpers, called with the lock held
+
+func (g *GitLab) groupByPath(fullPath string) *gitlabCore.Group {
+	fullPath = strings.Trim(fullPath, "/")
+	for _, group := range g.groups {
+		if strings.EqualFold(group.FullPath, fullPath) {
+			return group
+		}
+	}
+	return nil
+}
+
+func (g *GitLab) projectByPath(fullPath string) *gitlabCore.Project {
+	fullPath = strings.Trim(fullPath, "/")
+	for _, project := range g.projects {
+		if strings.EqualFold(project.PathWithNamespace, fullPath) {
+			return project
+		}
+	}
+	return nil
+}
+
+// ancestors returns the group and all its parents
+func (g *GitLab) ancestors(groupID int64) []*gitlabCore.Group {
+	var groups []*gitlabCore.Group
+	for group, ok := g.groups[groupID]; ok; group, ok = g.groups[group.ParentID] {
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// isDescendant returns true if groupID is ancestorID or one of its descendants
+func (g *GitLab) isDescendant(groupID, ancestorID int64) bool {
+	for _, group := range g.ancestors(groupID) {
+		if group.ID == ancestorID {
+			return true
+		}
+	}
+	return false
+}
+
+// pathTaken returns true if a group or a project already uses path in the namespace
+func (g *GitLab) pathTaken(namespace *gitlabCore.Group, path string) bool {
+	fullPath := fmt.Sprintf("%s/%s", namespace.FullPath, path)
+	return g.groupByPath(fullPath) != nil || g.projectByPath(fullPath) != nil
+}
+
+// hasRegistryTags returns true if the project registry still holds tags, which prevents transfers
+func (g *GitLab) hasRegistryTags(projectID int64) bool {
+	for _, repo := range g.repositories[projectID] {
+		if len(repo.tags) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// moveRegistry updates the locations of the registry repositories of a project after a transfer
+func (g *GitLab) moveRegistry(project *gitlabCore.Project, oldPath string) {
+	for _, repo := range g.repositories[project.ID] {
+		repo.registry.Path = project.PathWithNamespace + strings.TrimPrefix(repo.registry.Path, oldPath)
+		repo.registry.Location = fmt.Sprintf("%s/%s", g.registryHost, repo.registry.Path)
+		for _, tag := range repo.tags {
+			tag.Path = fmt.Sprintf("%s:%s", repo.registry.Path, tag.Name)
+			tag.Location = fmt.Sprintf("%s:%s", repo.registry.Location, tag.Name)
+		}
+	}
+}
+
+// parseImage finds the project and repository path of an image reference served by the registry
+func (g *GitLab) parseImage(imageRef string) (*gitlabCore.Project, string, string, error) {
+	rest, ok := strings.CutPrefix(imageRef, g.registryHost+"/")
+	if !ok {
+		return nil, "", "", fmt.Errorf("image %s is not served by registry %s", imageRef, g.registryHost)
+	}
+
+	repoPath, tag := rest, "latest"
+	if idx := strings.LastIndex(rest, ":"); idx > strings.LastIndex(rest, "/") {
+		repoPath, tag = rest[:idx], rest[idx+1:]
+	}
+
+	var found *gitlabCore.Project
+	for _, project := range g.projects {
+		if repoPath != project.PathWithNamespace && !strings.HasPrefix(repoPath, project.PathWithNamespace+"/") {
+			continue
+		}
+		if found == nil || len(project.PathWithNamespace) > len(found.PathWithNamespace) {
+			found = project
+		}
+	}
+	if found == nil {
+		return nil, "", "", notFound("no project for image %s", imageRef)
+	}
+	return found, repoPath, tag, nil
+}
+
+// pushImage stores a tag in the registry, creating its repository if needed
+func (g *GitLab) pushImage(imageRef, digest string, size int64) error {
+	project, repoPath, tagName, err := g.parseImage(imageRef)
+	if err != nil {
+		return err
+	}
+
+	var repo *repository
+	for _, existing := range g.repositories[project.ID] {
+		if existing.registry.Path == repoPath && existing.registry.Status == nil {
+			repo = existing
+			break
+		}
+	}
+	if repo == nil {
+		now := time.Now()
+		repo = &repository{
+			registry: &gitlabCore.RegistryRepository{
+				ID:        g.newID(),
+				Name:      strings.TrimPrefix(strings.TrimPrefix(repoPath, project.PathWithNamespace), "/"),
+				Path:      repoPath,
+				ProjectID: project.ID,
+				Location:  fmt.Sprintf("%s/%s", g.registryHost, repoPath),
+				CreatedAt: &now,
+			},
+			tags: make(map[string]*gitlabCore.RegistryRepositoryTag),
+		}
+		g.repositories[project.ID] = append(g.repositories[project.ID], repo)
+	}
+
+	now := time.Now()
+	repo.tags[tagName] = &gitlabCore.RegistryRepositoryTag{
+		Name:      tagName,
+		Path:      fmt.Sprintf("%s:%s", repoPath, tagName),
+		Location:  fmt.Sprintf("%s:%s", repo.registry.Location, tagName),
+		Digest:    digest,
+		TotalSize: size,
+		CreatedAt: &now,
+	}
+	return nil
+}
+
+// lookupImage returns the digest and size of an image stored in the registry
+func (g *GitLab) lookupImage(imageRef string) (string, int64, error) {
+	project, repoPath, tagName, err := g.parseImage(imageRef)
+	if err != nil {
+		return "", 0, err
+	}
+
+	for _, repo := range g.repositories[project.ID] {
+		if repo.registry.Path != repoPath || repo.registry.Status != nil {
+			continue
+		}
+		if tag, ok := repo.tags[tagName]; ok {
+			return tag.Digest, tag.TotalSize, nil
+		}
+	}
+	return "", 0, notFound("manifest unknown for %s", imageRef)
+}
+
+func (g *GitLab) findRepository(projectID, repositoryID int) (*repository, error) {
+	for _, repo := range g.repositories[int64(projectID)] {
+		if repo.registry.ID == int64(repositoryID) {
+			return repo, nil
+		}
+	}
+	return nil, notFound("registry repository %d of project %d", repositoryID, projectID)
+}
+
+func namespaceOf(group *gitlabCore.Group) *gitlabCore.ProjectNamespace {
+	return &gitlabCore.ProjectNamespace{
+		ID:       group.ID,
+		Name:     group.Name,
+		Path:     group.Path,
+		Kind:     "group",
+		FullPath: group.FullPath,
+		ParentID: group.ParentID,
+	}
+}
+
+func copyGroup(group *gitlabCore.Group) *gitlabCore.Group {
+	c := *group
+	c.SharedWithGroups = append([]gitlabCore.SharedWithGroup(nil), group.SharedWithGroups...)
+	return &c
+}
+
+func copyProject(project *gitlabCore.Project) *gitlabCore.Project {
+	c := *project
+	if project.Namespace != nil {
+		namespace := *project.Namespace
+		c.Namespace = &namespace
+	}
+	c.SharedWithGroups = append([]gitlabCore.ProjectSharedWithGroup(nil), project.SharedWithGroups...)
+	return &c
+}
+
+// Groups
+
+// SearchGroup retrieves a group by its full path
+func (g *GitLab) SearchGroup(ctx context.Context, name string) (*gitlabCore.Group, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	group := g.groupByPath(name)
+	if group == nil {
+		return nil, fmt.Errorf("failed to search groups: %w", notFound("group %s", name))
+	}
+	return copyGroup(group), nil
+}
+
+// GetGroup retrieves a group by ID
+func (g *GitLab) GetGroup(ctx context.Context, groupID int) (*gitlabCore.Group, *gitlabCore.Response, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	group, ok := g.groups[int64(groupID)]
+	if !ok {
+		return nil, response(http.StatusNotFound), notFound("group %d", groupID)
+	}
+	return copyGroup(group), response(http.StatusOK), nil
+}
+
+// CreateGroupWithOptions creates a group, failing if its path is already taken in the parent
+func (g *GitLab) CreateGroupWithOptions(ctx context.Context, opt *gitlabCore.CreateGroupOptions) (*gitlabCore.Group, *gitlabCore.Response, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if opt.Path == nil || *opt.Path == "" {
+		return nil, response(http.StatusBadRequest), badRequest("path is missing")
+	}
+
+	group := &gitlabCore.Group{
+		ID:         g.newID(),
+		Path:       *opt.Path,
+		FullPath:   *opt.Path,
+		Name:       *opt.Path,
+		Visibility: gitlabCore.PrivateVisibility,
+	}
+	if opt.ParentID != nil {
+		parent, ok := g.groups[*opt.ParentID]
+		if !ok {
+			return nil, response(http.StatusNotFound), notFound("parent group %d", *opt.ParentID)
+		}
+		if g.pathTaken(parent, *opt.Path) {
+			return nil, response(http.StatusBadRequest), badRequest("path %s has already been taken in %s", *opt.Path, parent.FullPath)
+		}
+		group.ParentID = parent.ID
+		group.FullPath = fmt.Sprintf("%s/%s", parent.FullPath, *opt.Path)
+	} else if g.groupByPath(*opt.Path) != nil {
+		return nil, response(http.StatusBadRequest), badRequest("path %s has already been taken", *opt.Path)
+	}
+
+	if opt.Name != nil {
+		group.Name = *opt.Name
+	}
+	if opt.Description != nil {
+		group.Description = *opt.Description
+	}
+	if opt.Visibility != nil {
+		group.Visibility = *opt.Visibility
+	}
+	if opt.ProjectCreationLevel != nil {
+		group.ProjectCreationLevel = *opt.ProjectCreationLevel
+	}
+	if opt.Avatar != nil {
+		data, err := io.ReadAll(opt.Avatar.Image)
+		if err != nil {
+			return nil, nil, err
+		}
+		g.avatars[group.ID] = data
+		group.AvatarURL = fmt.Sprintf("/uploads/-/system/group/avatar/%d/%s", group.ID, opt.Avatar.Filename)
+	}
+	if protection := opt.DefaultBranchProtectionDefaults; protection != nil {
+		defaults := &gitlabCore.BranchProtectionDefaults{}
+		if protection.AllowForcePush != nil {
+			defaults.AllowForcePush = *protection.AllowForcePush
+		}
+		if protection.DeveloperCanInitialPush != nil {
+			defaults.DeveloperCanInitialPush = *protection.DeveloperCanInitialPush
+		}
+		group.DefaultBranchProtectionDefaults = defaults
+	}
+
+	g.groups[group.ID] = group
+	return copyGroup(group), response(http.StatusCreated), nil
+}
+
+// UpdateGroup updates the visibility, description and shared runners setting of a group
+func (g *GitLab) UpdateGroup(ctx context.Context, groupID int, opt *gitlabCore.UpdateGroupOptions) (*gitlabCore.Group, *gitlabCore.Response, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	group, ok := g.groups[int64(groupID)]
+	if !ok {
+		return nil, response(http.StatusNotFound), notFound("group %d", groupID)
+	}
+	if opt.Visibility != nil {
+		group.Visibility = *opt.Visibility
+	}
+	if opt.Description != nil {
+		group.Description = *opt.Description
+	}
+	if opt.SharedRunnersSetting != nil {
+		group.SharedRunnersSetting = *opt.SharedRunnersSetting
+	}
+	return copyGroup(group), response(http.StatusOK), nil
+}
+
+// DownloadGroupAvatar downloads the avatar of a group
+func (g *GitLab) DownloadGroupAvatar(ctx context.Context, groupID int) (*bytes.Reader, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	avatar, ok := g.avatars[int64(groupID)]
+	if !ok {
+		return nil, fmt.Errorf("failed to download group avatar: %w", notFound("avatar of group %d", groupID))
+	}
+	return bytes.NewReader(avatar), nil
+}
+
+// ListGroupLabels lists the labels defined on a group
+func (g *GitLab) ListGroupLabels(ctx context.Context, groupID int) ([]*gitlabCore.GroupLabel, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var labels []*gitlabCore.GroupLabel
+	for _, label := range g.labels[int64(groupID)] {
+		c := *label
+		labels = append(labels, &c)
+	}
+	return labels, nil
+}
+
+// CreateGroupLabel creates a label on a group
+func (g *GitLab) CreateGroupLabel(ctx context.Context, groupID int, name, color, description string) (*gitlabCore.GroupLabel, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.groups[int64(groupID)]; !ok {
+		return nil, notFound("group %d", groupID)
+	}
+	for _, label := range g.labels[int64(groupID)] {
+		if label.Name == name {
+			return nil, fmt.Errorf("failed to create group label %s: %w", name, badRequest("label already exists"))
+		}
+	}
+
+	label := &gitlabCore.GroupLabel{ID: g.newID(), Name: name, Color: color, Description: description}
+	g.labels[int64(groupID)] = append(g.labels[int64(groupID)], label)
+	c := *label
+	return &c, nil
+}
+
+// TransferGroup moves a group and its content below another group, as long as no project
+// of the group holds container registry tags, nor packages scoped to the root namespace when it changes
+func (g *GitLab) TransferGroup(ctx context.Context, groupID, targetGroupID int) (*gitlabCore.Response, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	group, ok := g.groups[int64(groupID)]
+	if !ok {
+		return response(http.StatusNotFound), notFound("group %d", groupID)
+	}
+	target, ok := g.groups[int64(targetGroupID)]
+	if !ok {
+		return response(http.StatusNotFound), notFound("group %d", targetGroupID)
+	}
+	if g.isDescendant(target.ID, group.ID) {
+		return response(http.StatusBadRequest), badRequest("cannot transfer group %s to one of its sub-groups", group.FullPath)
+	}
+	if g.pathTaken(target, group.Path) {
+		return response(http.StatusBadRequest), badRequest("path %s has already been taken in %s", group.Path, target.FullPath)
+	}
+	for _, project := range g.projects {
+		if g.isDescendant(project.Namespace.ID, group.ID) && g.hasRegistryTags(project.ID) {
+			return response(http.StatusBadRequest), badRequest("group contains projects with container registry tags")
+		}
+		if g.isDescendant(project.Namespace.ID, group.ID) && rootPath(target.FullPath) != rootPath(group.FullPath) && g.hasRootScopedPackages(project.ID) {
+			return response(http.StatusBadRequest), badRequest("group contains projects with npm packages or Terraform modules scoped to the current root level")
+		}
+	}
+
+	oldPath := group.FullPath
+	newPath := fmt.Sprintf("%s/%s", target.FullPath, group.Path)
+	group.ParentID = target.ID
+	for _, other := range g.groups {
+		if other.FullPath == oldPath || strings.HasPrefix(other.FullPath, oldPath+"/") {
+			other.FullPath = newPath + strings.TrimPrefix(other.FullPath, oldPath)
+		}
+	}
+	for _, project := range g.projects {
+		if !g.isDescendant(project.Namespace.ID, group.ID) {
+			continue
+		}
+		previous := project.PathWithNamespace
+		project.Namespace = namespaceOf(g.groups[project.Namespace.ID])
+		project.PathWithNamespace = fmt.Sprintf("%s/%s", project.Namespace.FullPath, project.Path)
+		g.moveRegistry(project, previous)
+	}
+
+	return response(http.StatusCreated), nil
+}
+
+// GetSubGroups lists the direct sub-groups of a group
+func (g *GitLab) GetSubGroups(ctx context.Context, groupID int64) ([]*gitlabCore.Group, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.groups[groupID]; !ok {
+		return nil, fmt.Errorf("failed to list subgroups: %w", notFound("group %d", groupID))
+	}
+
+	var subGroups []*gitlabCore.Group
+	for _, group := range g.groups {
+		if group.ParentID == groupID {
+			subGroups = append(subGroups, copyGroup(group))
+		}
+	}
+	sort.Slice(subGroups, func(i, j int) bool { return subGroups[i].ID < subGroups[j].ID })
+	return subGroups, nil
+}
+
+// Projects
+
+// ListProjects lists the projects directly in a group
+func (g *GitLab) ListProjects(ctx context.Context, groupID int) ([]*gitlabCore.Project, *gitlabCore.Response, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.groups[int64(groupID)]; !ok {
+		return nil, response(http.StatusNotFound), notFound("group %d", groupID)
+	}
+
+	var projects []*gitlabCore.Project
+	for _, project := range g.projects {
+		if project.Namespace.ID == int64(groupID) {
+			projects = append(projects, copyProject(project))
+		}
+	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].ID < projects[j].ID })
+	return projects, response(http.StatusOK), nil
+}
+
+// GetProject retrieves a project by ID
+func (g *GitLab) GetProject(ctx context.Context, projectID int) (*gitlabCore.Project, *gitlabCore.Response, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	project, ok := g.projects[int64(projectID)]
+	if !ok {
+		return nil, response(http.StatusNotFound), notFound("project %d", projectID)
+	}
+	return copyProject(project), response(http.StatusOK), nil
+}
+
+// GetProjectByPath retrieves a project by its full path, returning nil if it does not exist
+func (g *GitLab) GetProjectByPath(ctx context.Context, fullPath string) (*gitlabCore.Project, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if project := g.projectByPath(fullPath); project != nil {
+		return copyProject(project), nil
+	}
+	return nil, nil
+}
+
+// TransferProject moves a project to another group, as long as its container registry holds no tags,
+// nor its package registry npm packages or Terraform modules when the root namespace changes
+func (g *GitLab) TransferProject(ctx context.Context, projectID, namespaceID int) (*gitlabCore.Response, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	project, ok := g.projects[int64(projectID)]
+	if !ok {
+		return response(http.StatusNotFound), fmt.Errorf("failed to transfer project: %w", notFound("project %d", projectID))
+	}
+	target, ok := g.groups[int64(namespaceID)]
+	if !ok {
+		return response(http.StatusNotFound), fmt.Errorf("failed to transfer project: %w", notFound("namespace %d", namespaceID))
+	}
+	if g.hasRegistryTags(project.ID) {
+		return response(http.StatusBadRequest), fmt.Errorf("failed to transfer project: %w",
+			badRequest("project cannot be transferred, because tags are present in its container registry"))
+	}
+	if rootPath(target.FullPath) != rootPath(project.PathWithNamespace) && g.hasRootScopedPackages(project.ID) {
+		return response(http.StatusBadRequest), fmt.Errorf("failed to transfer project: %w",
+			badRequest("root namespace cannot be changed, because the project has npm packages or Terraform modules"))
+	}
+	if g.pathTaken(target, project.Path) {
+		return response(http.StatusBadRequest), fmt.Errorf("failed to transfer project: %w",
+			badRequest("path %s has already been taken in %s", project.Path, target.FullPath))
+	}
+
+	previous := project.PathWithNamespace
+	project.Namespace = namespaceOf(target)
+	project.PathWithNamespace = fmt.Sprintf("%s/%s", target.FullPath, project.Path)
+	g.moveRegistry(project, previous)
+
+	return response(http.StatusOK), nil
+}
+
+// ArchiveProject archives a project
+func (g *GitLab) ArchiveProject(ctx context.Context, projectID int) (*gitlabCore.Response, error) {
+	return g.setArchived(projectID, true)
+}
+
+// UnarchiveProject unarchives a project
+func (g *GitLab) UnarchiveProject(ctx context.Context, projectID int) (*gitlabCore.Response, error) {
+	return g.setArchived(projectID, false)
+}
+
+func (g *GitLab) setArchived(projectID int, archived bool) (*gitlabCore.Response, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	project, ok := g.projects[int64(projectID)]
+	if !ok {
+		return response(http.StatusNotFound), notFound("project %d", projectID)
+	}
+	project.Archived = archived
+	return response(http.StatusCreated), nil
+}
+
+// Container registry
+
+// ListRegistryRepositories lists the registry repositories of a project. Repositories scheduled
+// for deletion are listed until RegistryDeletionDelay listings have been done.
+func (g *GitLab) ListRegistryRepositories(ctx context.Context, projectID int) ([]*gitlabCore.RegistryRepository, *gitlabCore.Response, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.projects[int64(projectID)]; !ok {
+		return nil, response(http.StatusNotFound), notFound("project %d", projectID)
+	}
+
+	var kept []*repository
+	var repositories []*gitlabCore.RegistryRepository
+	for _, repo := range g.repositories[int64(projectID)] {
+		if repo.registry.Status != nil {
+			if repo.pendingListings <= 0 {
+				continue
+			}
+			repo.pendingListings--
+		}
+		kept = append(kept, repo)
+		c := *repo.registry
+		c.TagsCount = int64(len(repo.tags))
+		repositories = append(repositories, &c)
+	}
+	g.repositories[int64(projectID)] = kept
+	return repositories, response(http.StatusOK), nil
+}
+
+// ListRegistryRepositoryTags lists the tags of a registry repository, sorted by name
+func (g *GitLab) ListRegistryRepositoryTags(ctx context.Context, projectID, repositoryID int) ([]*gitlabCore.RegistryRepositoryTag, *gitlabCore.Response, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	repo, err := g.findRepository(projectID, repositoryID)
+	if err != nil {
+		return nil, response(http.StatusNotFound), err
+	}
+	if err := g.TagListErrors[repo.registry.Path]; err != nil {
+		return nil, response(http.StatusInternalServerError), err
+	}
+
+	var tags []*gitlabCore.RegistryRepositoryTag
+	for _, tag := range repo.tags {
+		// The list endpoint does not return digests and sizes
+		tags = append(tags, &gitlabCore.RegistryRepositoryTag{Name: tag.Name, Path: tag.Path, Location: tag.Location})
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags, response(http.StatusOK), nil
+}
+
+// GetRegistryRepositoryTagDetail gets the digest and size of a registry repository tag
+func (g *GitLab) GetRegistryRepositoryTagDetail(ctx context.Context, projectID, repositoryID int, tagName string) (*gitlabCore.RegistryRepositoryTag, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	repo, err := g.findRepository(projectID, repositoryID)
+	if err != nil {
+		return nil, err
+	}
+	tag, ok := repo.tags[tagName]
+	if !ok {
+		return nil, fmt.Errorf("failed to get details of tag %s: %w", tagName, notFound("tag %s", tagName))
+	}
+	c := *tag
+	return &c, nil
+}
+
+// DeleteRegistryRepository schedules the deletion of a registry repository and its tags
+func (g *GitLab) DeleteRegistryRepository(ctx context.Context, projectID, repositoryID int) (*gitlabCore.Response, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	repo, err := g.findRepository(projectID, repositoryID)
+	if err != nil {
+		return response(http.StatusNotFound), fmt.Errorf("failed to delete registry repository: %w", err)
+	}
+
+	status := gitlabCore.ContainerRegistryStatusDeleteScheduled
+	repo.registry.Status = &status
+	repo.pendingListings = g.RegistryDeletionDelay
+	if repo.pendingListings <= 0 {
+		repo.tags = make(map[string]*gitlabCore.RegistryRepositoryTag)
+	}
+	return response(http.StatusAccepted), nil
+}
+
+// DeleteRegistryRepositoryTag deletes a tag from a registry repository
+func (g *GitLab) DeleteRegistryRepositoryTag(ctx context.Context, projectID, repositoryID int, tagName string) (*gitlabCore.Response, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	repo, err := g.findRepository(projectID, repositoryID)
+	if err != nil {
+		return response(http.StatusNotFound), fmt.Errorf("failed to delete registry repository tag: %w", err)
+	}
+	if _, ok := repo.tags[tagName]; !ok {
+		return response(http.StatusNotFound), fmt.Errorf("failed to delete registry repository tag: %w", notFound("tag %s", tagName))
+	}
+	delete(repo.tags, tagName)
+	return response(http.StatusOK), nil
+}
+
+// Members
+
+func (g *GitLab) groupMember(userID int64, accessLevel gitlabCore.AccessLevelValue, expiresAt *gitlabCore.ISOTime) *gitlabCore.GroupMember {
+	user := g.users[userID]
+	return &gitlabCore.GroupMember{
+		ID:          user.ID,
+		Username:    user.Username,
+		Name:        user.Name,
+		State:       user.State,
+		AccessLevel: accessLevel,
+		ExpiresAt:   expiresAt,
+	}
+}
+
+func parseExpiresAt(expiresAt string) (*gitlabCore.ISOTime, error) {
+	if expiresAt == "" {
+		return nil, nil
+	}
+	parsed, err := gitlabCore.ParseISOTime(expiresAt)
+	if err != nil {
+		return nil, badRequest("invalid expiration date %s", expiresAt)
+	}
+	return &parsed, nil
+}
+
+// inheritedGroupMembers returns the members of a group and of its ancestors, keeping the highest access level
+func (g *GitLab) inheritedGroupMembers(groupID int64) map[int64]*gitlabCore.GroupMember {
+	members := make(map[int64]*gitlabCore.GroupMember)
+	for _, group := range g.ancestors(groupID) {
+		for userID, member := range g.groupMembers[group.ID] {
+			if existing, ok := members[userID]; !ok || existing.AccessLevel < member.AccessLevel {
+				members[userID] = member
+			}
+		}
+	}
+	return members
+}
+
+func sortedGroupMembers(members map[int64]*gitlabCore.GroupMember) []*gitlabCore.GroupMember {
+	var list []*gitlabCore.GroupMember
+	for _, member := range members {
+		c := *member
+		list = append(list, &c)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// ListGroupMembers lists the direct members of a group, including the ones of its ancestors if inherited is true
+func (g *GitLab) ListGroupMembers(ctx context.Context, groupID int, inherited bool) ([]*gitlabCore.GroupMember, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.groups[int64(groupID)]; !ok {
+		return nil, notFound("group %d", groupID)
+	}
+	if inherited {
+		return sortedGroupMembers(g.inheritedGroupMembers(int64(groupID))), nil
+	}
+	return sortedGroupMembers(g.groupMembers[int64(groupID)]), nil
+}
+
+// AddGroupMember adds a user as direct member of a group
+func (g *GitLab) AddGroupMember(ctx context.Context, groupID int, userID int64, accessLevel gitlabCore.AccessLevelValue, expiresAt string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.groups[int64(groupID)]; !ok {
+		return notFound("group %d", groupID)
+	}
+	if _, ok := g.users[userID]; !ok {
+		return notFound("user %d", userID)
+	}
+	if _, ok := g.groupMembers[int64(groupID)][userID]; ok {
+		return conflict("member already exists")
+	}
+	expires, err := parseExpiresAt(expiresAt)
+	if err != nil {
+		return err
+	}
+
+	if g.groupMembers[int64(groupID)] == nil {
+		g.groupMembers[int64(groupID)] = make(map[int64]*gitlabCore.GroupMember)
+	}
+	g.groupMembers[int64(groupID)][userID] = g.groupMember(userID, accessLevel, expires)
+	return nil
+}
+
+// EditGroupMember changes the access level of a direct member of a group
+func (g *GitLab) EditGroupMember(ctx context.Context, groupID int, userID int64, accessLevel gitlabCore.AccessLevelValue, expiresAt string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	member, ok := g.groupMembers[int64(groupID)][userID]
+	if !ok {
+		return notFound("member %d of group %d", userID, groupID)
+	}
+	expires, err := parseExpiresAt(expiresAt)
+	if err != nil {
+		return err
+	}
+	member.AccessLevel = accessLevel
+	member.ExpiresAt = expires
+	return nil
+}
+
+// ListProjectMembers lists the direct members of a project, including the ones of its namespace if inherited is true
+func (g *GitLab) ListProjectMembers(ctx context.Context, projectID int, inherited bool) ([]*gitlabCore.ProjectMember, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	project, ok := g.projects[int64(projectID)]
+	if !ok {
+		return nil, notFound("project %d", projectID)
+	}
+
+	members := make(map[int64]*gitlabCore.ProjectMember)
+	for userID, member := range g.projectMembers[project.ID] {
+		c := *member
+		members[userID] = &c
+	}
+	if inherited {
+		for userID, member := range g.inheritedGroupMembers(project.Namespace.ID) {
+			if existing, ok := members[userID]; ok && existing.AccessLevel >= member.AccessLevel {
+				continue
+			}
+			members[userID] = &gitlabCore.ProjectMember{
+				ID:          member.ID,
+				Username:    member.Username,
+				Name:        member.Name,
+				State:       member.State,
+				AccessLevel: member.AccessLevel,
+				ExpiresAt:   member.ExpiresAt,
+			}
+		}
+	}
+
+	var list []*gitlabCore.ProjectMember
+	for _, member := range members {
+		list = append(list, member)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+// AddProjectMember adds a user as direct member of a project
+func (g *GitLab) AddProjectMember(ctx context.Context, projectID int, userID int64, accessLevel gitlabCore.AccessLevelValue, expiresAt string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.projects[int64(projectID)]; !ok {
+		return notFound("project %d", projectID)
+	}
+	user, ok := g.users[userID]
+	if !ok {
+		return notFound("user %d", userID)
+	}
+	if _, ok := g.projectMembers[int64(projectID)][userID]; ok {
+		return conflict("member already exists")
+	}
+	expires, err := parseExpiresAt(expiresAt)
+	if err != nil {
+		return err
+	}
+
+	if g.projectMembers[int64(projectID)] == nil {
+		g.projectMembers[int64(projectID)] = make(map[int64]*gitlabCore.ProjectMember)
+	}
+	g.projectMembers[int64(projectID)][userID] = &gitlabCore.ProjectMember{
+		ID:          user.ID,
+		Username:    user.Username,
+		Name:        user.Name,
+		State:       user.State,
+		AccessLevel: accessLevel,
+		ExpiresAt:   expires,
+	}
+	return nil
+}
+
+// EditProjectMember changes the access level of a direct member of a project
+func (g *GitLab) EditProjectMember(ctx context.Context, projectID int, userID int64, accessLevel gitlabCore.AccessLevelValue, expiresAt string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	member, ok := g.projectMembers[int64(projectID)][userID]
+	if !ok {
+		return notFound("member %d of project %d", userID, projectID)
+	}
+	expires, err := parseExpiresAt(expiresAt)
+	if err != nil {
+		return err
+	}
+	member.AccessLevel = accessLevel
+	member.ExpiresAt = expires
+	return nil
+}
+
+// ShareProjectWithGroup shares a project with a group
+func (g *GitLab) ShareProjectWithGroup(ctx context.Context, projectID int, groupID int64, accessLevel gitlabCore.AccessLevelValue, expiresAt string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	project, ok := g.projects[int64(projectID)]
+	if !ok {
+		return notFound("project %d", projectID)
+	}
+	group, ok := g.groups[groupID]
+	if !ok {
+		return notFound("group %d", groupID)
+	}
+	expires, err := parseExpiresAt(expiresAt)
+	if err != nil {
+		return err
+	}
+
+	project.SharedWithGroups = append(project.SharedWithGroups, gitlabCore.ProjectSharedWithGroup{
+		GroupID:          group.ID,
+		GroupName:        group.Name,
+		GroupFullPath:    group.FullPath,
+		GroupAccessLevel: int64(accessLevel),
+		ExpiresAt:        expires,
+	})
+	return nil
+}
+
+// ShareGroupWithGroup shares a group with another group
+func (g *GitLab) ShareGroupWithGroup(ctx context.Context, groupID int, sharedWithGroupID int64, accessLevel gitlabCore.AccessLevelValue, expiresAt *gitlabCore.ISOTime) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	group, ok := g.groups[int64(groupID)]
+	if !ok {
+		return notFound("group %d", groupID)
+	}
+	shared, ok := g.groups[sharedWithGroupID]
+	if !ok {
+		return notFound("group %d", sharedWithGroupID)
+	}
+
+	group.SharedWithGroups = append(group.SharedWithGroups, gitlabCore.SharedWithGroup{
+		GroupID:          shared.ID,
+		GroupName:        shared.Name,
+		GroupFullPath:    shared.FullPath,
+		GroupAccessLevel: int64(accessLevel),
+		ExpiresAt:        expiresAt,
+	})
+	return nil
+}
+
+// Preflight
+
+// GetCurrentUser returns the owner of the token
+func (g *GitLab) GetCurrentUser(ctx context.Context) (*gitlabCore.User, *gitlabCore.Response, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	c := *g.CurrentUser
+	return &c, response(http.StatusOK), nil
+}
+
+// CheckConnection always succeeds
+func (g *GitLab) CheckConnection(ctx context.Context) error {
+	return nil
+}
+
+// GetTokenScopes returns the scopes of the token
+func (g *GitLab) GetTokenScopes(ctx context.Context) ([]string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return append([]string(nil), g.TokenScopes...), nil
+}
+
+// GetGroupAccessLevel returns the highest access level of a user on a group, including inherited membership
+func (g *GitLab) GetGroupAccessLevel(ctx context.Context, groupID int, userID int64) (gitlabCore.AccessLevelValue, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if member, ok := g.inheritedGroupMembers(int64(groupID))[userID]; ok {
+		return member.AccessLevel, nil
+	}
+	return gitlabCore.NoPermissions, nil
+}
+
+// GetProjectAccessLevel returns the highest access level of a user on a project, including inherited membership
+func (g *GitLab) GetProjectAccessLevel(ctx context.Context, projectID int, userID int64) (gitlabCore.AccessLevelValue, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	project, ok := g.projects[int64(projectID)]
+	if !ok {
+		return gitlabCore.NoPermissions, notFound("project %d", projectID)
+	}
+
+	level := gitlabCore.NoPermissions
+	if member, ok := g.projectMembers[project.ID][userID]; ok {
+		level = member.AccessLevel
+	}
+	if member, ok := g.inheritedGroupMembers(project.Namespace.ID)[userID]; ok && member.AccessLevel > level {
+		level = member.AccessLevel
+	}
+	return level, nil
+}
+
+// ListActivePipelines lists the running and pending pipelines of a project
+func (g *GitLab) ListActivePipelines(ctx context.Context, projectID int) ([]*gitlabCore.PipelineInfo, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var pipelines []*gitlabCore.PipelineInfo
+	for _, pipeline := range g.pipelines[int64(projectID)] {
+		if pipeline.Status == "running" || pipeline.Status == "pending" {
+			c := *pipeline
+			pipelines = append(pipelines, &c)
+		}
+	}
+	return pipelines, nil
+}
+
+// GetNamespaceStorage returns the storage used by a root namespace and its limit (0 if unlimited)
+func (g *GitLab) GetNamespaceStorage(ctx context.Context, fullPath string) (*gitlab.NamespaceStorage, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.groupByPath(fullPath) == nil {
+		return nil, notFound("namespace %s", fullPath)
+	}
+	if storage, ok := g.storage[fullPath]; ok {
+		c := *storage
+		return &c, nil
+	}
+	return &gitlab.NamespaceStorage{}, nil
+}
+
+// GetProjectStorageSize returns the storage size of a project
+func (g *GitLab) GetProjectStorageSize(ctx context.Context, projectID int) (int64, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.projects[int64(projectID)]; !ok {
+		return 0, notFound("project %d", projectID)
+	}
+	return g.projectSizes[int64(projectID)], nil
+}
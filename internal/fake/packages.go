@@ -0,0 +1,249 @@
+package fake
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	gitlabCore "gitlab.com/gitlab-org/api/client-go"
+)
+
+// storedPackage is a package of a project's package registry with the content of its files
+type storedPackage struct {
+	pkg      *gitlabCore.Package
+	files    []*gitlabCore.PackageFile
+	contents map[int64][]byte
+}
+
+// AddPackage publishes a package with the given files to the package registry of a project
+func (g *GitLab) AddPackage(projectFullPath, packageType, name, version string, files map[string][]byte) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	project := g.projectByPath(projectFullPath)
+	if project == nil {
+		return notFound("project %s", projectFullPath)
+	}
+	fileNames := make([]string, 0, len(files))
+	for fileName := range files {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+	for _, fileName := range fileNames {
+		g.storePackageFile(project.ID, packageType, name, version, fileName, files[fileName])
+	}
+	return nil
+}
+
+// Packages returns the sorted packages of a project, as "<type> <name> <version>"
+func (g *GitLab) Packages(projectFullPath string) []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	project := g.projectByPath(projectFullPath)
+	if project == nil {
+		return nil
+	}
+	var packages []string
+	for _, stored := range g.packages[project.ID] {
+		packages = append(packages, fmt.Sprintf("%s %s %s", stored.pkg.PackageType, stored.pkg.Name, stored.pkg.Version))
+	}
+	sort.Strings(packages)
+	return packages
+}
+
+// PackageFile returns the content of a file of a package of a project
+func (g *GitLab) PackageFile(projectFullPath, name, version, fileName string) ([]byte, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	project := g.projectByPath(projectFullPath)
+	if project == nil {
+		return nil, false
+	}
+	for _, stored := range g.packages[project.ID] {
+		if stored.pkg.Name != name || stored.pkg.Version != version {
+			continue
+		}
+		for _, file := range stored.files {
+			if file.FileName == fileName {
+				return stored.contents[file.ID], true
+			}
+		}
+	}
+	return nil, false
+}
+
+// storePackageFile adds a file to a package, creating the package if needed
+func (g *GitLab) storePackageFile(projectID int64, packageType, name, version, fileName string, content []byte) {
+	var stored *storedPackage
+	for _, existing := range g.packages[projectID] {
+		if existing.pkg.PackageType == packageType && existing.pkg.Name == name && existing.pkg.Version == version {
+			stored = existing
+		}
+	}
+	if stored == nil {
+		stored = &storedPackage{
+			pkg:      &gitlabCore.Package{ID: g.newID(), Name: name, Version: version, PackageType: packageType, Status: "default"},
+			contents: make(map[int64][]byte),
+		}
+		g.packages[projectID] = append(g.packages[projectID], stored)
+	}
+
+	file := &gitlabCore.PackageFile{
+		ID:         g.newID(),
+		PackageID:  stored.pkg.ID,
+		FileName:   fileName,
+		Size:       int64(len(content)),
+		FileSHA256: fmt.Sprintf("%x", sha256.Sum256(content)),
+	}
+	stored.files = append(stored.files, file)
+	stored.contents[file.ID] = content
+}
+
+// findPackage returns a package of a project
+func (g *GitLab) findPackage(projectID int, packageID int64) (*storedPackage, error) {
+	if _, ok := g.projects[int64(projectID)]; !ok {
+		return nil, notFound("project %d", projectID)
+	}
+	for _, stored := range g.packages[int64(projectID)] {
+		if stored.pkg.ID == packageID {
+			return stored, nil
+		}
+	}
+	return nil, notFound("package %d", packageID)
+}
+
+// lookupPackageFile finds a file of a package of a project by name, whatever the package version if version is empty
+func (g *GitLab) lookupPackageFile(projectID int, packageType, name, version, fileName string) (*gitlabCore.Package, *gitlabCore.PackageFile, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, stored := range g.packages[int64(projectID)] {
+		if stored.pkg.PackageType != packageType || stored.pkg.Name != name || (version != "" && stored.pkg.Version != version) {
+			continue
+		}
+		for _, file := range stored.files {
+			if file.FileName == fileName {
+				pkg, f := *stored.pkg, *file
+				return &pkg, &f, nil
+			}
+		}
+	}
+	return nil, nil, notFound("package file %s", fileName)
+}
+
+// hasRootScopedPackages returns true if the project holds npm packages or Terraform modules, which are scoped
+// to the root namespace and prevent transfers to another one
+func (g *GitLab) hasRootScopedPackages(projectID int64) bool {
+	return slices.ContainsFunc(g.packages[projectID], func(stored *storedPackage) bool {
+		return stored.pkg.PackageType == "npm" || stored.pkg.PackageType == "terraform_module"
+	})
+}
+
+// rootPath returns the first segment of a full path
+func rootPath(fullPath string) string {
+	root, _, _ := strings.Cut(fullPath, "/")
+	return root
+}
+
+// Package registry API
+
+// ListProjectPackages lists the packages of a project
+func (g *GitLab) ListProjectPackages(ctx context.Context, projectID int) ([]*gitlabCore.Package, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.projects[int64(projectID)]; !ok {
+		return nil, fmt.Errorf("failed to list packages of project %d: %w", projectID, notFound("project %d", projectID))
+	}
+	var packages []*gitlabCore.Package
+	for _, stored := range g.packages[int64(projectID)] {
+		pkg := *stored.pkg
+		packages = append(packages, &pkg)
+	}
+	return packages, nil
+}
+
+// ListPackageFiles lists the files of a package
+func (g *GitLab) ListPackageFiles(ctx context.Context, projectID int, packageID int64) ([]*gitlabCore.PackageFile, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	stored, err := g.findPackage(projectID, packageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files of package %d: %w", packageID, err)
+	}
+	files := make([]*gitlabCore.PackageFile, 0, len(stored.files))
+	for _, file := range stored.files {
+		f := *file
+		files = append(files, &f)
+	}
+	return files, nil
+}
+
+// DownloadPackageFile writes the content of a package file to w
+func (g *GitLab) DownloadPackageFile(ctx context.Context, projectID int, pkg *gitlabCore.Package, file *gitlabCore.PackageFile, w io.Writer) error {
+	g.mu.Lock()
+	stored, err := g.findPackage(projectID, pkg.ID)
+	var content []byte
+	if err == nil {
+		var ok bool
+		if content, ok = stored.contents[file.ID]; !ok {
+			err = notFound("file %s", file.FileName)
+		}
+	}
+	g.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to download %s of package %s %s: %w", file.FileName, pkg.Name, pkg.Version, err)
+	}
+
+	_, err = io.Copy(w, bytes.NewReader(content))
+	return err
+}
+
+// PublishPackageFile adds a file to a package of a project, creating the package if needed.
+// As on GitLab, an npm version cannot be published twice.
+func (g *GitLab) PublishPackageFile(ctx context.Context, projectID int, pkg *gitlabCore.Package, fileName string, content io.Reader) error {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.projects[int64(projectID)]; !ok {
+		return fmt.Errorf("failed to publish %s of package %s %s: %w", fileName, pkg.Name, pkg.Version, notFound("project %d", projectID))
+	}
+	if pkg.PackageType == "npm" {
+		for _, existing := range g.packages[int64(projectID)] {
+			if existing.pkg.PackageType == "npm" && existing.pkg.Name == pkg.Name && existing.pkg.Version == pkg.Version {
+				return fmt.Errorf("failed to publish %s of package %s %s: %w", fileName, pkg.Name, pkg.Version,
+					&apiError{statusCode: http.StatusForbidden, message: "package already exists"})
+			}
+		}
+	}
+	g.storePackageFile(int64(projectID), pkg.PackageType, pkg.Name, pkg.Version, fileName, data)
+	return nil
+}
+
+// DeleteProjectPackage deletes a package and its files
+func (g *GitLab) DeleteProjectPackage(ctx context.Context, projectID int, packageID int64) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, err := g.findPackage(projectID, packageID); err != nil {
+		return fmt.Errorf("failed to delete package %d: %w", packageID, err)
+	}
+	g.packages[int64(projectID)] = slices.DeleteFunc(g.packages[int64(projectID)], func(stored *storedPackage) bool {
+		return stored.pkg.ID == packageID
+	})
+	return nil
+}
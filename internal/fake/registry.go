@@ -0,0 +1,524 @@
+package fake
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	ociIndexMediaType    = "application/vnd.oci.image.index.v1+json"
+	ociManifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+	ociConfigMediaType   = "application/vnd.oci.image.config.v1+json"
+	ociLayerMediaType    = "application/vnd.oci.image.layer.v1.tar+gzip"
+	ociEmptyMediaType    = "application/vnd.oci.empty.v1+json"
+)
+
+// manifest is a stored image manifest with its media type
+type manifest struct {
+	mediaType string
+	content   []byte
+}
+
+// descriptor references a blob or a manifest from a manifest
+type descriptor struct {
+	MediaType    string    `json:"mediaType"`
+	Digest       string    `json:"digest"`
+	Size         int64     `json:"size"`
+	ArtifactType string    `json:"artifactType,omitempty"`
+	Platform     *platform `json:"platform,omitempty"`
+}
+
+// platform is the platform of a manifest listed in an index
+type platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+}
+
+// imageManifest holds the fields of an image manifest or index needed to compute its size and find its subject
+type imageManifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType,omitempty"`
+	ArtifactType  string       `json:"artifactType,omitempty"`
+	Config        descriptor   `json:"config,omitzero"`
+	Layers        []descriptor `json:"layers,omitempty"`
+	Manifests     []descriptor `json:"manifests,omitempty"`
+	Subject       *descriptor  `json:"subject,omitempty"`
+}
+
+// registry is a minimal OCI distribution registry storing blobs and manifests in memory.
+// Tags are stored in the fake GitLab so that the registry API of GitLab and the registry stay consistent.
+type registry struct {
+	mu        sync.Mutex
+	gitlab    *GitLab
+	blobs     map[string][]byte
+	manifests map[string]manifest
+	uploads   map[string]*bytes.Buffer
+	// referrers are the descriptors of the manifests referring to a manifest, keyed by subject digest
+	referrers map[string][]descriptor
+}
+
+func newRegistry(gitlab *GitLab) *registry {
+	return &registry{
+		gitlab:    gitlab,
+		blobs:     make(map[string][]byte),
+		manifests: make(map[string]manifest),
+		uploads:   make(map[string]*bytes.Buffer),
+		referrers: make(map[string][]descriptor),
+	}
+}
+
+func digestOf(content []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(content))
+}
+
+func registryError(w http.ResponseWriter, statusCode int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": []map[string]string{{"code": code, "message": message}},
+	})
+}
+
+// ServeHTTP handles the /v2/ endpoints used to pull and push images
+func (r *registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	if path == "" {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("{}"))
+		return
+	}
+
+	if name, reference, ok := strings.Cut(path, "/manifests/"); ok {
+		switch req.Method {
+		case http.MethodGet, http.MethodHead:
+			r.getManifest(w, req, name, reference)
+		case http.MethodPut:
+			r.putManifest(w, req, name, reference)
+		default:
+			registryError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "method not allowed")
+		}
+		return
+	}
+
+	if name, upload, ok := strings.Cut(path, "/blobs/uploads/"); ok {
+		switch req.Method {
+		case http.MethodPost:
+			r.startUpload(w, req, name)
+		case http.MethodPatch:
+			r.patchUpload(w, req, name, upload)
+		case http.MethodPut:
+			r.finishUpload(w, req, name, upload)
+		default:
+			registryError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "method not allowed")
+		}
+		return
+	}
+
+	if _, digest, ok := strings.Cut(path, "/referrers/"); ok && req.Method == http.MethodGet {
+		r.getReferrers(w, digest)
+		return
+	}
+
+	if _, digest, ok := strings.Cut(path, "/blobs/"); ok && (req.Method == http.MethodGet || req.Method == http.MethodHead) {
+		r.getBlob(w, req, digest)
+		return
+	}
+
+	registryError(w, http.StatusNotFound, "UNSUPPORTED", "unsupported endpoint")
+}
+
+// resolve returns the digest of a manifest reference, either a digest or a tag stored in GitLab
+func (r *registry) resolve(name, reference string) (string, error) {
+	if strings.HasPrefix(reference, "sha256:") {
+		return reference, nil
+	}
+
+	r.gitlab.mu.Lock()
+	defer r.gitlab.mu.Unlock()
+
+	digest, _, err := r.gitlab.lookupImage(fmt.Sprintf("%s/%s:%s", r.gitlab.registryHost, name, reference))
+	return digest, err
+}
+
+func (r *registry) getManifest(w http.ResponseWriter, req *http.Request, name, reference string) {
+	digest, err := r.resolve(name, reference)
+	if err != nil {
+		registryError(w, http.StatusNotFound, "MANIFEST_UNKNOWN", err.Error())
+		return
+	}
+
+	r.mu.Lock()
+	stored, ok := r.manifests[digest]
+	r.mu.Unlock()
+	if !ok {
+		registryError(w, http.StatusNotFound, "MANIFEST_UNKNOWN", fmt.Sprintf("manifest %s unknown", digest))
+		return
+	}
+
+	w.Header().Set("Content-Type", stored.mediaType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(stored.content)))
+	w.Header().Set("Docker-Content-Digest", digest)
+	w.WriteHeader(http.StatusOK)
+	if req.Method == http.MethodGet {
+		_, _ = w.Write(stored.content)
+	}
+}
+
+func (r *registry) putManifest(w http.ResponseWriter, req *http.Request, name, reference string) {
+	content, err := io.ReadAll(req.Body)
+	if err != nil {
+		registryError(w, http.StatusBadRequest, "MANIFEST_INVALID", err.Error())
+		return
+	}
+
+	var parsed imageManifest
+	if err := json.Unmarshal(content, &parsed); err != nil {
+		registryError(w, http.StatusBadRequest, "MANIFEST_INVALID", err.Error())
+		return
+	}
+
+	size := parsed.Config.Size
+	for _, desc := range append(parsed.Layers, parsed.Manifests...) {
+		size += desc.Size
+	}
+
+	digest := digestOf(content)
+	if err := r.storeManifest(name, reference, digest, req.Header.Get("Content-Type"), content, size); err != nil {
+		registryError(w, http.StatusNotFound, "NAME_UNKNOWN", err.Error())
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/v2/%s/manifests/%s", name, digest))
+	w.Header().Set("Docker-Content-Digest", digest)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// storeManifest stores a manifest and, when pushed by tag, records the tag in GitLab
+func (r *registry) storeManifest(name, reference, digest, mediaType string, content []byte, size int64) error {
+	if mediaType == "" {
+		mediaType = ociManifestMediaType
+	}
+
+	if !strings.HasPrefix(reference, "sha256:") {
+		r.gitlab.mu.Lock()
+		err := r.gitlab.pushImage(fmt.Sprintf("%s/%s:%s", r.gitlab.registryHost, name, reference), digest, size)
+		r.gitlab.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.manifests[digest] = manifest{mediaType: mediaType, content: content}
+
+	var parsed imageManifest
+	if err := json.Unmarshal(content, &parsed); err == nil && parsed.Subject != nil {
+		artifactType := parsed.ArtifactType
+		if artifactType == "" {
+			artifactType = parsed.Config.MediaType
+		}
+		subject := parsed.Subject.Digest
+		if !slices.ContainsFunc(r.referrers[subject], func(desc descriptor) bool { return desc.Digest == digest }) {
+			r.referrers[subject] = append(r.referrers[subject], descriptor{
+				MediaType:    mediaType,
+				Digest:       digest,
+				Size:         int64(len(content)),
+				ArtifactType: artifactType,
+			})
+		}
+	}
+	return nil
+}
+
+// getReferrers lists the manifests whose subject is the given digest, as an image index
+func (r *registry) getReferrers(w http.ResponseWriter, digest string) {
+	r.mu.Lock()
+	referrers := slices.Clone(r.referrers[digest])
+	r.mu.Unlock()
+
+	w.Header().Set("Content-Type", ociIndexMediaType)
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(imageManifest{
+		SchemaVersion: 2,
+		MediaType:     ociIndexMediaType,
+		Manifests:     append([]descriptor{}, referrers...),
+	})
+}
+
+func (r *registry) getBlob(w http.ResponseWriter, req *http.Request, digest string) {
+	r.mu.Lock()
+	blob, ok := r.blobs[digest]
+	r.mu.Unlock()
+	if !ok {
+		registryError(w, http.StatusNotFound, "BLOB_UNKNOWN", fmt.Sprintf("blob %s unknown", digest))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(len(blob)))
+	w.Header().Set("Docker-Content-Digest", digest)
+	w.WriteHeader(http.StatusOK)
+	if req.Method == http.MethodGet {
+		_, _ = w.Write(blob)
+	}
+}
+
+func (r *registry) startUpload(w http.ResponseWriter, req *http.Request, name string) {
+	// Cross repository mount of a blob already stored
+	if mount := req.URL.Query().Get("mount"); mount != "" {
+		r.mu.Lock()
+		_, ok := r.blobs[mount]
+		r.mu.Unlock()
+		if ok {
+			w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/%s", name, mount))
+			w.Header().Set("Docker-Content-Digest", mount)
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+	}
+
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	uuid := hex.EncodeToString(id)
+
+	r.mu.Lock()
+	r.uploads[uuid] = &bytes.Buffer{}
+	r.mu.Unlock()
+
+	// Monolithic upload
+	if digest := req.URL.Query().Get("digest"); digest != "" {
+		r.finishUpload(w, req, name, uuid)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%s", name, uuid))
+	w.Header().Set("Docker-Upload-UUID", uuid)
+	w.Header().Set("Range", "0-0")
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (r *registry) patchUpload(w http.ResponseWriter, req *http.Request, name, uuid string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	upload, ok := r.uploads[uuid]
+	if !ok {
+		registryError(w, http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN", "upload unknown")
+		return
+	}
+	if _, err := io.Copy(upload, req.Body); err != nil {
+		registryError(w, http.StatusBadRequest, "BLOB_UPLOAD_INVALID", err.Error())
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%s", name, uuid))
+	w.Header().Set("Docker-Upload-UUID", uuid)
+	w.Header().Set("Range", fmt.Sprintf("0-%d", max(upload.Len()-1, 0)))
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (r *registry) finishUpload(w http.ResponseWriter, req *http.Request, name, uuid string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	upload, ok := r.uploads[uuid]
+	if !ok {
+		registryError(w, http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN", "upload unknown")
+		return
+	}
+	if _, err := io.Copy(upload, req.Body); err != nil {
+		registryError(w, http.StatusBadRequest, "BLOB_UPLOAD_INVALID", err.Error())
+		return
+	}
+
+	digest := req.URL.Query().Get("digest")
+	if digest != digestOf(upload.Bytes()) {
+		registryError(w, http.StatusBadRequest, "DIGEST_INVALID", fmt.Sprintf("digest %s does not match content", digest))
+		return
+	}
+	r.blobs[digest] = upload.Bytes()
+	delete(r.uploads, uuid)
+
+	w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/%s", name, digest))
+	w.Header().Set("Docker-Content-Digest", digest)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// splitReference returns the repository name and tag of an image of the registry
+func (r *registry) splitReference(imageRef string) (string, string, error) {
+	host := r.gitlab.registryHost + "/"
+	if !strings.HasPrefix(imageRef, host) {
+		return "", "", fmt.Errorf("image %s is not served by registry %s", imageRef, r.gitlab.registryHost)
+	}
+	name, tag := strings.TrimPrefix(imageRef, host), "latest"
+	if idx := strings.LastIndex(name, ":"); idx > strings.LastIndex(name, "/") {
+		name, tag = name[:idx], name[idx+1:]
+	}
+	return name, tag, nil
+}
+
+// buildImage stores the blobs of an image for the architecture whose layer holds size bytes,
+// and returns its manifest with its size
+func (r *registry) buildImage(imageRef, architecture string, size int64) ([]byte, int64, error) {
+	// The layer content depends on the reference and architecture so that each image has its own digest
+	seed := imageRef + " " + architecture + "\n"
+	content := bytes.Repeat([]byte(seed), int(size)/len(seed)+1)[:size]
+	var layerTar bytes.Buffer
+	tw := tar.NewWriter(&layerTar)
+	if err := tw.WriteHeader(&tar.Header{Name: "data", Mode: 0644, Size: size}); err != nil {
+		return nil, 0, err
+	}
+	if _, err := tw.Write(content); err != nil {
+		return nil, 0, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, 0, err
+	}
+
+	var layer bytes.Buffer
+	gw := gzip.NewWriter(&layer)
+	if _, err := gw.Write(layerTar.Bytes()); err != nil {
+		return nil, 0, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, 0, err
+	}
+
+	config, err := json.Marshal(map[string]interface{}{
+		"architecture": architecture,
+		"os":           "linux",
+		"config":       map[string]interface{}{},
+		"rootfs": map[string]interface{}{
+			"type":     "layers",
+			"diff_ids": []string{digestOf(layerTar.Bytes())},
+		},
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	image := imageManifest{
+		SchemaVersion: 2,
+		MediaType:     ociManifestMediaType,
+		Config:        descriptor{MediaType: ociConfigMediaType, Digest: digestOf(config), Size: int64(len(config))},
+		Layers:        []descriptor{{MediaType: ociLayerMediaType, Digest: digestOf(layer.Bytes()), Size: int64(layer.Len())}},
+	}
+	manifestContent, err := json.Marshal(image)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	r.mu.Lock()
+	r.blobs[image.Config.Digest] = config
+	r.blobs[image.Layers[0].Digest] = layer.Bytes()
+	r.mu.Unlock()
+
+	return manifestContent, image.Config.Size + image.Layers[0].Size, nil
+}
+
+// addImage stores a single platform image whose layer holds size bytes, and tags it in GitLab
+func (r *registry) addImage(imageRef string, size int64) error {
+	name, tag, err := r.splitReference(imageRef)
+	if err != nil {
+		return err
+	}
+	content, imageSize, err := r.buildImage(imageRef, runtime.GOARCH, size)
+	if err != nil {
+		return err
+	}
+	return r.storeManifest(name, tag, digestOf(content), ociManifestMediaType, content, imageSize)
+}
+
+// addIndex stores an image index with an image of about size bytes per architecture, and tags it in GitLab
+func (r *registry) addIndex(imageRef string, architectures []string, size int64) error {
+	name, tag, err := r.splitReference(imageRef)
+	if err != nil {
+		return err
+	}
+
+	index := imageManifest{SchemaVersion: 2, MediaType: ociIndexMediaType}
+	var totalSize int64
+	for _, architecture := range architectures {
+		content, imageSize, err := r.buildImage(imageRef, architecture, size)
+		if err != nil {
+			return err
+		}
+		digest := digestOf(content)
+		if err := r.storeManifest(name, digest, digest, ociManifestMediaType, content, imageSize); err != nil {
+			return err
+		}
+		index.Manifests = append(index.Manifests, descriptor{
+			MediaType: ociManifestMediaType,
+			Digest:    digest,
+			Size:      int64(len(content)),
+			Platform:  &platform{Architecture: architecture, OS: "linux"},
+		})
+		totalSize += imageSize
+	}
+
+	content, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return r.storeManifest(name, tag, digestOf(content), ociIndexMediaType, content, totalSize)
+}
+
+// addArtifact stores an artifact of the given type referring to an image, as signatures and SBOMs do,
+// and returns its digest. The artifact is not tagged.
+func (r *registry) addArtifact(imageRef, artifactType string) (string, error) {
+	name, tag, err := r.splitReference(imageRef)
+	if err != nil {
+		return "", err
+	}
+	subject, err := r.resolve(name, tag)
+	if err != nil {
+		return "", err
+	}
+
+	r.mu.Lock()
+	stored, ok := r.manifests[subject]
+	r.mu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("manifest %s unknown", subject)
+	}
+
+	empty := []byte("{}")
+	payload := []byte(fmt.Sprintf("%s of %s", artifactType, subject))
+	artifact := imageManifest{
+		SchemaVersion: 2,
+		MediaType:     ociManifestMediaType,
+		ArtifactType:  artifactType,
+		Config:        descriptor{MediaType: ociEmptyMediaType, Digest: digestOf(empty), Size: int64(len(empty))},
+		Layers:        []descriptor{{MediaType: "application/octet-stream", Digest: digestOf(payload), Size: int64(len(payload))}},
+		Subject:       &descriptor{MediaType: stored.mediaType, Digest: subject, Size: int64(len(stored.content))},
+	}
+	content, err := json.Marshal(artifact)
+	if err != nil {
+		return "", err
+	}
+
+	r.mu.Lock()
+	r.blobs[artifact.Config.Digest] = empty
+	r.blobs[artifact.Layers[0].Digest] = payload
+	r.mu.Unlock()
+
+	digest := digestOf(content)
+	return digest, r.storeManifest(name, digest, digest, ociManifestMediaType, content, int64(len(payload)))
+}
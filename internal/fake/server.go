@@ -0,0 +1,1012 @@
+package fake
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+
+	gitlabCore "gitlab.com/gitlab-org/api/client-go"
+)
+
+// Server serves a GitLab instance over HTTP: the subset of the REST API used by MigRaptor
+// under /api/v4, including the generic and npm package registries, the GraphQL storage query under /api/graphql,
+// and an OCI registry under /v2/.
+// The registry is served on the same host, so the CLI runs against it with
+// --instance localhost:<port> --registry localhost:<port>.
+type Server struct {
+	// GitLab holds the state of the instance, it can be seeded and inspected while the server runs
+	GitLab *GitLab
+	// Token is the token expected by the API, any token is accepted if empty
+	Token string
+
+	registry   *registry
+	httpServer *httptest.Server
+}
+
+// NewServer starts a server listening on addr, or on a random local port if addr is empty
+func NewServer(addr string) (*Server, error) {
+	s := &Server{}
+	s.httpServer = httptest.NewUnstartedServer(s.routes())
+	if addr != "" {
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+		}
+		_ = s.httpServer.Listener.Close()
+		s.httpServer.Listener = listener
+	}
+
+	_, port, err := net.SplitHostPort(s.httpServer.Listener.Addr().String())
+	if err != nil {
+		return nil, err
+	}
+	s.GitLab = NewGitLab("localhost:" + port)
+	s.registry = newRegistry(s.GitLab)
+	s.httpServer.Start()
+	return s, nil
+}
+
+// Addr returns the host and port to pass as GitLab instance and registry
+func (s *Server) Addr() string {
+	return s.GitLab.RegistryHost()
+}
+
+// URL returns the base URL of the server
+func (s *Server) URL() string {
+	return "http://" + s.Addr()
+}
+
+// Close stops the server
+func (s *Server) Close() {
+	s.httpServer.Close()
+}
+
+// AddImage pushes a single layer image of about size bytes to the registry and tags it in its project
+func (s *Server) AddImage(imageRef string, size int64) error {
+	return s.registry.addImage(imageRef, size)
+}
+
+// AddIndex pushes a multi-architecture image index with an image of about size bytes per architecture,
+// and tags it in its project
+func (s *Server) AddIndex(imageRef string, architectures []string, size int64) error {
+	return s.registry.addIndex(imageRef, architectures, size)
+}
+
+// AddArtifact pushes an untagged artifact of the given type referring to an image, such as a signature,
+// and returns its digest
+func (s *Server) AddArtifact(imageRef, artifactType string) (string, error) {
+	return s.registry.addArtifact(imageRef, artifactType)
+}
+
+func (s *Server) routes() http.Handler {
+	api := http.NewServeMux()
+
+	api.HandleFunc("GET /user", s.getCurrentUser)
+	api.HandleFunc("GET /personal_access_tokens/self", s.getToken)
+
+	api.HandleFunc("GET /groups/{id}", s.getGroup)
+	api.HandleFunc("POST /groups", s.createGroup)
+	api.HandleFunc("PUT /groups/{id}", s.updateGroup)
+	api.HandleFunc("GET /groups/{id}/avatar", s.getGroupAvatar)
+	api.HandleFunc("GET /groups/{id}/labels", s.listGroupLabels)
+	api.HandleFunc("POST /groups/{id}/labels", s.createGroupLabel)
+	api.HandleFunc("POST /groups/{id}/transfer", s.transferGroup)
+	api.HandleFunc("GET /groups/{id}/subgroups", s.listSubGroups)
+	api.HandleFunc("GET /groups/{id}/projects", s.listProjects)
+	api.HandleFunc("GET /groups/{id}/members", s.listGroupMembers(false))
+	api.HandleFunc("GET /groups/{id}/members/all", s.listGroupMembers(true))
+	api.HandleFunc("GET /groups/{id}/members/all/{user}", s.getGroupMember)
+	api.HandleFunc("POST /groups/{id}/members", s.addGroupMember)
+	api.HandleFunc("PUT /groups/{id}/members/{user}", s.editGroupMember)
+	api.HandleFunc("POST /groups/{id}/share", s.shareGroup)
+
+	api.HandleFunc("GET /projects/{id}", s.getProject)
+	api.HandleFunc("PUT /projects/{id}/transfer", s.transferProject)
+	api.HandleFunc("POST /projects/{id}/archive", s.archiveProject(true))
+	api.HandleFunc("POST /projects/{id}/unarchive", s.archiveProject(false))
+	api.HandleFunc("GET /projects/{id}/registry/repositories", s.listRepositories)
+	api.HandleFunc("DELETE /projects/{id}/registry/repositories/{repo}", s.deleteRepository)
+	api.HandleFunc("GET /projects/{id}/registry/repositories/{repo}/tags", s.listTags)
+	api.HandleFunc("GET /projects/{id}/registry/repositories/{repo}/tags/{tag}", s.getTag)
+	api.HandleFunc("DELETE /projects/{id}/registry/repositories/{repo}/tags/{tag}", s.deleteTag)
+	api.HandleFunc("GET /projects/{id}/packages", s.listPackages)
+	api.HandleFunc("GET /projects/{id}/packages/{package}/package_files", s.listPackageFiles)
+	api.HandleFunc("DELETE /projects/{id}/packages/{package}", s.deletePackage)
+	api.HandleFunc("GET /projects/{id}/packages/generic/{name}/{version}/{file}", s.downloadPackageFile("generic"))
+	api.HandleFunc("PUT /projects/{id}/packages/generic/{name}/{version}/{file}", s.publishGenericPackage)
+	api.HandleFunc("GET /projects/{id}/packages/npm/{name}/-/{file}", s.downloadPackageFile("npm"))
+	api.HandleFunc("PUT /projects/{id}/packages/npm/{name}", s.publishNpmPackage)
+	api.HandleFunc("GET /projects/{id}/members", s.listProjectMembers(false))
+	api.HandleFunc("GET /projects/{id}/members/all", s.listProjectMembers(true))
+	api.HandleFunc("GET /projects/{id}/members/all/{user}", s.getProjectMember)
+	api.HandleFunc("POST /projects/{id}/members", s.addProjectMember)
+	api.HandleFunc("PUT /projects/{id}/members/{user}", s.editProjectMember)
+	api.HandleFunc("POST /projects/{id}/share", s.shareProject)
+	api.HandleFunc("GET /projects/{id}/pipelines", s.listPipelines)
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/v4/", s.authenticate(http.StripPrefix("/api/v4", api)))
+	mux.Handle("POST /api/graphql", s.authenticate(http.HandlerFunc(s.graphQL)))
+	mux.Handle("/v2/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.registry.ServeHTTP(w, r)
+	}))
+	return mux
+}
+
+// authenticate rejects API requests without the expected token
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.Token != "" && r.Header.Get("PRIVATE-TOKEN") != s.Token && r.Header.Get("Authorization") != "Bearer "+s.Token {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"message": "401 Unauthorized"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if body != nil {
+		_ = json.NewEncoder(w).Encode(body)
+	}
+}
+
+// writeError answers with the status code of the error, as GitLab does
+func writeError(w http.ResponseWriter, err error) {
+	var apiErr *apiError
+	switch {
+	case errors.As(err, &apiErr):
+		writeJSON(w, apiErr.statusCode, map[string]string{"message": apiErr.message})
+	case errors.Is(err, gitlabCore.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "404 Not found"})
+	default:
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"message": err.Error()})
+	}
+}
+
+func decode(r *http.Request, body interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(body); err != nil && err != io.EOF {
+		return badRequest("invalid body: %v", err)
+	}
+	return nil
+}
+
+func pathInt(r *http.Request, name string) (int, error) {
+	value, err := strconv.Atoi(r.PathValue(name))
+	if err != nil {
+		return 0, badRequest("invalid %s %s", name, r.PathValue(name))
+	}
+	return value, nil
+}
+
+// groupID resolves the id of the request path, either a numeric ID or a full path
+func (s *Server) groupID(r *http.Request) (int, error) {
+	id := r.PathValue("id")
+	if value, err := strconv.Atoi(id); err == nil {
+		return value, nil
+	}
+	group, err := s.GitLab.SearchGroup(r.Context(), id)
+	if err != nil {
+		return 0, err
+	}
+	return int(group.ID), nil
+}
+
+// projectID resolves the id of the request path, either a numeric ID or a full path
+func (s *Server) projectID(r *http.Request) (int, error) {
+	id := r.PathValue("id")
+	if value, err := strconv.Atoi(id); err == nil {
+		return value, nil
+	}
+	project, err := s.GitLab.GetProjectByPath(r.Context(), id)
+	if err != nil {
+		return 0, err
+	}
+	if project == nil {
+		return 0, notFound("project %s", id)
+	}
+	return int(project.ID), nil
+}
+
+// Users
+
+func (s *Server) getCurrentUser(w http.ResponseWriter, r *http.Request) {
+	user, _, err := s.GitLab.GetCurrentUser(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+func (s *Server) getToken(w http.ResponseWriter, r *http.Request) {
+	scopes, err := s.GitLab.GetTokenScopes(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, gitlabCore.PersonalAccessToken{Name: "migraptor", Scopes: scopes, Active: true})
+}
+
+// Groups
+
+func (s *Server) getGroup(w http.ResponseWriter, r *http.Request) {
+	id, err := s.groupID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	group, _, err := s.GitLab.GetGroup(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, group)
+}
+
+func (s *Server) createGroup(w http.ResponseWriter, r *http.Request) {
+	opt := &gitlabCore.CreateGroupOptions{}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		var err error
+		if opt, err = parseGroupForm(r); err != nil {
+			writeError(w, err)
+			return
+		}
+	} else if err := decode(r, opt); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	group, _, err := s.GitLab.CreateGroupWithOptions(r.Context(), opt)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, group)
+}
+
+// parseGroupForm reads the settings of a group created with an avatar, sent as a multipart form
+func parseGroupForm(r *http.Request) (*gitlabCore.CreateGroupOptions, error) {
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		return nil, badRequest("invalid form: %v", err)
+	}
+
+	opt := &gitlabCore.CreateGroupOptions{}
+	form := r.MultipartForm.Value
+	if values := form["name"]; len(values) > 0 {
+		opt.Name = &values[0]
+	}
+	if values := form["path"]; len(values) > 0 {
+		opt.Path = &values[0]
+	}
+	if values := form["description"]; len(values) > 0 {
+		opt.Description = &values[0]
+	}
+	if values := form["visibility"]; len(values) > 0 {
+		visibility := gitlabCore.VisibilityValue(values[0])
+		opt.Visibility = &visibility
+	}
+	if values := form["project_creation_level"]; len(values) > 0 {
+		level := gitlabCore.ProjectCreationLevelValue(values[0])
+		opt.ProjectCreationLevel = &level
+	}
+	if values := form["parent_id"]; len(values) > 0 {
+		parentID, err := strconv.ParseInt(values[0], 10, 64)
+		if err != nil {
+			return nil, badRequest("invalid parent_id %s", values[0])
+		}
+		opt.ParentID = &parentID
+	}
+
+	if files := r.MultipartForm.File["avatar"]; len(files) > 0 {
+		file, err := files[0].Open()
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return nil, err
+		}
+		opt.Avatar = &gitlabCore.GroupAvatar{Filename: files[0].Filename, Image: strings.NewReader(string(data))}
+	}
+	return opt, nil
+}
+
+func (s *Server) updateGroup(w http.ResponseWriter, r *http.Request) {
+	id, err := s.groupID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	opt := &gitlabCore.UpdateGroupOptions{}
+	if err := decode(r, opt); err != nil {
+		writeError(w, err)
+		return
+	}
+	group, _, err := s.GitLab.UpdateGroup(r.Context(), id, opt)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, group)
+}
+
+func (s *Server) getGroupAvatar(w http.ResponseWriter, r *http.Request) {
+	id, err := s.groupID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	avatar, err := s.GitLab.DownloadGroupAvatar(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, _ = io.Copy(w, avatar)
+}
+
+func (s *Server) listGroupLabels(w http.ResponseWriter, r *http.Request) {
+	id, err := s.groupID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	labels, err := s.GitLab.ListGroupLabels(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, nonNil(labels))
+}
+
+func (s *Server) createGroupLabel(w http.ResponseWriter, r *http.Request) {
+	id, err := s.groupID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var opt struct {
+		Name        string `json:"name"`
+		Color       string `json:"color"`
+		Description string `json:"description"`
+	}
+	if err := decode(r, &opt); err != nil {
+		writeError(w, err)
+		return
+	}
+	label, err := s.GitLab.CreateGroupLabel(r.Context(), id, opt.Name, opt.Color, opt.Description)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, label)
+}
+
+func (s *Server) transferGroup(w http.ResponseWriter, r *http.Request) {
+	id, err := s.groupID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var opt struct {
+		GroupID int `json:"group_id"`
+	}
+	if err := decode(r, &opt); err != nil {
+		writeError(w, err)
+		return
+	}
+	if _, err := s.GitLab.TransferGroup(r.Context(), id, opt.GroupID); err != nil {
+		writeError(w, err)
+		return
+	}
+	group, _, err := s.GitLab.GetGroup(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, group)
+}
+
+func (s *Server) listSubGroups(w http.ResponseWriter, r *http.Request) {
+	id, err := s.groupID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	groups, err := s.GitLab.GetSubGroups(r.Context(), int64(id))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, nonNil(groups))
+}
+
+func (s *Server) listProjects(w http.ResponseWriter, r *http.Request) {
+	id, err := s.groupID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	projects, _, err := s.GitLab.ListProjects(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, nonNil(projects))
+}
+
+func (s *Server) listGroupMembers(inherited bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := s.groupID(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		members, err := s.GitLab.ListGroupMembers(r.Context(), id, inherited)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, nonNil(members))
+	}
+}
+
+func (s *Server) getGroupMember(w http.ResponseWriter, r *http.Request) {
+	id, err := s.groupID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	userID, err := pathInt(r, "user")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	level, err := s.GitLab.GetGroupAccessLevel(r.Context(), id, int64(userID))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if level == gitlabCore.NoPermissions {
+		writeError(w, notFound("member %d", userID))
+		return
+	}
+	writeJSON(w, http.StatusOK, gitlabCore.GroupMember{ID: int64(userID), AccessLevel: level})
+}
+
+// memberOptions is the body of requests adding or editing a member
+type memberOptions struct {
+	UserID      int64                       `json:"user_id"`
+	AccessLevel gitlabCore.AccessLevelValue `json:"access_level"`
+	ExpiresAt   string                      `json:"expires_at"`
+}
+
+func (s *Server) addGroupMember(w http.ResponseWriter, r *http.Request) {
+	id, err := s.groupID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var opt memberOptions
+	if err := decode(r, &opt); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.GitLab.AddGroupMember(r.Context(), id, opt.UserID, opt.AccessLevel, opt.ExpiresAt); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, gitlabCore.GroupMember{ID: opt.UserID, AccessLevel: opt.AccessLevel})
+}
+
+func (s *Server) editGroupMember(w http.ResponseWriter, r *http.Request) {
+	id, err := s.groupID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	userID, err := pathInt(r, "user")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var opt memberOptions
+	if err := decode(r, &opt); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.GitLab.EditGroupMember(r.Context(), id, int64(userID), opt.AccessLevel, opt.ExpiresAt); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, gitlabCore.GroupMember{ID: int64(userID), AccessLevel: opt.AccessLevel})
+}
+
+func (s *Server) shareGroup(w http.ResponseWriter, r *http.Request) {
+	id, err := s.groupID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var opt gitlabCore.ShareGroupWithGroupOptions
+	if err := decode(r, &opt); err != nil {
+		writeError(w, err)
+		return
+	}
+	if opt.GroupID == nil || opt.GroupAccess == nil {
+		writeError(w, badRequest("group_id and group_access are required"))
+		return
+	}
+	if err := s.GitLab.ShareGroupWithGroup(r.Context(), id, *opt.GroupID, *opt.GroupAccess, opt.ExpiresAt); err != nil {
+		writeError(w, err)
+		return
+	}
+	group, _, err := s.GitLab.GetGroup(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, group)
+}
+
+// Projects
+
+func (s *Server) getProject(w http.ResponseWriter, r *http.Request) {
+	id, err := s.projectID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	project, _, err := s.GitLab.GetProject(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if r.URL.Query().Get("statistics") == "true" {
+		size, err := s.GitLab.GetProjectStorageSize(r.Context(), id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		project.Statistics = &gitlabCore.Statistics{StorageSize: size}
+	}
+	writeJSON(w, http.StatusOK, project)
+}
+
+func (s *Server) transferProject(w http.ResponseWriter, r *http.Request) {
+	id, err := s.projectID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var opt struct {
+		Namespace json.Number `json:"namespace"`
+	}
+	if err := decode(r, &opt); err != nil {
+		writeError(w, err)
+		return
+	}
+	namespaceID, err := opt.Namespace.Int64()
+	if err != nil {
+		writeError(w, badRequest("invalid namespace %s", opt.Namespace))
+		return
+	}
+	if _, err := s.GitLab.TransferProject(r.Context(), id, int(namespaceID)); err != nil {
+		writeError(w, err)
+		return
+	}
+	project, _, err := s.GitLab.GetProject(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, project)
+}
+
+func (s *Server) archiveProject(archived bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := s.projectID(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if archived {
+			_, err = s.GitLab.ArchiveProject(r.Context(), id)
+		} else {
+			_, err = s.GitLab.UnarchiveProject(r.Context(), id)
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		project, _, err := s.GitLab.GetProject(r.Context(), id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, project)
+	}
+}
+
+func (s *Server) listRepositories(w http.ResponseWriter, r *http.Request) {
+	id, err := s.projectID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	repositories, _, err := s.GitLab.ListRegistryRepositories(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, nonNil(repositories))
+}
+
+func (s *Server) deleteRepository(w http.ResponseWriter, r *http.Request) {
+	id, err := s.projectID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	repositoryID, err := pathInt(r, "repo")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if _, err := s.GitLab.DeleteRegistryRepository(r.Context(), id, repositoryID); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, nil)
+}
+
+func (s *Server) listTags(w http.ResponseWriter, r *http.Request) {
+	id, err := s.projectID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	repositoryID, err := pathInt(r, "repo")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	tags, _, err := s.GitLab.ListRegistryRepositoryTags(r.Context(), id, repositoryID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, nonNil(tags))
+}
+
+func (s *Server) getTag(w http.ResponseWriter, r *http.Request) {
+	id, err := s.projectID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	repositoryID, err := pathInt(r, "repo")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	tag, err := s.GitLab.GetRegistryRepositoryTagDetail(r.Context(), id, repositoryID, r.PathValue("tag"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, tag)
+}
+
+func (s *Server) deleteTag(w http.ResponseWriter, r *http.Request) {
+	id, err := s.projectID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	repositoryID, err := pathInt(r, "repo")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if _, err := s.GitLab.DeleteRegistryRepositoryTag(r.Context(), id, repositoryID, r.PathValue("tag")); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, nil)
+}
+
+func (s *Server) listPackages(w http.ResponseWriter, r *http.Request) {
+	id, err := s.projectID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	packages, err := s.GitLab.ListProjectPackages(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, nonNil(packages))
+}
+
+func (s *Server) listPackageFiles(w http.ResponseWriter, r *http.Request) {
+	id, err := s.projectID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	packageID, err := pathInt(r, "package")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	files, err := s.GitLab.ListPackageFiles(r.Context(), id, int64(packageID))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, nonNil(files))
+}
+
+func (s *Server) deletePackage(w http.ResponseWriter, r *http.Request) {
+	id, err := s.projectID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	packageID, err := pathInt(r, "package")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.GitLab.DeleteProjectPackage(r.Context(), id, int64(packageID)); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// downloadPackageFile serves a file of a package of the given type, the version is not part of npm paths
+func (s *Server) downloadPackageFile(packageType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := s.projectID(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		pkg, file, err := s.GitLab.lookupPackageFile(id, packageType, r.PathValue("name"), r.PathValue("version"), r.PathValue("file"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if err := s.GitLab.DownloadPackageFile(r.Context(), id, pkg, file, w); err != nil {
+			writeError(w, err)
+		}
+	}
+}
+
+func (s *Server) publishGenericPackage(w http.ResponseWriter, r *http.Request) {
+	id, err := s.projectID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	pkg := &gitlabCore.Package{PackageType: "generic", Name: r.PathValue("name"), Version: r.PathValue("version")}
+	if err := s.GitLab.PublishPackageFile(r.Context(), id, pkg, r.PathValue("file"), r.Body); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]string{"message": "201 Created"})
+}
+
+// publishNpmPackage stores the tarballs attached to an npm publish document
+func (s *Server) publishNpmPackage(w http.ResponseWriter, r *http.Request) {
+	id, err := s.projectID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var body struct {
+		Name        string                     `json:"name"`
+		Versions    map[string]json.RawMessage `json:"versions"`
+		Attachments map[string]struct {
+			Data string `json:"data"`
+		} `json:"_attachments"`
+	}
+	if err := decode(r, &body); err != nil {
+		writeError(w, err)
+		return
+	}
+	if len(body.Versions) != 1 || len(body.Attachments) != 1 {
+		writeError(w, badRequest("expected one version and one attachment"))
+		return
+	}
+
+	for version := range body.Versions {
+		for fileName, attachment := range body.Attachments {
+			content, err := base64.StdEncoding.DecodeString(attachment.Data)
+			if err != nil {
+				writeError(w, badRequest("invalid attachment %s: %v", fileName, err))
+				return
+			}
+			pkg := &gitlabCore.Package{PackageType: "npm", Name: body.Name, Version: version}
+			if err := s.GitLab.PublishPackageFile(r.Context(), id, pkg, fileName, bytes.NewReader(content)); err != nil {
+				writeError(w, err)
+				return
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"message": "200 OK"})
+}
+
+func (s *Server) listProjectMembers(inherited bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := s.projectID(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		members, err := s.GitLab.ListProjectMembers(r.Context(), id, inherited)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, nonNil(members))
+	}
+}
+
+func (s *Server) getProjectMember(w http.ResponseWriter, r *http.Request) {
+	id, err := s.projectID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	userID, err := pathInt(r, "user")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	level, err := s.GitLab.GetProjectAccessLevel(r.Context(), id, int64(userID))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if level == gitlabCore.NoPermissions {
+		writeError(w, notFound("member %d", userID))
+		return
+	}
+	writeJSON(w, http.StatusOK, gitlabCore.ProjectMember{ID: int64(userID), AccessLevel: level})
+}
+
+func (s *Server) addProjectMember(w http.ResponseWriter, r *http.Request) {
+	id, err := s.projectID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var opt memberOptions
+	if err := decode(r, &opt); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.GitLab.AddProjectMember(r.Context(), id, opt.UserID, opt.AccessLevel, opt.ExpiresAt); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, gitlabCore.ProjectMember{ID: opt.UserID, AccessLevel: opt.AccessLevel})
+}
+
+func (s *Server) editProjectMember(w http.ResponseWriter, r *http.Request) {
+	id, err := s.projectID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	userID, err := pathInt(r, "user")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var opt memberOptions
+	if err := decode(r, &opt); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.GitLab.EditProjectMember(r.Context(), id, int64(userID), opt.AccessLevel, opt.ExpiresAt); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, gitlabCore.ProjectMember{ID: int64(userID), AccessLevel: opt.AccessLevel})
+}
+
+func (s *Server) shareProject(w http.ResponseWriter, r *http.Request) {
+	id, err := s.projectID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var opt struct {
+		GroupID     int64                       `json:"group_id"`
+		GroupAccess gitlabCore.AccessLevelValue `json:"group_access"`
+		ExpiresAt   string                      `json:"expires_at"`
+	}
+	if err := decode(r, &opt); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.GitLab.ShareProjectWithGroup(r.Context(), id, opt.GroupID, opt.GroupAccess, opt.ExpiresAt); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, nil)
+}
+
+func (s *Server) listPipelines(w http.ResponseWriter, r *http.Request) {
+	id, err := s.projectID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	pipelines, err := s.GitLab.ListActivePipelines(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	// Only running and pending pipelines are tracked, they are filtered by the requested scope
+	scope := r.URL.Query().Get("scope")
+	filtered := []*gitlabCore.PipelineInfo{}
+	for _, pipeline := range pipelines {
+		if scope == "" || pipeline.Status == scope {
+			filtered = append(filtered, pipeline)
+		}
+	}
+	writeJSON(w, http.StatusOK, filtered)
+}
+
+// graphQL answers the namespace storage query
+func (s *Server) graphQL(w http.ResponseWriter, r *http.Request) {
+	var query struct {
+		Variables struct {
+			FullPath string `json:"fullPath"`
+		} `json:"variables"`
+	}
+	if err := decode(r, &query); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var namespace interface{}
+	storage, err := s.GitLab.GetNamespaceStorage(r.Context(), query.Variables.FullPath)
+	if err == nil {
+		namespace = map[string]interface{}{
+			"storageSizeLimit":               storage.Limit,
+			"additionalPurchasedStorageSize": 0,
+			"rootStorageStatistics":          map[string]interface{}{"storageSize": storage.Used},
+		}
+	} else if !errors.Is(err, gitlabCore.ErrNotFound) {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"namespace": namespace}})
+}
+
+// nonNil returns an empty list instead of nil, so that lists are encoded as [] rather than null
+func nonNil[T any](list []T) []T {
+	if list == nil {
+		return []T{}
+	}
+	return list
+}
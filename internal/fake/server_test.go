@@ -0,0 +1,247 @@
+package fake
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"migraptor/internal/gitlab"
+
+	gitlabCore "gitlab.com/gitlab-org/api/client-go"
+)
+
+// newTestServer starts a server and a GitLab client connected to it
+func newTestServer(t *testing.T) (*Server, *gitlab.Client) {
+	t.Helper()
+	server, err := NewServer("")
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	t.Cleanup(server.Close)
+
+	client, err := gitlab.NewClient("token", server.Addr())
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	return server, client
+}
+
+func TestServer_Groups(t *testing.T) {
+	server, client := newTestServer(t)
+	server.GitLab.AddProject("team/app")
+	server.GitLab.AddProject("team/backend/api")
+	platform := server.GitLab.AddGroup("platform")
+
+	if err := client.CheckConnection(t.Context()); err != nil {
+		t.Fatalf("CheckConnection failed: %v", err)
+	}
+
+	team, err := client.SearchGroup(t.Context(), "team")
+	if err != nil {
+		t.Fatalf("SearchGroup failed: %v", err)
+	}
+	subGroups, err := client.GetSubGroups(t.Context(), team.ID)
+	if err != nil || len(subGroups) != 1 || subGroups[0].FullPath != "team/backend" {
+		t.Errorf("Expected sub-group team/backend, got %v (%v)", subGroups, err)
+	}
+	projects, _, err := client.ListProjects(t.Context(), int(team.ID))
+	if err != nil || len(projects) != 1 || projects[0].PathWithNamespace != "team/app" {
+		t.Errorf("Expected project team/app, got %v (%v)", projects, err)
+	}
+
+	name, path := "Team", "team"
+	parentID := platform.ID
+	created, _, err := client.CreateGroupWithOptions(t.Context(), &gitlabCore.CreateGroupOptions{
+		Name:     &name,
+		Path:     &path,
+		ParentID: &parentID,
+		Avatar:   &gitlabCore.GroupAvatar{Filename: "logo.png", Image: bytes.NewReader([]byte("png"))},
+	})
+	if err != nil {
+		t.Fatalf("CreateGroupWithOptions failed: %v", err)
+	}
+	if created.FullPath != "platform/team" || created.Name != "Team" {
+		t.Errorf("Expected group platform/team named Team, got %s named %s", created.FullPath, created.Name)
+	}
+	avatar, err := client.DownloadGroupAvatar(t.Context(), int(created.ID))
+	if err != nil {
+		t.Fatalf("DownloadGroupAvatar failed: %v", err)
+	}
+	if data, _ := io.ReadAll(avatar); string(data) != "png" {
+		t.Errorf("Expected avatar png, got %s", data)
+	}
+
+	// Path already taken in the destination
+	if _, err := client.TransferGroup(t.Context(), int(team.ID), int(platform.ID)); err == nil {
+		t.Error("Expected transfer to fail on path collision")
+	}
+	if _, err := client.TransferGroup(t.Context(), int(team.ID), int(created.ID)); err != nil {
+		t.Fatalf("TransferGroup failed: %v", err)
+	}
+	if server.GitLab.Project("platform/team/team/backend/api") == nil {
+		t.Error("Expected api to be transferred with its group")
+	}
+
+	if _, err := client.SearchGroup(t.Context(), "unknown"); !errors.Is(err, gitlabCore.ErrNotFound) {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
+
+func TestServer_Registry(t *testing.T) {
+	server, client := newTestServer(t)
+	app := server.GitLab.AddProject("team/app")
+	target := server.GitLab.AddGroup("platform")
+	imageRef := server.Addr() + "/team/app:1.0"
+	if err := server.AddImage(imageRef, 1024); err != nil {
+		t.Fatalf("AddImage failed: %v", err)
+	}
+
+	repositories, _, err := client.ListRegistryRepositories(t.Context(), int(app.ID))
+	if err != nil || len(repositories) != 1 {
+		t.Fatalf("Expected 1 repository, got %v (%v)", repositories, err)
+	}
+	tag, err := client.GetRegistryRepositoryTagDetail(t.Context(), int(app.ID), int(repositories[0].ID), "1.0")
+	if err != nil {
+		t.Fatalf("GetRegistryRepositoryTagDetail failed: %v", err)
+	}
+	if tag.Location != imageRef {
+		t.Errorf("Expected location %s, got %s", imageRef, tag.Location)
+	}
+
+	// The registry serves the manifest recorded in GitLab
+	resp, err := http.Get(server.URL() + "/v2/team/app/manifests/1.0")
+	if err != nil {
+		t.Fatalf("Failed to get manifest: %v", err)
+	}
+	content, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Docker-Content-Digest") != tag.Digest {
+		t.Fatalf("Expected manifest %s, got status %d and digest %s", tag.Digest, resp.StatusCode, resp.Header.Get("Docker-Content-Digest"))
+	}
+	var manifest imageManifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		t.Fatalf("Invalid manifest: %v", err)
+	}
+	resp, err = http.Head(fmt.Sprintf("%s/v2/team/app/blobs/%s", server.URL(), manifest.Layers[0].Digest))
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected layer blob to exist, got %v (%v)", resp, err)
+	}
+
+	// Tags block the transfer until the repository is deleted
+	if _, err := client.TransferProject(t.Context(), int(app.ID), int(target.ID)); err == nil {
+		t.Fatal("Expected transfer to fail while tags exist")
+	}
+	if _, err := client.DeleteRegistryRepository(t.Context(), int(app.ID), int(repositories[0].ID)); err != nil {
+		t.Fatalf("DeleteRegistryRepository failed: %v", err)
+	}
+	if _, err := client.TransferProject(t.Context(), int(app.ID), int(target.ID)); err != nil {
+		t.Fatalf("TransferProject failed: %v", err)
+	}
+
+	// Pushing the manifest under the new path records the tag in the transferred project
+	req, _ := http.NewRequest(http.MethodPut, server.URL()+"/v2/platform/app/manifests/1.0", bytes.NewReader(content))
+	req.Header.Set("Content-Type", ociManifestMediaType)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil || resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected manifest to be pushed, got %v (%v)", resp, err)
+	}
+	if images := server.GitLab.Images("platform/app"); len(images) != 1 || images[0] != server.Addr()+"/platform/app:1.0" {
+		t.Errorf("Expected image to be restored in platform/app, got %v", images)
+	}
+}
+
+func TestServer_Uploads(t *testing.T) {
+	server, _ := newTestServer(t)
+	blob := []byte("layer")
+
+	resp, err := http.Post(server.URL()+"/v2/team/app/blobs/uploads/", "", nil)
+	if err != nil || resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Expected upload to start, got %v (%v)", resp, err)
+	}
+	location := server.URL() + resp.Header.Get("Location")
+
+	req, _ := http.NewRequest(http.MethodPatch, location, bytes.NewReader(blob))
+	if resp, err = http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Expected chunk to be accepted, got %v (%v)", resp, err)
+	}
+
+	req, _ = http.NewRequest(http.MethodPut, location+"?digest=sha256:0000", nil)
+	if resp, err = http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected digest mismatch to be rejected, got %v (%v)", resp, err)
+	}
+	req, _ = http.NewRequest(http.MethodPut, location+"?digest="+digestOf(blob), nil)
+	if resp, err = http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected upload to complete, got %v (%v)", resp, err)
+	}
+
+	resp, err = http.Get(server.URL() + "/v2/team/app/blobs/" + digestOf(blob))
+	if err != nil {
+		t.Fatalf("Failed to get blob: %v", err)
+	}
+	defer resp.Body.Close()
+	if data, _ := io.ReadAll(resp.Body); string(data) != "layer" {
+		t.Errorf("Expected blob layer, got %s", data)
+	}
+}
+
+func TestServer_Preflight(t *testing.T) {
+	server, client := newTestServer(t)
+	app := server.GitLab.AddProject("team/app")
+	team := server.GitLab.Group("team")
+	user := server.GitLab.AddUser("alice")
+	server.GitLab.AddPipeline(app.ID, "running")
+	server.GitLab.AddPipeline(app.ID, "success")
+	server.GitLab.SetProjectStorageSize(app.ID, 600)
+	server.GitLab.SetNamespaceStorage("team", 500, 1000)
+
+	if scopes, err := client.GetTokenScopes(t.Context()); err != nil || len(scopes) != 1 || scopes[0] != "api" {
+		t.Errorf("Expected scopes [api], got %v (%v)", scopes, err)
+	}
+	if storage, err := client.GetNamespaceStorage(t.Context(), "team/sub"); err != nil || storage.Used != 500 || storage.Limit != 1000 {
+		t.Errorf("Expected 500 used out of 1000, got %v (%v)", storage, err)
+	}
+	if size, err := client.GetProjectStorageSize(t.Context(), int(app.ID)); err != nil || size != 600 {
+		t.Errorf("Expected storage size 600, got %d (%v)", size, err)
+	}
+	if pipelines, err := client.ListActivePipelines(t.Context(), int(app.ID)); err != nil || len(pipelines) != 1 {
+		t.Errorf("Expected 1 active pipeline, got %v (%v)", pipelines, err)
+	}
+	if project, err := client.GetProjectByPath(t.Context(), "team/unknown"); err != nil || project != nil {
+		t.Errorf("Expected no project, got %v (%v)", project, err)
+	}
+
+	if err := client.AddGroupMember(t.Context(), int(team.ID), user.ID, gitlabCore.DeveloperPermissions, "2030-01-01"); err != nil {
+		t.Fatalf("AddGroupMember failed: %v", err)
+	}
+	if err := client.AddGroupMember(t.Context(), int(team.ID), user.ID, gitlabCore.DeveloperPermissions, ""); err == nil {
+		t.Error("Expected adding an existing member to fail")
+	}
+	if level, err := client.GetProjectAccessLevel(t.Context(), int(app.ID), user.ID); err != nil || level != gitlabCore.DeveloperPermissions {
+		t.Errorf("Expected inherited developer access, got %v (%v)", level, err)
+	}
+	members, err := client.ListProjectMembers(t.Context(), int(app.ID), true)
+	if err != nil || len(members) != 1 || members[0].Username != "alice" {
+		t.Errorf("Expected alice as inherited member, got %v (%v)", members, err)
+	}
+}
+
+func TestServer_Token(t *testing.T) {
+	server, client := newTestServer(t)
+	server.Token = "secret"
+
+	if err := client.CheckConnection(t.Context()); err == nil {
+		t.Error("Expected connection to fail with a wrong token")
+	}
+
+	client, err := gitlab.NewClient("secret", server.Addr())
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	if err := client.CheckConnection(t.Context()); err != nil {
+		t.Errorf("Expected connection to succeed, got %v", err)
+	}
+}
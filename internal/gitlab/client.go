@@ -1,8 +1,13 @@
 package gitlab
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	gitlab "gitlab.com/gitlab-org/api/client-go"
@@ -17,7 +22,7 @@ type Client struct {
 
 // NewClient creates a new GitLab client
 func NewClient(token, instance string) (*Client, error) {
-	baseURL := fmt.Sprintf("https://%s/api/v4", instance)
+	baseURL := fmt.Sprintf("%s/api/v4", InstanceURL(instance))
 
 	client, err := gitlab.NewClient(token, gitlab.WithBaseURL(baseURL))
 	if err != nil {
@@ -34,20 +39,37 @@ func NewClient(token, instance string) (*Client, error) {
 	}, nil
 }
 
+// InstanceURL returns the base URL of a GitLab instance given as a host or as a URL.
+// Hosts are served over HTTPS, except local ones (localhost, 127.0.0.1, ::1) served over HTTP.
+func InstanceURL(instance string) string {
+	if strings.HasPrefix(instance, "http://") || strings.HasPrefix(instance, "https://") {
+		return strings.TrimSuffix(instance, "/")
+	}
+
+	host := instance
+	if h, _, err := net.SplitHostPort(instance); err == nil {
+		host = h
+	}
+	if host == "localhost" || host == "127.0.0.1" || host == "::1" {
+		return "http://" + instance
+	}
+	return "https://" + instance
+}
+
 // GetClient returns the underlying GitLab client
 func (c *Client) GetClient() *gitlab.Client {
 	return c.client
 }
 
 // SearchGroup searches for a group by name/path
-func (c *Client) SearchGroup(name string) (*gitlab.Group, error) {
+func (c *Client) SearchGroup(ctx context.Context, name string) (*gitlab.Group, error) {
 	opt := &gitlab.GetGroupOptions{
 		ListOptions: gitlab.ListOptions{
 			PerPage: 200,
 		},
 	}
 
-	group, _, err := c.client.Groups.GetGroup(name, opt)
+	group, _, err := c.client.Groups.GetGroup(name, opt, gitlab.WithContext(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("failed to search groups: %w", err)
 	}
@@ -55,12 +77,12 @@ func (c *Client) SearchGroup(name string) (*gitlab.Group, error) {
 }
 
 // GetGroup retrieves a group by ID
-func (c *Client) GetGroup(groupID int) (*gitlab.Group, *gitlab.Response, error) {
-	return c.client.Groups.GetGroup(int64(groupID), nil)
+func (c *Client) GetGroup(ctx context.Context, groupID int) (*gitlab.Group, *gitlab.Response, error) {
+	return c.client.Groups.GetGroup(int64(groupID), nil, gitlab.WithContext(ctx))
 }
 
 // CreateGroup creates a new group
-func (c *Client) CreateGroup(name string, parentID *int) (*gitlab.Group, *gitlab.Response, error) {
+func (c *Client) CreateGroup(ctx context.Context, name string, parentID *int) (*gitlab.Group, *gitlab.Response, error) {
 	opt := &gitlab.CreateGroupOptions{
 		Name: &name,
 		Path: &name,
@@ -71,16 +93,77 @@ func (c *Client) CreateGroup(name string, parentID *int) (*gitlab.Group, *gitlab
 		opt.ParentID = &parentID64
 	}
 
-	return c.client.Groups.CreateGroup(opt)
+	return c.client.Groups.CreateGroup(opt, gitlab.WithContext(ctx))
+}
+
+// CreateGroupWithOptions creates a new group with the given settings
+func (c *Client) CreateGroupWithOptions(ctx context.Context, opt *gitlab.CreateGroupOptions) (*gitlab.Group, *gitlab.Response, error) {
+	return c.client.Groups.CreateGroup(opt, gitlab.WithContext(ctx))
+}
+
+// UpdateGroup updates the settings of a group
+func (c *Client) UpdateGroup(ctx context.Context, groupID int, opt *gitlab.UpdateGroupOptions) (*gitlab.Group, *gitlab.Response, error) {
+	return c.client.Groups.UpdateGroup(int64(groupID), opt, gitlab.WithContext(ctx))
+}
+
+// DownloadGroupAvatar downloads the avatar of a group
+func (c *Client) DownloadGroupAvatar(ctx context.Context, groupID int) (*bytes.Reader, error) {
+	avatar, _, err := c.client.Groups.DownloadAvatar(int64(groupID), gitlab.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download group avatar: %w", err)
+	}
+	return avatar, nil
+}
+
+// ListGroupLabels lists the labels defined on a group (inherited labels excluded)
+func (c *Client) ListGroupLabels(ctx context.Context, groupID int) ([]*gitlab.GroupLabel, error) {
+	onlyGroupLabels := true
+	opt := &gitlab.ListGroupLabelsOptions{
+		ListOptions: gitlab.ListOptions{
+			PerPage: 100,
+		},
+		OnlyGroupLabels: &onlyGroupLabels,
+	}
+
+	var labels []*gitlab.GroupLabel
+	for {
+		page, resp, err := c.client.GroupLabels.ListGroupLabels(int64(groupID), opt, gitlab.WithContext(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list group labels: %w", err)
+		}
+		labels = append(labels, page...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return labels, nil
+}
+
+// CreateGroupLabel creates a label on a group
+func (c *Client) CreateGroupLabel(ctx context.Context, groupID int, name, color, description string) (*gitlab.GroupLabel, error) {
+	opt := &gitlab.CreateGroupLabelOptions{
+		Name:  &name,
+		Color: &color,
+	}
+	if description != "" {
+		opt.Description = &description
+	}
+
+	label, _, err := c.client.GroupLabels.CreateGroupLabel(int64(groupID), opt, gitlab.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create group label %s: %w", name, err)
+	}
+	return label, nil
 }
 
 // TransferGroup transfers a group to another group
-func (c *Client) TransferGroup(groupID, targetGroupID int) (*gitlab.Response, error) {
+func (c *Client) TransferGroup(ctx context.Context, groupID, targetGroupID int) (*gitlab.Response, error) {
 	// Use the HTTP client directly since TransferGroup might not be in the SDK
 	// or might have a different signature
 	req, err := c.client.NewRequest("POST", fmt.Sprintf("/groups/%d/transfer", groupID), map[string]interface{}{
 		"group_id": targetGroupID,
-	}, nil)
+	}, []gitlab.RequestOptionFunc{gitlab.WithContext(ctx)})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create transfer request: %w", err)
 	}
@@ -93,33 +176,57 @@ func (c *Client) TransferGroup(groupID, targetGroupID int) (*gitlab.Response, er
 	return resp, nil
 }
 
-func (c *Client) GetSubGroups(groupID int64) ([]*gitlab.Group, error) {
-	subgroups, _, err := c.client.Groups.ListSubGroups(groupID, nil)
+func (c *Client) GetSubGroups(ctx context.Context, groupID int64) ([]*gitlab.Group, error) {
+	subgroups, _, err := c.client.Groups.ListSubGroups(groupID, nil, gitlab.WithContext(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("failed to list subgroups: %w", err)
 	}
 	return subgroups, nil
 }
 
+// ListGroups lists the groups the user has at least the given access level in
+func (c *Client) ListGroups(ctx context.Context, minAccessLevel gitlab.AccessLevelValue) ([]*gitlab.Group, error) {
+	opt := &gitlab.ListGroupsOptions{
+		ListOptions: gitlab.ListOptions{
+			PerPage: 100,
+		},
+		MinAccessLevel: &minAccessLevel,
+	}
+
+	var groups []*gitlab.Group
+	for {
+		page, resp, err := c.client.Groups.ListGroups(opt, gitlab.WithContext(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list groups: %w", err)
+		}
+		groups = append(groups, page...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return groups, nil
+}
+
 // ListProjects lists projects in a group
-func (c *Client) ListProjects(groupID int) ([]*gitlab.Project, *gitlab.Response, error) {
+func (c *Client) ListProjects(ctx context.Context, groupID int) ([]*gitlab.Project, *gitlab.Response, error) {
 	opt := &gitlab.ListGroupProjectsOptions{
 		ListOptions: gitlab.ListOptions{
 			PerPage: 100,
 		},
 	}
 
-	return c.client.Groups.ListGroupProjects(int64(groupID), opt)
+	return c.client.Groups.ListGroupProjects(int64(groupID), opt, gitlab.WithContext(ctx))
 }
 
 // TransferProject transfers a project to another namespace
-func (c *Client) TransferProject(projectID, namespaceID int) (*gitlab.Response, error) {
+func (c *Client) TransferProject(ctx context.Context, projectID, namespaceID int) (*gitlab.Response, error) {
 	namespaceID64 := int64(namespaceID)
 	opt := &gitlab.TransferProjectOptions{
 		Namespace: &namespaceID64,
 	}
 
-	_, resp, err := c.client.Projects.TransferProject(int64(projectID), opt)
+	_, resp, err := c.client.Projects.TransferProject(int64(projectID), opt, gitlab.WithContext(ctx))
 	if err != nil {
 		return resp, fmt.Errorf("failed to transfer project: %w", err)
 	}
@@ -128,8 +235,8 @@ func (c *Client) TransferProject(projectID, namespaceID int) (*gitlab.Response,
 }
 
 // ArchiveProject archives a project
-func (c *Client) ArchiveProject(projectID int) (*gitlab.Response, error) {
-	_, resp, err := c.client.Projects.ArchiveProject(int64(projectID))
+func (c *Client) ArchiveProject(ctx context.Context, projectID int) (*gitlab.Response, error) {
+	_, resp, err := c.client.Projects.ArchiveProject(int64(projectID), gitlab.WithContext(ctx))
 	if err != nil {
 		return resp, fmt.Errorf("failed to archive project: %w", err)
 	}
@@ -143,8 +250,8 @@ func (c *Client) ArchiveProject(projectID int) (*gitlab.Response, error) {
 }
 
 // UnarchiveProject unarchives a project
-func (c *Client) UnarchiveProject(projectID int) (*gitlab.Response, error) {
-	_, resp, err := c.client.Projects.UnarchiveProject(int64(projectID))
+func (c *Client) UnarchiveProject(ctx context.Context, projectID int) (*gitlab.Response, error) {
+	_, resp, err := c.client.Projects.UnarchiveProject(int64(projectID), gitlab.WithContext(ctx))
 	if err != nil {
 		return resp, fmt.Errorf("failed to unarchive project: %w", err)
 	}
@@ -158,23 +265,32 @@ func (c *Client) UnarchiveProject(projectID int) (*gitlab.Response, error) {
 }
 
 // ListRegistryRepositories lists container registry repositories for a project
-func (c *Client) ListRegistryRepositories(projectID int) ([]*gitlab.RegistryRepository, *gitlab.Response, error) {
-	return c.client.ContainerRegistry.ListProjectRegistryRepositories(int64(projectID), nil)
+func (c *Client) ListRegistryRepositories(ctx context.Context, projectID int) ([]*gitlab.RegistryRepository, *gitlab.Response, error) {
+	return c.client.ContainerRegistry.ListProjectRegistryRepositories(int64(projectID), nil, gitlab.WithContext(ctx))
 }
 
 // ListRegistryRepositoryTags lists tags for a registry repository
-func (c *Client) ListRegistryRepositoryTags(projectID, repositoryID int) ([]*gitlab.RegistryRepositoryTag, *gitlab.Response, error) {
+func (c *Client) ListRegistryRepositoryTags(ctx context.Context, projectID, repositoryID int) ([]*gitlab.RegistryRepositoryTag, *gitlab.Response, error) {
 	requestOptions := &gitlab.ListRegistryRepositoryTagsOptions{
 		ListOptions: gitlab.ListOptions{
 			PerPage: 100,
 		},
 	}
-	return c.client.ContainerRegistry.ListRegistryRepositoryTags(int64(projectID), int64(repositoryID), requestOptions)
+	return c.client.ContainerRegistry.ListRegistryRepositoryTags(int64(projectID), int64(repositoryID), requestOptions, gitlab.WithContext(ctx))
+}
+
+// GetRegistryRepositoryTagDetail gets the details (digest, size) of a registry repository tag
+func (c *Client) GetRegistryRepositoryTagDetail(ctx context.Context, projectID, repositoryID int, tagName string) (*gitlab.RegistryRepositoryTag, error) {
+	tag, _, err := c.client.ContainerRegistry.GetRegistryRepositoryTagDetail(int64(projectID), int64(repositoryID), tagName, gitlab.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get details of tag %s: %w", tagName, err)
+	}
+	return tag, nil
 }
 
 // DeleteRegistryRepository deletes a registry repository
-func (c *Client) DeleteRegistryRepository(projectID, repositoryID int) (*gitlab.Response, error) {
-	resp, err := c.client.ContainerRegistry.DeleteRegistryRepository(int64(projectID), int64(repositoryID))
+func (c *Client) DeleteRegistryRepository(ctx context.Context, projectID, repositoryID int) (*gitlab.Response, error) {
+	resp, err := c.client.ContainerRegistry.DeleteRegistryRepository(int64(projectID), int64(repositoryID), gitlab.WithContext(ctx))
 	if err != nil {
 		return resp, fmt.Errorf("failed to delete registry repository: %w", err)
 	}
@@ -183,9 +299,9 @@ func (c *Client) DeleteRegistryRepository(projectID, repositoryID int) (*gitlab.
 }
 
 // DeleteRegistryRepositoryTag deletes a specific tag from a registry repository
-func (c *Client) DeleteRegistryRepositoryTag(projectID, repositoryID int, tagName string) (*gitlab.Response, error) {
+func (c *Client) DeleteRegistryRepositoryTag(ctx context.Context, projectID, repositoryID int, tagName string) (*gitlab.Response, error) {
 	// Use the HTTP client directly since DeleteRegistryRepositoryTag might not be in the SDK
-	req, err := c.client.NewRequest("DELETE", fmt.Sprintf("/projects/%d/registry/repositories/%d/tags/%s", projectID, repositoryID, tagName), nil, nil)
+	req, err := c.client.NewRequest("DELETE", fmt.Sprintf("/projects/%d/registry/repositories/%d/tags/%s", projectID, repositoryID, tagName), nil, []gitlab.RequestOptionFunc{gitlab.WithContext(ctx)})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create delete tag request: %w", err)
 	}
@@ -198,14 +314,303 @@ func (c *Client) DeleteRegistryRepositoryTag(projectID, repositoryID int, tagNam
 	return resp, nil
 }
 
+// GetProject retrieves a project by ID
+func (c *Client) GetProject(ctx context.Context, projectID int) (*gitlab.Project, *gitlab.Response, error) {
+	return c.client.Projects.GetProject(int64(projectID), nil, gitlab.WithContext(ctx))
+}
+
+// ListGroupMembers lists the members of a group, including inherited and invited ones if inherited is true
+func (c *Client) ListGroupMembers(ctx context.Context, groupID int, inherited bool) ([]*gitlab.GroupMember, error) {
+	opt := &gitlab.ListGroupMembersOptions{
+		ListOptions: gitlab.ListOptions{
+			PerPage: 100,
+		},
+	}
+
+	var members []*gitlab.GroupMember
+	for {
+		var page []*gitlab.GroupMember
+		var resp *gitlab.Response
+		var err error
+		if inherited {
+			page, resp, err = c.client.Groups.ListAllGroupMembers(int64(groupID), opt, gitlab.WithContext(ctx))
+		} else {
+			page, resp, err = c.client.Groups.ListGroupMembers(int64(groupID), opt, gitlab.WithContext(ctx))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list group members: %w", err)
+		}
+		members = append(members, page...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return members, nil
+}
+
+// AddGroupMember adds a user to a group with the given access level
+func (c *Client) AddGroupMember(ctx context.Context, groupID int, userID int64, accessLevel gitlab.AccessLevelValue, expiresAt string) error {
+	opt := &gitlab.AddGroupMemberOptions{
+		UserID:      &userID,
+		AccessLevel: &accessLevel,
+	}
+	if expiresAt != "" {
+		opt.ExpiresAt = &expiresAt
+	}
+
+	if _, _, err := c.client.GroupMembers.AddGroupMember(int64(groupID), opt, gitlab.WithContext(ctx)); err != nil {
+		return fmt.Errorf("failed to add member %d to group %d: %w", userID, groupID, err)
+	}
+	return nil
+}
+
+// EditGroupMember changes the access level of a direct member of a group
+func (c *Client) EditGroupMember(ctx context.Context, groupID int, userID int64, accessLevel gitlab.AccessLevelValue, expiresAt string) error {
+	opt := &gitlab.EditGroupMemberOptions{
+		AccessLevel: &accessLevel,
+	}
+	if expiresAt != "" {
+		opt.ExpiresAt = &expiresAt
+	}
+
+	if _, _, err := c.client.GroupMembers.EditGroupMember(int64(groupID), userID, opt, gitlab.WithContext(ctx)); err != nil {
+		return fmt.Errorf("failed to edit member %d of group %d: %w", userID, groupID, err)
+	}
+	return nil
+}
+
+// ListProjectMembers lists the members of a project, including inherited and invited ones if inherited is true
+func (c *Client) ListProjectMembers(ctx context.Context, projectID int, inherited bool) ([]*gitlab.ProjectMember, error) {
+	opt := &gitlab.ListProjectMembersOptions{
+		ListOptions: gitlab.ListOptions{
+			PerPage: 100,
+		},
+	}
+
+	var members []*gitlab.ProjectMember
+	for {
+		var page []*gitlab.ProjectMember
+		var resp *gitlab.Response
+		var err error
+		if inherited {
+			page, resp, err = c.client.ProjectMembers.ListAllProjectMembers(int64(projectID), opt, gitlab.WithContext(ctx))
+		} else {
+			page, resp, err = c.client.ProjectMembers.ListProjectMembers(int64(projectID), opt, gitlab.WithContext(ctx))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list project members: %w", err)
+		}
+		members = append(members, page...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return members, nil
+}
+
+// AddProjectMember adds a user to a project with the given access level
+func (c *Client) AddProjectMember(ctx context.Context, projectID int, userID int64, accessLevel gitlab.AccessLevelValue, expiresAt string) error {
+	opt := &gitlab.AddProjectMemberOptions{
+		UserID:      userID,
+		AccessLevel: &accessLevel,
+	}
+	if expiresAt != "" {
+		opt.ExpiresAt = &expiresAt
+	}
+
+	if _, _, err := c.client.ProjectMembers.AddProjectMember(int64(projectID), opt, gitlab.WithContext(ctx)); err != nil {
+		return fmt.Errorf("failed to add member %d to project %d: %w", userID, projectID, err)
+	}
+	return nil
+}
+
+// EditProjectMember changes the access level of a direct member of a project
+func (c *Client) EditProjectMember(ctx context.Context, projectID int, userID int64, accessLevel gitlab.AccessLevelValue, expiresAt string) error {
+	opt := &gitlab.EditProjectMemberOptions{
+		AccessLevel: &accessLevel,
+	}
+	if expiresAt != "" {
+		opt.ExpiresAt = &expiresAt
+	}
+
+	if _, _, err := c.client.ProjectMembers.EditProjectMember(int64(projectID), userID, opt, gitlab.WithContext(ctx)); err != nil {
+		return fmt.Errorf("failed to edit member %d of project %d: %w", userID, projectID, err)
+	}
+	return nil
+}
+
+// ShareProjectWithGroup shares a project with a group at the given access level
+func (c *Client) ShareProjectWithGroup(ctx context.Context, projectID int, groupID int64, accessLevel gitlab.AccessLevelValue, expiresAt string) error {
+	opt := &gitlab.ShareWithGroupOptions{
+		GroupID:     &groupID,
+		GroupAccess: &accessLevel,
+	}
+	if expiresAt != "" {
+		opt.ExpiresAt = &expiresAt
+	}
+
+	if _, err := c.client.Projects.ShareProjectWithGroup(int64(projectID), opt, gitlab.WithContext(ctx)); err != nil {
+		return fmt.Errorf("failed to share project %d with group %d: %w", projectID, groupID, err)
+	}
+	return nil
+}
+
+// ShareGroupWithGroup shares a group with another group at the given access level
+func (c *Client) ShareGroupWithGroup(ctx context.Context, groupID int, sharedWithGroupID int64, accessLevel gitlab.AccessLevelValue, expiresAt *gitlab.ISOTime) error {
+	opt := &gitlab.ShareGroupWithGroupOptions{
+		GroupID:     &sharedWithGroupID,
+		GroupAccess: &accessLevel,
+		ExpiresAt:   expiresAt,
+	}
+
+	if _, _, err := c.client.Groups.ShareGroupWithGroup(int64(groupID), opt, gitlab.WithContext(ctx)); err != nil {
+		return fmt.Errorf("failed to share group %d with group %d: %w", groupID, sharedWithGroupID, err)
+	}
+	return nil
+}
+
+// GetProjectByPath retrieves a project by its full path, returning nil if it does not exist
+func (c *Client) GetProjectByPath(ctx context.Context, fullPath string) (*gitlab.Project, error) {
+	project, _, err := c.client.Projects.GetProject(fullPath, nil, gitlab.WithContext(ctx))
+	if err != nil {
+		if errors.Is(err, gitlab.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get project %s: %w", fullPath, err)
+	}
+	return project, nil
+}
+
+// GetProjectStorageSize returns the total storage size of a project (repository, registry, packages, ...)
+func (c *Client) GetProjectStorageSize(ctx context.Context, projectID int) (int64, error) {
+	withStatistics := true
+	project, _, err := c.client.Projects.GetProject(int64(projectID), &gitlab.GetProjectOptions{Statistics: &withStatistics}, gitlab.WithContext(ctx))
+	if err != nil {
+		return 0, fmt.Errorf("failed to get project %d statistics: %w", projectID, err)
+	}
+	if project.Statistics == nil {
+		return 0, fmt.Errorf("statistics of project %d are not available", projectID)
+	}
+	return project.Statistics.StorageSize, nil
+}
+
+// GetGroupAccessLevel returns the effective access level of a user on a group, including inherited memberships
+func (c *Client) GetGroupAccessLevel(ctx context.Context, groupID int, userID int64) (gitlab.AccessLevelValue, error) {
+	member, _, err := c.client.GroupMembers.GetInheritedGroupMember(int64(groupID), userID, gitlab.WithContext(ctx))
+	if err != nil {
+		if errors.Is(err, gitlab.ErrNotFound) {
+			return gitlab.NoPermissions, nil
+		}
+		return gitlab.NoPermissions, fmt.Errorf("failed to get member %d of group %d: %w", userID, groupID, err)
+	}
+	return member.AccessLevel, nil
+}
+
+// GetProjectAccessLevel returns the effective access level of a user on a project, including inherited memberships
+func (c *Client) GetProjectAccessLevel(ctx context.Context, projectID int, userID int64) (gitlab.AccessLevelValue, error) {
+	member, _, err := c.client.ProjectMembers.GetInheritedProjectMember(int64(projectID), userID, gitlab.WithContext(ctx))
+	if err != nil {
+		if errors.Is(err, gitlab.ErrNotFound) {
+			return gitlab.NoPermissions, nil
+		}
+		return gitlab.NoPermissions, fmt.Errorf("failed to get member %d of project %d: %w", userID, projectID, err)
+	}
+	return member.AccessLevel, nil
+}
+
+// GetTokenScopes returns the scopes of the personal access token used by the client
+func (c *Client) GetTokenScopes(ctx context.Context) ([]string, error) {
+	token, _, err := c.client.PersonalAccessTokens.GetSinglePersonalAccessToken(gitlab.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get personal access token: %w", err)
+	}
+	return token.Scopes, nil
+}
+
+// ListActivePipelines lists the running and pending pipelines of a project
+func (c *Client) ListActivePipelines(ctx context.Context, projectID int) ([]*gitlab.PipelineInfo, error) {
+	var pipelines []*gitlab.PipelineInfo
+	for _, scope := range []string{"running", "pending"} {
+		opt := &gitlab.ListProjectPipelinesOptions{
+			ListOptions: gitlab.ListOptions{
+				PerPage: 100,
+			},
+			Scope: &scope,
+		}
+		page, _, err := c.client.Pipelines.ListProjectPipelines(int64(projectID), opt, gitlab.WithContext(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s pipelines: %w", scope, err)
+		}
+		pipelines = append(pipelines, page...)
+	}
+	return pipelines, nil
+}
+
+// NamespaceStorage holds the storage usage and limit of a root namespace, in bytes.
+// Limit is 0 when the instance does not enforce a storage limit.
+type NamespaceStorage struct {
+	Used  int64
+	Limit int64
+}
+
+// GetNamespaceStorage returns the storage usage and limit of the root namespace of a group
+func (c *Client) GetNamespaceStorage(ctx context.Context, fullPath string) (*NamespaceStorage, error) {
+	rootPath := strings.Split(strings.Trim(fullPath, "/"), "/")[0]
+	query := gitlab.GraphQLQuery{
+		Query: `query($fullPath: ID!) {
+  namespace(fullPath: $fullPath) {
+    storageSizeLimit
+    additionalPurchasedStorageSize
+    rootStorageStatistics { storageSize }
+  }
+}`,
+		Variables: map[string]any{"fullPath": rootPath},
+	}
+
+	var response struct {
+		Data struct {
+			Namespace *struct {
+				StorageSizeLimit               float64 `json:"storageSizeLimit"`
+				AdditionalPurchasedStorageSize float64 `json:"additionalPurchasedStorageSize"`
+				RootStorageStatistics          *struct {
+					StorageSize float64 `json:"storageSize"`
+				} `json:"rootStorageStatistics"`
+			} `json:"namespace"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if _, err := c.client.GraphQL.Do(query, &response, gitlab.WithContext(ctx)); err != nil {
+		return nil, fmt.Errorf("failed to get storage of namespace %s: %w", rootPath, err)
+	}
+	if len(response.Errors) > 0 {
+		return nil, fmt.Errorf("failed to get storage of namespace %s: %s", rootPath, response.Errors[0].Message)
+	}
+	if response.Data.Namespace == nil {
+		return nil, fmt.Errorf("namespace %s not found", rootPath)
+	}
+
+	storage := &NamespaceStorage{}
+	if response.Data.Namespace.StorageSizeLimit > 0 {
+		storage.Limit = int64(response.Data.Namespace.StorageSizeLimit + response.Data.Namespace.AdditionalPurchasedStorageSize)
+	}
+	if response.Data.Namespace.RootStorageStatistics != nil {
+		storage.Used = int64(response.Data.Namespace.RootStorageStatistics.StorageSize)
+	}
+	return storage, nil
+}
+
 // GetCurrentUser gets the current authenticated user
-func (c *Client) GetCurrentUser() (*gitlab.User, *gitlab.Response, error) {
-	return c.client.Users.CurrentUser()
+func (c *Client) GetCurrentUser(ctx context.Context) (*gitlab.User, *gitlab.Response, error) {
+	return c.client.Users.CurrentUser(gitlab.WithContext(ctx))
 }
 
 // CheckConnection verifies that the client can connect to GitLab
-func (c *Client) CheckConnection() error {
-	_, _, err := c.GetCurrentUser()
+func (c *Client) CheckConnection(ctx context.Context) error {
+	_, _, err := c.GetCurrentUser(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to connect to GitLab: %w", err)
 	}
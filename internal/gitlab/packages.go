@@ -0,0 +1,291 @@
+package gitlab
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// Package types whose files can be downloaded and published again through the API
+const (
+	PackageTypeGeneric         = "generic"
+	PackageTypeNpm             = "npm"
+	PackageTypeMaven           = "maven"
+	PackageTypePyPI            = "pypi"
+	PackageTypeTerraformModule = "terraform_module"
+)
+
+// ListProjectPackages lists the packages of a project's package registry
+func (c *Client) ListProjectPackages(ctx context.Context, projectID int) ([]*gitlab.Package, error) {
+	opt := &gitlab.ListProjectPackagesOptions{
+		ListOptions: gitlab.ListOptions{
+			PerPage: 100,
+		},
+	}
+
+	var packages []*gitlab.Package
+	for {
+		page, resp, err := c.client.Packages.ListProjectPackages(int64(projectID), opt, gitlab.WithContext(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list packages of project %d: %w", projectID, err)
+		}
+		packages = append(packages, page...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return packages, nil
+}
+
+// ListPackageFiles lists the files of a package
+func (c *Client) ListPackageFiles(ctx context.Context, projectID int, packageID int64) ([]*gitlab.PackageFile, error) {
+	opt := &gitlab.ListPackageFilesOptions{
+		ListOptions: gitlab.ListOptions{
+			PerPage: 100,
+		},
+	}
+
+	var files []*gitlab.PackageFile
+	for {
+		page, resp, err := c.client.Packages.ListPackageFiles(int64(projectID), packageID, opt, gitlab.WithContext(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list files of package %d: %w", packageID, err)
+		}
+		files = append(files, page...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return files, nil
+}
+
+// DeleteProjectPackage deletes a package and all its files
+func (c *Client) DeleteProjectPackage(ctx context.Context, projectID int, packageID int64) error {
+	if _, err := c.client.Packages.DeleteProjectPackage(int64(projectID), packageID, gitlab.WithContext(ctx)); err != nil {
+		return fmt.Errorf("failed to delete package %d: %w", packageID, err)
+	}
+	return nil
+}
+
+// DownloadPackageFile writes the content of a package file to w, using the download endpoint of the package type
+func (c *Client) DownloadPackageFile(ctx context.Context, projectID int, pkg *gitlab.Package, file *gitlab.PackageFile, w io.Writer) error {
+	var u string
+	switch pkg.PackageType {
+	case PackageTypeGeneric:
+		u = fmt.Sprintf("projects/%d/packages/generic/%s/%s/%s", projectID, gitlab.PathEscape(pkg.Name), gitlab.PathEscape(pkg.Version), gitlab.PathEscape(file.FileName))
+	case PackageTypeNpm:
+		u = fmt.Sprintf("projects/%d/packages/npm/%s/-/%s", projectID, gitlab.PathEscape(pkg.Name), gitlab.PathEscape(file.FileName))
+	case PackageTypeMaven:
+		u = fmt.Sprintf("projects/%d/packages/maven/%s/%s/%s", projectID, pkg.Name, gitlab.PathEscape(pkg.Version), gitlab.PathEscape(file.FileName))
+	case PackageTypePyPI:
+		u = fmt.Sprintf("projects/%d/packages/pypi/files/%s/%s", projectID, file.FileSHA256, gitlab.PathEscape(file.FileName))
+	case PackageTypeTerraformModule:
+		// Modules are only downloaded through the registry of the root namespace of their project
+		project, _, err := c.GetProject(ctx, projectID)
+		if err != nil {
+			return fmt.Errorf("failed to get project %d: %w", projectID, err)
+		}
+		moduleName, system, err := splitTerraformModule(pkg.Name)
+		if err != nil {
+			return err
+		}
+		namespace := strings.Split(project.PathWithNamespace, "/")[0]
+		u = fmt.Sprintf("packages/terraform/modules/v1/%s/%s/%s/%s/file", gitlab.PathEscape(namespace), gitlab.PathEscape(moduleName), gitlab.PathEscape(system), gitlab.PathEscape(pkg.Version))
+	default:
+		return fmt.Errorf("cannot download files of %s packages", pkg.PackageType)
+	}
+
+	req, err := c.client.NewRequest(http.MethodGet, u, nil, []gitlab.RequestOptionFunc{gitlab.WithContext(ctx)})
+	if err != nil {
+		return fmt.Errorf("failed to create download request: %w", err)
+	}
+	if _, err := c.client.Do(req, w); err != nil {
+		return fmt.Errorf("failed to download %s of package %s %s: %w", file.FileName, pkg.Name, pkg.Version, err)
+	}
+	return nil
+}
+
+// PublishPackageFile publishes a file of a package to a project, using the upload endpoint of the package type.
+// npm and PyPI packages are published with the metadata the registry expects, read from the file itself.
+func (c *Client) PublishPackageFile(ctx context.Context, projectID int, pkg *gitlab.Package, fileName string, content io.Reader) error {
+	var err error
+	switch pkg.PackageType {
+	case PackageTypeGeneric:
+		_, _, err = c.client.GenericPackages.PublishPackageFile(int64(projectID), pkg.Name, pkg.Version, fileName, content, nil, gitlab.WithContext(ctx))
+	case PackageTypeNpm:
+		err = c.publishNpmPackage(ctx, projectID, pkg, fileName, content)
+	case PackageTypeMaven:
+		err = c.putPackageFile(ctx, fmt.Sprintf("projects/%d/packages/maven/%s/%s/%s", projectID, pkg.Name, gitlab.PathEscape(pkg.Version), gitlab.PathEscape(fileName)), content)
+	case PackageTypePyPI:
+		err = c.publishPyPIPackage(ctx, projectID, pkg, fileName, content)
+	case PackageTypeTerraformModule:
+		var moduleName, system string
+		if moduleName, system, err = splitTerraformModule(pkg.Name); err == nil {
+			err = c.putPackageFile(ctx, fmt.Sprintf("projects/%d/packages/terraform/modules/%s/%s/%s/file", projectID, gitlab.PathEscape(moduleName), gitlab.PathEscape(system), gitlab.PathEscape(pkg.Version)), content)
+		}
+	default:
+		return fmt.Errorf("cannot publish files of %s packages", pkg.PackageType)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to publish %s of package %s %s: %w", fileName, pkg.Name, pkg.Version, err)
+	}
+	return nil
+}
+
+// putPackageFile uploads the raw content of a file
+func (c *Client) putPackageFile(ctx context.Context, u string, content io.Reader) error {
+	// The request is created without body, then the content is set as is instead of JSON encoded
+	req, err := c.client.NewRequest(http.MethodGet, u, nil, []gitlab.RequestOptionFunc{gitlab.WithContext(ctx)})
+	if err != nil {
+		return err
+	}
+	req.Method = http.MethodPut
+	if err := req.SetBody(content); err != nil {
+		return err
+	}
+	_, err = c.client.Do(req, nil)
+	return err
+}
+
+// npmPublishRequest is the document the npm client sends to publish a version of a package
+type npmPublishRequest struct {
+	Name        string                       `json:"name"`
+	Versions    map[string]map[string]any    `json:"versions"`
+	DistTags    map[string]string            `json:"dist-tags"`
+	Attachments map[string]npmPublishTarball `json:"_attachments"`
+}
+
+type npmPublishTarball struct {
+	ContentType string `json:"content_type"`
+	Data        string `json:"data"`
+	Length      int    `json:"length"`
+}
+
+// publishNpmPackage publishes an npm tarball with the manifest it contains, as npm publish does
+func (c *Client) publishNpmPackage(ctx context.Context, projectID int, pkg *gitlab.Package, fileName string, content io.Reader) error {
+	tarball, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	manifest, err := npmManifest(tarball)
+	if err != nil {
+		return err
+	}
+
+	sum := sha1.Sum(tarball)
+	manifest["name"] = pkg.Name
+	manifest["version"] = pkg.Version
+	manifest["_id"] = pkg.Name + "@" + pkg.Version
+	manifest["dist"] = map[string]string{
+		"shasum":  hex.EncodeToString(sum[:]),
+		"tarball": fmt.Sprintf("%s/projects/%d/packages/npm/%s/-/%s", c.baseURL, projectID, pkg.Name, fileName),
+	}
+
+	body := &npmPublishRequest{
+		Name:     pkg.Name,
+		Versions: map[string]map[string]any{pkg.Version: manifest},
+		DistTags: map[string]string{"latest": pkg.Version},
+		Attachments: map[string]npmPublishTarball{fileName: {
+			ContentType: "application/octet-stream",
+			Data:        base64.StdEncoding.EncodeToString(tarball),
+			Length:      len(tarball),
+		}},
+	}
+	for _, tag := range pkg.Tags {
+		body.DistTags[tag.Name] = pkg.Version
+	}
+
+	req, err := c.client.NewRequest(http.MethodPut, fmt.Sprintf("projects/%d/packages/npm/%s", projectID, gitlab.PathEscape(pkg.Name)), body, []gitlab.RequestOptionFunc{gitlab.WithContext(ctx)})
+	if err != nil {
+		return err
+	}
+	_, err = c.client.Do(req, nil)
+	return err
+}
+
+// npmManifest returns the package.json of an npm tarball
+func npmManifest(tarball []byte) (map[string]any, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(tarball))
+	if err != nil {
+		return nil, fmt.Errorf("invalid npm tarball: %w", err)
+	}
+	defer gz.Close()
+
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("invalid npm tarball: package.json not found")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid npm tarball: %w", err)
+		}
+		// npm packs files below a single top-level directory, usually "package"
+		if header.Typeflag != tar.TypeReg || strings.Count(header.Name, "/") != 1 || path.Base(header.Name) != "package.json" {
+			continue
+		}
+
+		var manifest map[string]any
+		if err := json.NewDecoder(archive).Decode(&manifest); err != nil {
+			return nil, fmt.Errorf("invalid package.json in npm tarball: %w", err)
+		}
+		return manifest, nil
+	}
+}
+
+// pypiUploadOptions are the form fields of a PyPI upload
+type pypiUploadOptions struct {
+	Name         string `url:"name"`
+	Version      string `url:"version"`
+	MD5Digest    string `url:"md5_digest"`
+	SHA256Digest string `url:"sha256_digest"`
+}
+
+// publishPyPIPackage uploads a wheel or source distribution, as twine does
+func (c *Client) publishPyPIPackage(ctx context.Context, projectID int, pkg *gitlab.Package, fileName string, content io.Reader) error {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	md5Sum := md5.Sum(data)
+	sha256Sum := sha256.Sum256(data)
+	opt := &pypiUploadOptions{
+		Name:         pkg.Name,
+		Version:      pkg.Version,
+		MD5Digest:    hex.EncodeToString(md5Sum[:]),
+		SHA256Digest: hex.EncodeToString(sha256Sum[:]),
+	}
+
+	req, err := c.client.UploadRequest(http.MethodPost, fmt.Sprintf("projects/%d/packages/pypi", projectID), bytes.NewReader(data), fileName, "content", opt, []gitlab.RequestOptionFunc{gitlab.WithContext(ctx)})
+	if err != nil {
+		return err
+	}
+	_, err = c.client.Do(req, nil)
+	return err
+}
+
+// splitTerraformModule returns the name and the provider system of a Terraform module package named name/system
+func splitTerraformModule(name string) (string, string, error) {
+	moduleName, system, found := strings.Cut(name, "/")
+	if !found || moduleName == "" || system == "" {
+		return "", "", fmt.Errorf("invalid Terraform module name %s, expected <name>/<system>", name)
+	}
+	return moduleName, system, nil
+}
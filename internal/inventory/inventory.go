@@ -0,0 +1,281 @@
+// Package inventory lists the projects of a group tree with their container registry repositories,
+// to size and plan migrations and cleanups without running them.
+package inventory
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"migraptor/internal/migration"
+	"migraptor/internal/ui"
+)
+
+// Output formats of an inventory
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+	FormatCSV   = "csv"
+)
+
+// Formats are the supported output formats
+var Formats = []string{FormatTable, FormatJSON, FormatCSV}
+
+// Client is the subset of the GitLab API read to build an inventory
+type Client interface {
+	migration.GroupClient
+	migration.RegistryClient
+}
+
+// Entry is a registry repository of a project. Projects without registry repository have a single entry
+// with an empty repository.
+type Entry struct {
+	Project        string     `json:"project"`
+	Archived       bool       `json:"archived"`
+	LastActivityAt *time.Time `json:"last_activity_at,omitempty"`
+	Topics         []string   `json:"topics,omitempty"`
+	Repository     string     `json:"repository,omitempty"`
+	Tags           int        `json:"tags"`
+	// Size is the sum of the sizes of the tags, counting each digest once
+	Size int64 `json:"size"`
+	// LastUpdatedAt is the creation date of the most recent tag
+	LastUpdatedAt *time.Time `json:"last_updated_at,omitempty"`
+	// TagDetails are the tags whose details could be read
+	TagDetails []*Tag `json:"-"`
+}
+
+// Tag is a registry repository tag with its size and creation date
+type Tag struct {
+	Location  string     `json:"location"`
+	Digest    string     `json:"digest,omitempty"`
+	Size      int64      `json:"size"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+// Inventory is the content of a group tree
+type Inventory struct {
+	// Group is the full path of the group walked
+	Group string
+	// SubGroups are the full paths of the sub-groups walked, sorted
+	SubGroups []string
+	Entries   []*Entry
+}
+
+// Collector walks a group tree and reads the registries of its projects
+type Collector struct {
+	client    Client
+	consoleUI *ui.UI
+}
+
+// NewCollector creates a new Collector
+func NewCollector(client Client, cUI *ui.UI) *Collector {
+	return &Collector{
+		client:    client,
+		consoleUI: cUI,
+	}
+}
+
+// Collect lists the projects of a group and its sub-groups included by the selection, all of them if it is nil,
+// with the tags of their registry repositories, optionally filtered by name.
+// Entries are sorted by project then repository path.
+func (c *Collector) Collect(ctx context.Context, groupName string, selection *migration.Selection, tagFilter []string) (*Inventory, error) {
+	groupMigrator := migration.NewGroupMigrator(c.client, true, c.consoleUI)
+	// Only registry methods of the image migrator are used, it needs no container engine
+	imageMigrator := migration.NewImageMigrator(c.client, nil, true, c.consoleUI)
+
+	c.consoleUI.Info("🔍 Searching for source group...")
+	group, err := groupMigrator.SearchGroup(ctx, groupName)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", migration.ErrGroupNotFound, err)
+	}
+	if group == nil {
+		return nil, fmt.Errorf("%w: %s", migration.ErrGroupNotFound, groupName)
+	}
+
+	projects, _, err := c.client.ListProjects(ctx, int(group.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list projects: %w", err)
+	}
+	allProjects := make(map[int]*migration.ProjectInfo)
+	for _, project := range migration.FilterProjects(projects, selection) {
+		allProjects[project.ID] = &project
+	}
+	subGroups, subProjects, err := groupMigrator.GetSubGroupsAndProjects(ctx, group.ID, selection)
+	if err != nil {
+		c.consoleUI.Warning("Failed to list some sub-groups: %v", err)
+	}
+	maps.Copy(allProjects, subProjects)
+	c.consoleUI.Info("📦 Found %d projects in %d sub-groups", len(allProjects), len(subGroups))
+
+	var entries []*Entry
+	for _, project := range allProjects {
+		projectEntries, err := c.collectProject(ctx, imageMigrator, project, tagFilter)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, projectEntries...)
+	}
+	slices.SortFunc(entries, func(a, b *Entry) int {
+		if n := strings.Compare(a.Project, b.Project); n != 0 {
+			return n
+		}
+		return strings.Compare(a.Repository, b.Repository)
+	})
+	var subGroupPaths []string
+	for _, subGroup := range subGroups {
+		subGroupPaths = append(subGroupPaths, subGroup.FullPath)
+	}
+	slices.Sort(subGroupPaths)
+	return &Inventory{Group: group.FullPath, SubGroups: subGroupPaths, Entries: entries}, nil
+}
+
+// collectProject returns the entries of the registry repositories of a project
+func (c *Collector) collectProject(ctx context.Context, imageMigrator *migration.ImageMigrator, project *migration.ProjectInfo, tagFilter []string) ([]*Entry, error) {
+	newEntry := func() *Entry {
+		return &Entry{
+			Project:        project.PathWithNamespace,
+			Archived:       project.Archived,
+			LastActivityAt: project.LastActivityAt,
+			Topics:         project.Topics,
+		}
+	}
+	if !project.ContainerRegistryEnabled {
+		return []*Entry{newEntry()}, nil
+	}
+
+	repositories, _, err := c.client.ListRegistryRepositories(ctx, project.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list registry repositories of project %s: %w", project.PathWithNamespace, err)
+	}
+	if len(repositories) == 0 {
+		return []*Entry{newEntry()}, nil
+	}
+
+	var entries []*Entry
+	for _, repo := range repositories {
+		images, err := imageMigrator.GetImages(ctx, project.ID, int(repo.ID), tagFilter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tags of repository %s: %w", repo.Path, err)
+		}
+
+		entry := newEntry()
+		entry.Repository = repo.Path
+		entry.Tags = len(images)
+		seen := make(map[string]bool)
+		for _, img := range images {
+			tag, err := c.client.GetRegistryRepositoryTagDetail(ctx, project.ID, int(repo.ID), img.Name)
+			if err != nil {
+				c.consoleUI.Debug("Cannot get details of %s: %v", img.Location, err)
+				continue
+			}
+			entry.TagDetails = append(entry.TagDetails, &Tag{
+				Location:  img.Location,
+				Digest:    tag.Digest,
+				Size:      tag.TotalSize,
+				CreatedAt: tag.CreatedAt,
+			})
+			if tag.CreatedAt != nil && (entry.LastUpdatedAt == nil || tag.CreatedAt.After(*entry.LastUpdatedAt)) {
+				entry.LastUpdatedAt = tag.CreatedAt
+			}
+			if tag.Digest != "" {
+				if seen[tag.Digest] {
+					continue
+				}
+				seen[tag.Digest] = true
+			}
+			entry.Size += tag.TotalSize
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Write renders the entries in the given format
+func Write(w io.Writer, entries []*Entry, format string) error {
+	switch format {
+	case FormatTable:
+		return writeTable(w, entries)
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if entries == nil {
+			entries = []*Entry{}
+		}
+		return encoder.Encode(entries)
+	case FormatCSV:
+		return writeCSV(w, entries)
+	default:
+		return fmt.Errorf("unknown inventory format %s, expected one of %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+// writeTable writes aligned columns with human readable sizes and dates, followed by the totals
+func writeTable(w io.Writer, entries []*Entry) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROJECT\tARCHIVED\tLAST ACTIVITY\tREPOSITORY\tTAGS\tSIZE\tLAST UPDATED")
+
+	projects := make(map[string]bool)
+	tags := 0
+	var size int64
+	for _, entry := range entries {
+		projects[entry.Project] = true
+		tags += entry.Tags
+		size += entry.Size
+
+		repository, tagCount, tagSize := "-", "-", "-"
+		if entry.Repository != "" {
+			repository, tagCount, tagSize = entry.Repository, strconv.Itoa(entry.Tags), ui.FormatBytes(entry.Size)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", entry.Project, yesNo(entry.Archived), formatDate(entry.LastActivityAt, time.DateOnly, "-"),
+			repository, tagCount, tagSize, formatDate(entry.LastUpdatedAt, time.DateOnly, "-"))
+	}
+	fmt.Fprintf(tw, "TOTAL\t\t\t%d projects\t%d\t%s\n", len(projects), tags, ui.FormatBytes(size))
+	return tw.Flush()
+}
+
+// writeCSV writes a header and one record per entry, with sizes in bytes and RFC 3339 dates
+func writeCSV(w io.Writer, entries []*Entry) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"project", "archived", "last_activity_at", "repository", "tags", "size", "last_updated_at"}); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		record := []string{
+			entry.Project,
+			strconv.FormatBool(entry.Archived),
+			formatDate(entry.LastActivityAt, time.RFC3339, ""),
+			entry.Repository,
+			strconv.Itoa(entry.Tags),
+			strconv.FormatInt(entry.Size, 10),
+			formatDate(entry.LastUpdatedAt, time.RFC3339, ""),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// formatDate formats an optional date, returning empty when it is not set
+func formatDate(t *time.Time, layout, empty string) string {
+	if t == nil {
+		return empty
+	}
+	return t.Format(layout)
+}
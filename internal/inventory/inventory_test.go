@@ -0,0 +1,172 @@
+package inventory
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"migraptor/internal/fake"
+	"migraptor/internal/migration"
+	"migraptor/internal/ui"
+)
+
+const testRegistry = "registry.example.com"
+
+var _ Client = (*fake.GitLab)(nil)
+
+// newTestGitLab creates a group tree with an archived project, a project without images
+// and a project with two repositories, one holding two tags of the same image
+func newTestGitLab(t *testing.T) *fake.GitLab {
+	t.Helper()
+	gl := fake.NewGitLab(testRegistry)
+	gl.AddProject("team/app")
+	gl.AddProject("team/docs")
+	legacy := gl.AddProject("team/backend/legacy")
+	gl.AddProject("other/app")
+
+	images := map[string]int64{
+		testRegistry + "/team/app:1.0":               10,
+		testRegistry + "/team/app:2.0":               20,
+		testRegistry + "/team/app/worker:1.0":        5,
+		testRegistry + "/team/backend/legacy:latest": 100,
+		testRegistry + "/other/app:1.0":              1000,
+	}
+	for imageRef, size := range images {
+		if err := gl.AddImage(imageRef, size); err != nil {
+			t.Fatalf("AddImage(%s) failed: %v", imageRef, err)
+		}
+	}
+	if _, err := gl.ArchiveProject(t.Context(), int(legacy.ID)); err != nil {
+		t.Fatalf("ArchiveProject failed: %v", err)
+	}
+	return gl
+}
+
+func TestCollect(t *testing.T) {
+	gl := newTestGitLab(t)
+	collector := NewCollector(gl, ui.New(false, io.Discard))
+
+	inv, err := collector.Collect(t.Context(), "team", nil, nil)
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	if inv.Group != "team" {
+		t.Errorf("Expected group team, got %s", inv.Group)
+	}
+	entries := inv.Entries
+
+	type row struct {
+		project, repository string
+		archived            bool
+		tags                int
+		size                int64
+	}
+	expected := []row{
+		{"team/app", "team/app", false, 2, 30},
+		{"team/app", "team/app/worker", false, 1, 5},
+		{"team/backend/legacy", "team/backend/legacy", true, 1, 100},
+		{"team/docs", "", false, 0, 0},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d entries, got %d", len(expected), len(entries))
+	}
+	for i, entry := range entries {
+		got := row{entry.Project, entry.Repository, entry.Archived, entry.Tags, entry.Size}
+		if got != expected[i] {
+			t.Errorf("Expected entry %d to be %+v, got %+v", i, expected[i], got)
+		}
+		if entry.LastActivityAt == nil {
+			t.Errorf("Expected last activity of %s to be set", entry.Project)
+		}
+		if (entry.LastUpdatedAt != nil) != (entry.Repository != "") {
+			t.Errorf("Expected last update of %s only for repositories, got %v", entry.Project, entry.LastUpdatedAt)
+		}
+		if len(entry.TagDetails) != entry.Tags {
+			t.Errorf("Expected %d tag details for %s, got %d", entry.Tags, entry.Repository, len(entry.TagDetails))
+		}
+	}
+}
+
+func TestCollect_Filters(t *testing.T) {
+	gl := newTestGitLab(t)
+	collector := NewCollector(gl, ui.New(false, io.Discard))
+
+	selection, err := migration.NewSelection(migration.SelectionRules{Projects: []string{"app"}})
+	if err != nil {
+		t.Fatalf("NewSelection failed: %v", err)
+	}
+	inv, err := collector.Collect(t.Context(), "team", selection, []string{"1.0"})
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	entries := inv.Entries
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+	if entries[0].Tags != 1 || entries[0].Size != 10 {
+		t.Errorf("Expected 1 tag of 10 bytes in team/app, got %d tags of %d bytes", entries[0].Tags, entries[0].Size)
+	}
+
+	if _, err := collector.Collect(t.Context(), "missing", nil, nil); !errors.Is(err, migration.ErrGroupNotFound) {
+		t.Errorf("Expected ErrGroupNotFound, got %v", err)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	updated := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	entries := []*Entry{
+		{Project: "team/app", Repository: "team/app", Tags: 2, Size: 2048, LastUpdatedAt: &updated},
+		{Project: "team/docs", Archived: true},
+	}
+
+	var table bytes.Buffer
+	if err := Write(&table, entries, FormatTable); err != nil {
+		t.Fatalf("Write table failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(table.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected header, 2 rows and total, got %q", table.String())
+	}
+	if !strings.Contains(lines[1], "2.0 KiB") || !strings.Contains(lines[1], "2026-03-01") {
+		t.Errorf("Expected size and date in %q", lines[1])
+	}
+	if fields := strings.Fields(lines[2]); fields[1] != "yes" || fields[3] != "-" {
+		t.Errorf("Expected archived project without repository, got %q", lines[2])
+	}
+	if !strings.HasPrefix(lines[3], "TOTAL") || !strings.Contains(lines[3], "2 projects") {
+		t.Errorf("Expected totals, got %q", lines[3])
+	}
+
+	var jsonOut bytes.Buffer
+	if err := Write(&jsonOut, entries, FormatJSON); err != nil {
+		t.Fatalf("Write JSON failed: %v", err)
+	}
+	var decoded []*Entry
+	if err := json.Unmarshal(jsonOut.Bytes(), &decoded); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if len(decoded) != 2 || decoded[0].Size != 2048 || !decoded[1].Archived {
+		t.Errorf("Expected entries to round trip, got %s", jsonOut.String())
+	}
+
+	var csvOut bytes.Buffer
+	if err := Write(&csvOut, entries, FormatCSV); err != nil {
+		t.Fatalf("Write CSV failed: %v", err)
+	}
+	records, err := csv.NewReader(&csvOut).ReadAll()
+	if err != nil {
+		t.Fatalf("Invalid CSV: %v", err)
+	}
+	if len(records) != 3 || records[1][5] != "2048" || records[1][6] != "2026-03-01T12:00:00Z" || records[2][1] != "true" {
+		t.Errorf("Unexpected CSV records %v", records)
+	}
+
+	if err := Write(io.Discard, entries, "xml"); err == nil {
+		t.Error("Expected unknown format to fail")
+	}
+}
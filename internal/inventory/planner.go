@@ -0,0 +1,74 @@
+package inventory
+
+import (
+	"path"
+	"strings"
+
+	"migraptor/internal/migration"
+	"migraptor/internal/ui"
+)
+
+// PlannerTree returns the group tree of the inventory for the migration planner.
+// Projects are ticked when the selection includes them, sub-groups when the selection does not exclude them.
+func (inv *Inventory) PlannerTree(selection *migration.Selection) *ui.PlannerGroup {
+	root := &ui.PlannerGroup{FullPath: inv.Group, Selected: true}
+	groups := map[string]*ui.PlannerGroup{inv.Group: root}
+
+	// Sub-groups are sorted, so that a parent is always added before its children
+	var addGroup func(fullPath string) *ui.PlannerGroup
+	addGroup = func(fullPath string) *ui.PlannerGroup {
+		if group, found := groups[fullPath]; found {
+			return group
+		}
+		group := &ui.PlannerGroup{FullPath: fullPath, Selected: selection.ExcludedGroup(fullPath) == ""}
+		groups[fullPath] = group
+		parent := addGroup(path.Dir(fullPath))
+		parent.Groups = append(parent.Groups, group)
+		return group
+	}
+	for _, subGroup := range inv.SubGroups {
+		if strings.HasPrefix(subGroup, inv.Group+"/") {
+			addGroup(subGroup)
+		}
+	}
+
+	var project *ui.PlannerProject
+	for _, entry := range inv.Entries {
+		if project == nil || project.PathWithNamespace != entry.Project {
+			info := &migration.ProjectInfo{
+				Path:              path.Base(entry.Project),
+				PathWithNamespace: entry.Project,
+				NamespacePath:     path.Dir(entry.Project),
+				Archived:          entry.Archived,
+				LastActivityAt:    entry.LastActivityAt,
+				Topics:            entry.Topics,
+			}
+			project = &ui.PlannerProject{
+				Path:              info.Path,
+				PathWithNamespace: info.PathWithNamespace,
+				NamespacePath:     info.NamespacePath,
+				Archived:          info.Archived,
+				Selected:          selection.Includes(info),
+			}
+			group := root
+			if info.NamespacePath != inv.Group {
+				group = addGroup(info.NamespacePath)
+			}
+			group.Projects = append(group.Projects, project)
+		}
+		if entry.Repository != "" {
+			project.Repositories = append(project.Repositories, ui.PlannerRepository{Path: entry.Repository, Tags: entry.Tags, Size: entry.Size})
+		}
+	}
+	return root
+}
+
+// PlannerDestination returns the full path a project of the inventory gets once migrated to destination
+func (inv *Inventory) PlannerDestination(project *ui.PlannerProject, destination string, keepParent bool) string {
+	info := migration.ProjectInfo{
+		Path:              project.Path,
+		PathWithNamespace: project.PathWithNamespace,
+		NamespacePath:     project.NamespacePath,
+	}
+	return migration.DestinationProjectPath(info, inv.Group, destination, keepParent)
+}
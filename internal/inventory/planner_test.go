@@ -0,0 +1,73 @@
+package inventory
+
+import (
+	"io"
+	"testing"
+
+	"migraptor/internal/migration"
+	"migraptor/internal/ui"
+)
+
+func TestPlannerTree(t *testing.T) {
+	gl := newTestGitLab(t)
+	gl.AddProject("team/backend/jobs/cron")
+	inv, err := NewCollector(gl, ui.New(false, io.Discard)).Collect(t.Context(), "team", nil, nil)
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	if len(inv.SubGroups) != 2 || inv.SubGroups[0] != "team/backend" || inv.SubGroups[1] != "team/backend/jobs" {
+		t.Fatalf("Expected sub-groups team/backend and team/backend/jobs, got %v", inv.SubGroups)
+	}
+
+	selection, err := migration.NewSelection(migration.SelectionRules{
+		Exclude:       []string{"docs"},
+		ExcludeGroups: []string{"backend/jobs"},
+		Group:         "team",
+	})
+	if err != nil {
+		t.Fatalf("NewSelection failed: %v", err)
+	}
+	root := inv.PlannerTree(selection)
+
+	if root.FullPath != "team" || !root.Selected {
+		t.Fatalf("Expected ticked root team, got %s (%v)", root.FullPath, root.Selected)
+	}
+	if len(root.Projects) != 2 || root.Projects[0].Path != "app" || root.Projects[1].Path != "docs" {
+		t.Fatalf("Expected projects app and docs in team, got %+v", root.Projects)
+	}
+	app, docs := root.Projects[0], root.Projects[1]
+	if !app.Selected || docs.Selected {
+		t.Errorf("Expected app ticked and docs unticked, got %v and %v", app.Selected, docs.Selected)
+	}
+	if len(app.Repositories) != 2 || app.Size() != 35 {
+		t.Errorf("Expected 2 repositories of 35 bytes in app, got %d of %d bytes", len(app.Repositories), app.Size())
+	}
+	if len(docs.Repositories) != 0 {
+		t.Errorf("Expected no repository in docs, got %v", docs.Repositories)
+	}
+
+	if len(root.Groups) != 1 {
+		t.Fatalf("Expected 1 sub-group in team, got %d", len(root.Groups))
+	}
+	backend := root.Groups[0]
+	if backend.FullPath != "team/backend" || !backend.Selected || len(backend.Projects) != 1 || !backend.Projects[0].Archived {
+		t.Errorf("Expected ticked team/backend with the archived legacy project, got %+v", backend)
+	}
+	if len(backend.Groups) != 1 {
+		t.Fatalf("Expected 1 sub-group in team/backend, got %d", len(backend.Groups))
+	}
+	jobs := backend.Groups[0]
+	if jobs.FullPath != "team/backend/jobs" || jobs.Selected || len(jobs.Projects) != 1 || jobs.Projects[0].Selected {
+		t.Errorf("Expected unticked team/backend/jobs with an unticked project, got %+v", jobs)
+	}
+	if root.Size() != 135 {
+		t.Errorf("Expected 135 bytes in team, got %d", root.Size())
+	}
+
+	if got := inv.PlannerDestination(jobs.Projects[0], "platform", true); got != "platform/team/backend/jobs/cron" {
+		t.Errorf("Expected platform/team/backend/jobs/cron, got %s", got)
+	}
+	if got := inv.PlannerDestination(jobs.Projects[0], "platform", false); got != "platform/cron" {
+		t.Errorf("Expected platform/cron, got %s", got)
+	}
+}
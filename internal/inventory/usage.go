@@ -0,0 +1,217 @@
+package inventory
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"migraptor/internal/ui"
+)
+
+// Snapshot is the registry size of a group tree at a point in time, saved to follow its growth.
+// Sizes are the sum of the sizes of the repositories below each path.
+type Snapshot struct {
+	Group        string           `json:"group"`
+	TakenAt      time.Time        `json:"taken_at"`
+	Total        int64            `json:"total"`
+	Groups       map[string]int64 `json:"groups"`
+	Projects     map[string]int64 `json:"projects"`
+	Repositories map[string]int64 `json:"repositories"`
+}
+
+// NewSnapshot aggregates the registry size of an inventory by group, sub-group, project and repository
+func NewSnapshot(inv *Inventory, takenAt time.Time) *Snapshot {
+	snapshot := &Snapshot{
+		Group:        inv.Group,
+		TakenAt:      takenAt,
+		Groups:       make(map[string]int64),
+		Projects:     make(map[string]int64),
+		Repositories: make(map[string]int64),
+	}
+	for _, entry := range inv.Entries {
+		snapshot.Total += entry.Size
+		snapshot.Projects[entry.Project] += entry.Size
+		if entry.Repository != "" {
+			snapshot.Repositories[entry.Repository] = entry.Size
+		}
+		// A group counts the projects of all its sub-groups, up to the group walked
+		for namespace := path.Dir(entry.Project); namespace == inv.Group || strings.HasPrefix(namespace, inv.Group+"/"); namespace = path.Dir(namespace) {
+			snapshot.Groups[namespace] += entry.Size
+		}
+	}
+	return snapshot
+}
+
+// LoadSnapshots reads the snapshots saved in a file, there are none if the file does not exist yet
+func LoadSnapshots(file string) ([]*Snapshot, error) {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshots: %w", err)
+	}
+
+	var snapshots []*Snapshot
+	if err := json.Unmarshal(data, &snapshots); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshots of %s: %w", file, err)
+	}
+	return snapshots, nil
+}
+
+// SaveSnapshots writes the snapshots as JSON to a file
+func SaveSnapshots(file string, snapshots []*Snapshot) error {
+	data, err := json.MarshalIndent(snapshots, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshots: %w", err)
+	}
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		return fmt.Errorf("failed to write snapshots: %w", err)
+	}
+	return nil
+}
+
+// LastSnapshot returns the most recent snapshot of a group, or nil
+func LastSnapshot(snapshots []*Snapshot, group string) *Snapshot {
+	var last *Snapshot
+	for _, snapshot := range snapshots {
+		if snapshot.Group == group && (last == nil || snapshot.TakenAt.After(last.TakenAt)) {
+			last = snapshot
+		}
+	}
+	return last
+}
+
+// UsageLine is the registry size of a group, project or repository.
+// Growth is the difference with the previous snapshot, unset without previous snapshot.
+type UsageLine struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Growth *int64 `json:"growth,omitempty"`
+}
+
+// Usage is a registry storage report of a group tree, from the largest to the smallest
+type Usage struct {
+	Group           string      `json:"group"`
+	TakenAt         time.Time   `json:"taken_at"`
+	PreviousTakenAt *time.Time  `json:"previous_taken_at,omitempty"`
+	Total           UsageLine   `json:"total"`
+	Groups          []UsageLine `json:"groups"`
+	Projects        []UsageLine `json:"projects"`
+	TopRepositories []UsageLine `json:"top_repositories"`
+	TopTags         []*Tag      `json:"top_tags"`
+}
+
+// NewUsage builds the report of a snapshot, with its top largest repositories and tags, none if top is negative.
+// Growths are computed when a previous snapshot is given.
+func NewUsage(inv *Inventory, snapshot, previous *Snapshot, top int) *Usage {
+	top = max(top, 0)
+	usage := &Usage{
+		Group:    snapshot.Group,
+		TakenAt:  snapshot.TakenAt,
+		Groups:   usageLines(snapshot.Groups, previous, func(s *Snapshot) map[string]int64 { return s.Groups }),
+		Projects: usageLines(snapshot.Projects, previous, func(s *Snapshot) map[string]int64 { return s.Projects }),
+	}
+	usage.Total = UsageLine{Path: snapshot.Group, Size: snapshot.Total}
+	if previous != nil {
+		usage.PreviousTakenAt = &previous.TakenAt
+		growth := snapshot.Total - previous.Total
+		usage.Total.Growth = &growth
+	}
+
+	repositories := usageLines(snapshot.Repositories, previous, func(s *Snapshot) map[string]int64 { return s.Repositories })
+	usage.TopRepositories = repositories[:min(top, len(repositories))]
+
+	var tags []*Tag
+	for _, entry := range inv.Entries {
+		tags = append(tags, entry.TagDetails...)
+	}
+	slices.SortFunc(tags, func(a, b *Tag) int {
+		return cmp.Or(cmp.Compare(b.Size, a.Size), strings.Compare(a.Location, b.Location))
+	})
+	usage.TopTags = tags[:min(top, len(tags))]
+	return usage
+}
+
+// usageLines sorts sizes from the largest, with their growth since the previous snapshot
+func usageLines(sizes map[string]int64, previous *Snapshot, previousSizes func(*Snapshot) map[string]int64) []UsageLine {
+	lines := make([]UsageLine, 0, len(sizes))
+	for _, p := range slices.Sorted(maps.Keys(sizes)) {
+		line := UsageLine{Path: p, Size: sizes[p]}
+		if previous != nil {
+			growth := sizes[p] - previousSizes(previous)[p]
+			line.Growth = &growth
+		}
+		lines = append(lines, line)
+	}
+	slices.SortStableFunc(lines, func(a, b UsageLine) int { return cmp.Compare(b.Size, a.Size) })
+	return lines
+}
+
+// Write renders the report as tables or JSON
+func (u *Usage) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatTable:
+		return u.writeTables(w)
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(u)
+	default:
+		return fmt.Errorf("unknown usage format %s, expected %s or %s", format, FormatTable, FormatJSON)
+	}
+}
+
+func (u *Usage) writeTables(w io.Writer) error {
+	fmt.Fprintf(w, "Registry usage of %s on %s", u.Group, u.TakenAt.Format(time.DateTime))
+	if u.PreviousTakenAt != nil {
+		fmt.Fprintf(w, ", growth since %s", u.PreviousTakenAt.Format(time.DateTime))
+	}
+	fmt.Fprintf(w, "\nTotal: %s", ui.FormatBytes(u.Total.Size))
+	if u.Total.Growth != nil {
+		fmt.Fprintf(w, " (%s)", formatGrowth(u.Total.Growth))
+	}
+	fmt.Fprintln(w)
+
+	sections := []struct {
+		title string
+		lines []UsageLine
+	}{
+		{"GROUP", u.Groups},
+		{"PROJECT", u.Projects},
+		{fmt.Sprintf("TOP %d REPOSITORIES", len(u.TopRepositories)), u.TopRepositories},
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, section := range sections {
+		fmt.Fprintf(tw, "\n%s\tSIZE\tGROWTH\n", section.title)
+		for _, line := range section.lines {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", line.Path, ui.FormatBytes(line.Size), formatGrowth(line.Growth))
+		}
+	}
+	fmt.Fprintf(tw, "\nTOP %d TAGS\tSIZE\tCREATED\n", len(u.TopTags))
+	for _, tag := range u.TopTags {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", tag.Location, ui.FormatBytes(tag.Size), formatDate(tag.CreatedAt, time.DateOnly, "-"))
+	}
+	return tw.Flush()
+}
+
+// formatGrowth formats a size difference with its sign, "-" when unknown
+func formatGrowth(growth *int64) string {
+	switch {
+	case growth == nil:
+		return "-"
+	case *growth < 0:
+		return "-" + ui.FormatBytes(-*growth)
+	default:
+		return "+" + ui.FormatBytes(*growth)
+	}
+}
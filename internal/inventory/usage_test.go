@@ -0,0 +1,140 @@
+package inventory
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"migraptor/internal/ui"
+)
+
+func collectTeam(t *testing.T) *Inventory {
+	t.Helper()
+	inv, err := NewCollector(newTestGitLab(t), ui.New(false, io.Discard)).Collect(t.Context(), "team", nil, nil)
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	return inv
+}
+
+func TestNewSnapshot(t *testing.T) {
+	snapshot := NewSnapshot(collectTeam(t), time.Now())
+
+	if snapshot.Total != 135 {
+		t.Errorf("Expected total of 135 bytes, got %d", snapshot.Total)
+	}
+	expectedGroups := map[string]int64{"team": 135, "team/backend": 100}
+	if len(snapshot.Groups) != len(expectedGroups) {
+		t.Errorf("Expected groups %v, got %v", expectedGroups, snapshot.Groups)
+	}
+	for group, size := range expectedGroups {
+		if snapshot.Groups[group] != size {
+			t.Errorf("Expected %s to use %d bytes, got %d", group, size, snapshot.Groups[group])
+		}
+	}
+	if snapshot.Projects["team/app"] != 35 || snapshot.Projects["team/docs"] != 0 {
+		t.Errorf("Unexpected project sizes %v", snapshot.Projects)
+	}
+	if snapshot.Repositories["team/app/worker"] != 5 || len(snapshot.Repositories) != 3 {
+		t.Errorf("Unexpected repository sizes %v", snapshot.Repositories)
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "usage.json")
+
+	snapshots, err := LoadSnapshots(file)
+	if err != nil || snapshots != nil {
+		t.Fatalf("Expected no snapshot in a missing file, got %v (%v)", snapshots, err)
+	}
+
+	first := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	snapshots = []*Snapshot{
+		{Group: "team", TakenAt: first, Total: 1},
+		{Group: "team", TakenAt: first.Add(time.Hour), Total: 2},
+		{Group: "other", TakenAt: first.Add(2 * time.Hour), Total: 3},
+	}
+	if err := SaveSnapshots(file, snapshots); err != nil {
+		t.Fatalf("SaveSnapshots failed: %v", err)
+	}
+	loaded, err := LoadSnapshots(file)
+	if err != nil {
+		t.Fatalf("LoadSnapshots failed: %v", err)
+	}
+	if last := LastSnapshot(loaded, "team"); last == nil || last.Total != 2 {
+		t.Errorf("Expected the latest snapshot of team, got %+v", last)
+	}
+	if last := LastSnapshot(loaded, "missing"); last != nil {
+		t.Errorf("Expected no snapshot of an unknown group, got %+v", last)
+	}
+}
+
+func TestNewUsage(t *testing.T) {
+	inv := collectTeam(t)
+	snapshot := NewSnapshot(inv, time.Now())
+
+	usage := NewUsage(inv, snapshot, nil, 2)
+	if usage.Total.Growth != nil || usage.Groups[0].Growth != nil {
+		t.Error("Expected no growth without previous snapshot")
+	}
+	if len(usage.TopRepositories) != 2 || usage.TopRepositories[0].Path != "team/backend/legacy" || usage.TopRepositories[1].Path != "team/app" {
+		t.Errorf("Expected top repositories legacy then app, got %+v", usage.TopRepositories)
+	}
+	if len(usage.TopTags) != 2 || usage.TopTags[0].Size != 100 || usage.TopTags[1].Size != 20 {
+		t.Errorf("Expected top tags of 100 and 20 bytes, got %+v", usage.TopTags)
+	}
+	if usage.Projects[len(usage.Projects)-1].Path != "team/docs" {
+		t.Errorf("Expected smallest project to be team/docs, got %+v", usage.Projects)
+	}
+	if usage := NewUsage(inv, snapshot, nil, -1); len(usage.TopRepositories) != 0 || len(usage.TopTags) != 0 {
+		t.Errorf("Expected no top repositories and tags with a negative top, got %+v", usage)
+	}
+
+	previous := &Snapshot{
+		Group:        "team",
+		TakenAt:      snapshot.TakenAt.Add(-24 * time.Hour),
+		Total:        200,
+		Groups:       map[string]int64{"team": 200, "team/backend": 190},
+		Projects:     map[string]int64{"team/app": 10},
+		Repositories: map[string]int64{"team/backend/legacy": 190},
+	}
+	usage = NewUsage(inv, snapshot, previous, 10)
+	if usage.Total.Growth == nil || *usage.Total.Growth != -65 {
+		t.Errorf("Expected total growth of -65, got %v", usage.Total.Growth)
+	}
+	for _, line := range usage.Projects {
+		if line.Path == "team/backend/legacy" && *line.Growth != 100 {
+			t.Errorf("Expected a new project to grow by its size, got %d", *line.Growth)
+		}
+	}
+
+	var table bytes.Buffer
+	if err := usage.Write(&table, FormatTable); err != nil {
+		t.Fatalf("Write table failed: %v", err)
+	}
+	for _, expected := range []string{"Total: 135 B (-65 B)", "growth since", "team/backend  100 B  -90 B", "TOP 4 TAGS"} {
+		if !strings.Contains(table.String(), expected) {
+			t.Errorf("Expected %q in report:\n%s", expected, table.String())
+		}
+	}
+
+	var jsonOut bytes.Buffer
+	if err := usage.Write(&jsonOut, FormatJSON); err != nil {
+		t.Fatalf("Write JSON failed: %v", err)
+	}
+	var decoded Usage
+	if err := json.Unmarshal(jsonOut.Bytes(), &decoded); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if decoded.Total.Size != 135 || decoded.PreviousTakenAt == nil || len(decoded.TopTags) != 4 {
+		t.Errorf("Expected report to round trip, got %s", jsonOut.String())
+	}
+
+	if err := usage.Write(io.Discard, FormatCSV); err == nil {
+		t.Error("Expected CSV to be refused")
+	}
+}
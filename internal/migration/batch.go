@@ -0,0 +1,162 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"migraptor/internal/ui"
+)
+
+// BatchStatus is the outcome of a migration of a batch
+type BatchStatus string
+
+const (
+	BatchSucceeded BatchStatus = "succeeded"
+	BatchFailed    BatchStatus = "failed"
+	// BatchSkipped is the status of migrations not run, because the batch stopped or a migration they depend on failed
+	BatchSkipped BatchStatus = "skipped"
+)
+
+// BatchResult is the outcome of a migration of a batch
+type BatchResult struct {
+	Options Options
+	Status  BatchStatus
+	// Result is the result returned by the run, nil for skipped migrations
+	Result *Result
+	Err    error
+}
+
+// Batch runs several migrations one after the other, in dependency order
+type Batch struct {
+	// Migrations are the options of each migration, sorted so that each one runs before those depending on it
+	Migrations      []Options
+	continueOnError bool
+	// dependencies are the indexes of the migrations each migration depends on
+	dependencies [][]int
+	consoleUI    *ui.UI
+}
+
+// NewBatch sorts migrations in dependency order, keeping the given order between independent migrations.
+// A migration depends on another one when it moves or reads groups the other one changes.
+// It returns an error if migrations depend on each other.
+func NewBatch(migrations []Options, continueOnError bool, cUI *ui.UI) (*Batch, error) {
+	batch := &Batch{
+		continueOnError: continueOnError,
+		consoleUI:       cUI,
+	}
+
+	// Repeatedly take the first migration whose dependencies are all scheduled
+	scheduled := make([]bool, len(migrations))
+	position := make([]int, len(migrations))
+	for len(batch.Migrations) < len(migrations) {
+		next := -1
+		for i := range migrations {
+			if scheduled[i] {
+				continue
+			}
+			ready := true
+			for j := range migrations {
+				if j != i && !scheduled[j] && mustRunBefore(migrations[j], migrations[i]) {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				next = i
+				break
+			}
+		}
+		if next == -1 {
+			var blocked []string
+			for i, migration := range migrations {
+				if !scheduled[i] {
+					blocked = append(blocked, fmt.Sprintf("%s → %s", migration.SourceGroup, migration.DestinationGroup))
+				}
+			}
+			return nil, fmt.Errorf("migrations depend on each other: %s", strings.Join(blocked, ", "))
+		}
+
+		scheduled[next] = true
+		position[next] = len(batch.Migrations)
+		var dependencies []int
+		for j := range migrations {
+			if j != next && scheduled[j] && mustRunBefore(migrations[j], migrations[next]) {
+				dependencies = append(dependencies, position[j])
+			}
+		}
+		batch.Migrations = append(batch.Migrations, migrations[next])
+		batch.dependencies = append(batch.dependencies, dependencies)
+	}
+	return batch, nil
+}
+
+// mustRunBefore returns true if migration a changes groups that migration b moves or reads:
+// a source nested in the source of b, a destination inside the source of b, or the source of b
+// inside the destination of a
+func mustRunBefore(a, b Options) bool {
+	aSource, bSource := strings.Trim(a.SourceGroup, "/"), strings.Trim(b.SourceGroup, "/")
+	aDestination := finalPath(a)
+	return (aSource != bSource && isWithin(aSource, bSource)) ||
+		isWithin(aDestination, bSource) ||
+		isWithin(bSource, aDestination)
+}
+
+// finalPath returns the group receiving the projects of a migration
+func finalPath(opts Options) string {
+	destination := strings.Trim(opts.DestinationGroup, "/")
+	if opts.KeepParent {
+		return destination + "/" + path.Base(strings.Trim(opts.SourceGroup, "/"))
+	}
+	return destination
+}
+
+// isWithin returns true if groupPath is root or one of its sub-groups
+func isWithin(groupPath, root string) bool {
+	return groupPath == root || strings.HasPrefix(groupPath, root+"/")
+}
+
+// Run runs the migrations in order with run. When a migration fails, the next ones are skipped,
+// or only those depending on it if the batch continues on error. An interrupted migration stops the batch.
+func (b *Batch) Run(ctx context.Context, run func(ctx context.Context, opts Options) (*Result, error)) []*BatchResult {
+	results := make([]*BatchResult, len(b.Migrations))
+	stopped := false
+	for i, opts := range b.Migrations {
+		results[i] = &BatchResult{Options: opts, Status: BatchSkipped}
+		if stopped {
+			continue
+		}
+		if failed := b.failedDependency(results, i); failed != nil {
+			b.consoleUI.Warning("Skipping migration of %s, it depends on the migration of %s which did not succeed", opts.SourceGroup, failed.Options.SourceGroup)
+			continue
+		}
+
+		b.consoleUI.PrintHeader(fmt.Sprintf("🚚 Migration %d/%d: %s → %s", i+1, len(b.Migrations), opts.SourceGroup, opts.DestinationGroup))
+		result, err := run(ctx, opts)
+		results[i].Result = result
+		results[i].Err = err
+		if err == nil {
+			results[i].Status = BatchSucceeded
+			continue
+		}
+
+		results[i].Status = BatchFailed
+		b.consoleUI.Error("Migration of %s failed: %v", opts.SourceGroup, err)
+		if !b.continueOnError || errors.Is(err, ErrInterrupted) || errors.Is(err, context.Canceled) {
+			stopped = true
+		}
+	}
+	return results
+}
+
+// failedDependency returns the result of a migration the i-th migration depends on which did not succeed, or nil
+func (b *Batch) failedDependency(results []*BatchResult, i int) *BatchResult {
+	for _, j := range b.dependencies[i] {
+		if results[j].Status != BatchSucceeded {
+			return results[j]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func batchOrder(batch *Batch) []string {
+	var order []string
+	for _, opts := range batch.Migrations {
+		order = append(order, opts.SourceGroup)
+	}
+	return order
+}
+
+func TestNewBatch_Order(t *testing.T) {
+	batch, err := NewBatch([]Options{
+		// Moves the group receiving the second migration, so must run after it
+		{SourceGroup: "platform", DestinationGroup: "archive"},
+		// Reads the group created by the last migration
+		{SourceGroup: "platform/team", DestinationGroup: "platform/legacy"},
+		{SourceGroup: "other", DestinationGroup: "misc"},
+		{SourceGroup: "org/team", DestinationGroup: "platform", KeepParent: true},
+		// Nested in the source of the first migration
+		{SourceGroup: "platform/ops", DestinationGroup: "infra"},
+	}, false, newTestUI(nil))
+	if err != nil {
+		t.Fatalf("NewBatch failed: %v", err)
+	}
+
+	expected := []string{"other", "org/team", "platform/team", "platform/ops", "platform"}
+	if order := batchOrder(batch); !slices.Equal(order, expected) {
+		t.Errorf("Expected order %v, got %v", expected, order)
+	}
+	if !slices.Equal(batch.dependencies[2], []int{1}) || len(batch.dependencies[0]) != 0 || len(batch.dependencies[4]) != 3 {
+		t.Errorf("Unexpected dependencies %v", batch.dependencies)
+	}
+}
+
+func TestNewBatch_SameSource(t *testing.T) {
+	batch, err := NewBatch([]Options{
+		{SourceGroup: "org/team", DestinationGroup: "web", Selection: selectProjects(t, "app")},
+		{SourceGroup: "org/team", DestinationGroup: "data", Selection: selectProjects(t, "api")},
+	}, false, newTestUI(nil))
+	if err != nil {
+		t.Fatalf("NewBatch failed: %v", err)
+	}
+	if order := batchOrder(batch); order[0] != "org/team" || batch.Migrations[0].DestinationGroup != "web" {
+		t.Errorf("Expected manifest order to be kept, got %v", batch.Migrations)
+	}
+}
+
+func TestNewBatch_Cycle(t *testing.T) {
+	_, err := NewBatch([]Options{
+		{SourceGroup: "a", DestinationGroup: "b/x"},
+		{SourceGroup: "b", DestinationGroup: "a/y"},
+	}, false, newTestUI(nil))
+	if err == nil {
+		t.Error("Expected migrations swapping groups to be refused")
+	}
+}
+
+func TestBatch_Run(t *testing.T) {
+	migrations := []Options{
+		{SourceGroup: "a", DestinationGroup: "x"},
+		{SourceGroup: "x", DestinationGroup: "y"},
+		{SourceGroup: "b", DestinationGroup: "z"},
+	}
+	failing := errors.New("transfer refused")
+	run := func(ran *[]string) func(ctx context.Context, opts Options) (*Result, error) {
+		return func(ctx context.Context, opts Options) (*Result, error) {
+			*ran = append(*ran, opts.SourceGroup)
+			if opts.SourceGroup == "a" {
+				return &Result{}, failing
+			}
+			return &Result{}, nil
+		}
+	}
+
+	t.Run("stop on error", func(t *testing.T) {
+		batch, err := NewBatch(migrations, false, newTestUI(nil))
+		if err != nil {
+			t.Fatalf("NewBatch failed: %v", err)
+		}
+		var ran []string
+		results := batch.Run(t.Context(), run(&ran))
+		if !slices.Equal(ran, []string{"a"}) {
+			t.Errorf("Expected only the first migration to run, ran %v", ran)
+		}
+		if results[0].Status != BatchFailed || !errors.Is(results[0].Err, failing) || results[1].Status != BatchSkipped || results[2].Status != BatchSkipped {
+			t.Errorf("Unexpected statuses %s, %s, %s", results[0].Status, results[1].Status, results[2].Status)
+		}
+	})
+
+	t.Run("continue on error", func(t *testing.T) {
+		batch, err := NewBatch(migrations, true, newTestUI(nil))
+		if err != nil {
+			t.Fatalf("NewBatch failed: %v", err)
+		}
+		var ran []string
+		results := batch.Run(t.Context(), run(&ran))
+		if !slices.Equal(ran, []string{"a", "b"}) {
+			t.Errorf("Expected the dependent migration to be skipped, ran %v", ran)
+		}
+		if results[1].Status != BatchSkipped || results[2].Status != BatchSucceeded {
+			t.Errorf("Unexpected statuses %s, %s", results[1].Status, results[2].Status)
+		}
+	})
+
+	t.Run("interrupted", func(t *testing.T) {
+		batch, err := NewBatch([]Options{{SourceGroup: "b", DestinationGroup: "z"}, {SourceGroup: "c", DestinationGroup: "w"}}, true, newTestUI(nil))
+		if err != nil {
+			t.Fatalf("NewBatch failed: %v", err)
+		}
+		runs := 0
+		results := batch.Run(t.Context(), func(ctx context.Context, opts Options) (*Result, error) {
+			runs++
+			return nil, ErrInterrupted
+		})
+		if runs != 1 || results[1].Status != BatchSkipped {
+			t.Errorf("Expected an interruption to stop the batch, got %d runs", runs)
+		}
+	})
+}
+
+func TestBatch_RunEngines(t *testing.T) {
+	f := newMigrationFixture(t)
+	f.gl.AddGroup("archive")
+	batch, err := NewBatch([]Options{
+		{SourceGroup: "platform/team", DestinationGroup: "archive", KeepParent: true},
+		{SourceGroup: "org/team", DestinationGroup: "platform", KeepParent: true},
+	}, false, newTestUI(nil))
+	if err != nil {
+		t.Fatalf("NewBatch failed: %v", err)
+	}
+
+	results := batch.Run(t.Context(), func(ctx context.Context, opts Options) (*Result, error) {
+		return NewEngine(f.gl, f.engine, opts, newTestUI(nil)).Run(ctx)
+	})
+	for _, result := range results {
+		if result.Status != BatchSucceeded {
+			t.Fatalf("Expected migration of %s to succeed, got %s: %v", result.Options.SourceGroup, result.Status, result.Err)
+		}
+	}
+	if f.gl.Project("archive/team/app") == nil {
+		t.Error("Expected org/team to end in archive/team through platform/team")
+	}
+	if images := f.gl.Images("archive/team/app"); len(images) != 2 {
+		t.Errorf("Expected images to follow both migrations, got %v", images)
+	}
+}
@@ -0,0 +1,105 @@
+package migration
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Checkpoint records what remains to do when a migration is interrupted, or fails once registries or packages
+// are deleted
+type Checkpoint struct {
+	SourceGroup      string `json:"source_group"`
+	DestinationGroup string `json:"destination_group"`
+	// Phase is the phase running when the migration stopped
+	Phase         Phase     `json:"phase"`
+	InterruptedAt time.Time `json:"interrupted_at"`
+	// Projects are the projects whose migration is not complete
+	Projects []ProjectCheckpoint `json:"projects"`
+}
+
+// ProjectCheckpoint records the remaining steps of a project
+type ProjectCheckpoint struct {
+	Path        string `json:"path"`
+	Destination string `json:"destination"`
+	// Remaining are the phases still to run for the project
+	Remaining []Phase `json:"remaining"`
+	// Images are the images backed up locally which still have to be pushed to the destination
+	Images []string `json:"images,omitempty"`
+	// Packages are the packages downloaded locally which still have to be published at destination
+	Packages []string `json:"packages,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// Checkpoint returns what remains of the migration, nil if discover has not run
+func (e *Engine) Checkpoint() *Checkpoint {
+	if e.plan == nil {
+		return nil
+	}
+
+	checkpoint := &Checkpoint{
+		SourceGroup:      e.opts.SourceGroup,
+		DestinationGroup: e.opts.DestinationGroup,
+		Phase:            e.phase,
+		InterruptedAt:    time.Now(),
+	}
+	for _, result := range e.result.Projects {
+		var remaining []Phase
+		if !result.BackedUp {
+			remaining = append(remaining, PhaseBackup)
+		}
+		if !result.Transferred {
+			remaining = append(remaining, PhaseTransfer)
+		}
+		if !result.Restored {
+			remaining = append(remaining, PhaseRestore)
+		}
+		if !e.finalized && e.memberMigrator != nil {
+			remaining = append(remaining, PhaseFinalize)
+		}
+		if len(remaining) == 0 && result.Err == nil {
+			continue
+		}
+
+		project := ProjectCheckpoint{
+			Path:        result.Project.PathWithNamespace,
+			Destination: result.Destination,
+			Remaining:   remaining,
+		}
+		if result.BackedUp && !result.Restored {
+			project.Images = result.Images
+			for _, backup := range result.Packages {
+				project.Packages = append(project.Packages, backup.String())
+			}
+		}
+		if result.Err != nil {
+			project.Error = result.Err.Error()
+		}
+		checkpoint.Projects = append(checkpoint.Projects, project)
+	}
+	return checkpoint
+}
+
+// HasPendingBackups returns true if a project had its registries or packages deleted and its backup is not restored,
+// the checkpoint then records what is left locally
+func (e *Engine) HasPendingBackups() bool {
+	for _, result := range e.result.Projects {
+		if result.BackedUp && !result.Restored {
+			return true
+		}
+	}
+	return false
+}
+
+// Save writes the checkpoint as JSON to path
+func (c *Checkpoint) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return nil
+}
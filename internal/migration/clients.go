@@ -0,0 +1,90 @@
+package migration
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	gitlabCore "gitlab.com/gitlab-org/api/client-go"
+)
+
+// GroupClient is the subset of the GitLab API used to discover, create and transfer groups
+type GroupClient interface {
+	SearchGroup(ctx context.Context, name string) (*gitlabCore.Group, error)
+	GetSubGroups(ctx context.Context, groupID int64) ([]*gitlabCore.Group, error)
+	ListProjects(ctx context.Context, groupID int) ([]*gitlabCore.Project, *gitlabCore.Response, error)
+	TransferGroup(ctx context.Context, groupID, targetGroupID int) (*gitlabCore.Response, error)
+	CreateGroupWithOptions(ctx context.Context, opt *gitlabCore.CreateGroupOptions) (*gitlabCore.Group, *gitlabCore.Response, error)
+	UpdateGroup(ctx context.Context, groupID int, opt *gitlabCore.UpdateGroupOptions) (*gitlabCore.Group, *gitlabCore.Response, error)
+	DownloadGroupAvatar(ctx context.Context, groupID int) (*bytes.Reader, error)
+	ListGroupLabels(ctx context.Context, groupID int) ([]*gitlabCore.GroupLabel, error)
+	CreateGroupLabel(ctx context.Context, groupID int, name, color, description string) (*gitlabCore.GroupLabel, error)
+}
+
+// ProjectClient is the subset of the GitLab API used to list, archive and transfer projects
+type ProjectClient interface {
+	ListProjects(ctx context.Context, groupID int) ([]*gitlabCore.Project, *gitlabCore.Response, error)
+	TransferProject(ctx context.Context, projectID, namespaceID int) (*gitlabCore.Response, error)
+	ArchiveProject(ctx context.Context, projectID int) (*gitlabCore.Response, error)
+	UnarchiveProject(ctx context.Context, projectID int) (*gitlabCore.Response, error)
+}
+
+// RegistryClient is the subset of the GitLab API used to handle container registry repositories and tags
+type RegistryClient interface {
+	ListRegistryRepositories(ctx context.Context, projectID int) ([]*gitlabCore.RegistryRepository, *gitlabCore.Response, error)
+	ListRegistryRepositoryTags(ctx context.Context, projectID, repositoryID int) ([]*gitlabCore.RegistryRepositoryTag, *gitlabCore.Response, error)
+	GetRegistryRepositoryTagDetail(ctx context.Context, projectID, repositoryID int, tagName string) (*gitlabCore.RegistryRepositoryTag, error)
+	DeleteRegistryRepository(ctx context.Context, projectID, repositoryID int) (*gitlabCore.Response, error)
+	DeleteRegistryRepositoryTag(ctx context.Context, projectID, repositoryID int, tagName string) (*gitlabCore.Response, error)
+}
+
+// PackageClient is the subset of the GitLab API used to download, delete and publish again the packages of a project
+type PackageClient interface {
+	ListProjectPackages(ctx context.Context, projectID int) ([]*gitlabCore.Package, error)
+	ListPackageFiles(ctx context.Context, projectID int, packageID int64) ([]*gitlabCore.PackageFile, error)
+	DownloadPackageFile(ctx context.Context, projectID int, pkg *gitlabCore.Package, file *gitlabCore.PackageFile, w io.Writer) error
+	PublishPackageFile(ctx context.Context, projectID int, pkg *gitlabCore.Package, fileName string, content io.Reader) error
+	DeleteProjectPackage(ctx context.Context, projectID int, packageID int64) error
+}
+
+// MemberClient is the subset of the GitLab API used to read and add memberships and share links
+type MemberClient interface {
+	GetGroup(ctx context.Context, groupID int) (*gitlabCore.Group, *gitlabCore.Response, error)
+	GetProject(ctx context.Context, projectID int) (*gitlabCore.Project, *gitlabCore.Response, error)
+	ListGroupMembers(ctx context.Context, groupID int, inherited bool) ([]*gitlabCore.GroupMember, error)
+	AddGroupMember(ctx context.Context, groupID int, userID int64, accessLevel gitlabCore.AccessLevelValue, expiresAt string) error
+	EditGroupMember(ctx context.Context, groupID int, userID int64, accessLevel gitlabCore.AccessLevelValue, expiresAt string) error
+	ListProjectMembers(ctx context.Context, projectID int, inherited bool) ([]*gitlabCore.ProjectMember, error)
+	AddProjectMember(ctx context.Context, projectID int, userID int64, accessLevel gitlabCore.AccessLevelValue, expiresAt string) error
+	EditProjectMember(ctx context.Context, projectID int, userID int64, accessLevel gitlabCore.AccessLevelValue, expiresAt string) error
+	ShareProjectWithGroup(ctx context.Context, projectID int, groupID int64, accessLevel gitlabCore.AccessLevelValue, expiresAt string) error
+	ShareGroupWithGroup(ctx context.Context, groupID int, sharedWithGroupID int64, accessLevel gitlabCore.AccessLevelValue, expiresAt *gitlabCore.ISOTime) error
+}
+
+// ContainerEngine is the subset of container engine operations used to back up and restore images
+type ContainerEngine interface {
+	PullImage(ctx context.Context, imageRef string) error
+	TagImage(ctx context.Context, sourceImage, targetImage string) error
+	PushImage(ctx context.Context, imageRef string) error
+	ImageExists(ctx context.Context, imageRef string) (bool, error)
+	RemoveImage(ctx context.Context, imageRef string) error
+	LocalImageDigests(ctx context.Context) (map[string]int64, error)
+	LocalLayers(ctx context.Context) (map[string]bool, error)
+	AvailableSpace(ctx context.Context) (int64, string, error)
+}
+
+// ImageArchive copies images at registry level, for the images a container engine would not push back unchanged:
+// multi-architecture indexes and images with attached artifacts such as signatures
+type ImageArchive interface {
+	// NeedsArchive returns true if the image must be saved to the archive instead of pulled by the container engine
+	NeedsArchive(ctx context.Context, imageRef string) (bool, error)
+	// ImageLayers returns the layers of an image in the registry, keyed by uncompressed digest with their
+	// compressed size, or nil when the layers pulled by the container engine are not known
+	ImageLayers(ctx context.Context, imageRef string) (map[string]int64, error)
+	Save(ctx context.Context, imageRef string) error
+	Contains(imageRef string) bool
+	// Restore pushes an image saved under sourceRef to targetRef, keeping its digest
+	Restore(ctx context.Context, sourceRef, targetRef string) error
+	// Remove deletes a saved image once it is restored
+	Remove(imageRef string) error
+}
@@ -0,0 +1,850 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"migraptor/internal/config"
+	"migraptor/internal/ui"
+
+	gitlabCore "gitlab.com/gitlab-org/api/client-go"
+)
+
+// GitLabClient is the subset of the GitLab API used by a whole migration
+type GitLabClient interface {
+	GroupClient
+	ProjectClient
+	RegistryClient
+	PackageClient
+	MemberClient
+}
+
+// Phase is a step of a migration
+type Phase string
+
+const (
+	// PhaseDiscover finds the source and destination groups and the projects to migrate
+	PhaseDiscover Phase = "discover"
+	// PhaseBackup pulls the images of the projects and deletes their registries, as well as their packages
+	PhaseBackup Phase = "backup"
+	// PhaseTransfer moves the group or the projects to their destination
+	PhaseTransfer Phase = "transfer"
+	// PhaseRestore pushes the images back, publishes the packages again and re-archives the projects
+	PhaseRestore Phase = "restore"
+	// PhaseFinalize adds the members lost by the transfer
+	PhaseFinalize Phase = "finalize"
+)
+
+var (
+	// ErrGroupNotFound is returned when the source or destination group cannot be found
+	ErrGroupNotFound = errors.New("group not found")
+	// ErrNoProjects is returned when the source group holds no project to migrate
+	ErrNoProjects = errors.New("no project found in group")
+	// ErrPreflightFailed is returned when preflight checks found blocking issues
+	ErrPreflightFailed = errors.New("preflight checks failed")
+	// ErrDiskSpace is returned when the images to back up may not fit on the local disk
+	ErrDiskSpace = errors.New("disk space check failed")
+	// ErrBackupFailed is returned when the whole group is transferred and some of its projects could not be backed up
+	ErrBackupFailed = errors.New("backup of the group failed")
+	// ErrInterrupted is returned when the migration was stopped between two steps
+	ErrInterrupted = errors.New("migration interrupted")
+)
+
+// PhaseError is returned when a phase stops the migration
+type PhaseError struct {
+	Phase Phase
+	Err   error
+}
+
+func (e *PhaseError) Error() string {
+	return fmt.Sprintf("%s phase failed: %v", e.Phase, e.Err)
+}
+
+func (e *PhaseError) Unwrap() error {
+	return e.Err
+}
+
+// Options configures a migration
+type Options struct {
+	// SourceGroup is the full path of the group containing the projects to migrate
+	SourceGroup string
+	// DestinationGroup is the full path of the group receiving the projects
+	DestinationGroup string
+	// KeepParent moves the source group itself below the destination instead of its projects only
+	KeepParent bool
+	// Selection restricts the migration to the projects it includes, all projects are migrated if nil
+	Selection *Selection
+	// TagsList restricts the images backed up to these tags
+	TagsList []string
+	DryRun   bool
+	// MigrateMembers adds the members lost when projects are transferred individually
+	MigrateMembers bool
+	// GroupTemplate overrides the settings of the groups created at destination
+	GroupTemplate *config.GroupTemplate
+	// ImageArchive copies multi-architecture images and images with attached artifacts at registry level.
+	// All images go through the container engine if nil.
+	ImageArchive ImageArchive
+	// RemoveLocalImages removes the local copies of the images of a project once their push is verified
+	RemoveLocalImages bool
+	// MigratePackages downloads the packages of the projects, deletes them before the transfer and publishes them again
+	MigratePackages bool
+	// PackageDir is the directory storing the downloaded packages
+	PackageDir string
+	// TransferDelay is the time given to GitLab to move registries after a transfer, 10 seconds if zero
+	TransferDelay time.Duration
+	// Preflight verifies the plan before any destructive action and returns true if it found blocking issues.
+	// Preflight checks are skipped if nil.
+	Preflight func(ctx context.Context, plan *Plan) bool
+}
+
+// Plan describes what a migration moves, as found by the discover phase
+type Plan struct {
+	SourceGroup      *gitlabCore.Group
+	DestinationGroup *gitlabCore.Group
+	DestinationPath  string
+	SubGroups        map[int64]*gitlabCore.Group
+	// AllProjects are the projects of the source group and its sub-groups, keyed by ID
+	AllProjects map[int]*ProjectInfo
+	// Projects are the projects to migrate, sorted by path
+	Projects []*ProjectInfo
+	// Decisions tell why each project of AllProjects is migrated or not, sorted by path
+	Decisions []Decision
+	// ExcludedGroups are the full paths of the sub-groups left in place with their sub-tree, sorted.
+	// They are not part of SubGroups.
+	ExcludedGroups []string
+	KeepParent     bool
+	// TransferGroup is true when the whole source group is transferred instead of projects one by one
+	TransferGroup bool
+}
+
+// ProjectResult is the outcome of the migration of a project
+type ProjectResult struct {
+	Project *ProjectInfo
+	// Destination is the full path of the project once migrated
+	Destination string
+	// Images are the images backed up from the project registry
+	Images []string
+	// Packages are the packages downloaded from the project package registry
+	Packages    []*PackageBackup
+	BackedUp    bool
+	Transferred bool
+	Restored    bool
+	// Err is the error which stopped the migration of the project
+	Err error
+}
+
+// Result is the outcome of a migration
+type Result struct {
+	Plan     *Plan
+	Projects []*ProjectResult
+	// MemberChanges is the number of members and share links added at destination
+	MemberChanges int
+	DryRun        bool
+}
+
+// Failed returns the projects whose migration failed
+func (r *Result) Failed() []*ProjectResult {
+	var failed []*ProjectResult
+	for _, project := range r.Projects {
+		if project.Err != nil {
+			failed = append(failed, project)
+		}
+	}
+	return failed
+}
+
+// Hooks receives the events of a migration, to report its progress
+type Hooks interface {
+	PhaseStarted(phase Phase)
+	PhaseFinished(phase Phase, err error)
+	ProjectStarted(phase Phase, project *ProjectInfo)
+	ProjectFinished(phase Phase, project *ProjectInfo, err error)
+}
+
+// NoopHooks ignores all events, embed it to implement only some of the Hooks methods
+type NoopHooks struct{}
+
+func (NoopHooks) PhaseStarted(Phase)                         {}
+func (NoopHooks) PhaseFinished(Phase, error)                 {}
+func (NoopHooks) ProjectStarted(Phase, *ProjectInfo)         {}
+func (NoopHooks) ProjectFinished(Phase, *ProjectInfo, error) {}
+
+// Engine runs a migration phase by phase
+type Engine struct {
+	opts      Options
+	consoleUI *ui.UI
+	hooks     Hooks
+
+	groupMigrator   *GroupMigrator
+	projectMigrator *ProjectMigrator
+	imageMigrator   *ImageMigrator
+	packageMigrator *PackageMigrator
+	memberMigrator  *MemberMigrator
+
+	plan     *Plan
+	result   *Result
+	projects map[int]*ProjectResult
+	// mirroredGroups are the destination groups mirroring the source sub-groups, keyed by source full path
+	mirroredGroups map[string]*gitlabCore.Group
+	// transferredProjects are the destination groups of the projects transferred individually
+	transferredProjects map[int]*gitlabCore.Group
+
+	// phase is the last phase started
+	phase     Phase
+	finalized bool
+	stopped   atomic.Bool
+}
+
+// NewEngine creates an Engine migrating with the given GitLab client and container engine
+func NewEngine(client GitLabClient, containerEngine ContainerEngine, opts Options, cUI *ui.UI) *Engine {
+	groupMigrator := NewGroupMigrator(client, opts.DryRun, cUI)
+	groupMigrator.SetGroupTemplate(opts.GroupTemplate)
+	if opts.TransferDelay == 0 {
+		opts.TransferDelay = 10 * time.Second
+	}
+
+	imageMigrator := NewImageMigrator(client, containerEngine, opts.DryRun, cUI)
+	imageMigrator.SetImageArchive(opts.ImageArchive)
+
+	e := &Engine{
+		opts:                opts,
+		consoleUI:           cUI,
+		hooks:               NoopHooks{},
+		groupMigrator:       groupMigrator,
+		projectMigrator:     NewProjectMigrator(client, opts.DryRun, cUI),
+		imageMigrator:       imageMigrator,
+		result:              &Result{DryRun: opts.DryRun},
+		projects:            make(map[int]*ProjectResult),
+		mirroredGroups:      make(map[string]*gitlabCore.Group),
+		transferredProjects: make(map[int]*gitlabCore.Group),
+	}
+	if opts.MigrateMembers {
+		e.memberMigrator = NewMemberMigrator(client, opts.DryRun, cUI)
+	}
+	if opts.MigratePackages {
+		e.packageMigrator = NewPackageMigrator(client, opts.PackageDir, opts.DryRun, cUI)
+	}
+	return e
+}
+
+// SetHooks sets the hooks receiving the events of the migration
+func (e *Engine) SetHooks(hooks Hooks) {
+	if hooks == nil {
+		hooks = NoopHooks{}
+	}
+	e.hooks = hooks
+}
+
+// Result returns the outcome of the phases run so far
+func (e *Engine) Result() *Result {
+	return e.result
+}
+
+// Stop asks the engine to stop once the current step is finished. It can be called from another goroutine,
+// the phase running then returns ErrInterrupted.
+func (e *Engine) Stop() {
+	e.stopped.Store(true)
+}
+
+// interrupted returns the error stopping the migration between two steps, if any
+func (e *Engine) interrupted(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if e.stopped.Load() {
+		return ErrInterrupted
+	}
+	return nil
+}
+
+// Run runs all the phases of the migration, stopping at the first phase failing
+func (e *Engine) Run(ctx context.Context) (*Result, error) {
+	if _, err := e.Discover(ctx); err != nil {
+		return e.result, err
+	}
+	for _, phase := range []func(context.Context) error{e.Backup, e.Transfer, e.Restore, e.Finalize} {
+		if err := phase(ctx); err != nil {
+			return e.result, err
+		}
+	}
+	return e.result, nil
+}
+
+// runPhase runs a phase, notifying the hooks and wrapping its error
+func (e *Engine) runPhase(ctx context.Context, phase Phase, run func() error) error {
+	if phase != PhaseDiscover && e.plan == nil {
+		return &PhaseError{Phase: phase, Err: errors.New("discover phase has not run")}
+	}
+
+	e.phase = phase
+	e.hooks.PhaseStarted(phase)
+	err := e.interrupted(ctx)
+	if err == nil {
+		err = run()
+	}
+	if err != nil {
+		err = &PhaseError{Phase: phase, Err: err}
+	}
+	e.hooks.PhaseFinished(phase, err)
+	return err
+}
+
+// forEachProject runs step on each project to migrate, notifying the hooks, until the context is done
+// or the engine is stopped
+func (e *Engine) forEachProject(ctx context.Context, phase Phase, step func(project *ProjectInfo, result *ProjectResult) error) error {
+	for _, project := range e.plan.Projects {
+		if err := e.interrupted(ctx); err != nil {
+			return err
+		}
+		result := e.projects[project.ID]
+		if result.Err != nil {
+			continue
+		}
+
+		e.hooks.ProjectStarted(phase, project)
+		err := step(project, result)
+		result.Err = err
+		e.hooks.ProjectFinished(phase, project, err)
+	}
+	return nil
+}
+
+// Discover finds the source and destination groups and the projects to migrate, runs the preflight
+// checks and records the memberships which may be lost
+func (e *Engine) Discover(ctx context.Context) (*Plan, error) {
+	err := e.runPhase(ctx, PhaseDiscover, func() error { return e.discover(ctx) })
+	return e.plan, err
+}
+
+func (e *Engine) discover(ctx context.Context) error {
+	e.consoleUI.Info("🔍 Searching for source group...")
+	sourceGroup, err := e.groupMigrator.SearchGroup(ctx, e.opts.SourceGroup)
+	if err != nil {
+		e.consoleUI.Error("Failed to search for group: %v", err)
+		return fmt.Errorf("%w: %v", ErrGroupNotFound, err)
+	}
+	if sourceGroup == nil {
+		e.consoleUI.PrintGroupNotFound(e.opts.SourceGroup)
+		return fmt.Errorf("%w: %s", ErrGroupNotFound, e.opts.SourceGroup)
+	}
+	e.consoleUI.Debug("Found group with ID %d", sourceGroup.ID)
+
+	destinationPath := strings.TrimPrefix(e.opts.DestinationGroup, "/")
+	e.consoleUI.Info("🛤️ Migrating group to new path: %s", destinationPath)
+	destinationGroup, err := e.groupMigrator.SearchGroup(ctx, destinationPath)
+	if err != nil {
+		e.consoleUI.Error("Failed to find destination group: %v", err)
+		return fmt.Errorf("%w: %v", ErrGroupNotFound, err)
+	}
+
+	// All projects are listed, so that the plan explains why the ones left behind are not migrated
+	projects, err := e.projectMigrator.ListProjects(ctx, sourceGroup.ID, nil)
+	if err != nil {
+		e.consoleUI.Error("Failed to list projects: %v", err)
+		return err
+	}
+	if len(projects) == 0 {
+		e.consoleUI.PrintNoProjectsFound()
+		return ErrNoProjects
+	}
+
+	allProjects := make(map[int]*ProjectInfo)
+	for _, project := range projects {
+		allProjects[project.ID] = &project
+	}
+	subGroups, subProjects, err := e.groupMigrator.GetSubGroupsAndProjects(ctx, sourceGroup.ID, nil)
+	if err != nil {
+		e.consoleUI.Warning("Failed to list some sub-groups: %v", err)
+	}
+	maps.Copy(allProjects, subProjects)
+
+	var excludedGroups []string
+	for id, subGroup := range subGroups {
+		if excluded := e.opts.Selection.ExcludedGroup(subGroup.FullPath); excluded != "" {
+			delete(subGroups, id)
+			if excluded == subGroup.FullPath {
+				excludedGroups = append(excludedGroups, excluded)
+			}
+		}
+	}
+	slices.Sort(excludedGroups)
+	for _, excluded := range excludedGroups {
+		e.consoleUI.Info("🚧 Leaving sub-group %s and its content in place", excluded)
+	}
+	for _, excluded := range e.opts.Selection.ExcludeGroups() {
+		if !slices.Contains(excludedGroups, excluded) {
+			e.consoleUI.Warning("Excluded sub-group %s not found in %s", excluded, sourceGroup.FullPath)
+		}
+	}
+
+	if len(subGroups) > 0 {
+		e.consoleUI.Info("📂 Found %d sub-groups to consider", len(subGroups))
+	}
+	e.consoleUI.Info("📦 Found %d projects to consider", len(allProjects))
+
+	plan := &Plan{
+		SourceGroup:      sourceGroup,
+		DestinationGroup: destinationGroup,
+		DestinationPath:  destinationPath,
+		SubGroups:        subGroups,
+		ExcludedGroups:   excludedGroups,
+		AllProjects:      allProjects,
+		KeepParent:       e.opts.KeepParent,
+		// The whole group can only be transferred when no project stays behind
+		TransferGroup: e.opts.KeepParent && e.opts.Selection.SelectsAll(),
+	}
+	for _, project := range allProjects {
+		plan.Decisions = append(plan.Decisions, e.opts.Selection.Decide(project))
+	}
+	slices.SortFunc(plan.Decisions, func(a, b Decision) int {
+		return strings.Compare(a.Project.PathWithNamespace, b.Project.PathWithNamespace)
+	})
+	for _, decision := range plan.Decisions {
+		if decision.Included {
+			e.consoleUI.Debug("Migrating %s: %s", decision.Project.PathWithNamespace, decision.Reason)
+			plan.Projects = append(plan.Projects, decision.Project)
+		} else {
+			e.consoleUI.Info("⏭️ Not migrating %s: %s", decision.Project.PathWithNamespace, decision.Reason)
+		}
+	}
+	if len(plan.Projects) == 0 {
+		e.consoleUI.Warning("No project of %s is selected by the project rules", sourceGroup.FullPath)
+		return ErrNoProjects
+	}
+	e.consoleUI.Info("📦 %d projects to migrate", len(plan.Projects))
+
+	e.plan = plan
+	e.result.Plan = plan
+	e.result.Projects = nil
+	for _, project := range plan.Projects {
+		result := &ProjectResult{
+			Project:     project,
+			Destination: DestinationProjectPath(*project, sourceGroup.FullPath, destinationPath, e.opts.KeepParent),
+		}
+		e.projects[project.ID] = result
+		e.result.Projects = append(e.result.Projects, result)
+	}
+
+	// Preflight checks: verify permissions and feasibility before any destructive action
+	if e.opts.Preflight != nil && e.opts.Preflight(ctx, plan) {
+		if !e.opts.DryRun {
+			e.consoleUI.PrintPreflightFailed()
+			return ErrPreflightFailed
+		}
+		e.consoleUI.Warning("🌵 DRY RUN: The migration would stop here because of blocking preflight issues")
+	}
+
+	return e.snapshotMembers(ctx)
+}
+
+// snapshotMembers records memberships before anything moves, as projects transferred individually lose inherited members
+func (e *Engine) snapshotMembers(ctx context.Context) error {
+	if e.memberMigrator == nil {
+		return nil
+	}
+	if e.plan.TransferGroup {
+		e.consoleUI.Info("👥 Whole group is transferred, members are kept as is")
+		e.memberMigrator = nil
+		return nil
+	}
+
+	e.consoleUI.Info("👥 Recording memberships of source groups and projects...")
+	sourceGroups := []*gitlabCore.Group{e.plan.SourceGroup}
+	for _, subGroup := range e.plan.SubGroups {
+		sourceGroups = append(sourceGroups, subGroup)
+	}
+	for _, group := range sourceGroups {
+		if err := e.memberMigrator.SnapshotGroup(ctx, group); err != nil {
+			e.consoleUI.Error("Failed to record memberships: %v", err)
+			return err
+		}
+	}
+	for _, project := range e.plan.Projects {
+		if err := e.memberMigrator.SnapshotProject(ctx, project); err != nil {
+			e.consoleUI.Error("Failed to record memberships: %v", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// Backup pulls the images of the projects to migrate and deletes their registries, so that they can be transferred.
+// Packages are downloaded and deleted too when they are migrated.
+func (e *Engine) Backup(ctx context.Context) error {
+	return e.runPhase(ctx, PhaseBackup, func() error {
+		// Make sure the local Docker daemon can hold the images before deleting any registry
+		if err := e.imageMigrator.CheckDiskSpace(ctx, e.plan.Projects, e.opts.TagsList); err != nil {
+			e.consoleUI.Error("Disk space check failed: %v", err)
+			if !e.opts.DryRun {
+				return fmt.Errorf("%w: %v", ErrDiskSpace, err)
+			}
+		}
+
+		backedUp := false
+		// A registry which cannot be deleted would block the transfer, the migration stops
+		var deleteErr error
+		// When the whole group is transferred, nothing is deleted before every project is backed up:
+		// a project keeping its registries would block the transfer of all the others
+		pending := make(map[int][]*gitlabCore.RegistryRepository)
+		err := e.forEachProject(ctx, PhaseBackup, func(project *ProjectInfo, result *ProjectResult) (err error) {
+			if deleteErr != nil {
+				return nil
+			}
+			e.consoleUI.PrintProjectHeader(project.Path, "💾 Backup")
+
+			if project.Archived {
+				if err := e.projectMigrator.UnarchiveProject(ctx, project.Path, project.ID); err != nil {
+					e.consoleUI.Error("Failed to unarchive project: %v", err)
+					return err
+				}
+				// A project whose backup fails is not migrated, it is archived again
+				defer func() {
+					if err != nil {
+						e.archiveAgain(ctx, project)
+					}
+				}()
+			}
+
+			// Nothing is deleted before the packages and every image of the project are backed up
+			if e.packageMigrator != nil {
+				packages, err := e.packageMigrator.BackupPackages(ctx, project)
+				if err != nil {
+					e.consoleUI.Error("Failed to backup packages: %v", err)
+					return err
+				}
+				result.Packages = packages
+			}
+			var repos []*gitlabCore.RegistryRepository
+			if project.ContainerRegistryEnabled {
+				images, repositories, err := e.imageMigrator.BackupImages(ctx, project, e.opts.TagsList)
+				if err != nil {
+					e.consoleUI.Error("Failed to backup images of %s: %v", project.Path, err)
+					return err
+				}
+				result.Images = images
+				repos = repositories
+			}
+
+			if e.plan.TransferGroup {
+				pending[project.ID] = repos
+				return nil
+			}
+			if err := e.deleteBackedUp(ctx, project, result, repos); err != nil {
+				deleteErr = err
+				return err
+			}
+			backedUp = backedUp || project.ContainerRegistryEnabled
+			return nil
+		})
+		if e.plan.TransferGroup && err == nil {
+			var failed []string
+			for _, project := range e.plan.Projects {
+				if e.projects[project.ID].Err != nil {
+					failed = append(failed, project.PathWithNamespace)
+				}
+			}
+			if len(failed) > 0 {
+				err = fmt.Errorf("%w: %s, nothing was deleted", ErrBackupFailed, strings.Join(failed, ", "))
+			}
+		}
+		if err != nil {
+			for _, project := range e.plan.Projects {
+				if _, ok := pending[project.ID]; ok {
+					e.archiveAgain(ctx, project)
+				}
+			}
+			return err
+		}
+		if deleteErr != nil {
+			return deleteErr
+		}
+
+		for _, project := range e.plan.Projects {
+			repos, ok := pending[project.ID]
+			if !ok {
+				continue
+			}
+			result := e.projects[project.ID]
+			if err := e.deleteBackedUp(ctx, project, result, repos); err != nil {
+				result.Err = err
+				return err
+			}
+			backedUp = backedUp || project.ContainerRegistryEnabled
+		}
+
+		if backedUp {
+			// Projects left in place, or whose backup failed, keep their images
+			projects := make(map[int]*ProjectInfo, len(e.plan.Projects))
+			for _, project := range e.plan.Projects {
+				if e.projects[project.ID].BackedUp {
+					projects[project.ID] = project
+				}
+			}
+			if err := e.imageMigrator.CheckIfRemainingImages(ctx, projects, e.opts.TagsList); err != nil {
+				e.consoleUI.Error("Failed to check if remaining images: %v", err)
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// deleteBackedUp deletes the packages and the registries of a project once they are backed up
+func (e *Engine) deleteBackedUp(ctx context.Context, project *ProjectInfo, result *ProjectResult, repos []*gitlabCore.RegistryRepository) error {
+	if e.packageMigrator != nil {
+		if err := e.packageMigrator.DeletePackages(ctx, project, result.Packages); err != nil {
+			return fmt.Errorf("failed to delete packages of project %s: %w", project.Path, err)
+		}
+	}
+
+	if !project.ContainerRegistryEnabled {
+		result.BackedUp = true
+		return nil
+	}
+	e.consoleUI.Info("👀 Found %d registries in project %s", len(project.RegistryRepositoriesIDs), project.Path)
+	e.consoleUI.PrintRemovingRegistry()
+	if err := e.imageMigrator.DeleteRegistries(ctx, project, repos); err != nil {
+		e.consoleUI.Error("Failed to delete registries: %v", err)
+		return fmt.Errorf("failed to delete registries of project %s: %w", project.Path, err)
+	}
+	result.BackedUp = true
+	return nil
+}
+
+// Transfer moves the source group below the destination, or the projects one by one
+// when the parent group is not kept or only some projects are migrated
+func (e *Engine) Transfer(ctx context.Context) error {
+	return e.runPhase(ctx, PhaseTransfer, func() error {
+		err := e.transfer(ctx)
+		if err == nil || errors.Is(err, ErrInterrupted) || errors.Is(err, context.Canceled) {
+			return err
+		}
+		// The projects left in place get their images and packages back, an interrupted migration
+		// keeps them in its checkpoint
+		errs := []error{err}
+		for _, project := range e.plan.Projects {
+			result := e.projects[project.ID]
+			if result.BackedUp && !result.Transferred && result.Err == nil {
+				if err := e.rollback(ctx, project, result); err != nil {
+					result.Err = err
+					errs = append(errs, err)
+				}
+			}
+		}
+		return errors.Join(errs...)
+	})
+}
+
+func (e *Engine) transfer(ctx context.Context) error {
+	if e.plan.TransferGroup {
+		e.consoleUI.PrintTransferringGroup(e.opts.SourceGroup, e.opts.DestinationGroup)
+		if err := e.groupMigrator.TransferGroup(ctx, e.plan.SourceGroup.ID, int(e.plan.DestinationGroup.ID)); err != nil {
+			e.consoleUI.Error("Failed to transfer group: %v", err)
+			return err
+		}
+		for _, result := range e.result.Projects {
+			result.Transferred = true
+		}
+		e.waitAfterTransfer()
+		return nil
+	}
+
+	targetRoot := e.plan.DestinationGroup
+	if e.opts.KeepParent {
+		// Only some projects are migrated, the group cannot be transferred: a counterpart is created instead
+		pathParts := strings.Split(e.plan.SourceGroup.Path, "/")
+		newGroupFullPath := fmt.Sprintf("%s/%s", e.plan.DestinationPath, pathParts[len(pathParts)-1])
+		existingGroup, err := e.groupMigrator.SearchGroup(ctx, newGroupFullPath)
+		if err != nil {
+			e.consoleUI.Info("🪄 New group %s does not exist yet, creating it...", newGroupFullPath)
+			createdGroup, err := e.groupMigrator.CreateGroupFrom(ctx, e.plan.SourceGroup, e.plan.DestinationGroup)
+			if err != nil {
+				e.consoleUI.Error("Failed to create new group: %v", err)
+				return err
+			}
+			targetRoot = createdGroup
+		} else {
+			e.consoleUI.Info("ℹ️ New group %s already exists, using it...", newGroupFullPath)
+			targetRoot = existingGroup
+		}
+	}
+	if e.opts.KeepParent {
+		e.mirroredGroups[e.plan.SourceGroup.FullPath] = targetRoot
+	}
+	if e.opts.KeepParent && len(e.plan.ExcludedGroups) > 0 {
+		// The sub-groups which are not excluded move as well, even when none of their projects is migrated
+		subGroupPaths := make([]string, 0, len(e.plan.SubGroups))
+		for _, subGroup := range e.plan.SubGroups {
+			subGroupPaths = append(subGroupPaths, subGroup.FullPath)
+		}
+		slices.Sort(subGroupPaths)
+		for _, subGroupPath := range subGroupPaths {
+			if _, err := e.groupMigrator.MirrorNamespace(ctx, e.plan.SourceGroup, targetRoot, subGroupPath, e.plan.SubGroups, e.mirroredGroups); err != nil {
+				e.consoleUI.Error("Failed to create sub-group %s: %v", subGroupPath, err)
+				return err
+			}
+		}
+	}
+
+	return e.forEachProject(ctx, PhaseTransfer, func(project *ProjectInfo, result *ProjectResult) error {
+		e.consoleUI.PrintProjectHeader(project.Path, "🚚 Transfer")
+
+		targetGroup := targetRoot
+		if e.opts.KeepParent && project.NamespacePath != "" {
+			// Reproduce the source sub-group tree so the project lands in its counterpart
+			var err error
+			targetGroup, err = e.groupMigrator.MirrorNamespace(ctx, e.plan.SourceGroup, targetRoot, project.NamespacePath, e.plan.SubGroups, e.mirroredGroups)
+			if err != nil {
+				e.consoleUI.Error("Failed to create sub-groups for project %s: %v", project.Path, err)
+				return errors.Join(err, e.rollback(ctx, project, result))
+			}
+		}
+
+		if err := e.projectMigrator.TransferProject(ctx, project.Path, project.ID, int(targetGroup.ID)); err != nil {
+			e.consoleUI.Error("Failed to transfer project: %v", err)
+			return errors.Join(err, e.rollback(ctx, project, result))
+		}
+		result.Transferred = true
+		e.transferredProjects[project.ID] = targetGroup
+		e.waitAfterTransfer()
+		return nil
+	})
+}
+
+// rollback pushes the images of a project whose transfer failed back to its source registry, publishes its
+// packages again and archives it, leaving it as it was before its backup. What cannot be put back stays
+// recorded as backed up.
+func (e *Engine) rollback(ctx context.Context, project *ProjectInfo, result *ProjectResult) error {
+	defer e.archiveAgain(ctx, project)
+
+	var errs []error
+	if len(result.Images) > 0 {
+		e.consoleUI.Warning("↩️ Pushing the images of project %s back to its source registry", project.Path)
+		if _, err := e.imageMigrator.RestoreImages(ctx, result.Images, project.PathWithNamespace, project.PathWithNamespace, e.opts.KeepParent); err != nil {
+			e.consoleUI.Error("Failed to push images back: %v", err)
+			errs = append(errs, fmt.Errorf("failed to push images of project %s back to its source registry: %w", project.Path, err))
+		}
+	}
+	if len(result.Packages) > 0 {
+		e.consoleUI.Warning("↩️ Publishing the packages of project %s again in its source project", project.Path)
+		if err := e.packageMigrator.RestorePackages(ctx, project, result.Packages); err != nil {
+			e.consoleUI.Error("Failed to publish packages again: %v", err)
+			errs = append(errs, fmt.Errorf("failed to publish packages of project %s again: %w", project.Path, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	result.BackedUp = false
+	return nil
+}
+
+// archiveAgain archives a project unarchived for its backup again when its migration stops before
+// the restore phase. A failure is only reported, the project keeps the error which stopped it.
+func (e *Engine) archiveAgain(ctx context.Context, project *ProjectInfo) {
+	if !project.Archived {
+		return
+	}
+	if err := e.projectMigrator.ArchiveProject(ctx, project.Path, project.ID); err != nil {
+		e.consoleUI.Error("Failed to archive project %s again: %v", project.Path, err)
+	}
+}
+
+// waitAfterTransfer gives GitLab time to move the registries of transferred projects
+func (e *Engine) waitAfterTransfer() {
+	if !e.opts.DryRun {
+		e.consoleUI.SleepWithLog(e.opts.TransferDelay)
+	}
+}
+
+// Restore pushes the images of the transferred projects to their new path, publishes their packages again
+// and re-archives them
+func (e *Engine) Restore(ctx context.Context) error {
+	return e.runPhase(ctx, PhaseRestore, func() error {
+		return e.forEachProject(ctx, PhaseRestore, func(project *ProjectInfo, result *ProjectResult) error {
+			e.consoleUI.PrintProjectHeader(project.Path, "🪄 Restore")
+
+			// Images which cannot be pushed stay in the local backup, the packages are restored
+			// and the project archived again all the same before the project is reported as failed
+			var imagesErr error
+			if len(result.Images) > 0 {
+				restored, err := e.imageMigrator.RestoreImages(ctx, result.Images, project.PathWithNamespace, result.Destination, e.opts.KeepParent)
+				if err != nil {
+					e.consoleUI.Error("Failed to restore images: %v", err)
+					imagesErr = fmt.Errorf("failed to restore images of project %s: %w", project.Path, err)
+				} else if e.opts.RemoveLocalImages {
+					if err := e.imageMigrator.RemoveLocalImages(ctx, project, result.Images, restored); err != nil {
+						e.consoleUI.Warning("⚠️ Local images of project %s are kept as backup: %v", project.Path, err)
+					}
+				}
+			}
+
+			if len(result.Packages) > 0 {
+				if err := e.packageMigrator.RestorePackages(ctx, project, result.Packages); err != nil {
+					e.consoleUI.Error("Failed to restore packages: %v", err)
+					return err
+				}
+			}
+
+			if project.Archived {
+				if err := e.projectMigrator.ArchiveProject(ctx, project.Path, project.ID); err != nil {
+					e.consoleUI.Error("Failed to archive project: %v", err)
+					return err
+				}
+			}
+
+			if imagesErr != nil {
+				return imagesErr
+			}
+			result.Restored = true
+			e.consoleUI.PrintMigrationComplete(project.Path)
+			return nil
+		})
+	})
+}
+
+// Finalize adds the members lost by groups and projects in their new location
+func (e *Engine) Finalize(ctx context.Context) error {
+	return e.runPhase(ctx, PhaseFinalize, func() error {
+		if e.memberMigrator == nil {
+			e.finalized = true
+			return nil
+		}
+
+		e.consoleUI.PrintSection("👥 Members")
+		changes := 0
+		if e.opts.KeepParent {
+			for sourcePath, destGroup := range e.mirroredGroups {
+				count, err := e.memberMigrator.SyncGroup(ctx, sourcePath, destGroup)
+				if err != nil {
+					e.consoleUI.Error("Failed to migrate members of group %s: %v", sourcePath, err)
+				}
+				changes += count
+			}
+		}
+		for _, project := range e.plan.Projects {
+			targetGroup, ok := e.transferredProjects[project.ID]
+			if !ok {
+				continue
+			}
+			// Without the parent group, share links inherited from source groups are lost and set on projects
+			count, err := e.memberMigrator.SyncProject(ctx, project, targetGroup, !e.opts.KeepParent)
+			if err != nil {
+				e.consoleUI.Error("Failed to migrate members of project %s: %v", project.Path, err)
+			}
+			changes += count
+		}
+		if changes == 0 {
+			e.consoleUI.Info("👥 No missing member")
+		}
+		e.result.MemberChanges = changes
+		e.finalized = true
+		return nil
+	})
+}
@@ -0,0 +1,618 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"migraptor/internal/fake"
+
+	gitlabCore "gitlab.com/gitlab-org/api/client-go"
+)
+
+var _ GitLabClient = (*fake.GitLab)(nil)
+
+// recordingHooks records the events of a migration as strings
+type recordingHooks struct {
+	NoopHooks
+	events []string
+}
+
+func (h *recordingHooks) PhaseStarted(phase Phase) {
+	h.events = append(h.events, fmt.Sprintf("start %s", phase))
+}
+
+func (h *recordingHooks) ProjectFinished(phase Phase, project *ProjectInfo, err error) {
+	h.events = append(h.events, fmt.Sprintf("%s %s: %v", phase, project.PathWithNamespace, err))
+}
+
+func TestEngine_TransferGroup(t *testing.T) {
+	f := newMigrationFixture(t)
+	sleeps := 0
+	engine := NewEngine(f.gl, f.engine, Options{
+		SourceGroup:      "org/team",
+		DestinationGroup: "platform",
+		KeepParent:       true,
+	}, newTestUI(&sleeps))
+	hooks := &recordingHooks{}
+	engine.SetHooks(hooks)
+
+	result, err := engine.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(result.Projects) != 3 || len(result.Failed()) != 0 {
+		t.Fatalf("Expected 3 projects migrated, got %d with %d failures", len(result.Projects), len(result.Failed()))
+	}
+	if !result.Plan.TransferGroup {
+		t.Error("Expected the whole group to be transferred")
+	}
+
+	expected := map[string][]string{
+		"platform/team/app":         {testRegistry + "/platform/team/app/worker:1.0", testRegistry + "/platform/team/app:1.0"},
+		"platform/team/backend/api": {testRegistry + "/platform/team/backend/api:2.0"},
+		"platform/team/docs":        nil,
+	}
+	for _, project := range result.Projects {
+		images, ok := expected[project.Destination]
+		if !ok {
+			t.Errorf("Unexpected destination %s", project.Destination)
+			continue
+		}
+		if !project.Transferred || !project.Restored {
+			t.Errorf("Expected %s to be transferred and restored", project.Destination)
+		}
+		if got := f.gl.Images(project.Destination); !slices.Equal(got, images) {
+			t.Errorf("Expected images %v in %s, got %v", images, project.Destination, got)
+		}
+	}
+
+	expectedEvents := []string{
+		"start discover",
+		"start backup",
+		"backup org/team/app: <nil>",
+		"backup org/team/backend/api: <nil>",
+		"backup org/team/docs: <nil>",
+		"start transfer",
+		"start restore",
+		"restore org/team/app: <nil>",
+		"restore org/team/backend/api: <nil>",
+		"restore org/team/docs: <nil>",
+		"start finalize",
+	}
+	if !slices.Equal(hooks.events, expectedEvents) {
+		t.Errorf("Expected events %v, got %v", expectedEvents, hooks.events)
+	}
+}
+
+func TestEngine_ProjectsIndividually(t *testing.T) {
+	f := newMigrationFixture(t)
+	f.gl.AddProject("platform/docs")
+	alice := f.gl.AddUser("alice")
+	if err := f.gl.AddGroupMember(t.Context(), int(f.source.ID), alice.ID, gitlabCore.DeveloperPermissions, ""); err != nil {
+		t.Fatalf("AddGroupMember failed: %v", err)
+	}
+
+	engine := NewEngine(f.gl, f.engine, Options{
+		SourceGroup:      "org/team",
+		DestinationGroup: "platform",
+		MigrateMembers:   true,
+	}, newTestUI(nil))
+	result, err := engine.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// docs collides with an existing project, the other projects are migrated
+	failed := result.Failed()
+	if len(failed) != 1 || failed[0].Project.Path != "docs" || failed[0].Transferred {
+		t.Fatalf("Expected only docs to fail, got %v", failed)
+	}
+	expected := []string{testRegistry + "/platform/api:2.0"}
+	if got := f.gl.Images("platform/api"); !slices.Equal(got, expected) {
+		t.Errorf("Expected images %v, got %v", expected, got)
+	}
+
+	// alice is added to the two transferred projects
+	if result.MemberChanges != 2 {
+		t.Errorf("Expected 2 member changes, got %d", result.MemberChanges)
+	}
+	if level, _ := f.gl.GetProjectAccessLevel(t.Context(), int(f.gl.Project("platform/api").ID), alice.ID); level != gitlabCore.DeveloperPermissions {
+		t.Errorf("Expected alice to keep developer access, got %v", level)
+	}
+}
+
+func TestEngine_Selection(t *testing.T) {
+	f := newMigrationFixture(t)
+	f.gl.SetProjectTopics(f.gl.Project("org/team/backend/api").ID, "backend", "deprecated")
+	selection, err := NewSelection(SelectionRules{Include: []string{"org/team/*"}, Topics: []string{"!deprecated"}})
+	if err != nil {
+		t.Fatalf("NewSelection failed: %v", err)
+	}
+
+	engine := NewEngine(f.gl, f.engine, Options{
+		SourceGroup:      "org/team",
+		DestinationGroup: "platform",
+		KeepParent:       true,
+		Selection:        selection,
+	}, newTestUI(nil))
+	plan, err := engine.Discover(t.Context())
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	if plan.TransferGroup {
+		t.Error("Expected projects to be transferred individually when some stay in place")
+	}
+
+	expected := []string{
+		"org/team/app: true, matches include pattern org/team/*",
+		"org/team/backend/api: false, has excluded topic deprecated",
+		"org/team/docs: true, matches include pattern org/team/*",
+	}
+	var decisions []string
+	for _, decision := range plan.Decisions {
+		decisions = append(decisions, fmt.Sprintf("%s: %v, %s", decision.Project.PathWithNamespace, decision.Included, decision.Reason))
+	}
+	if !slices.Equal(decisions, expected) {
+		t.Errorf("Expected decisions %v, got %v", expected, decisions)
+	}
+	if len(plan.Projects) != 2 || plan.Projects[0].Path != "app" || plan.Projects[1].Path != "docs" {
+		t.Errorf("Expected app and docs to be migrated, got %v", plan.Projects)
+	}
+}
+
+func TestEngine_ExcludeGroups(t *testing.T) {
+	f := newMigrationFixture(t)
+	f.gl.AddProject("org/team/backend/legacy/worker")
+	f.gl.AddGroup("org/team/infra/terraform")
+	selection, err := NewSelection(SelectionRules{Group: "org/team", ExcludeGroups: []string{"backend", "org/team/missing"}})
+	if err != nil {
+		t.Fatalf("NewSelection failed: %v", err)
+	}
+
+	engine := NewEngine(f.gl, f.engine, Options{
+		SourceGroup:      "org/team",
+		DestinationGroup: "platform",
+		KeepParent:       true,
+		Selection:        selection,
+	}, newTestUI(nil))
+	result, err := engine.Run(t.Context())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.Plan.TransferGroup || !slices.Equal(result.Plan.ExcludedGroups, []string{"org/team/backend"}) {
+		t.Errorf("Expected org/team/backend to be excluded from an individual transfer, got %v", result.Plan.ExcludedGroups)
+	}
+	for _, subGroup := range result.Plan.SubGroups {
+		if subGroup.FullPath != "org/team/infra" && subGroup.FullPath != "org/team/infra/terraform" {
+			t.Errorf("Unexpected sub-group %s in the plan", subGroup.FullPath)
+		}
+	}
+	if len(result.Projects) != 2 || len(result.Failed()) != 0 {
+		t.Fatalf("Expected app and docs to be migrated, got %d projects with %d failures", len(result.Projects), len(result.Failed()))
+	}
+
+	// Excluded sub-trees stay in place, the other sub-groups are recreated even without projects
+	if f.gl.Project("org/team/backend/api") == nil || f.gl.Project("org/team/backend/legacy/worker") == nil {
+		t.Error("Expected the projects of the excluded sub-group to stay in place")
+	}
+	if f.gl.Project("platform/team/app") == nil || f.gl.Project("platform/team/docs") == nil {
+		t.Error("Expected app and docs to be transferred")
+	}
+	if f.gl.Group("platform/team/infra/terraform") == nil || f.gl.Group("platform/team/backend") != nil {
+		t.Error("Expected only the sub-groups which are not excluded to be recreated")
+	}
+	var reasons []string
+	for _, decision := range result.Plan.Decisions {
+		if !decision.Included {
+			reasons = append(reasons, decision.Reason)
+		}
+	}
+	expected := []string{"in excluded sub-group org/team/backend", "in excluded sub-group org/team/backend"}
+	if !slices.Equal(reasons, expected) {
+		t.Errorf("Expected reasons %v, got %v", expected, reasons)
+	}
+}
+
+func TestEngine_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     Options
+		expected error
+		phase    Phase
+	}{
+		{
+			name:     "source group not found",
+			opts:     Options{SourceGroup: "unknown", DestinationGroup: "platform"},
+			expected: ErrGroupNotFound,
+			phase:    PhaseDiscover,
+		},
+		{
+			name:     "no project",
+			opts:     Options{SourceGroup: "platform", DestinationGroup: "org"},
+			expected: ErrNoProjects,
+			phase:    PhaseDiscover,
+		},
+		{
+			name:     "no project selected",
+			opts:     Options{SourceGroup: "org/team", DestinationGroup: "platform", Selection: selectProjects(t, "missing")},
+			expected: ErrNoProjects,
+			phase:    PhaseDiscover,
+		},
+		{
+			name: "preflight failed",
+			opts: Options{
+				SourceGroup:      "org/team",
+				DestinationGroup: "platform",
+				Preflight:        func(ctx context.Context, plan *Plan) bool { return true },
+			},
+			expected: ErrPreflightFailed,
+			phase:    PhaseDiscover,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newMigrationFixture(t)
+			_, err := NewEngine(f.gl, f.engine, tt.opts, newTestUI(nil)).Run(context.Background())
+
+			var phaseErr *PhaseError
+			if !errors.Is(err, tt.expected) || !errors.As(err, &phaseErr) || phaseErr.Phase != tt.phase {
+				t.Errorf("Expected %v in %s phase, got %v", tt.expected, tt.phase, err)
+			}
+			if len(f.engine.Pulls) != 0 {
+				t.Errorf("Expected no image to be pulled, got %v", f.engine.Pulls)
+			}
+		})
+	}
+}
+
+func TestEngine_DiskSpace(t *testing.T) {
+	f := newMigrationFixture(t)
+	f.engine.FreeSpace = 10
+
+	for _, dryRun := range []bool{true, false} {
+		engine := NewEngine(f.gl, f.engine, Options{SourceGroup: "org/team", DestinationGroup: "platform", DryRun: dryRun}, newTestUI(nil))
+		_, err := engine.Run(context.Background())
+		if dryRun && err != nil {
+			t.Errorf("Expected dry run to go on, got %v", err)
+		}
+		if !dryRun && !errors.Is(err, ErrDiskSpace) {
+			t.Errorf("Expected disk space error, got %v", err)
+		}
+	}
+	if got := f.gl.Images("org/team/app"); len(got) != 2 {
+		t.Errorf("Expected registries to be kept, got %v", got)
+	}
+}
+
+func TestEngine_BackupFailed(t *testing.T) {
+	f := newMigrationFixture(t)
+	f.engine.PullErrors = map[string]error{testRegistry + "/org/team/app/worker:1.0": errors.New("unauthorized")}
+	if _, err := f.gl.ArchiveProject(t.Context(), int(f.gl.Project("org/team/app").ID)); err != nil {
+		t.Fatal(err)
+	}
+
+	engine := NewEngine(f.gl, f.engine, Options{SourceGroup: "org/team", DestinationGroup: "platform"}, newTestUI(nil))
+	result, err := engine.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	failed := result.Failed()
+	if len(failed) != 1 || failed[0].Project.Path != "app" || failed[0].BackedUp || failed[0].Transferred {
+		t.Fatalf("Expected only app to fail before its transfer, got %v", failed)
+	}
+	// The registry of the failed project is not deleted
+	if got := f.gl.Images("org/team/app"); len(got) != 2 {
+		t.Errorf("Expected registry of app to be kept, got %v", got)
+	}
+	// The project unarchived for its backup is archived again
+	if !f.gl.Project("org/team/app").Archived {
+		t.Error("Expected app to be archived again")
+	}
+	expected := []string{testRegistry + "/platform/api:2.0"}
+	if got := f.gl.Images("platform/api"); !slices.Equal(got, expected) {
+		t.Errorf("Expected images %v, got %v", expected, got)
+	}
+}
+
+// tagListingHooks makes the tag listings of a registry repository fail once the backup of the projects has
+// started, after the disk space check
+type tagListingHooks struct {
+	NoopHooks
+	gl         *fake.GitLab
+	repository string
+}
+
+func (h *tagListingHooks) ProjectStarted(phase Phase, project *ProjectInfo) {
+	if phase == PhaseBackup {
+		h.gl.TagListErrors = map[string]error{h.repository: errors.New("internal server error")}
+	}
+}
+
+func TestEngine_TagListingFailed(t *testing.T) {
+	f := newMigrationFixture(t)
+	engine := NewEngine(f.gl, f.engine, Options{SourceGroup: "org/team", DestinationGroup: "platform"}, newTestUI(nil))
+	engine.SetHooks(&tagListingHooks{gl: f.gl, repository: "org/team/app/worker"})
+
+	result, err := engine.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	failed := result.Failed()
+	if len(failed) != 1 || failed[0].Project.Path != "app" || failed[0].BackedUp {
+		t.Fatalf("Expected only app to fail during its backup, got %v", failed)
+	}
+	if !strings.Contains(failed[0].Err.Error(), "org/team/app/worker") {
+		t.Errorf("Expected the error to name the repository, got %v", failed[0].Err)
+	}
+	// No registry of the failed project is deleted, including the one whose tags were listed
+	if got := f.gl.Images("org/team/app"); len(got) != 2 {
+		t.Errorf("Expected registries of app to be kept, got %v", got)
+	}
+}
+
+func TestEngine_TransferGroupBackupFailed(t *testing.T) {
+	f := newMigrationFixture(t)
+	f.engine.PullErrors = map[string]error{testRegistry + "/org/team/app/worker:1.0": errors.New("unauthorized")}
+
+	engine := NewEngine(f.gl, f.engine, Options{SourceGroup: "org/team", DestinationGroup: "platform", KeepParent: true}, newTestUI(nil))
+	result, err := engine.Run(context.Background())
+	var phaseErr *PhaseError
+	if !errors.Is(err, ErrBackupFailed) || !errors.As(err, &phaseErr) || phaseErr.Phase != PhaseBackup {
+		t.Fatalf("Expected backup phase to fail, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "org/team/app") {
+		t.Errorf("Expected the error to name the failed project, got %v", err)
+	}
+
+	// The group is not transferred, no registry is deleted, even of the projects backed up
+	for _, project := range result.Projects {
+		if project.BackedUp || project.Transferred {
+			t.Errorf("Expected %s to be left in place, got %+v", project.Project.PathWithNamespace, project)
+		}
+	}
+	if got := f.gl.Images("org/team/app"); len(got) != 2 {
+		t.Errorf("Expected registries of app to be kept, got %v", got)
+	}
+	expected := []string{testRegistry + "/org/team/backend/api:2.0"}
+	if got := f.gl.Images("org/team/backend/api"); !slices.Equal(got, expected) {
+		t.Errorf("Expected images %v, got %v", expected, got)
+	}
+	if f.gl.Project("org/team/app") == nil {
+		t.Error("Expected the group to stay in place")
+	}
+}
+
+func TestEngine_TransferGroupFailed(t *testing.T) {
+	f := newMigrationFixture(t)
+	// npm packages are scoped to the root namespace and block the transfer of the group
+	if err := f.gl.AddPackage("org/team/app", "npm", "@org/app", "1.0.0", map[string][]byte{"app-1.0.0.tgz": []byte("tarball")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.gl.ArchiveProject(t.Context(), int(f.gl.Project("org/team/backend/api").ID)); err != nil {
+		t.Fatal(err)
+	}
+
+	engine := NewEngine(f.gl, f.engine, Options{SourceGroup: "org/team", DestinationGroup: "platform", KeepParent: true}, newTestUI(nil))
+	result, err := engine.Run(context.Background())
+	var phaseErr *PhaseError
+	if !errors.As(err, &phaseErr) || phaseErr.Phase != PhaseTransfer {
+		t.Fatalf("Expected transfer phase to fail, got %v", err)
+	}
+
+	// The images are pushed back to the source registries, the projects are left as before the migration
+	for _, project := range result.Projects {
+		if project.BackedUp || project.Transferred || project.Err != nil {
+			t.Errorf("Expected %s to be rolled back, got %+v", project.Project.PathWithNamespace, project)
+		}
+	}
+	expected := map[string][]string{
+		"org/team/app":         {testRegistry + "/org/team/app/worker:1.0", testRegistry + "/org/team/app:1.0"},
+		"org/team/backend/api": {testRegistry + "/org/team/backend/api:2.0"},
+	}
+	for path, images := range expected {
+		if got := f.gl.Images(path); !slices.Equal(got, images) {
+			t.Errorf("Expected images %v in %s, got %v", images, path, got)
+		}
+	}
+	if !f.gl.Project("org/team/backend/api").Archived {
+		t.Error("Expected api to be archived again")
+	}
+	if engine.HasPendingBackups() {
+		t.Error("Expected no pending backup once the images are pushed back")
+	}
+}
+
+func TestEngine_TransferFailedAfterBackup(t *testing.T) {
+	f := newMigrationFixture(t)
+	f.gl.AddProject("platform/docs")
+	addImages(t, f.gl, 10, testRegistry+"/org/team/docs:1.0")
+	if err := f.gl.AddPackage("org/team/docs", "generic", "site", "1.0.0", map[string][]byte{"site.tar.gz": []byte("site")}); err != nil {
+		t.Fatal(err)
+	}
+
+	engine := NewEngine(f.gl, f.engine, Options{
+		SourceGroup:      "org/team",
+		DestinationGroup: "platform",
+		MigratePackages:  true,
+		PackageDir:       t.TempDir(),
+	}, newTestUI(nil))
+	result, err := engine.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// docs collides with an existing project once its package and registry are deleted, both are put back
+	failed := result.Failed()
+	if len(failed) != 1 || failed[0].Project.Path != "docs" || failed[0].Transferred || failed[0].BackedUp {
+		t.Fatalf("Expected only docs to fail and be rolled back, got %v", failed)
+	}
+	expected := []string{"generic site 1.0.0"}
+	if got := f.gl.Packages("org/team/docs"); !slices.Equal(got, expected) {
+		t.Errorf("Expected packages %v in the source project, got %v", expected, got)
+	}
+	expected = []string{testRegistry + "/org/team/docs:1.0"}
+	if got := f.gl.Images("org/team/docs"); !slices.Equal(got, expected) {
+		t.Errorf("Expected images %v in the source registry, got %v", expected, got)
+	}
+	if engine.HasPendingBackups() {
+		t.Error("Expected no pending backup once docs is rolled back")
+	}
+}
+
+func TestEngine_PushFailed(t *testing.T) {
+	f := newMigrationFixture(t)
+	failing := testRegistry + "/platform/team/app/worker:1.0"
+	f.engine.PushErrors = map[string]error{failing: errors.New("denied")}
+
+	engine := NewEngine(f.gl, f.engine, Options{
+		SourceGroup:       "org/team",
+		DestinationGroup:  "platform",
+		KeepParent:        true,
+		RemoveLocalImages: true,
+	}, newTestUI(nil))
+	result, err := engine.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	failed := result.Failed()
+	if len(failed) != 1 || failed[0].Project.Path != "app" || failed[0].Restored {
+		t.Fatalf("Expected only app to fail, got %v", failed)
+	}
+	if failed[0].Err == nil || !strings.Contains(failed[0].Err.Error(), testRegistry+"/org/team/app/worker:1.0") {
+		t.Errorf("Expected the error to list the image not pushed, got %v", failed[0].Err)
+	}
+	// The images not pushed are only in the local backup, they are recorded in the checkpoint
+	if !engine.HasPendingBackups() {
+		t.Error("Expected app to have a pending backup")
+	}
+	if app := engine.Checkpoint().Projects[0]; len(app.Images) != 2 {
+		t.Errorf("Expected the checkpoint to list the images of app, got %+v", app)
+	}
+	// The images of the failed project are kept locally as backup
+	if slices.Contains(f.engine.Removals, testRegistry+"/org/team/app:1.0") {
+		t.Errorf("Expected local images of app to be kept, got removals %v", f.engine.Removals)
+	}
+	expected := []string{testRegistry + "/platform/team/backend/api:2.0"}
+	if got := f.gl.Images("platform/team/backend/api"); !slices.Equal(got, expected) {
+		t.Errorf("Expected images %v, got %v", expected, got)
+	}
+}
+
+func TestEngine_Canceled(t *testing.T) {
+	f := newMigrationFixture(t)
+	engine := NewEngine(f.gl, f.engine, Options{SourceGroup: "org/team", DestinationGroup: "platform", KeepParent: true}, newTestUI(nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := engine.Discover(ctx); err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	cancel()
+
+	err := engine.Backup(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected backup to be canceled, got %v", err)
+	}
+	if len(f.engine.Pulls) != 0 {
+		t.Errorf("Expected no image to be pulled, got %v", f.engine.Pulls)
+	}
+
+	// Phases cannot run before discover
+	if err := NewEngine(f.gl, f.engine, Options{}, newTestUI(nil)).Transfer(context.Background()); err == nil {
+		t.Error("Expected transfer to fail before discover")
+	}
+}
+
+// stoppingHooks stops the engine once the first project of a phase is finished
+type stoppingHooks struct {
+	NoopHooks
+	engine *Engine
+	phase  Phase
+}
+
+func (h *stoppingHooks) ProjectFinished(phase Phase, project *ProjectInfo, err error) {
+	if phase == h.phase {
+		h.engine.Stop()
+	}
+}
+
+func TestEngine_Stop(t *testing.T) {
+	f := newMigrationFixture(t)
+	// Projects transferred one by one have their registries deleted as soon as they are backed up
+	engine := NewEngine(f.gl, f.engine, Options{SourceGroup: "org/team", DestinationGroup: "platform"}, newTestUI(nil))
+	engine.SetHooks(&stoppingHooks{engine: engine, phase: PhaseBackup})
+
+	_, err := engine.Run(context.Background())
+	var phaseErr *PhaseError
+	if !errors.Is(err, ErrInterrupted) || !errors.As(err, &phaseErr) || phaseErr.Phase != PhaseBackup {
+		t.Fatalf("Expected backup to be interrupted, got %v", err)
+	}
+	// The first project is fully backed up, the others are left untouched
+	if len(f.engine.Pulls) != 2 {
+		t.Errorf("Expected only app images to be pulled, got %v", f.engine.Pulls)
+	}
+	if got := f.gl.Images("org/team/backend/api"); len(got) != 1 {
+		t.Errorf("Expected api registries to be kept, got %v", got)
+	}
+
+	checkpoint := engine.Checkpoint()
+	if checkpoint.Phase != PhaseBackup || len(checkpoint.Projects) != 3 {
+		t.Fatalf("Expected 3 projects remaining in backup phase, got %d in %s", len(checkpoint.Projects), checkpoint.Phase)
+	}
+	app := checkpoint.Projects[0]
+	if app.Path != "org/team/app" || !slices.Equal(app.Remaining, []Phase{PhaseTransfer, PhaseRestore}) || len(app.Images) != 2 {
+		t.Errorf("Expected app to remain to transfer and restore with 2 images, got %+v", app)
+	}
+	api := checkpoint.Projects[1]
+	if !slices.Equal(api.Remaining, []Phase{PhaseBackup, PhaseTransfer, PhaseRestore}) || len(api.Images) != 0 {
+		t.Errorf("Expected api to remain to migrate entirely, got %+v", api)
+	}
+
+	path := t.TempDir() + "/checkpoint.json"
+	if err := checkpoint.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+}
+
+func TestEngine_Packages(t *testing.T) {
+	for _, migratePackages := range []bool{false, true} {
+		t.Run(fmt.Sprintf("migrate packages %v", migratePackages), func(t *testing.T) {
+			f := newMigrationFixture(t)
+			if err := f.gl.AddPackage("org/team/app", "npm", "@org/app", "1.0.0", map[string][]byte{"app-1.0.0.tgz": []byte("tarball")}); err != nil {
+				t.Fatal(err)
+			}
+
+			engine := NewEngine(f.gl, f.engine, Options{
+				SourceGroup:      "org/team",
+				DestinationGroup: "platform",
+				KeepParent:       true,
+				MigratePackages:  migratePackages,
+				PackageDir:       t.TempDir(),
+			}, newTestUI(nil))
+			_, err := engine.Run(context.Background())
+
+			if !migratePackages {
+				// npm packages are scoped to the root namespace and block the transfer
+				var phaseErr *PhaseError
+				if !errors.As(err, &phaseErr) || phaseErr.Phase != PhaseTransfer {
+					t.Fatalf("Expected transfer phase to fail, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+			expected := []string{"npm @org/app 1.0.0"}
+			if got := f.gl.Packages("platform/team/app"); !slices.Equal(got, expected) {
+				t.Errorf("Expected packages %v, got %v", expected, got)
+			}
+		})
+	}
+}
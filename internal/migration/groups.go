@@ -1,9 +1,10 @@
 package migration
 
 import (
+	"context"
 	"fmt"
 	"maps"
-	"migraptor/internal/gitlab"
+	"migraptor/internal/config"
 	"migraptor/internal/ui"
 	"strings"
 
@@ -12,13 +13,14 @@ import (
 
 // GroupMigrator handles group-related migration operations
 type GroupMigrator struct {
-	client    *gitlab.Client
+	client    GroupClient
 	consoleUI *ui.UI
 	dryRun    bool
+	template  *config.GroupTemplate
 }
 
 // NewGroupMigrator creates a new GroupMigrator
-func NewGroupMigrator(client *gitlab.Client, dryRun bool, cUI *ui.UI) *GroupMigrator {
+func NewGroupMigrator(client GroupClient, dryRun bool, cUI *ui.UI) *GroupMigrator {
 	return &GroupMigrator{
 		client:    client,
 		dryRun:    dryRun,
@@ -27,12 +29,12 @@ func NewGroupMigrator(client *gitlab.Client, dryRun bool, cUI *ui.UI) *GroupMigr
 }
 
 // SearchGroup searches for a group by name/path
-func (gm *GroupMigrator) SearchGroup(name string) (*gitlabCore.Group, error) {
+func (gm *GroupMigrator) SearchGroup(ctx context.Context, name string) (*gitlabCore.Group, error) {
 	gm.consoleUI.Debug("Searching for group: %s", name)
 
 	trimName := strings.TrimSuffix(name, "/")
 	trimName = strings.TrimPrefix(trimName, "/")
-	result, err := gm.client.SearchGroup(trimName)
+	result, err := gm.client.SearchGroup(ctx, trimName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search group %s: %w", name, err)
 	}
@@ -40,25 +42,31 @@ func (gm *GroupMigrator) SearchGroup(name string) (*gitlabCore.Group, error) {
 	return result, nil
 }
 
-func (gm *GroupMigrator) GetSubGroupsAndProjects(groupID int64, filterList []string) (map[int64]*gitlabCore.Group, map[int]*ProjectInfo, error) {
+// GetSubGroupsAndProjects lists the sub-groups of a group recursively, with their projects included by the selection.
+// The sub-groups excluded by the selection are skipped with their whole sub-tree.
+func (gm *GroupMigrator) GetSubGroupsAndProjects(ctx context.Context, groupID int64, selection *Selection) (map[int64]*gitlabCore.Group, map[int]*ProjectInfo, error) {
 
 	allProjects := make(map[int]*ProjectInfo)
 	allSubGroups := make(map[int64]*gitlabCore.Group)
 
-	subgroups, err := gm.client.GetSubGroups(groupID)
+	subgroups, err := gm.client.GetSubGroups(ctx, groupID)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to get subgroups for group %d: %w", groupID, err)
 	}
 
 	for _, subgroup := range subgroups {
+		if selection.ExcludedGroup(subgroup.FullPath) != "" {
+			gm.consoleUI.Debug("Skipping excluded sub-group %s", subgroup.FullPath)
+			continue
+		}
 		subGrpID := subgroup.ID
 		allSubGroups[subGrpID] = &*subgroup
-		subprojects, _, _ := gm.client.ListProjects(int(subGrpID))
-		for _, subproject := range FilterProjects(subprojects, filterList) {
+		subprojects, _, _ := gm.client.ListProjects(ctx, int(subGrpID))
+		for _, subproject := range FilterProjects(subprojects, selection) {
 			allProjects[subproject.ID] = &subproject
 		}
 
-		innerGroups, innerProjects, err := gm.GetSubGroupsAndProjects(subGrpID, filterList)
+		innerGroups, innerProjects, err := gm.GetSubGroupsAndProjects(ctx, subGrpID, selection)
 		maps.Copy(allProjects, innerProjects)
 		maps.Copy(allSubGroups, innerGroups)
 		if err != nil {
@@ -70,7 +78,7 @@ func (gm *GroupMigrator) GetSubGroupsAndProjects(groupID int64, filterList []str
 }
 
 // TransferGroup transfers a group to another group
-func (gm *GroupMigrator) TransferGroup(groupID int64, targetGroupID int) error {
+func (gm *GroupMigrator) TransferGroup(ctx context.Context, groupID int64, targetGroupID int) error {
 	gm.consoleUI.PrintTransferringGroup(fmt.Sprintf("group-%d", groupID), fmt.Sprintf("group-%d", targetGroupID))
 
 	if gm.dryRun {
@@ -78,7 +86,7 @@ func (gm *GroupMigrator) TransferGroup(groupID int64, targetGroupID int) error {
 		return nil
 	}
 
-	resp, err := gm.client.TransferGroup(int(groupID), targetGroupID)
+	resp, err := gm.client.TransferGroup(ctx, int(groupID), targetGroupID)
 	if err != nil {
 		return fmt.Errorf("failed to transfer group: %w", err)
 	}
@@ -91,3 +99,72 @@ func (gm *GroupMigrator) TransferGroup(groupID int64, targetGroupID int) error {
 	gm.consoleUI.PrintMoveResult(fmt.Sprintf("%d", resp.StatusCode))
 	return nil
 }
+
+// CreateGroupFrom creates a group under parent, copying the settings of the source group
+// (path, name, visibility, description, avatar, branch protection, project creation level,
+// shared runners and labels) or the ones of the configured group template
+func (gm *GroupMigrator) CreateGroupFrom(ctx context.Context, source *gitlabCore.Group, parent *gitlabCore.Group) (*gitlabCore.Group, error) {
+	settings, err := gm.BuildGroupSettings(ctx, source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute settings of group %s: %w", source.FullPath, err)
+	}
+
+	return gm.ProvisionGroup(ctx, settings, parent)
+}
+
+// relativeNamespace returns the path of namespace below root, empty for root itself. It returns false when
+// namespace is neither root nor one of its sub-groups, such as org/team2 for org/team.
+func relativeNamespace(namespace, root string) (string, bool) {
+	if namespace == root {
+		return "", true
+	}
+	return strings.CutPrefix(namespace, root+"/")
+}
+
+// MirrorNamespace ensures the sub-group hierarchy between sourceRoot and sourceNamespace exists below destRoot,
+// creating missing groups from their source counterpart, and returns the destination group matching sourceNamespace.
+// mirrored caches the destination groups already resolved, keyed by source full path.
+func (gm *GroupMigrator) MirrorNamespace(ctx context.Context, sourceRoot, destRoot *gitlabCore.Group, sourceNamespace string, subGroups map[int64]*gitlabCore.Group, mirrored map[string]*gitlabCore.Group) (*gitlabCore.Group, error) {
+	relative, ok := relativeNamespace(sourceNamespace, sourceRoot.FullPath)
+	if !ok {
+		return nil, fmt.Errorf("namespace %s is not below group %s", sourceNamespace, sourceRoot.FullPath)
+	}
+	if relative == "" {
+		return destRoot, nil
+	}
+
+	sourceByPath := make(map[string]*gitlabCore.Group, len(subGroups))
+	for _, subGroup := range subGroups {
+		sourceByPath[subGroup.FullPath] = subGroup
+	}
+
+	sourcePath := sourceRoot.FullPath
+	current := destRoot
+	for _, segment := range strings.Split(relative, "/") {
+		sourcePath = fmt.Sprintf("%s/%s", sourcePath, segment)
+		if dest, ok := mirrored[sourcePath]; ok {
+			current = dest
+			continue
+		}
+
+		destPath := fmt.Sprintf("%s/%s", current.FullPath, segment)
+		existing, err := gm.SearchGroup(ctx, destPath)
+		if err == nil && existing != nil {
+			gm.consoleUI.Debug("Group %s already exists, using it", destPath)
+			current = existing
+		} else {
+			source, ok := sourceByPath[sourcePath]
+			if !ok {
+				return nil, fmt.Errorf("source sub-group %s not found", sourcePath)
+			}
+			gm.consoleUI.Info("🪄 Group %s does not exist yet, creating it...", destPath)
+			current, err = gm.CreateGroupFrom(ctx, source, current)
+			if err != nil {
+				return nil, err
+			}
+		}
+		mirrored[sourcePath] = current
+	}
+
+	return current, nil
+}
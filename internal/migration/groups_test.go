@@ -0,0 +1,257 @@
+package migration
+
+import (
+	"strings"
+	"testing"
+
+	"migraptor/internal/config"
+	"migraptor/internal/fake"
+
+	gitlabCore "gitlab.com/gitlab-org/api/client-go"
+)
+
+func TestSearchGroup_TrimsSlashes(t *testing.T) {
+	gl := fake.NewGitLab(testRegistry)
+	gl.AddGroup("team/backend")
+	gm := NewGroupMigrator(gl, false, newTestUI(nil))
+
+	group, err := gm.SearchGroup(t.Context(), "/team/backend/")
+	if err != nil {
+		t.Fatalf("SearchGroup failed: %v", err)
+	}
+	if group.FullPath != "team/backend" {
+		t.Errorf("Expected group team/backend, got %s", group.FullPath)
+	}
+
+	if _, err := gm.SearchGroup(t.Context(), "team/frontend"); err == nil {
+		t.Error("Expected an error for a missing group")
+	}
+}
+
+func TestGetSubGroupsAndProjects(t *testing.T) {
+	gl := fake.NewGitLab(testRegistry)
+	root := gl.AddGroup("team")
+	gl.AddProject("team/app")
+	gl.AddProject("team/backend/api")
+	gl.AddProject("team/backend/db/postgres")
+	gl.AddProject("team/backend/db/redis")
+	gm := NewGroupMigrator(gl, false, newTestUI(nil))
+
+	subGroups, projects, err := gm.GetSubGroupsAndProjects(t.Context(), root.ID, nil)
+	if err != nil {
+		t.Fatalf("GetSubGroupsAndProjects failed: %v", err)
+	}
+	if len(subGroups) != 2 {
+		t.Errorf("Expected 2 sub-groups, got %d", len(subGroups))
+	}
+	// Projects of the group itself are listed separately
+	if len(projects) != 3 {
+		t.Errorf("Expected 3 projects in sub-groups, got %d", len(projects))
+	}
+	for _, project := range projects {
+		if project.NamespacePath == "team" {
+			t.Errorf("Project %s of the root group should not be returned", project.Path)
+		}
+	}
+
+	_, filtered, err := gm.GetSubGroupsAndProjects(t.Context(), root.ID, selectProjects(t, "redis"))
+	if err != nil {
+		t.Fatalf("GetSubGroupsAndProjects failed: %v", err)
+	}
+	if len(filtered) != 1 {
+		t.Fatalf("Expected 1 filtered project, got %d", len(filtered))
+	}
+	for _, project := range filtered {
+		if project.PathWithNamespace != "team/backend/db/redis" || project.NamespacePath != "team/backend/db" {
+			t.Errorf("Unexpected project %s in namespace %s", project.PathWithNamespace, project.NamespacePath)
+		}
+	}
+}
+
+func TestTransferGroup(t *testing.T) {
+	gl := fake.NewGitLab(testRegistry)
+	source := gl.AddGroup("team")
+	gl.AddProject("team/backend/api")
+	target := gl.AddGroup("platform")
+	gm := NewGroupMigrator(gl, false, newTestUI(nil))
+
+	if err := gm.TransferGroup(t.Context(), source.ID, int(target.ID)); err != nil {
+		t.Fatalf("TransferGroup failed: %v", err)
+	}
+	if gl.Group("platform/team/backend") == nil {
+		t.Error("Expected sub-group to be moved to platform/team/backend")
+	}
+	if gl.Project("platform/team/backend/api") == nil {
+		t.Error("Expected project to be moved to platform/team/backend/api")
+	}
+	if gl.Group("team") != nil {
+		t.Error("Expected group team to be gone")
+	}
+}
+
+func TestTransferGroup_BlockedByRegistryTags(t *testing.T) {
+	gl := fake.NewGitLab(testRegistry)
+	source := gl.AddGroup("team")
+	gl.AddProject("team/backend/api")
+	addImages(t, gl, 10, testRegistry+"/team/backend/api:1.0")
+	target := gl.AddGroup("platform")
+	gm := NewGroupMigrator(gl, false, newTestUI(nil))
+
+	if err := gm.TransferGroup(t.Context(), source.ID, int(target.ID)); err == nil {
+		t.Fatal("Expected transfer to fail while registry tags are present")
+	}
+	if gl.Group("team") == nil {
+		t.Error("Expected group team to stay in place")
+	}
+}
+
+func TestTransferGroup_DryRun(t *testing.T) {
+	gl := fake.NewGitLab(testRegistry)
+	source := gl.AddGroup("team")
+	target := gl.AddGroup("platform")
+	gm := NewGroupMigrator(gl, true, newTestUI(nil))
+
+	if err := gm.TransferGroup(t.Context(), source.ID, int(target.ID)); err != nil {
+		t.Fatalf("TransferGroup failed: %v", err)
+	}
+	if gl.Group("team") == nil || gl.Group("platform/team") != nil {
+		t.Error("Expected nothing to move in dry run")
+	}
+}
+
+func TestCreateGroupFrom_CopiesSettings(t *testing.T) {
+	gl := fake.NewGitLab(testRegistry)
+	visibility := gitlabCore.InternalVisibility
+	description := "Backend services"
+	name := "Backend"
+	path := "backend"
+	source, _, err := gl.CreateGroupWithOptions(t.Context(), &gitlabCore.CreateGroupOptions{
+		Name:        &name,
+		Path:        &path,
+		Description: &description,
+		Visibility:  &visibility,
+	})
+	if err != nil {
+		t.Fatalf("CreateGroupWithOptions failed: %v", err)
+	}
+	if _, err := gl.CreateGroupLabel(t.Context(), int(source.ID), "bug", "#ff0000", "Something is broken"); err != nil {
+		t.Fatalf("CreateGroupLabel failed: %v", err)
+	}
+	parent := gl.AddGroup("platform")
+	gm := NewGroupMigrator(gl, false, newTestUI(nil))
+
+	created, err := gm.CreateGroupFrom(t.Context(), source, parent)
+	if err != nil {
+		t.Fatalf("CreateGroupFrom failed: %v", err)
+	}
+	if created.FullPath != "platform/backend" || created.Name != "Backend" {
+		t.Errorf("Expected group Backend at platform/backend, got %s at %s", created.Name, created.FullPath)
+	}
+	if created.Visibility != gitlabCore.InternalVisibility {
+		t.Errorf("Expected visibility internal, got %s", created.Visibility)
+	}
+	if created.Description != description {
+		t.Errorf("Expected description %q, got %q", description, created.Description)
+	}
+	labels, _ := gl.ListGroupLabels(t.Context(), int(created.ID))
+	if len(labels) != 1 || labels[0].Name != "bug" || labels[0].Color != "#ff0000" {
+		t.Errorf("Expected label bug to be copied, got %v", labels)
+	}
+}
+
+func TestCreateGroupFrom_Template(t *testing.T) {
+	gl := fake.NewGitLab(testRegistry)
+	source := gl.AddGroup("team/backend")
+	parent := gl.AddGroup("platform")
+	gm := NewGroupMigrator(gl, false, newTestUI(nil))
+	gm.SetGroupTemplate(&config.GroupTemplate{
+		Visibility:           "public",
+		SharedRunnersSetting: "disabled_and_unoverridable",
+		Labels:               []config.LabelTemplate{{Name: "migrated", Color: "#00ff00"}},
+	})
+
+	created, err := gm.CreateGroupFrom(t.Context(), source, parent)
+	if err != nil {
+		t.Fatalf("CreateGroupFrom failed: %v", err)
+	}
+
+	group := gl.Group(created.FullPath)
+	if group.Visibility != gitlabCore.PublicVisibility {
+		t.Errorf("Expected visibility public, got %s", group.Visibility)
+	}
+	if group.SharedRunnersSetting != gitlabCore.DisabledAndUnoverridableSharedRunnersSettingValue {
+		t.Errorf("Expected shared runners to be disabled, got %s", group.SharedRunnersSetting)
+	}
+	labels, _ := gl.ListGroupLabels(t.Context(), int(created.ID))
+	if len(labels) != 1 || labels[0].Name != "migrated" {
+		t.Errorf("Expected template label, got %v", labels)
+	}
+}
+
+func TestMirrorNamespace(t *testing.T) {
+	gl := fake.NewGitLab(testRegistry)
+	source := gl.AddGroup("team")
+	gl.AddProject("team/backend/db/postgres")
+	destRoot := gl.AddGroup("platform/team")
+	// Existing destination groups are reused
+	existing := gl.AddGroup("platform/team/backend")
+	gm := NewGroupMigrator(gl, false, newTestUI(nil))
+
+	subGroups, _, err := gm.GetSubGroupsAndProjects(t.Context(), source.ID, nil)
+	if err != nil {
+		t.Fatalf("GetSubGroupsAndProjects failed: %v", err)
+	}
+
+	mirrored := make(map[string]*gitlabCore.Group)
+	target, err := gm.MirrorNamespace(t.Context(), source, destRoot, "team/backend/db", subGroups, mirrored)
+	if err != nil {
+		t.Fatalf("MirrorNamespace failed: %v", err)
+	}
+	if target.FullPath != "platform/team/backend/db" {
+		t.Errorf("Expected target platform/team/backend/db, got %s", target.FullPath)
+	}
+	if gl.Group("platform/team/backend/db") == nil {
+		t.Error("Expected group platform/team/backend/db to be created")
+	}
+	if mirrored["team/backend"].ID != existing.ID {
+		t.Errorf("Expected existing group %d to be reused, got %d", existing.ID, mirrored["team/backend"].ID)
+	}
+
+	root, err := gm.MirrorNamespace(t.Context(), source, destRoot, "team", subGroups, mirrored)
+	if err != nil || root.ID != destRoot.ID {
+		t.Errorf("Expected root namespace to map to destination root, got %v (%v)", root, err)
+	}
+
+	if _, err := gm.MirrorNamespace(t.Context(), source, destRoot, "other/backend", subGroups, mirrored); err == nil {
+		t.Error("Expected an error for a namespace outside of the source group")
+	}
+	// A sibling group sharing the path prefix of the source group is not below it
+	gl.AddGroup("team2/backend")
+	if _, err := gm.MirrorNamespace(t.Context(), source, destRoot, "team2/backend", subGroups, mirrored); err == nil || !strings.Contains(err.Error(), "is not below group team") {
+		t.Errorf("Expected team2/backend not to be below team, got %v", err)
+	}
+}
+
+func TestMirrorNamespace_DryRun(t *testing.T) {
+	gl := fake.NewGitLab(testRegistry)
+	source := gl.AddGroup("team")
+	gl.AddProject("team/backend/db/postgres")
+	destRoot := gl.AddGroup("platform/team")
+	gm := NewGroupMigrator(gl, true, newTestUI(nil))
+
+	subGroups, _, err := gm.GetSubGroupsAndProjects(t.Context(), source.ID, nil)
+	if err != nil {
+		t.Fatalf("GetSubGroupsAndProjects failed: %v", err)
+	}
+
+	target, err := gm.MirrorNamespace(t.Context(), source, destRoot, "team/backend/db", subGroups, make(map[string]*gitlabCore.Group))
+	if err != nil {
+		t.Fatalf("MirrorNamespace failed: %v", err)
+	}
+	if target.FullPath != "platform/team/backend/db" {
+		t.Errorf("Expected planned target platform/team/backend/db, got %s", target.FullPath)
+	}
+	if gl.Group("platform/team/backend") != nil {
+		t.Error("Expected no group to be created in dry run")
+	}
+}
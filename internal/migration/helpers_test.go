@@ -0,0 +1,66 @@
+package migration
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"migraptor/internal/fake"
+	"migraptor/internal/oci"
+	"migraptor/internal/ui"
+
+	gitlabCore "gitlab.com/gitlab-org/api/client-go"
+)
+
+const testRegistry = "registry.example.com"
+
+var (
+	_ GroupClient     = (*fake.GitLab)(nil)
+	_ ProjectClient   = (*fake.GitLab)(nil)
+	_ RegistryClient  = (*fake.GitLab)(nil)
+	_ MemberClient    = (*fake.GitLab)(nil)
+	_ ContainerEngine = (*fake.Engine)(nil)
+	_ ImageArchive    = (*oci.Archive)(nil)
+)
+
+// newTestUI creates a UI discarding its log and counting sleeps instead of waiting
+func newTestUI(sleeps *int) *ui.UI {
+	consoleUI := ui.New(false, io.Discard)
+	consoleUI.SetSleep(func(time.Duration) {
+		if sleeps != nil {
+			*sleeps++
+		}
+	})
+	return consoleUI
+}
+
+// addImages pushes images of the given size to the registry of the fake GitLab
+func addImages(t *testing.T, gl *fake.GitLab, size int64, imageRefs ...string) {
+	t.Helper()
+	for _, imageRef := range imageRefs {
+		if err := gl.AddImage(imageRef, size); err != nil {
+			t.Fatalf("AddImage(%s) failed: %v", imageRef, err)
+		}
+	}
+}
+
+// selectProjects returns a selection of the projects with the given paths
+func selectProjects(t *testing.T, paths ...string) *Selection {
+	t.Helper()
+	selection, err := NewSelection(SelectionRules{Projects: paths})
+	if err != nil {
+		t.Fatalf("NewSelection failed: %v", err)
+	}
+	return selection
+}
+
+// projectInfo converts a project of the fake GitLab into a ProjectInfo
+func projectInfo(t *testing.T, gl *fake.GitLab, fullPath string) *ProjectInfo {
+	t.Helper()
+	project := gl.Project(fullPath)
+	if project == nil {
+		t.Fatalf("project %s not found", fullPath)
+	}
+	info := FilterProjects([]*gitlabCore.Project{project}, nil)[0]
+	return &info
+}
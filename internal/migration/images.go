@@ -1,12 +1,14 @@
 package migration
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 	"time"
 
-	"migraptor/internal/docker"
-	"migraptor/internal/gitlab"
 	"migraptor/internal/ui"
 
 	gitlabCore "gitlab.com/gitlab-org/api/client-go"
@@ -21,14 +23,15 @@ type ImageInfo struct {
 
 // ImageMigrator handles Docker image migration operations
 type ImageMigrator struct {
-	gitlabClient *gitlab.Client
-	dockerClient *docker.Client
+	gitlabClient RegistryClient
+	dockerClient ContainerEngine
+	archive      ImageArchive
 	dryRun       bool
 	consoleUI    *ui.UI
 }
 
 // NewImageMigrator creates a new ImageMigrator
-func NewImageMigrator(gitlabClient *gitlab.Client, dockerClient *docker.Client, dryRun bool, cUI *ui.UI) *ImageMigrator {
+func NewImageMigrator(gitlabClient RegistryClient, dockerClient ContainerEngine, dryRun bool, cUI *ui.UI) *ImageMigrator {
 	return &ImageMigrator{
 		gitlabClient: gitlabClient,
 		dockerClient: dockerClient,
@@ -37,9 +40,15 @@ func NewImageMigrator(gitlabClient *gitlab.Client, dockerClient *docker.Client,
 	}
 }
 
+// SetImageArchive sets the archive used for multi-architecture images and images with attached artifacts.
+// All images are pulled and pushed by the container engine if nil.
+func (im *ImageMigrator) SetImageArchive(archive ImageArchive) {
+	im.archive = archive
+}
+
 // GetImages gets all images for a project's registry repository
-func (im *ImageMigrator) GetImages(projectID, repositoryID int, tagFilter []string) ([]ImageInfo, error) {
-	tags, _, err := im.gitlabClient.ListRegistryRepositoryTags(projectID, repositoryID)
+func (im *ImageMigrator) GetImages(ctx context.Context, projectID, repositoryID int, tagFilter []string) ([]ImageInfo, error) {
+	tags, _, err := im.gitlabClient.ListRegistryRepositoryTags(ctx, projectID, repositoryID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list repository tags: %w", err)
 	}
@@ -71,8 +80,8 @@ func (im *ImageMigrator) GetImages(projectID, repositoryID int, tagFilter []stri
 }
 
 // BackupImages backs up all images from a project's registry
-func (im *ImageMigrator) BackupImages(project *ProjectInfo, tagFilter []string) ([]string, []*gitlabCore.RegistryRepository, error) {
-	repositories, _, err := im.gitlabClient.ListRegistryRepositories(project.ID)
+func (im *ImageMigrator) BackupImages(ctx context.Context, project *ProjectInfo, tagFilter []string) ([]string, []*gitlabCore.RegistryRepository, error) {
+	repositories, _, err := im.gitlabClient.ListRegistryRepositories(ctx, project.ID)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to list registry repositories: %w", err)
 	}
@@ -83,18 +92,16 @@ func (im *ImageMigrator) BackupImages(project *ProjectInfo, tagFilter []string)
 	}
 
 	var allImages []string
-	var allRepositoryIDs []int
 
 	for _, repo := range repositories {
 		im.consoleUI.Debug("Found registry with ID %d", repo.ID)
 		im.consoleUI.Debug("Working on repository %d from project %d", repo.ID, project.ID)
 
-		allRepositoryIDs = append(allRepositoryIDs, int(repo.ID))
-
-		images, err := im.GetImages(project.ID, int(repo.ID), tagFilter)
+		// The repository is deleted afterwards, its images cannot be skipped
+		images, err := im.GetImages(ctx, project.ID, int(repo.ID), tagFilter)
 		if err != nil {
 			im.consoleUI.Error("Error occurred during image search on project %d - repository %d: %v", project.ID, repo.ID, err)
-			continue
+			return nil, nil, fmt.Errorf("failed to list tags of repository %s: %w", repo.Path, err)
 		}
 
 		if len(images) == 0 {
@@ -113,14 +120,40 @@ func (im *ImageMigrator) BackupImages(project *ProjectInfo, tagFilter []string)
 		im.consoleUI.PrintPullingImages()
 		for _, img := range images {
 			imageRef := img.Location
-			if im.dryRun {
+			archived, err := im.needsArchive(ctx, imageRef)
+			if err != nil {
+				im.consoleUI.Error("Failed to inspect image %s in registry: %v", imageRef, err)
+				return nil, nil, fmt.Errorf("failed to inspect image %s in registry: %w", imageRef, err)
+			}
+
+			if archived {
+				if im.dryRun {
+					im.consoleUI.Info("🌵DRY RUN: Would save image %s with all its platforms and attached artifacts", imageRef)
+				} else {
+					im.consoleUI.Info("📦 Saving image %s with all its platforms and attached artifacts...", imageRef)
+					if err := im.archive.Save(ctx, imageRef); err != nil {
+						im.consoleUI.Error("Failed to save image %s: %v", imageRef, err)
+						return nil, nil, fmt.Errorf("failed to save image %s: %w", imageRef, err)
+					}
+				}
+			} else if im.dryRun {
 				im.consoleUI.Info("🌵DRY RUN: Would pull image %s", imageRef)
 			} else {
 				im.consoleUI.Info("🔌 Pulling image %s...", imageRef)
-				if err := im.dockerClient.PullImage(imageRef); err != nil {
+				if err := im.dockerClient.PullImage(ctx, imageRef); err != nil {
 					im.consoleUI.Error("Failed to pull image %s: %v", imageRef, err)
 					return nil, nil, fmt.Errorf("failed to pull image %s: %w", imageRef, err)
 				}
+				// Registries are deleted afterwards, make sure the engine really holds the image
+				exists, err := im.dockerClient.ImageExists(ctx, imageRef)
+				if err != nil {
+					im.consoleUI.Error("Failed to inspect image %s: %v", imageRef, err)
+					return nil, nil, fmt.Errorf("failed to inspect image %s: %w", imageRef, err)
+				}
+				if !exists {
+					im.consoleUI.Error("Image %s not found locally after pull", imageRef)
+					return nil, nil, fmt.Errorf("image %s not found locally after pull", imageRef)
+				}
 			}
 			allImages = append(allImages, imageRef)
 		}
@@ -128,14 +161,26 @@ func (im *ImageMigrator) BackupImages(project *ProjectInfo, tagFilter []string)
 	return allImages, repositories, nil
 }
 
-func (im *ImageMigrator) DeleteRegistries(project *ProjectInfo, repositories []*gitlabCore.RegistryRepository) error {
+// needsArchive returns true if the image must be copied at registry level rather than by the container engine
+func (im *ImageMigrator) needsArchive(ctx context.Context, imageRef string) (bool, error) {
+	if im.archive == nil {
+		return false, nil
+	}
+	return im.archive.NeedsArchive(ctx, imageRef)
+}
+
+// DeleteRegistries deletes the registry repositories of a project. It returns the errors of the repositories
+// which could not be deleted, once the deletion of the others is requested.
+func (im *ImageMigrator) DeleteRegistries(ctx context.Context, project *ProjectInfo, repositories []*gitlabCore.RegistryRepository) error {
+	var errs []error
 	for _, repo := range repositories {
 		if im.dryRun {
 			im.consoleUI.Info("🌵 DRY RUN: Would delete registry repository %d", repo.ID)
 		} else {
-			_, err := im.gitlabClient.DeleteRegistryRepository(project.ID, int(repo.ID))
+			_, err := im.gitlabClient.DeleteRegistryRepository(ctx, project.ID, int(repo.ID))
 			if err != nil {
 				im.consoleUI.Error("Failed to delete registry repository %d: %v", repo.ID, err)
+				errs = append(errs, fmt.Errorf("failed to delete registry repository %d: %w", repo.ID, err))
 			} else {
 				im.consoleUI.Debug("Removed registry %d on project %d", repo.ID, project.ID)
 			}
@@ -144,10 +189,10 @@ func (im *ImageMigrator) DeleteRegistries(project *ProjectInfo, repositories []*
 			im.consoleUI.SleepWithLog(10 * time.Second)
 		}
 	}
-	return nil
+	return errors.Join(errs...)
 }
 
-func (im *ImageMigrator) CheckIfRemainingImages(projects map[int]*ProjectInfo, tagFilter []string) error {
+func (im *ImageMigrator) CheckIfRemainingImages(ctx context.Context, projects map[int]*ProjectInfo, tagFilter []string) error {
 	// Wait for images to be deleted from registry if there is any temporization
 	im.consoleUI.Info("🔄 Waiting for images to be deleted from registry...")
 	// Wait until images are deleted from registry before proceeding
@@ -164,7 +209,7 @@ func (im *ImageMigrator) CheckIfRemainingImages(projects map[int]*ProjectInfo, t
 
 			// Wait until all images for this project's repositories are deleted or timeout
 			for {
-				repositories, _, _ := im.gitlabClient.ListRegistryRepositories(project.ID)
+				repositories, _, _ := im.gitlabClient.ListRegistryRepositories(ctx, project.ID)
 				if len(repositories) == 0 {
 					im.consoleUI.Info("🚮 All registries deleted for project %s", project.Path)
 					break
@@ -183,10 +228,13 @@ func (im *ImageMigrator) CheckIfRemainingImages(projects map[int]*ProjectInfo, t
 	return nil
 }
 
-// RestoreImages restores images to the new registry location
-func (im *ImageMigrator) RestoreImages(imageList []string, oldFullPath, newGroupPath string, keepParent bool) error {
+// RestoreImages restores images to the new registry location.
+// It returns the new location of each image pushed (or to be pushed in dry run), keyed by backed up image,
+// and an error listing the images which could not be pushed.
+func (im *ImageMigrator) RestoreImages(ctx context.Context, imageList []string, oldFullPath, newGroupPath string, keepParent bool) (map[string]string, error) {
+	restored := make(map[string]string)
 	if len(imageList) == 0 {
-		return nil
+		return restored, nil
 	}
 
 	im.consoleUI.PrintTaggingAndPushing()
@@ -210,30 +258,125 @@ func (im *ImageMigrator) RestoreImages(imageList []string, oldFullPath, newGroup
 		im.consoleUI.Debug("new_image is %s based on %s and %s", newImage, oldFullPath, newGroupPath)
 		im.consoleUI.PrintTagAndPush(newImage)
 
+		if im.archive != nil && im.archive.Contains(img) {
+			if im.dryRun {
+				im.consoleUI.Info("🌵DRY RUN: Would push saved image %s as %s", img, newImage)
+			} else {
+				im.consoleUI.Info("🔌 Pushing saved image %s with all its platforms and attached artifacts...", newImage)
+				if err := im.archive.Restore(ctx, img, newImage); err != nil {
+					im.consoleUI.Error("Failed to push image %s: %v", newImage, err)
+					continue
+				}
+			}
+			restored[img] = newImage
+			continue
+		}
+
 		if im.dryRun {
 			im.consoleUI.Info("🌵DRY RUN: Would tag %s as %s", img, newImage)
 			im.consoleUI.Info("🌵DRY RUN: Would push %s", newImage)
 		} else {
 			// Tag the image
-			if err := im.dockerClient.TagImage(img, newImage); err != nil {
+			if err := im.dockerClient.TagImage(ctx, img, newImage); err != nil {
 				im.consoleUI.Error("Failed to tag image %s as %s: %v", img, newImage, err)
 				continue
 			}
 
 			// Push the image
 			im.consoleUI.Info("🔌 Pushing image %s...", newImage)
-			if err := im.dockerClient.PushImage(newImage); err != nil {
+			if err := im.dockerClient.PushImage(ctx, newImage); err != nil {
 				im.consoleUI.Error("Failed to push image %s: %v", newImage, err)
 				continue
 			}
 		}
+		restored[img] = newImage
+	}
+
+	if len(restored) < len(imageList) {
+		var failed []string
+		for _, img := range imageList {
+			if _, ok := restored[strings.Trim(img, `"`)]; !ok {
+				failed = append(failed, img)
+			}
+		}
+		return restored, fmt.Errorf("%d of %d images were not pushed: %s", len(failed), len(imageList), strings.Join(failed, ", "))
+	}
+	return restored, nil
+}
+
+// RemoveLocalImages removes the local copies of the images of a project, backed up and re-tagged, once all of them
+// are pushed and listed in the registry of the project. If an image was not restored, every copy is kept as backup
+// and an error tells why.
+func (im *ImageMigrator) RemoveLocalImages(ctx context.Context, project *ProjectInfo, imageList []string, restored map[string]string) error {
+	if len(imageList) == 0 {
+		return nil
+	}
+	if failed := len(imageList) - len(restored); failed > 0 {
+		return fmt.Errorf("%d of %d images were not pushed", failed, len(imageList))
 	}
 
+	sources := slices.Sorted(maps.Keys(restored))
+	if im.dryRun {
+		for _, source := range sources {
+			im.consoleUI.Info("🌵 DRY RUN: Would remove local images %s and %s once their push is verified", source, restored[source])
+		}
+		return nil
+	}
+
+	if err := im.verifyPushed(ctx, project.ID, slices.Collect(maps.Values(restored))); err != nil {
+		return fmt.Errorf("pushed images cannot be verified: %w", err)
+	}
+
+	removed := 0
+	for _, source := range sources {
+		if im.archive != nil && im.archive.Contains(source) {
+			if err := im.archive.Remove(source); err != nil {
+				im.consoleUI.Warning("Failed to remove saved image %s: %v", source, err)
+				continue
+			}
+			removed++
+			continue
+		}
+
+		for _, imageRef := range []string{restored[source], source} {
+			if err := im.dockerClient.RemoveImage(ctx, imageRef); err != nil {
+				im.consoleUI.Warning("Failed to remove local image %s: %v", imageRef, err)
+			}
+		}
+		removed++
+	}
+	im.consoleUI.Info("🧹 Removed local copies of %d images of project %s", removed, project.Path)
+	return nil
+}
+
+// verifyPushed checks that every image is listed in the registry of the project
+func (im *ImageMigrator) verifyPushed(ctx context.Context, projectID int, images []string) error {
+	repositories, _, err := im.gitlabClient.ListRegistryRepositories(ctx, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to list registry repositories: %w", err)
+	}
+
+	listed := make(map[string]bool)
+	for _, repo := range repositories {
+		tags, _, err := im.gitlabClient.ListRegistryRepositoryTags(ctx, projectID, int(repo.ID))
+		if err != nil {
+			return fmt.Errorf("failed to list tags of repository %s: %w", repo.Path, err)
+		}
+		for _, tag := range tags {
+			listed[tag.Location] = true
+		}
+	}
+
+	for _, image := range images {
+		if !listed[image] {
+			return fmt.Errorf("image %s is not listed in the registry", image)
+		}
+	}
 	return nil
 }
 
 // GetAllImagesFromProjects collects all images from all projects and registries
-func (im *ImageMigrator) GetAllImagesFromProjects(projects map[int]*ProjectInfo, tagFilter []string) ([]*ui.ImageItem, error) {
+func (im *ImageMigrator) GetAllImagesFromProjects(ctx context.Context, projects map[int]*ProjectInfo, tagFilter []string) ([]*ui.ImageItem, error) {
 	var allImages []*ui.ImageItem
 
 	for _, project := range projects {
@@ -241,7 +384,7 @@ func (im *ImageMigrator) GetAllImagesFromProjects(projects map[int]*ProjectInfo,
 			continue
 		}
 
-		repositories, _, err := im.gitlabClient.ListRegistryRepositories(project.ID)
+		repositories, _, err := im.gitlabClient.ListRegistryRepositories(ctx, project.ID)
 		if err != nil {
 			im.consoleUI.Debug("Failed to list registry repositories for project %d: %v", project.ID, err)
 			continue
@@ -252,7 +395,7 @@ func (im *ImageMigrator) GetAllImagesFromProjects(projects map[int]*ProjectInfo,
 		}
 
 		for _, repo := range repositories {
-			images, err := im.GetImages(project.ID, int(repo.ID), tagFilter)
+			images, err := im.GetImages(ctx, project.ID, int(repo.ID), tagFilter)
 			if err != nil {
 				im.consoleUI.Debug("Error occurred during image search on project %d - repository %d: %v", project.ID, repo.ID, err)
 				continue
@@ -277,3 +420,161 @@ func (im *ImageMigrator) GetAllImagesFromProjects(projects map[int]*ProjectInfo,
 
 	return allImages, nil
 }
+
+// BackupEstimate holds the disk space needed to pull the images of a backup
+type BackupEstimate struct {
+	Tags         int
+	TotalSize    int64
+	LocalSize    int64
+	RequiredSize int64
+}
+
+// EstimateBackupSize sums the size of the tags to pull from the registries of the projects, counting each layer
+// once and subtracting the layers already present locally. When the layers of an image are not known, such as
+// for multi-architecture images or without image archive, the whole image is counted once per digest and
+// subtracted if an image with that digest is present locally.
+func (im *ImageMigrator) EstimateBackupSize(ctx context.Context, projects []*ProjectInfo, tagFilter []string) (*BackupEstimate, error) {
+	localDigests, err := im.dockerClient.LocalImageDigests(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list local images: %w", err)
+	}
+	localLayers, err := im.dockerClient.LocalLayers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list local layers: %w", err)
+	}
+
+	estimate := &BackupEstimate{}
+	seen := make(map[string]bool)
+	seenLayers := make(map[string]bool)
+	for _, project := range projects {
+		if !project.ContainerRegistryEnabled {
+			continue
+		}
+
+		repositories, _, err := im.gitlabClient.ListRegistryRepositories(ctx, project.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list registry repositories of project %s: %w", project.Path, err)
+		}
+
+		for _, repo := range repositories {
+			images, err := im.GetImages(ctx, project.ID, int(repo.ID), tagFilter)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list tags of repository %s: %w", repo.Path, err)
+			}
+
+			for _, img := range images {
+				tag, err := im.gitlabClient.GetRegistryRepositoryTagDetail(ctx, project.ID, int(repo.ID), img.Name)
+				if err != nil {
+					im.consoleUI.Debug("Cannot get size of %s: %v", img.Location, err)
+					continue
+				}
+				estimate.Tags++
+
+				if layers := im.imageLayers(ctx, img.Location); layers != nil {
+					for layer, size := range layers {
+						if seenLayers[layer] {
+							continue
+						}
+						seenLayers[layer] = true
+						estimate.TotalSize += size
+						if localLayers[layer] {
+							estimate.LocalSize += size
+						}
+					}
+					continue
+				}
+
+				if tag.Digest != "" {
+					if seen[tag.Digest] {
+						continue
+					}
+					seen[tag.Digest] = true
+				}
+
+				estimate.TotalSize += tag.TotalSize
+				if _, ok := localDigests[tag.Digest]; ok {
+					estimate.LocalSize += tag.TotalSize
+				}
+			}
+		}
+	}
+
+	estimate.RequiredSize = estimate.TotalSize - estimate.LocalSize
+	return estimate, nil
+}
+
+// imageLayers returns the layers of an image in the registry, nil if they are not known
+func (im *ImageMigrator) imageLayers(ctx context.Context, imageRef string) map[string]int64 {
+	if im.archive == nil {
+		return nil
+	}
+	layers, err := im.archive.ImageLayers(ctx, imageRef)
+	if err != nil {
+		im.consoleUI.Debug("Cannot get layers of %s, counting the whole image: %v", imageRef, err)
+		return nil
+	}
+	return layers
+}
+
+// CheckDiskSpace estimates the space needed to back up the images of the projects and compares it
+// with the free space on the Docker data root. It returns an error if there is not enough space.
+func (im *ImageMigrator) CheckDiskSpace(ctx context.Context, projects []*ProjectInfo, tagFilter []string) error {
+	im.consoleUI.Info("💽 Estimating disk space needed to backup images...")
+
+	estimate, err := im.EstimateBackupSize(ctx, projects, tagFilter)
+	if err != nil {
+		return fmt.Errorf("failed to estimate backup size: %w", err)
+	}
+	if estimate.Tags == 0 {
+		return nil
+	}
+
+	im.consoleUI.PrintBackupEstimate(estimate.Tags, estimate.TotalSize, estimate.LocalSize, estimate.RequiredSize)
+
+	free, dataRoot, err := im.dockerClient.AvailableSpace(ctx)
+	if err != nil {
+		im.consoleUI.Warning("Cannot check free disk space, make sure %s are available: %v", ui.FormatBytes(estimate.RequiredSize), err)
+		return nil
+	}
+
+	im.consoleUI.Info("💽 Free space on %s: %s", dataRoot, ui.FormatBytes(free))
+	if estimate.RequiredSize > free {
+		return fmt.Errorf("not enough disk space on %s: %s needed, %s available", dataRoot, ui.FormatBytes(estimate.RequiredSize), ui.FormatBytes(free))
+	}
+	// Registry sizes are compressed, extracted layers take more space on disk
+	if estimate.RequiredSize > free/2 {
+		im.consoleUI.Warning("Local images take more space than their compressed registry size, %s to download may not fit in %s available", ui.FormatBytes(estimate.RequiredSize), ui.FormatBytes(free))
+	}
+	return nil
+}
+
+// DeleteImages deletes the selected image tags from their registry repository.
+// It returns the number of deleted (or to be deleted in dry run) and failed images.
+func (im *ImageMigrator) DeleteImages(ctx context.Context, images []ui.ImageItem) (int, int) {
+	deletedCount := 0
+	failedCount := 0
+	totalImages := len(images)
+
+	for i, img := range images {
+		imageNum := i + 1
+		if im.dryRun {
+			im.consoleUI.Info("🌵 DRY RUN: Would delete image %d of %d: %s (Project: %s, Registry: %s)",
+				imageNum, totalImages, img.ImageInfo.Name, img.ProjectName, img.RegistryPath)
+			deletedCount++
+			continue
+		}
+
+		im.consoleUI.Info("🗑️  Deleting image %d of %d: %s (Project: %s, Registry: %s)",
+			imageNum, totalImages, img.ImageInfo.Name, img.ProjectName, img.RegistryPath)
+
+		_, err := im.gitlabClient.DeleteRegistryRepositoryTag(ctx, img.ProjectID, img.RegistryID, img.ImageInfo.Name)
+		if err != nil {
+			im.consoleUI.Error("Failed to delete image %s: %v", img.ImageInfo.Name, err)
+			failedCount++
+		} else {
+			deletedCount++
+		}
+	}
+
+	return deletedCount, failedCount
+}
@@ -0,0 +1,426 @@
+package migration
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"migraptor/internal/fake"
+	"migraptor/internal/ui"
+
+	gitlabCore "gitlab.com/gitlab-org/api/client-go"
+)
+
+func TestBackupImages(t *testing.T) {
+	gl := fake.NewGitLab(testRegistry)
+	gl.AddProject("team/app")
+	addImages(t, gl, 10,
+		testRegistry+"/team/app:1.0",
+		testRegistry+"/team/app:2.0",
+		testRegistry+"/team/app/worker:1.0",
+	)
+	engine := fake.NewEngine(gl)
+	im := NewImageMigrator(gl, engine, false, newTestUI(nil))
+
+	images, repositories, err := im.BackupImages(t.Context(), projectInfo(t, gl, "team/app"), []string{"1.0"})
+	if err != nil {
+		t.Fatalf("BackupImages failed: %v", err)
+	}
+	if len(repositories) != 2 {
+		t.Errorf("Expected 2 repositories, got %d", len(repositories))
+	}
+	expected := []string{testRegistry + "/team/app/worker:1.0", testRegistry + "/team/app:1.0"}
+	slices.Sort(images)
+	if !slices.Equal(images, expected) {
+		t.Errorf("Expected images %v, got %v", expected, images)
+	}
+	if !slices.Equal(engine.Images(), expected) {
+		t.Errorf("Expected local images %v, got %v", expected, engine.Images())
+	}
+}
+
+func TestBackupImages_DryRun(t *testing.T) {
+	gl := fake.NewGitLab(testRegistry)
+	gl.AddProject("team/app")
+	addImages(t, gl, 10, testRegistry+"/team/app:1.0")
+	engine := fake.NewEngine(gl)
+	im := NewImageMigrator(gl, engine, true, newTestUI(nil))
+
+	images, _, err := im.BackupImages(t.Context(), projectInfo(t, gl, "team/app"), nil)
+	if err != nil {
+		t.Fatalf("BackupImages failed: %v", err)
+	}
+	if len(images) != 1 {
+		t.Errorf("Expected 1 image listed, got %d", len(images))
+	}
+	if len(engine.Pulls) != 0 {
+		t.Errorf("Expected no pull in dry run, got %v", engine.Pulls)
+	}
+}
+
+func TestDeleteRegistriesAndWait(t *testing.T) {
+	gl := fake.NewGitLab(testRegistry)
+	gl.RegistryDeletionDelay = 2
+	gl.AddProject("team/app")
+	addImages(t, gl, 10, testRegistry+"/team/app:1.0")
+	sleeps := 0
+	im := NewImageMigrator(gl, fake.NewEngine(gl), false, newTestUI(&sleeps))
+
+	project := projectInfo(t, gl, "team/app")
+	_, repositories, err := im.BackupImages(t.Context(), project, nil)
+	if err != nil {
+		t.Fatalf("BackupImages failed: %v", err)
+	}
+	if err := im.DeleteRegistries(t.Context(), project, repositories); err != nil {
+		t.Fatalf("DeleteRegistries failed: %v", err)
+	}
+
+	// Deleted repositories remain listed for a while
+	if err := im.CheckIfRemainingImages(t.Context(), map[int]*ProjectInfo{project.ID: project}, nil); err != nil {
+		t.Fatalf("CheckIfRemainingImages failed: %v", err)
+	}
+	// One sleep after deletion, then one per listing still showing the repository
+	if sleeps != 3 {
+		t.Errorf("Expected 3 sleeps, got %d", sleeps)
+	}
+	if images := gl.Images("team/app"); len(images) != 0 {
+		t.Errorf("Expected registry to be empty, got %v", images)
+	}
+}
+
+func TestDeleteRegistries_Error(t *testing.T) {
+	gl := fake.NewGitLab(testRegistry)
+	gl.AddProject("team/app")
+	addImages(t, gl, 10, testRegistry+"/team/app:1.0")
+	im := NewImageMigrator(gl, fake.NewEngine(gl), false, newTestUI(nil))
+
+	project := projectInfo(t, gl, "team/app")
+	_, repositories, err := im.BackupImages(t.Context(), project, nil)
+	if err != nil {
+		t.Fatalf("BackupImages failed: %v", err)
+	}
+	// The repository of another project cannot be deleted, the existing one is
+	unknown := &gitlabCore.RegistryRepository{ID: 999}
+	if err := im.DeleteRegistries(t.Context(), project, append([]*gitlabCore.RegistryRepository{unknown}, repositories...)); err == nil {
+		t.Error("Expected error when a registry repository cannot be deleted")
+	}
+	if images := gl.Images("team/app"); len(images) != 0 {
+		t.Errorf("Expected registry to be empty, got %v", images)
+	}
+}
+
+func TestRestoreImages(t *testing.T) {
+	gl := fake.NewGitLab(testRegistry)
+	gl.AddProject("platform/app")
+	engine := fake.NewEngine(gl)
+	im := NewImageMigrator(gl, engine, false, newTestUI(nil))
+
+	// Images pulled before the project was transferred from team to platform
+	gl.AddProject("team/app")
+	addImages(t, gl, 10, testRegistry+"/team/app:1.0", testRegistry+"/team/app/worker:1.0")
+	for _, ref := range []string{testRegistry + "/team/app:1.0", testRegistry + "/team/app/worker:1.0"} {
+		if err := engine.PullImage(t.Context(), ref); err != nil {
+			t.Fatalf("PullImage failed: %v", err)
+		}
+	}
+
+	_, err := im.RestoreImages(t.Context(), []string{`"` + testRegistry + `/team/app:1.0"`, testRegistry + "/team/app/worker:1.0"}, "team", "platform", false)
+	if err != nil {
+		t.Fatalf("RestoreImages failed: %v", err)
+	}
+
+	expected := []string{testRegistry + "/platform/app/worker:1.0", testRegistry + "/platform/app:1.0"}
+	if images := gl.Images("platform/app"); !slices.Equal(images, expected) {
+		t.Errorf("Expected images %v, got %v", expected, images)
+	}
+}
+
+// stubArchive archives the images listed in archived, recording what is saved and restored
+type stubArchive struct {
+	archived map[string]bool
+	layers   map[string]map[string]int64
+	saved    []string
+	restored map[string]string
+	removed  []string
+}
+
+func (a *stubArchive) NeedsArchive(ctx context.Context, imageRef string) (bool, error) {
+	return a.archived[imageRef], nil
+}
+
+func (a *stubArchive) ImageLayers(ctx context.Context, imageRef string) (map[string]int64, error) {
+	return a.layers[imageRef], nil
+}
+
+func (a *stubArchive) Save(ctx context.Context, imageRef string) error {
+	a.saved = append(a.saved, imageRef)
+	return nil
+}
+
+func (a *stubArchive) Contains(imageRef string) bool {
+	return slices.Contains(a.saved, imageRef)
+}
+
+func (a *stubArchive) Restore(ctx context.Context, sourceRef, targetRef string) error {
+	a.restored[sourceRef] = targetRef
+	return nil
+}
+
+func (a *stubArchive) Remove(imageRef string) error {
+	a.removed = append(a.removed, imageRef)
+	a.saved = slices.DeleteFunc(a.saved, func(saved string) bool { return saved == imageRef })
+	return nil
+}
+
+func TestBackupAndRestoreImages_Archive(t *testing.T) {
+	gl := fake.NewGitLab(testRegistry)
+	gl.AddProject("team/app")
+	gl.AddProject("platform/app")
+	single, multiArch := testRegistry+"/team/app:1.0", testRegistry+"/team/app:multi"
+	addImages(t, gl, 10, single, multiArch)
+	engine := fake.NewEngine(gl)
+	archive := &stubArchive{archived: map[string]bool{multiArch: true}, restored: make(map[string]string)}
+	im := NewImageMigrator(gl, engine, false, newTestUI(nil))
+	im.SetImageArchive(archive)
+
+	images, _, err := im.BackupImages(t.Context(), projectInfo(t, gl, "team/app"), nil)
+	if err != nil {
+		t.Fatalf("BackupImages failed: %v", err)
+	}
+	if len(images) != 2 {
+		t.Errorf("Expected 2 images backed up, got %v", images)
+	}
+	if !slices.Equal(engine.Pulls, []string{single}) {
+		t.Errorf("Expected only %s to be pulled, got %v", single, engine.Pulls)
+	}
+	if !slices.Equal(archive.saved, []string{multiArch}) {
+		t.Errorf("Expected only %s to be archived, got %v", multiArch, archive.saved)
+	}
+
+	restored, err := im.RestoreImages(t.Context(), images, "team", "platform", false)
+	if err != nil {
+		t.Fatalf("RestoreImages failed: %v", err)
+	}
+	if len(restored) != 2 {
+		t.Errorf("Expected 2 images restored, got %v", restored)
+	}
+	if !slices.Equal(engine.Pushes, []string{testRegistry + "/platform/app:1.0"}) {
+		t.Errorf("Expected only the single platform image to be pushed by the engine, got %v", engine.Pushes)
+	}
+	if target := archive.restored[multiArch]; target != testRegistry+"/platform/app:multi" {
+		t.Errorf("Expected %s to be restored to %s, got %q", multiArch, testRegistry+"/platform/app:multi", target)
+	}
+}
+
+func TestRemoveLocalImages(t *testing.T) {
+	setup := func(t *testing.T) (*fake.GitLab, *fake.Engine, *stubArchive, *ImageMigrator, []string) {
+		gl := fake.NewGitLab(testRegistry)
+		gl.AddProject("team/app")
+		addImages(t, gl, 10, testRegistry+"/team/app:1.0", testRegistry+"/team/app:multi")
+		engine := fake.NewEngine(gl)
+		archive := &stubArchive{archived: map[string]bool{testRegistry + "/team/app:multi": true}, restored: make(map[string]string)}
+		im := NewImageMigrator(gl, engine, false, newTestUI(nil))
+		im.SetImageArchive(archive)
+
+		images, _, err := im.BackupImages(t.Context(), projectInfo(t, gl, "team/app"), nil)
+		if err != nil {
+			t.Fatalf("BackupImages failed: %v", err)
+		}
+		return gl, engine, archive, im, images
+	}
+
+	t.Run("verified push", func(t *testing.T) {
+		gl, engine, archive, im, images := setup(t)
+		gl.AddProject("platform/app")
+		restored, err := im.RestoreImages(t.Context(), images, "team", "platform", false)
+		if err != nil {
+			t.Fatalf("RestoreImages failed: %v", err)
+		}
+		// The stub archive does not push, the saved image is pushed as the archive would
+		addImages(t, gl, 10, testRegistry+"/platform/app:multi")
+
+		if err := im.RemoveLocalImages(t.Context(), projectInfo(t, gl, "platform/app"), images, restored); err != nil {
+			t.Fatalf("RemoveLocalImages failed: %v", err)
+		}
+		if len(engine.Images()) != 0 {
+			t.Errorf("Expected no local image left, got %v", engine.Images())
+		}
+		if !slices.Equal(archive.removed, []string{testRegistry + "/team/app:multi"}) {
+			t.Errorf("Expected the saved image to be removed from the archive, got %v", archive.removed)
+		}
+	})
+
+	t.Run("failed push", func(t *testing.T) {
+		gl, engine, archive, im, images := setup(t)
+		// The destination project does not exist, the push of the single platform image fails
+		restored, err := im.RestoreImages(t.Context(), images, "team", "platform", false)
+		if err == nil || !strings.Contains(err.Error(), testRegistry+"/team/app:1.0") {
+			t.Errorf("Expected error listing the image not pushed, got %v", err)
+		}
+
+		if err := im.RemoveLocalImages(t.Context(), projectInfo(t, gl, "team/app"), images, restored); err == nil {
+			t.Error("Expected error when a push failed")
+		}
+		if len(engine.Removals) != 0 || len(archive.removed) != 0 {
+			t.Errorf("Expected all local copies to be kept, got %v removed from the engine and %v from the archive", engine.Removals, archive.removed)
+		}
+	})
+
+	t.Run("push not listed", func(t *testing.T) {
+		gl, engine, archive, im, images := setup(t)
+		gl.AddProject("platform/app")
+		restored, err := im.RestoreImages(t.Context(), images, "team", "platform", false)
+		if err != nil {
+			t.Fatalf("RestoreImages failed: %v", err)
+		}
+
+		// The saved image was never pushed by the stub archive
+		if err := im.RemoveLocalImages(t.Context(), projectInfo(t, gl, "platform/app"), images, restored); err == nil {
+			t.Error("Expected error when a pushed image is not listed in the registry")
+		}
+		if len(engine.Removals) != 0 || len(archive.removed) != 0 {
+			t.Errorf("Expected all local copies to be kept, got %v removed from the engine and %v from the archive", engine.Removals, archive.removed)
+		}
+	})
+}
+
+func TestEstimateBackupSize(t *testing.T) {
+	gl := fake.NewGitLab(testRegistry)
+	gl.AddProject("team/app")
+	gl.AddProject("team/api")
+	addImages(t, gl, 100, testRegistry+"/team/app:1.0", testRegistry+"/team/api:1.0")
+	addImages(t, gl, 50, testRegistry+"/team/app:2.0")
+	engine := fake.NewEngine(gl)
+	im := NewImageMigrator(gl, engine, false, newTestUI(nil))
+
+	// Same digest pushed under another tag is counted once
+	if err := engine.PullImage(t.Context(), testRegistry+"/team/app:2.0"); err != nil {
+		t.Fatalf("PullImage failed: %v", err)
+	}
+	if err := engine.PushImage(t.Context(), testRegistry+"/team/app:2.0"); err != nil {
+		t.Fatalf("PushImage failed: %v", err)
+	}
+	if err := engine.TagImage(t.Context(), testRegistry+"/team/app:2.0", testRegistry+"/team/app:latest"); err != nil {
+		t.Fatalf("TagImage failed: %v", err)
+	}
+	if err := engine.PushImage(t.Context(), testRegistry+"/team/app:latest"); err != nil {
+		t.Fatalf("PushImage failed: %v", err)
+	}
+
+	projects := []*ProjectInfo{projectInfo(t, gl, "team/app"), projectInfo(t, gl, "team/api")}
+	estimate, err := im.EstimateBackupSize(t.Context(), projects, nil)
+	if err != nil {
+		t.Fatalf("EstimateBackupSize failed: %v", err)
+	}
+	if estimate.Tags != 4 {
+		t.Errorf("Expected 4 tags, got %d", estimate.Tags)
+	}
+	if estimate.TotalSize != 250 {
+		t.Errorf("Expected total size 250, got %d", estimate.TotalSize)
+	}
+	if estimate.LocalSize != 50 {
+		t.Errorf("Expected local size 50, got %d", estimate.LocalSize)
+	}
+	if estimate.RequiredSize != 200 {
+		t.Errorf("Expected required size 200, got %d", estimate.RequiredSize)
+	}
+}
+
+func TestEstimateBackupSize_Layers(t *testing.T) {
+	gl := fake.NewGitLab(testRegistry)
+	gl.AddProject("team/app")
+	app, api, multiArch := testRegistry+"/team/app:1.0", testRegistry+"/team/app/api:1.0", testRegistry+"/team/app:multi"
+	addImages(t, gl, 100, app, api, multiArch)
+	engine := fake.NewEngine(gl)
+	// The base layer shared by both images is already present locally
+	engine.LoadImage("base:latest", "sha256:base")
+	im := NewImageMigrator(gl, engine, false, newTestUI(nil))
+	im.SetImageArchive(&stubArchive{layers: map[string]map[string]int64{
+		app: {"sha256:base": 40, "sha256:app": 10},
+		api: {"sha256:base": 40, "sha256:api": 20},
+	}})
+
+	estimate, err := im.EstimateBackupSize(t.Context(), []*ProjectInfo{projectInfo(t, gl, "team/app")}, nil)
+	if err != nil {
+		t.Fatalf("EstimateBackupSize failed: %v", err)
+	}
+	if estimate.Tags != 3 {
+		t.Errorf("Expected 3 tags, got %d", estimate.Tags)
+	}
+	// The multi-architecture image, whose layers are not known, is counted whole
+	if estimate.TotalSize != 170 {
+		t.Errorf("Expected total size 170, got %d", estimate.TotalSize)
+	}
+	if estimate.LocalSize != 40 {
+		t.Errorf("Expected local size 40, got %d", estimate.LocalSize)
+	}
+	if estimate.RequiredSize != 130 {
+		t.Errorf("Expected required size 130, got %d", estimate.RequiredSize)
+	}
+}
+
+func TestCheckDiskSpace(t *testing.T) {
+	gl := fake.NewGitLab(testRegistry)
+	gl.AddProject("team/app")
+	addImages(t, gl, 1000, testRegistry+"/team/app:1.0")
+	engine := fake.NewEngine(gl)
+	im := NewImageMigrator(gl, engine, false, newTestUI(nil))
+	projects := []*ProjectInfo{projectInfo(t, gl, "team/app")}
+
+	engine.FreeSpace = 10000
+	if err := im.CheckDiskSpace(t.Context(), projects, nil); err != nil {
+		t.Errorf("Expected enough disk space, got %v", err)
+	}
+
+	engine.FreeSpace = 999
+	if err := im.CheckDiskSpace(t.Context(), projects, nil); err == nil {
+		t.Error("Expected an error when disk space is too low")
+	}
+}
+
+func TestDeleteImages(t *testing.T) {
+	gl := fake.NewGitLab(testRegistry)
+	gl.AddProject("team/app")
+	addImages(t, gl, 10, testRegistry+"/team/app:1.0", testRegistry+"/team/app:2.0")
+	im := NewImageMigrator(gl, fake.NewEngine(gl), false, newTestUI(nil))
+
+	images, err := im.GetAllImagesFromProjects(t.Context(), map[int]*ProjectInfo{0: projectInfo(t, gl, "team/app")}, nil)
+	if err != nil {
+		t.Fatalf("GetAllImagesFromProjects failed: %v", err)
+	}
+	if len(images) != 2 {
+		t.Fatalf("Expected 2 images, got %d", len(images))
+	}
+
+	selected := []ui.ImageItem{*images[0], *images[0]}
+	deleted, failed := im.DeleteImages(t.Context(), selected)
+	// The second deletion of the same tag fails
+	if deleted != 1 || failed != 1 {
+		t.Errorf("Expected 1 deleted and 1 failed, got %d and %d", deleted, failed)
+	}
+	expected := []string{testRegistry + "/team/app:2.0"}
+	if remaining := gl.Images("team/app"); !slices.Equal(remaining, expected) {
+		t.Errorf("Expected remaining images %v, got %v", expected, remaining)
+	}
+}
+
+func TestDeleteImages_DryRun(t *testing.T) {
+	gl := fake.NewGitLab(testRegistry)
+	gl.AddProject("team/app")
+	addImages(t, gl, 10, testRegistry+"/team/app:1.0")
+	im := NewImageMigrator(gl, fake.NewEngine(gl), true, newTestUI(nil))
+
+	images, err := im.GetAllImagesFromProjects(t.Context(), map[int]*ProjectInfo{0: projectInfo(t, gl, "team/app")}, nil)
+	if err != nil {
+		t.Fatalf("GetAllImagesFromProjects failed: %v", err)
+	}
+
+	deleted, failed := im.DeleteImages(t.Context(), []ui.ImageItem{*images[0]})
+	if deleted != 1 || failed != 0 {
+		t.Errorf("Expected 1 planned deletion, got %d deleted and %d failed", deleted, failed)
+	}
+	if remaining := gl.Images("team/app"); len(remaining) != 1 {
+		t.Errorf("Expected image to be kept in dry run, got %v", remaining)
+	}
+}
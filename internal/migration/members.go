@@ -0,0 +1,367 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"migraptor/internal/ui"
+
+	gitlabCore "gitlab.com/gitlab-org/api/client-go"
+)
+
+// MemberInfo holds the effective access of a user on a group or project
+type MemberInfo struct {
+	UserID      int64
+	Username    string
+	AccessLevel gitlabCore.AccessLevelValue
+	ExpiresAt   string
+}
+
+// ShareInfo holds a share link between a group or project and another group
+type ShareInfo struct {
+	GroupID       int64
+	GroupFullPath string
+	AccessLevel   gitlabCore.AccessLevelValue
+	ExpiresAt     string
+}
+
+// MembershipSnapshot holds the memberships of a group or project before the migration
+type MembershipSnapshot struct {
+	Members map[int64]MemberInfo
+	Shares  []ShareInfo
+}
+
+// MemberMigrator handles membership and permission migration operations
+type MemberMigrator struct {
+	client    MemberClient
+	dryRun    bool
+	consoleUI *ui.UI
+	groups    map[string]*MembershipSnapshot
+	projects  map[int]*MembershipSnapshot
+}
+
+// NewMemberMigrator creates a new MemberMigrator
+func NewMemberMigrator(client MemberClient, dryRun bool, cUI *ui.UI) *MemberMigrator {
+	return &MemberMigrator{
+		client:    client,
+		dryRun:    dryRun,
+		consoleUI: cUI,
+		groups:    make(map[string]*MembershipSnapshot),
+		projects:  make(map[int]*MembershipSnapshot),
+	}
+}
+
+// AccessLevelName returns a readable name for an access level
+func AccessLevelName(level gitlabCore.AccessLevelValue) string {
+	for name, value := range accessLevelsByName {
+		if value == level {
+			return name
+		}
+	}
+	switch level {
+	case gitlabCore.MinimalAccessPermissions:
+		return "minimal_access"
+	case gitlabCore.GuestPermissions:
+		return "guest"
+	case gitlabCore.PlannerPermissions:
+		return "planner"
+	case gitlabCore.ReporterPermissions:
+		return "reporter"
+	}
+	return fmt.Sprintf("%d", level)
+}
+
+// SnapshotGroup records the direct and inherited members and the share links of a source group
+func (mm *MemberMigrator) SnapshotGroup(ctx context.Context, group *gitlabCore.Group) error {
+	mm.consoleUI.Debug("Recording memberships of group %s", group.FullPath)
+
+	members, err := mm.client.ListGroupMembers(ctx, int(group.ID), true)
+	if err != nil {
+		return fmt.Errorf("failed to list members of group %s: %w", group.FullPath, err)
+	}
+
+	snapshot := &MembershipSnapshot{Members: make(map[int64]MemberInfo)}
+	for _, member := range members {
+		addMember(snapshot.Members, member.ID, member.Username, member.State, member.AccessLevel, member.ExpiresAt)
+	}
+
+	// Sub-groups listed through the API do not include their share links
+	details, _, err := mm.client.GetGroup(ctx, int(group.ID))
+	if err != nil {
+		return fmt.Errorf("failed to get group %s: %w", group.FullPath, err)
+	}
+	for _, share := range details.SharedWithGroups {
+		snapshot.Shares = append(snapshot.Shares, ShareInfo{
+			GroupID:       share.GroupID,
+			GroupFullPath: share.GroupFullPath,
+			AccessLevel:   gitlabCore.AccessLevelValue(share.GroupAccessLevel),
+			ExpiresAt:     isoTimeString(share.ExpiresAt),
+		})
+	}
+
+	mm.groups[group.FullPath] = snapshot
+	return nil
+}
+
+// SnapshotProject records the direct and inherited members of a source project
+func (mm *MemberMigrator) SnapshotProject(ctx context.Context, project *ProjectInfo) error {
+	mm.consoleUI.Debug("Recording memberships of project %s", project.Path)
+
+	members, err := mm.client.ListProjectMembers(ctx, project.ID, true)
+	if err != nil {
+		return fmt.Errorf("failed to list members of project %s: %w", project.Path, err)
+	}
+
+	snapshot := &MembershipSnapshot{Members: make(map[int64]MemberInfo)}
+	for _, member := range members {
+		addMember(snapshot.Members, member.ID, member.Username, member.State, member.AccessLevel, member.ExpiresAt)
+	}
+
+	mm.projects[project.ID] = snapshot
+	return nil
+}
+
+// SyncGroup adds to the destination group the members and share links of its source counterpart
+// which are missing or have a lower access level. It returns the number of changes (or planned changes in dry run).
+func (mm *MemberMigrator) SyncGroup(ctx context.Context, sourceFullPath string, dest *gitlabCore.Group) (int, error) {
+	snapshot, ok := mm.groups[sourceFullPath]
+	if !ok {
+		return 0, fmt.Errorf("no membership recorded for group %s", sourceFullPath)
+	}
+
+	current := make(map[int64]MemberInfo)
+	direct := make(map[int64]bool)
+	currentShares := make(map[int64]gitlabCore.AccessLevelValue)
+	if dest.ID != 0 {
+		members, err := mm.client.ListGroupMembers(ctx, int(dest.ID), true)
+		if err != nil {
+			return 0, fmt.Errorf("failed to list members of group %s: %w", dest.FullPath, err)
+		}
+		for _, member := range members {
+			addMember(current, member.ID, member.Username, member.State, member.AccessLevel, member.ExpiresAt)
+		}
+		directMembers, err := mm.client.ListGroupMembers(ctx, int(dest.ID), false)
+		if err != nil {
+			return 0, fmt.Errorf("failed to list direct members of group %s: %w", dest.FullPath, err)
+		}
+		for _, member := range directMembers {
+			direct[member.ID] = true
+		}
+		details, _, err := mm.client.GetGroup(ctx, int(dest.ID))
+		if err != nil {
+			return 0, fmt.Errorf("failed to get group %s: %w", dest.FullPath, err)
+		}
+		for _, share := range details.SharedWithGroups {
+			currentShares[share.GroupID] = gitlabCore.AccessLevelValue(share.GroupAccessLevel)
+		}
+	}
+
+	changes := 0
+	for _, member := range missingMembers(snapshot.Members, current) {
+		changes++
+		if mm.dryRun {
+			mm.consoleUI.Info("🌵 DRY RUN: Would add @%s as %s on group %s", member.Username, AccessLevelName(member.AccessLevel), dest.FullPath)
+			continue
+		}
+
+		var err error
+		if direct[member.UserID] {
+			err = mm.client.EditGroupMember(ctx, int(dest.ID), member.UserID, member.AccessLevel, member.ExpiresAt)
+		} else {
+			err = mm.client.AddGroupMember(ctx, int(dest.ID), member.UserID, member.AccessLevel, member.ExpiresAt)
+		}
+		if err != nil {
+			mm.consoleUI.Error("Failed to add @%s on group %s: %v", member.Username, dest.FullPath, err)
+			continue
+		}
+		mm.consoleUI.Info("➕ Added @%s as %s on group %s", member.Username, AccessLevelName(member.AccessLevel), dest.FullPath)
+	}
+
+	for _, share := range snapshot.Shares {
+		if level, ok := currentShares[share.GroupID]; ok && level >= share.AccessLevel {
+			continue
+		}
+		changes++
+		if mm.dryRun {
+			mm.consoleUI.Info("🌵 DRY RUN: Would share group %s with %s as %s", dest.FullPath, share.GroupFullPath, AccessLevelName(share.AccessLevel))
+			continue
+		}
+
+		var expiresAt *gitlabCore.ISOTime
+		if share.ExpiresAt != "" {
+			if parsed, err := time.Parse("2006-01-02", share.ExpiresAt); err == nil {
+				isoTime := gitlabCore.ISOTime(parsed)
+				expiresAt = &isoTime
+			}
+		}
+		if err := mm.client.ShareGroupWithGroup(ctx, int(dest.ID), share.GroupID, share.AccessLevel, expiresAt); err != nil {
+			mm.consoleUI.Error("Failed to share group %s with %s: %v", dest.FullPath, share.GroupFullPath, err)
+			continue
+		}
+		mm.consoleUI.Info("🔗 Shared group %s with %s as %s", dest.FullPath, share.GroupFullPath, AccessLevelName(share.AccessLevel))
+	}
+
+	return changes, nil
+}
+
+// SyncProject adds to a transferred project the members it had before the migration
+// which are missing or have a lower access level in its new namespace.
+// When shareInheritedLinks is true, the share links of the source groups the project inherited
+// are re-created as project share links.
+// It returns the number of changes (or planned changes in dry run).
+func (mm *MemberMigrator) SyncProject(ctx context.Context, project *ProjectInfo, target *gitlabCore.Group, shareInheritedLinks bool) (int, error) {
+	snapshot, ok := mm.projects[project.ID]
+	if !ok {
+		return 0, fmt.Errorf("no membership recorded for project %s", project.Path)
+	}
+
+	current := make(map[int64]MemberInfo)
+	direct := make(map[int64]bool)
+	directMembers, err := mm.client.ListProjectMembers(ctx, project.ID, false)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list direct members of project %s: %w", project.Path, err)
+	}
+	for _, member := range directMembers {
+		direct[member.ID] = true
+	}
+
+	if mm.dryRun {
+		// Predict the memberships after transfer: direct members follow the project,
+		// inherited ones come from the destination group
+		for _, member := range directMembers {
+			addMember(current, member.ID, member.Username, member.State, member.AccessLevel, member.ExpiresAt)
+		}
+		if target != nil && target.ID != 0 {
+			members, err := mm.client.ListGroupMembers(ctx, int(target.ID), true)
+			if err != nil {
+				return 0, fmt.Errorf("failed to list members of group %s: %w", target.FullPath, err)
+			}
+			for _, member := range members {
+				addMember(current, member.ID, member.Username, member.State, member.AccessLevel, member.ExpiresAt)
+			}
+		}
+	} else {
+		members, err := mm.client.ListProjectMembers(ctx, project.ID, true)
+		if err != nil {
+			return 0, fmt.Errorf("failed to list members of project %s: %w", project.Path, err)
+		}
+		for _, member := range members {
+			addMember(current, member.ID, member.Username, member.State, member.AccessLevel, member.ExpiresAt)
+		}
+	}
+
+	changes := 0
+	for _, member := range missingMembers(snapshot.Members, current) {
+		changes++
+		if mm.dryRun {
+			mm.consoleUI.Info("🌵 DRY RUN: Would add @%s as %s on project %s", member.Username, AccessLevelName(member.AccessLevel), project.Path)
+			continue
+		}
+
+		var err error
+		if direct[member.UserID] {
+			err = mm.client.EditProjectMember(ctx, project.ID, member.UserID, member.AccessLevel, member.ExpiresAt)
+		} else {
+			err = mm.client.AddProjectMember(ctx, project.ID, member.UserID, member.AccessLevel, member.ExpiresAt)
+		}
+		if err != nil {
+			mm.consoleUI.Error("Failed to add @%s on project %s: %v", member.Username, project.Path, err)
+			continue
+		}
+		mm.consoleUI.Info("➕ Added @%s as %s on project %s", member.Username, AccessLevelName(member.AccessLevel), project.Path)
+	}
+
+	if !shareInheritedLinks {
+		return changes, nil
+	}
+
+	details, _, err := mm.client.GetProject(ctx, project.ID)
+	if err != nil {
+		return changes, fmt.Errorf("failed to get project %s: %w", project.Path, err)
+	}
+	currentShares := make(map[int64]gitlabCore.AccessLevelValue)
+	for _, share := range details.SharedWithGroups {
+		currentShares[share.GroupID] = gitlabCore.AccessLevelValue(share.GroupAccessLevel)
+	}
+
+	for _, share := range mm.inheritedShares(project.NamespacePath) {
+		if level, ok := currentShares[share.GroupID]; ok && level >= share.AccessLevel {
+			continue
+		}
+		changes++
+		if mm.dryRun {
+			mm.consoleUI.Info("🌵 DRY RUN: Would share project %s with %s as %s", project.Path, share.GroupFullPath, AccessLevelName(share.AccessLevel))
+			continue
+		}
+		if err := mm.client.ShareProjectWithGroup(ctx, project.ID, share.GroupID, share.AccessLevel, share.ExpiresAt); err != nil {
+			mm.consoleUI.Error("Failed to share project %s with %s: %v", project.Path, share.GroupFullPath, err)
+			continue
+		}
+		currentShares[share.GroupID] = share.AccessLevel
+		mm.consoleUI.Info("🔗 Shared project %s with %s as %s", project.Path, share.GroupFullPath, AccessLevelName(share.AccessLevel))
+	}
+
+	return changes, nil
+}
+
+// inheritedShares returns the share links of the recorded groups containing the namespace,
+// keeping the highest access level per shared group
+func (mm *MemberMigrator) inheritedShares(namespace string) []ShareInfo {
+	byGroup := make(map[int64]ShareInfo)
+	for fullPath, snapshot := range mm.groups {
+		if namespace != fullPath && !strings.HasPrefix(namespace, fullPath+"/") {
+			continue
+		}
+		for _, share := range snapshot.Shares {
+			if existing, ok := byGroup[share.GroupID]; !ok || existing.AccessLevel < share.AccessLevel {
+				byGroup[share.GroupID] = share
+			}
+		}
+	}
+
+	shares := make([]ShareInfo, 0, len(byGroup))
+	for _, share := range byGroup {
+		shares = append(shares, share)
+	}
+	sort.Slice(shares, func(i, j int) bool { return shares[i].GroupFullPath < shares[j].GroupFullPath })
+	return shares
+}
+
+// missingMembers returns the expected members which are absent or have a lower access level, sorted by username
+func missingMembers(expected, current map[int64]MemberInfo) []MemberInfo {
+	var missing []MemberInfo
+	for userID, member := range expected {
+		if existing, ok := current[userID]; ok && existing.AccessLevel >= member.AccessLevel {
+			continue
+		}
+		missing = append(missing, member)
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i].Username < missing[j].Username })
+	return missing
+}
+
+// addMember records an active member, keeping its highest access level
+func addMember(members map[int64]MemberInfo, userID int64, username, state string, accessLevel gitlabCore.AccessLevelValue, expiresAt *gitlabCore.ISOTime) {
+	if state != "" && state != "active" {
+		return
+	}
+	if existing, ok := members[userID]; ok && existing.AccessLevel >= accessLevel {
+		return
+	}
+	members[userID] = MemberInfo{
+		UserID:      userID,
+		Username:    username,
+		AccessLevel: accessLevel,
+		ExpiresAt:   isoTimeString(expiresAt),
+	}
+}
+
+func isoTimeString(t *gitlabCore.ISOTime) string {
+	if t == nil {
+		return ""
+	}
+	return t.String()
+}
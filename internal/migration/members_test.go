@@ -0,0 +1,169 @@
+package migration
+
+import (
+	"testing"
+
+	"migraptor/internal/fake"
+
+	gitlabCore "gitlab.com/gitlab-org/api/client-go"
+)
+
+func TestAccessLevelName(t *testing.T) {
+	if name := AccessLevelName(gitlabCore.MaintainerPermissions); name != "maintainer" {
+		t.Errorf("Expected maintainer, got %s", name)
+	}
+	if name := AccessLevelName(gitlabCore.ReporterPermissions); name != "reporter" {
+		t.Errorf("Expected reporter, got %s", name)
+	}
+	if name := AccessLevelName(42); name != "42" {
+		t.Errorf("Expected 42, got %s", name)
+	}
+}
+
+func TestMissingMembers(t *testing.T) {
+	expected := map[int64]MemberInfo{
+		1: {UserID: 1, Username: "bob", AccessLevel: gitlabCore.DeveloperPermissions},
+		2: {UserID: 2, Username: "alice", AccessLevel: gitlabCore.MaintainerPermissions},
+		3: {UserID: 3, Username: "carol", AccessLevel: gitlabCore.ReporterPermissions},
+		4: {UserID: 4, Username: "dave", AccessLevel: gitlabCore.GuestPermissions},
+	}
+	current := map[int64]MemberInfo{
+		1: {UserID: 1, Username: "bob", AccessLevel: gitlabCore.DeveloperPermissions},
+		2: {UserID: 2, Username: "alice", AccessLevel: gitlabCore.DeveloperPermissions},
+		3: {UserID: 3, Username: "carol", AccessLevel: gitlabCore.MaintainerPermissions},
+	}
+
+	missing := missingMembers(expected, current)
+	if len(missing) != 2 || missing[0].Username != "alice" || missing[1].Username != "dave" {
+		t.Fatalf("Expected alice with a lower level and dave absent, got %v", missing)
+	}
+	if missing[0].AccessLevel != gitlabCore.MaintainerPermissions {
+		t.Errorf("Expected alice to keep her source access level, got %s", AccessLevelName(missing[0].AccessLevel))
+	}
+}
+
+func TestAddMember(t *testing.T) {
+	members := make(map[int64]MemberInfo)
+	addMember(members, 1, "alice", "active", gitlabCore.DeveloperPermissions, nil)
+	addMember(members, 1, "alice", "active", gitlabCore.ReporterPermissions, nil)
+	addMember(members, 1, "alice", "", gitlabCore.MaintainerPermissions, nil)
+	addMember(members, 2, "bob", "blocked", gitlabCore.OwnerPermissions, nil)
+
+	if len(members) != 1 {
+		t.Fatalf("Expected blocked members to be skipped, got %v", members)
+	}
+	if members[1].AccessLevel != gitlabCore.MaintainerPermissions {
+		t.Errorf("Expected the highest access level to be kept, got %s", AccessLevelName(members[1].AccessLevel))
+	}
+}
+
+func TestSyncProject(t *testing.T) {
+	gl := fake.NewGitLab(testRegistry)
+	team := gl.AddGroup("team")
+	project := gl.AddProject("team/app")
+	target := gl.AddGroup("platform")
+	alice := gl.AddUser("alice")
+	bob := gl.AddUser("bob")
+	carol := gl.AddUser("carol")
+	if err := gl.AddGroupMember(t.Context(), int(team.ID), alice.ID, gitlabCore.DeveloperPermissions, ""); err != nil {
+		t.Fatalf("AddGroupMember failed: %v", err)
+	}
+	if err := gl.AddGroupMember(t.Context(), int(target.ID), carol.ID, gitlabCore.MaintainerPermissions, ""); err != nil {
+		t.Fatalf("AddGroupMember failed: %v", err)
+	}
+	if err := gl.AddProjectMember(t.Context(), int(project.ID), bob.ID, gitlabCore.ReporterPermissions, ""); err != nil {
+		t.Fatalf("AddProjectMember failed: %v", err)
+	}
+	if err := gl.AddProjectMember(t.Context(), int(project.ID), carol.ID, gitlabCore.GuestPermissions, ""); err != nil {
+		t.Fatalf("AddProjectMember failed: %v", err)
+	}
+
+	info := projectInfo(t, gl, "team/app")
+	dryRun := NewMemberMigrator(gl, true, newTestUI(nil))
+	mm := NewMemberMigrator(gl, false, newTestUI(nil))
+	for _, migrator := range []*MemberMigrator{dryRun, mm} {
+		if err := migrator.SnapshotProject(t.Context(), info); err != nil {
+			t.Fatalf("SnapshotProject failed: %v", err)
+		}
+	}
+
+	// Before the transfer, the dry run predicts alice is lost
+	changes, err := dryRun.SyncProject(t.Context(), info, target, false)
+	if err != nil {
+		t.Fatalf("SyncProject failed: %v", err)
+	}
+	if changes != 1 {
+		t.Errorf("Expected 1 planned change, got %d", changes)
+	}
+
+	if _, err := gl.TransferProject(t.Context(), info.ID, int(target.ID)); err != nil {
+		t.Fatalf("TransferProject failed: %v", err)
+	}
+	changes, err = mm.SyncProject(t.Context(), info, target, false)
+	if err != nil {
+		t.Fatalf("SyncProject failed: %v", err)
+	}
+	if changes != 1 {
+		t.Errorf("Expected 1 change, got %d", changes)
+	}
+
+	members, _ := gl.ListProjectMembers(t.Context(), info.ID, false)
+	levels := make(map[string]gitlabCore.AccessLevelValue)
+	for _, member := range members {
+		levels[member.Username] = member.AccessLevel
+	}
+	if levels["alice"] != gitlabCore.DeveloperPermissions {
+		t.Errorf("Expected alice to be added as developer, got %v", levels["alice"])
+	}
+	if levels["bob"] != gitlabCore.ReporterPermissions {
+		t.Errorf("Expected bob to stay reporter, got %v", levels["bob"])
+	}
+	// carol has a higher access level inherited from the destination group
+	if levels["carol"] != gitlabCore.GuestPermissions {
+		t.Errorf("Expected carol to stay guest on the project, got %v", levels["carol"])
+	}
+}
+
+func TestSyncGroup(t *testing.T) {
+	gl := fake.NewGitLab(testRegistry)
+	source := gl.AddGroup("team/backend")
+	dest := gl.AddGroup("platform/team/backend")
+	reviewers := gl.AddGroup("reviewers")
+	alice := gl.AddUser("alice")
+	if err := gl.AddGroupMember(t.Context(), int(source.ID), alice.ID, gitlabCore.MaintainerPermissions, "2030-01-01"); err != nil {
+		t.Fatalf("AddGroupMember failed: %v", err)
+	}
+	if err := gl.AddGroupMember(t.Context(), int(dest.ID), alice.ID, gitlabCore.ReporterPermissions, ""); err != nil {
+		t.Fatalf("AddGroupMember failed: %v", err)
+	}
+	if err := gl.ShareGroupWithGroup(t.Context(), int(source.ID), reviewers.ID, gitlabCore.ReporterPermissions, nil); err != nil {
+		t.Fatalf("ShareGroupWithGroup failed: %v", err)
+	}
+
+	mm := NewMemberMigrator(gl, false, newTestUI(nil))
+	if err := mm.SnapshotGroup(t.Context(), source); err != nil {
+		t.Fatalf("SnapshotGroup failed: %v", err)
+	}
+	changes, err := mm.SyncGroup(t.Context(), source.FullPath, dest)
+	if err != nil {
+		t.Fatalf("SyncGroup failed: %v", err)
+	}
+	if changes != 2 {
+		t.Errorf("Expected 2 changes, got %d", changes)
+	}
+
+	level, _ := gl.GetGroupAccessLevel(t.Context(), int(dest.ID), alice.ID)
+	if level != gitlabCore.MaintainerPermissions {
+		t.Errorf("Expected alice to be promoted to maintainer, got %v", level)
+	}
+	group := gl.Group(dest.FullPath)
+	if len(group.SharedWithGroups) != 1 || group.SharedWithGroups[0].GroupID != reviewers.ID {
+		t.Errorf("Expected group to be shared with reviewers, got %v", group.SharedWithGroups)
+	}
+
+	// Nothing left to do on a second run
+	changes, err = mm.SyncGroup(t.Context(), source.FullPath, dest)
+	if err != nil || changes != 0 {
+		t.Errorf("Expected no change on second run, got %d (%v)", changes, err)
+	}
+}
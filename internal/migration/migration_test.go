@@ -0,0 +1,283 @@
+package migration
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"migraptor/internal/fake"
+	"migraptor/internal/gitlab"
+
+	gitlabCore "gitlab.com/gitlab-org/api/client-go"
+)
+
+// migrationFixture is a source group with images in nested projects and an empty destination group
+type migrationFixture struct {
+	gl     *fake.GitLab
+	engine *fake.Engine
+	source *gitlabCore.Group
+	dest   *gitlabCore.Group
+}
+
+func newMigrationFixture(t *testing.T) *migrationFixture {
+	t.Helper()
+	gl := fake.NewGitLab(testRegistry)
+	gl.RegistryDeletionDelay = 1
+	source := gl.AddGroup("org/team")
+	gl.AddProject("org/team/app")
+	gl.AddProject("org/team/backend/api")
+	gl.AddProject("org/team/docs")
+	addImages(t, gl, 10,
+		testRegistry+"/org/team/app:1.0",
+		testRegistry+"/org/team/app/worker:1.0",
+		testRegistry+"/org/team/backend/api:2.0",
+	)
+	return &migrationFixture{
+		gl:     gl,
+		engine: fake.NewEngine(gl),
+		source: source,
+		dest:   gl.AddGroup("platform"),
+	}
+}
+
+// discover lists the projects of the source group and its sub-groups, as the migrate and clean commands do
+func discover(t *testing.T, gm *GroupMigrator, pm *ProjectMigrator, group *gitlabCore.Group, selection *Selection) (map[int64]*gitlabCore.Group, map[int]*ProjectInfo) {
+	t.Helper()
+	projects, err := pm.ListProjects(t.Context(), group.ID, selection)
+	if err != nil {
+		t.Fatalf("ListProjects failed: %v", err)
+	}
+	allProjects := make(map[int]*ProjectInfo)
+	for _, project := range projects {
+		allProjects[project.ID] = &project
+	}
+
+	subGroups, subProjects, err := gm.GetSubGroupsAndProjects(t.Context(), group.ID, selection)
+	if err != nil {
+		t.Fatalf("GetSubGroupsAndProjects failed: %v", err)
+	}
+	maps.Copy(allProjects, subProjects)
+	return subGroups, allProjects
+}
+
+// backup pulls the images of the projects and deletes their registries, as the migrate command does
+func backup(t *testing.T, im *ImageMigrator, projects map[int]*ProjectInfo) map[int][]string {
+	t.Helper()
+	projectImages := make(map[int][]string)
+	for _, project := range projects {
+		images, repos, err := im.BackupImages(t.Context(), project, nil)
+		if err != nil {
+			t.Fatalf("BackupImages failed: %v", err)
+		}
+		if err := im.DeleteRegistries(t.Context(), project, repos); err != nil {
+			t.Fatalf("DeleteRegistries failed: %v", err)
+		}
+		projectImages[project.ID] = images
+	}
+	if err := im.CheckIfRemainingImages(t.Context(), projects, nil); err != nil {
+		t.Fatalf("CheckIfRemainingImages failed: %v", err)
+	}
+	return projectImages
+}
+
+func TestMigration_TransferGroup(t *testing.T) {
+	f := newMigrationFixture(t)
+	consoleUI := newTestUI(nil)
+	gm := NewGroupMigrator(f.gl, false, consoleUI)
+	pm := NewProjectMigrator(f.gl, false, consoleUI)
+	im := NewImageMigrator(f.gl, f.engine, false, consoleUI)
+
+	_, projects := discover(t, gm, pm, f.source, nil)
+	if len(projects) != 3 {
+		t.Fatalf("Expected 3 projects, got %d", len(projects))
+	}
+
+	// Transfer is refused while registries hold tags
+	if err := gm.TransferGroup(t.Context(), f.source.ID, int(f.dest.ID)); err == nil {
+		t.Fatal("Expected group transfer to fail before backup")
+	}
+
+	projectImages := backup(t, im, projects)
+	if err := gm.TransferGroup(t.Context(), f.source.ID, int(f.dest.ID)); err != nil {
+		t.Fatalf("TransferGroup failed: %v", err)
+	}
+	for id, images := range projectImages {
+		project := projects[id]
+		newPath := DestinationProjectPath(*project, f.source.FullPath, "platform", true)
+		if _, err := im.RestoreImages(t.Context(), images, project.PathWithNamespace, newPath, true); err != nil {
+			t.Fatalf("RestoreImages failed: %v", err)
+		}
+	}
+
+	expected := map[string][]string{
+		"platform/team/app":         {testRegistry + "/platform/team/app/worker:1.0", testRegistry + "/platform/team/app:1.0"},
+		"platform/team/backend/api": {testRegistry + "/platform/team/backend/api:2.0"},
+		"platform/team/docs":        nil,
+	}
+	for path, images := range expected {
+		if f.gl.Project(path) == nil {
+			t.Errorf("Expected project %s to exist", path)
+			continue
+		}
+		if got := f.gl.Images(path); !slices.Equal(got, images) {
+			t.Errorf("Expected images %v in %s, got %v", images, path, got)
+		}
+	}
+}
+
+func TestMigration_SelectedProjectsKeepParent(t *testing.T) {
+	f := newMigrationFixture(t)
+	consoleUI := newTestUI(nil)
+	gm := NewGroupMigrator(f.gl, false, consoleUI)
+	pm := NewProjectMigrator(f.gl, false, consoleUI)
+	im := NewImageMigrator(f.gl, f.engine, false, consoleUI)
+	selection := selectProjects(t, "api")
+
+	subGroups, projects := discover(t, gm, pm, f.source, selection)
+	selected := make(map[int]*ProjectInfo)
+	for id, project := range projects {
+		if selection.Includes(project) {
+			selected[id] = project
+		}
+	}
+	if len(selected) != 1 {
+		t.Fatalf("Expected 1 selected project, got %d", len(selected))
+	}
+
+	projectImages := backup(t, im, selected)
+	newGroup, err := gm.CreateGroupFrom(t.Context(), f.source, f.dest)
+	if err != nil {
+		t.Fatalf("CreateGroupFrom failed: %v", err)
+	}
+
+	mirrored := make(map[string]*gitlabCore.Group)
+	for _, project := range selected {
+		target, err := gm.MirrorNamespace(t.Context(), f.source, newGroup, project.NamespacePath, subGroups, mirrored)
+		if err != nil {
+			t.Fatalf("MirrorNamespace failed: %v", err)
+		}
+		if err := pm.TransferProject(t.Context(), project.Path, project.ID, int(target.ID)); err != nil {
+			t.Fatalf("TransferProject failed: %v", err)
+		}
+		newPath := DestinationProjectPath(*project, f.source.FullPath, "platform", true)
+		if _, err := im.RestoreImages(t.Context(), projectImages[project.ID], project.PathWithNamespace, newPath, true); err != nil {
+			t.Fatalf("RestoreImages failed: %v", err)
+		}
+	}
+
+	expected := []string{testRegistry + "/platform/team/backend/api:2.0"}
+	if got := f.gl.Images("platform/team/backend/api"); !slices.Equal(got, expected) {
+		t.Errorf("Expected images %v, got %v", expected, got)
+	}
+	// Projects not selected stay in the source group with their images
+	if got := f.gl.Images("org/team/app"); len(got) != 2 {
+		t.Errorf("Expected org/team/app to keep its 2 images, got %v", got)
+	}
+}
+
+func TestMigration_ProjectsIndividually(t *testing.T) {
+	f := newMigrationFixture(t)
+	f.gl.AddProject("platform/docs")
+	consoleUI := newTestUI(nil)
+	gm := NewGroupMigrator(f.gl, false, consoleUI)
+	pm := NewProjectMigrator(f.gl, false, consoleUI)
+	im := NewImageMigrator(f.gl, f.engine, false, consoleUI)
+
+	_, projects := discover(t, gm, pm, f.source, nil)
+	projectImages := backup(t, im, projects)
+
+	failed := 0
+	for _, project := range projects {
+		if err := pm.TransferProject(t.Context(), project.Path, project.ID, int(f.dest.ID)); err != nil {
+			failed++
+			continue
+		}
+		newPath := DestinationProjectPath(*project, f.source.FullPath, "platform", false)
+		if _, err := im.RestoreImages(t.Context(), projectImages[project.ID], project.PathWithNamespace, newPath, false); err != nil {
+			t.Fatalf("RestoreImages failed: %v", err)
+		}
+	}
+
+	// docs collides with an existing project
+	if failed != 1 || f.gl.Project("org/team/docs") == nil {
+		t.Errorf("Expected docs transfer to fail, %d transfers failed", failed)
+	}
+	expected := []string{testRegistry + "/platform/api:2.0"}
+	if got := f.gl.Images("platform/api"); !slices.Equal(got, expected) {
+		t.Errorf("Expected images %v, got %v", expected, got)
+	}
+}
+
+func TestMigration_DryRun(t *testing.T) {
+	f := newMigrationFixture(t)
+	consoleUI := newTestUI(nil)
+	gm := NewGroupMigrator(f.gl, true, consoleUI)
+	pm := NewProjectMigrator(f.gl, true, consoleUI)
+	im := NewImageMigrator(f.gl, f.engine, true, consoleUI)
+
+	_, projects := discover(t, gm, pm, f.source, nil)
+	projectImages := backup(t, im, projects)
+	if err := gm.TransferGroup(t.Context(), f.source.ID, int(f.dest.ID)); err != nil {
+		t.Fatalf("TransferGroup failed: %v", err)
+	}
+	for id, images := range projectImages {
+		project := projects[id]
+		newPath := DestinationProjectPath(*project, f.source.FullPath, "platform", true)
+		if _, err := im.RestoreImages(t.Context(), images, project.PathWithNamespace, newPath, true); err != nil {
+			t.Fatalf("RestoreImages failed: %v", err)
+		}
+	}
+
+	if len(f.engine.Pulls) != 0 || len(f.engine.Pushes) != 0 {
+		t.Errorf("Expected no pull nor push, got %v and %v", f.engine.Pulls, f.engine.Pushes)
+	}
+	if got := f.gl.Images("org/team/app"); len(got) != 2 {
+		t.Errorf("Expected source images to be kept, got %v", got)
+	}
+	if f.gl.Group("platform/team") != nil {
+		t.Error("Expected nothing to be transferred")
+	}
+}
+
+func TestMigration_OverHTTP(t *testing.T) {
+	server, err := fake.NewServer("")
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	defer server.Close()
+	client, err := gitlab.NewClient("token", server.Addr())
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	source := server.GitLab.AddGroup("org/team")
+	server.GitLab.AddProject("org/team/app")
+	server.GitLab.AddProject("org/team/backend/api")
+	dest := server.GitLab.AddGroup("platform")
+	if err := server.AddImage(server.Addr()+"/org/team/backend/api:2.0", 10); err != nil {
+		t.Fatalf("AddImage failed: %v", err)
+	}
+
+	consoleUI := newTestUI(nil)
+	gm := NewGroupMigrator(client, false, consoleUI)
+	pm := NewProjectMigrator(client, false, consoleUI)
+	im := NewImageMigrator(client, fake.NewEngine(server.GitLab), false, consoleUI)
+
+	_, projects := discover(t, gm, pm, source, nil)
+	projectImages := backup(t, im, projects)
+	if err := gm.TransferGroup(t.Context(), source.ID, int(dest.ID)); err != nil {
+		t.Fatalf("TransferGroup failed: %v", err)
+	}
+	for id, images := range projectImages {
+		project := projects[id]
+		newPath := DestinationProjectPath(*project, source.FullPath, "platform", true)
+		if _, err := im.RestoreImages(t.Context(), images, project.PathWithNamespace, newPath, true); err != nil {
+			t.Fatalf("RestoreImages failed: %v", err)
+		}
+	}
+
+	expected := []string{server.Addr() + "/platform/team/backend/api:2.0"}
+	if got := server.GitLab.Images("platform/team/backend/api"); !slices.Equal(got, expected) {
+		t.Errorf("Expected images %v, got %v", expected, got)
+	}
+}
@@ -0,0 +1,198 @@
+package migration
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"migraptor/internal/ui"
+
+	gitlabCore "gitlab.com/gitlab-org/api/client-go"
+)
+
+// migratedPackageTypes are the package types downloaded before a transfer and published again afterwards.
+// npm packages and Terraform modules are scoped to their root namespace and block transfers to another one.
+var migratedPackageTypes = []string{"generic", "npm", "maven", "pypi", "terraform_module"}
+
+// PackageBackup is a package downloaded from a project's package registry, with the local copies of its files
+type PackageBackup struct {
+	Package *gitlabCore.Package
+	// Dir is the directory holding the files of the package
+	Dir string
+	// Files are the package files, in upload order
+	Files []*gitlabCore.PackageFile
+}
+
+// filePath returns the local copy of a package file. Maven and PyPI file names may hold directories,
+// only their base name is kept.
+func (p *PackageBackup) filePath(file *gitlabCore.PackageFile) string {
+	return filepath.Join(p.Dir, strconv.FormatInt(file.ID, 10)+"-"+filepath.Base(file.FileName))
+}
+
+// String returns the type, name and version of the package
+func (p *PackageBackup) String() string {
+	return fmt.Sprintf("%s %s %s", p.Package.PackageType, p.Package.Name, p.Package.Version)
+}
+
+// PackageMigrator handles package registry migration operations
+type PackageMigrator struct {
+	gitlabClient PackageClient
+	dir          string
+	dryRun       bool
+	consoleUI    *ui.UI
+}
+
+// NewPackageMigrator creates a new PackageMigrator storing the downloaded packages in dir
+func NewPackageMigrator(gitlabClient PackageClient, dir string, dryRun bool, cUI *ui.UI) *PackageMigrator {
+	return &PackageMigrator{
+		gitlabClient: gitlabClient,
+		dir:          dir,
+		dryRun:       dryRun,
+		consoleUI:    cUI,
+	}
+}
+
+// BackupPackages downloads the files of the packages of a project which can be published again.
+// Other package types are left in the project and moved with it.
+func (pm *PackageMigrator) BackupPackages(ctx context.Context, project *ProjectInfo) ([]*PackageBackup, error) {
+	packages, err := pm.gitlabClient.ListProjectPackages(ctx, project.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list packages: %w", err)
+	}
+	if len(packages) == 0 {
+		pm.consoleUI.Debug("No package found in project %s", project.Path)
+		return nil, nil
+	}
+	pm.consoleUI.Info("📚 Found %d packages in project %s", len(packages), project.Path)
+
+	var backups []*PackageBackup
+	for _, pkg := range packages {
+		if !slices.Contains(migratedPackageTypes, pkg.PackageType) {
+			pm.consoleUI.Warning("Package %s %s of type %s is not migrated, it stays in project %s", pkg.Name, pkg.Version, pkg.PackageType, project.Path)
+			continue
+		}
+		if pkg.Status != "" && pkg.Status != "default" && pkg.Status != "hidden" {
+			pm.consoleUI.Warning("Package %s %s is %s, it is not migrated", pkg.Name, pkg.Version, pkg.Status)
+			continue
+		}
+
+		backup, err := pm.backupPackage(ctx, project, pkg)
+		if err != nil {
+			pm.consoleUI.Error("Failed to download package %s %s: %v", pkg.Name, pkg.Version, err)
+			return nil, err
+		}
+		backups = append(backups, backup)
+	}
+	return backups, nil
+}
+
+// backupPackage downloads the files of a package, checking their checksum
+func (pm *PackageMigrator) backupPackage(ctx context.Context, project *ProjectInfo, pkg *gitlabCore.Package) (*PackageBackup, error) {
+	backup := &PackageBackup{
+		Package: pkg,
+		Dir:     filepath.Join(pm.dir, strconv.Itoa(project.ID), strconv.FormatInt(pkg.ID, 10)),
+	}
+	files, err := pm.gitlabClient.ListPackageFiles(ctx, project.ID, pkg.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if pm.dryRun {
+		pm.consoleUI.Info("🌵 DRY RUN: Would download %d files of package %s", len(files), backup)
+		backup.Files = files
+		return backup, nil
+	}
+
+	pm.consoleUI.Info("📥 Downloading package %s...", backup)
+	if err := os.MkdirAll(backup.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create package directory: %w", err)
+	}
+	for _, file := range files {
+		if err := pm.downloadFile(ctx, project.ID, pkg, file, backup.filePath(file)); err != nil {
+			return nil, err
+		}
+	}
+	backup.Files = files
+	return backup, nil
+}
+
+// downloadFile downloads a package file to path and verifies its SHA-256 checksum when GitLab provides it
+func (pm *PackageMigrator) downloadFile(ctx context.Context, projectID int, pkg *gitlabCore.Package, file *gitlabCore.PackageFile, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+
+	hash := sha256.New()
+	err = pm.gitlabClient.DownloadPackageFile(ctx, projectID, pkg, file, io.MultiWriter(f, hash))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if file.FileSHA256 != "" && hex.EncodeToString(hash.Sum(nil)) != file.FileSHA256 {
+		return fmt.Errorf("checksum of %s does not match the package registry", file.FileName)
+	}
+	return nil
+}
+
+// DeletePackages deletes the backed up packages from the project, so that it can be transferred
+func (pm *PackageMigrator) DeletePackages(ctx context.Context, project *ProjectInfo, backups []*PackageBackup) error {
+	for _, backup := range backups {
+		if pm.dryRun {
+			pm.consoleUI.Info("🌵 DRY RUN: Would delete package %s", backup)
+			continue
+		}
+		if err := pm.gitlabClient.DeleteProjectPackage(ctx, project.ID, backup.Package.ID); err != nil {
+			pm.consoleUI.Error("Failed to delete package %s: %v", backup, err)
+			return err
+		}
+		pm.consoleUI.Debug("Removed package %d on project %d", backup.Package.ID, project.ID)
+	}
+	return nil
+}
+
+// RestorePackages publishes the backed up packages again in the project, once transferred.
+// All packages are tried, the error lists how many failed.
+func (pm *PackageMigrator) RestorePackages(ctx context.Context, project *ProjectInfo, backups []*PackageBackup) error {
+	failed := 0
+	for _, backup := range backups {
+		if pm.dryRun {
+			pm.consoleUI.Info("🌵 DRY RUN: Would publish package %s", backup)
+			continue
+		}
+
+		pm.consoleUI.Info("📤 Publishing package %s...", backup)
+		if err := pm.publishPackage(ctx, project.ID, backup); err != nil {
+			pm.consoleUI.Error("Failed to publish package %s: %v", backup, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d packages not published, their files are kept in %s", failed, len(backups), filepath.Join(pm.dir, strconv.Itoa(project.ID)))
+	}
+	return nil
+}
+
+// publishPackage uploads the files of a package in their original order
+func (pm *PackageMigrator) publishPackage(ctx context.Context, projectID int, backup *PackageBackup) error {
+	for _, file := range backup.Files {
+		f, err := os.Open(backup.filePath(file))
+		if err != nil {
+			return fmt.Errorf("local copy of %s not found: %w", file.FileName, err)
+		}
+		err = pm.gitlabClient.PublishPackageFile(ctx, projectID, backup.Package, file.FileName, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
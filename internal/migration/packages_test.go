@@ -0,0 +1,180 @@
+package migration
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"migraptor/internal/fake"
+	"migraptor/internal/gitlab"
+)
+
+// addPackages publishes an npm package, a Maven package and a Conan package to team/app
+func addPackages(t *testing.T, gl *fake.GitLab) {
+	t.Helper()
+	packages := []struct {
+		packageType, name, version string
+		files                      map[string][]byte
+	}{
+		{"npm", "@team/app", "1.0.0", map[string][]byte{"app-1.0.0.tgz": []byte("npm tarball")}},
+		{"maven", "com/example/app", "1.0", map[string][]byte{"app-1.0.jar": []byte("jar"), "app-1.0.pom": []byte("pom")}},
+		{"conan", "app", "1.0", map[string][]byte{"conanfile.py": []byte("recipe")}},
+	}
+	for _, pkg := range packages {
+		if err := gl.AddPackage("team/app", pkg.packageType, pkg.name, pkg.version, pkg.files); err != nil {
+			t.Fatalf("AddPackage(%s) failed: %v", pkg.name, err)
+		}
+	}
+}
+
+func TestBackupAndRestorePackages(t *testing.T) {
+	gl := fake.NewGitLab(testRegistry)
+	gl.AddProject("team/app")
+	platform := gl.AddGroup("platform")
+	addPackages(t, gl)
+	project := projectInfo(t, gl, "team/app")
+	pm := NewPackageMigrator(gl, t.TempDir(), false, newTestUI(nil))
+
+	backups, err := pm.BackupPackages(t.Context(), project)
+	if err != nil {
+		t.Fatalf("BackupPackages failed: %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("Expected npm and Maven packages to be backed up, got %v", backups)
+	}
+	for _, backup := range backups {
+		for _, file := range backup.Files {
+			if _, err := os.Stat(backup.filePath(file)); err != nil {
+				t.Errorf("Expected local copy of %s: %v", file.FileName, err)
+			}
+		}
+	}
+
+	// npm packages block the transfer to another root namespace until they are deleted
+	if _, err := gl.TransferProject(t.Context(), project.ID, int(platform.ID)); err == nil {
+		t.Fatal("Expected transfer to be blocked by npm packages")
+	}
+	if err := pm.DeletePackages(t.Context(), project, backups); err != nil {
+		t.Fatalf("DeletePackages failed: %v", err)
+	}
+	if got := gl.Packages("team/app"); !slices.Equal(got, []string{"conan app 1.0"}) {
+		t.Errorf("Expected only the Conan package to be left, got %v", got)
+	}
+	if _, err := gl.TransferProject(t.Context(), project.ID, int(platform.ID)); err != nil {
+		t.Fatalf("TransferProject failed: %v", err)
+	}
+
+	if err := pm.RestorePackages(t.Context(), project, backups); err != nil {
+		t.Fatalf("RestorePackages failed: %v", err)
+	}
+	expected := []string{"conan app 1.0", "maven com/example/app 1.0", "npm @team/app 1.0.0"}
+	if got := gl.Packages("platform/app"); !slices.Equal(got, expected) {
+		t.Errorf("Expected packages %v, got %v", expected, got)
+	}
+	if content, _ := gl.PackageFile("platform/app", "com/example/app", "1.0", "app-1.0.pom"); string(content) != "pom" {
+		t.Errorf("Expected pom content to be kept, got %q", content)
+	}
+
+	// Publishing again an npm version fails, the files are kept
+	if err := pm.RestorePackages(t.Context(), project, backups[:1]); err == nil {
+		t.Error("Expected error when an npm version already exists")
+	}
+}
+
+func TestBackupPackages_DryRun(t *testing.T) {
+	gl := fake.NewGitLab(testRegistry)
+	gl.AddProject("team/app")
+	addPackages(t, gl)
+	project := projectInfo(t, gl, "team/app")
+	dir := filepath.Join(t.TempDir(), "packages")
+	pm := NewPackageMigrator(gl, dir, true, newTestUI(nil))
+
+	backups, err := pm.BackupPackages(t.Context(), project)
+	if err != nil {
+		t.Fatalf("BackupPackages failed: %v", err)
+	}
+	if err := pm.DeletePackages(t.Context(), project, backups); err != nil {
+		t.Fatalf("DeletePackages failed: %v", err)
+	}
+	if err := pm.RestorePackages(t.Context(), project, backups); err != nil {
+		t.Fatalf("RestorePackages failed: %v", err)
+	}
+
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing downloaded in dry run, got %v", err)
+	}
+	if got := gl.Packages("team/app"); len(got) != 3 {
+		t.Errorf("Expected packages to be kept in dry run, got %v", got)
+	}
+}
+
+// npmTarball packs a package.json as npm pack does
+func npmTarball(t *testing.T, manifest string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	archive := tar.NewWriter(gz)
+	if err := archive.WriteHeader(&tar.Header{Name: "package/package.json", Mode: 0644, Size: int64(len(manifest)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := archive.Write([]byte(manifest)); err != nil {
+		t.Fatal(err)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestPackages_OverHTTP(t *testing.T) {
+	server, err := fake.NewServer("")
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	defer server.Close()
+	client, err := gitlab.NewClient("token", server.Addr())
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	server.GitLab.AddProject("team/app")
+	platform := server.GitLab.AddGroup("platform")
+	tarball := npmTarball(t, `{"name":"@team/app","version":"1.0.0","dependencies":{"left-pad":"^1.3.0"}}`)
+	if err := server.GitLab.AddPackage("team/app", "npm", "@team/app", "1.0.0", map[string][]byte{"app-1.0.0.tgz": tarball}); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.GitLab.AddPackage("team/app", "generic", "assets", "1.0.0", map[string][]byte{"assets.zip": []byte("zip")}); err != nil {
+		t.Fatal(err)
+	}
+	project := projectInfo(t, server.GitLab, "team/app")
+	pm := NewPackageMigrator(client, t.TempDir(), false, newTestUI(nil))
+
+	backups, err := pm.BackupPackages(t.Context(), project)
+	if err != nil {
+		t.Fatalf("BackupPackages failed: %v", err)
+	}
+	if err := pm.DeletePackages(t.Context(), project, backups); err != nil {
+		t.Fatalf("DeletePackages failed: %v", err)
+	}
+	if _, err := client.TransferProject(t.Context(), project.ID, int(platform.ID)); err != nil {
+		t.Fatalf("TransferProject failed: %v", err)
+	}
+	if err := pm.RestorePackages(t.Context(), project, backups); err != nil {
+		t.Fatalf("RestorePackages failed: %v", err)
+	}
+
+	expected := []string{"generic assets 1.0.0", "npm @team/app 1.0.0"}
+	if got := server.GitLab.Packages("platform/app"); !slices.Equal(got, expected) {
+		t.Errorf("Expected packages %v, got %v", expected, got)
+	}
+	if content, _ := server.GitLab.PackageFile("platform/app", "@team/app", "1.0.0", "app-1.0.0.tgz"); !bytes.Equal(content, tarball) {
+		t.Error("Expected npm tarball to be published unchanged")
+	}
+}
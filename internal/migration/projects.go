@@ -1,9 +1,11 @@
 package migration
 
 import (
+	"context"
 	"fmt"
+	"strings"
+	"time"
 
-	"migraptor/internal/gitlab"
 	"migraptor/internal/ui"
 
 	gitlabCore "gitlab.com/gitlab-org/api/client-go"
@@ -14,20 +16,24 @@ type ProjectInfo struct {
 	ID                       int
 	Name                     string
 	Path                     string
+	PathWithNamespace        string
+	NamespacePath            string
 	ContainerRegistryEnabled bool
 	Archived                 bool
+	LastActivityAt           *time.Time
+	Topics                   []string
 	RegistryRepositoriesIDs  []int
 }
 
 // ProjectMigrator handles project-related migration operations
 type ProjectMigrator struct {
-	client    *gitlab.Client
+	client    ProjectClient
 	dryRun    bool
 	consoleUI *ui.UI
 }
 
 // NewProjectMigrator creates a new ProjectMigrator
-func NewProjectMigrator(client *gitlab.Client, dryRun bool, cUI *ui.UI) *ProjectMigrator {
+func NewProjectMigrator(client ProjectClient, dryRun bool, cUI *ui.UI) *ProjectMigrator {
 	return &ProjectMigrator{
 		client:    client,
 		dryRun:    dryRun,
@@ -35,41 +41,35 @@ func NewProjectMigrator(client *gitlab.Client, dryRun bool, cUI *ui.UI) *Project
 	}
 }
 
-// ListProjects lists projects in a group, optionally filtered
-func (pm *ProjectMigrator) ListProjects(groupID int64, filterList []string) ([]ProjectInfo, error) {
-	projects, _, err := pm.client.ListProjects(int(groupID))
+// ListProjects lists the projects of a group included by the selection, all of them if selection is nil
+func (pm *ProjectMigrator) ListProjects(ctx context.Context, groupID int64, selection *Selection) ([]ProjectInfo, error) {
+	projects, _, err := pm.client.ListProjects(ctx, int(groupID))
 	if err != nil {
 		return nil, fmt.Errorf("failed to list projects: %w", err)
 	}
 
-	return FilterProjects(projects, filterList), nil
+	return FilterProjects(projects, selection), nil
 }
 
-func FilterProjects(projects []*gitlabCore.Project, filterList []string) []ProjectInfo {
+// FilterProjects converts the projects included by the selection, all of them if selection is nil
+func FilterProjects(projects []*gitlabCore.Project, selection *Selection) []ProjectInfo {
 	var result []ProjectInfo
 	for _, project := range projects {
-		// Apply filter if provided
-		if len(filterList) > 0 {
-			found := false
-			for _, filter := range filterList {
-				if project.Path == filter {
-					found = true
-					break
-				}
-			}
-			if !found {
-				// If project has no registry and keep_parent is true, migrate anyway
-				// This logic is handled in the main migration flow
-				continue
-			}
-		}
-
 		info := ProjectInfo{
 			ID:                       int(project.ID),
 			Name:                     project.Name,
 			Path:                     project.Path,
+			PathWithNamespace:        project.PathWithNamespace,
 			ContainerRegistryEnabled: project.ContainerRegistryEnabled,
 			Archived:                 project.Archived,
+			LastActivityAt:           project.LastActivityAt,
+			Topics:                   project.Topics,
+		}
+		if project.Namespace != nil {
+			info.NamespacePath = project.Namespace.FullPath
+		}
+		if !selection.Includes(&info) {
+			continue
 		}
 
 		result = append(result, info)
@@ -78,7 +78,7 @@ func FilterProjects(projects []*gitlabCore.Project, filterList []string) []Proje
 }
 
 // UnarchiveProject unarchives a project
-func (pm *ProjectMigrator) UnarchiveProject(projectName string, projectID int) error {
+func (pm *ProjectMigrator) UnarchiveProject(ctx context.Context, projectName string, projectID int) error {
 	pm.consoleUI.PrintUnarchivedMessage(projectName)
 
 	if pm.dryRun {
@@ -86,7 +86,7 @@ func (pm *ProjectMigrator) UnarchiveProject(projectName string, projectID int) e
 		return nil
 	}
 
-	resp, err := pm.client.UnarchiveProject(projectID)
+	resp, err := pm.client.UnarchiveProject(ctx, projectID)
 	if err != nil {
 		return fmt.Errorf("failed to unarchive project: %w", err)
 	}
@@ -100,7 +100,7 @@ func (pm *ProjectMigrator) UnarchiveProject(projectName string, projectID int) e
 }
 
 // ArchiveProject archives a project
-func (pm *ProjectMigrator) ArchiveProject(projectName string, projectID int) error {
+func (pm *ProjectMigrator) ArchiveProject(ctx context.Context, projectName string, projectID int) error {
 	pm.consoleUI.PrintArchivedMessage(projectName)
 
 	if pm.dryRun {
@@ -108,7 +108,7 @@ func (pm *ProjectMigrator) ArchiveProject(projectName string, projectID int) err
 		return nil
 	}
 
-	resp, err := pm.client.ArchiveProject(projectID)
+	resp, err := pm.client.ArchiveProject(ctx, projectID)
 	if err != nil {
 		return fmt.Errorf("failed to archive project: %w", err)
 	}
@@ -122,7 +122,7 @@ func (pm *ProjectMigrator) ArchiveProject(projectName string, projectID int) err
 }
 
 // TransferProject transfers a project to another namespace
-func (pm *ProjectMigrator) TransferProject(projectName string, projectID, targetGroupID int) error {
+func (pm *ProjectMigrator) TransferProject(ctx context.Context, projectName string, projectID, targetGroupID int) error {
 	pm.consoleUI.PrintTransferringProject(projectName, targetGroupID)
 
 	if pm.dryRun {
@@ -130,7 +130,7 @@ func (pm *ProjectMigrator) TransferProject(projectName string, projectID, target
 		return nil
 	}
 
-	resp, err := pm.client.TransferProject(projectID, targetGroupID)
+	resp, err := pm.client.TransferProject(ctx, projectID, targetGroupID)
 	if err != nil {
 		return fmt.Errorf("failed to transfer project: %w", err)
 	}
@@ -139,22 +139,20 @@ func (pm *ProjectMigrator) TransferProject(projectName string, projectID, target
 	return nil
 }
 
-// ShouldMigrateProject checks if a project should be migrated based on filters
-func ShouldMigrateProject(project ProjectInfo, filterList []string, keepParent bool) bool {
-	if len(filterList) == 0 {
-		return true
+// DestinationProjectPath returns the full path a project will have once migrated from sourceGroupFullPath to destGroupPath
+func DestinationProjectPath(project ProjectInfo, sourceGroupFullPath, destGroupPath string, keepParent bool) string {
+	destGroupPath = strings.Trim(destGroupPath, "/")
+	if !keepParent {
+		return fmt.Sprintf("%s/%s", destGroupPath, project.Path)
 	}
 
-	for _, filter := range filterList {
-		if project.Path == filter {
-			return true
-		}
-	}
+	sourceGroupFullPath = strings.Trim(sourceGroupFullPath, "/")
+	parts := strings.Split(sourceGroupFullPath, "/")
+	destRoot := fmt.Sprintf("%s/%s", destGroupPath, parts[len(parts)-1])
 
-	// If project has no registry and keep_parent is true, migrate anyway
-	if !project.ContainerRegistryEnabled && keepParent {
-		return true
+	relative, ok := relativeNamespace(project.NamespacePath, sourceGroupFullPath)
+	if !ok || relative == "" {
+		return fmt.Sprintf("%s/%s", destRoot, project.Path)
 	}
-
-	return false
+	return fmt.Sprintf("%s/%s/%s", destRoot, relative, project.Path)
 }
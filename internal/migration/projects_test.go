@@ -0,0 +1,116 @@
+package migration
+
+import (
+	"testing"
+
+	"migraptor/internal/fake"
+
+	gitlabCore "gitlab.com/gitlab-org/api/client-go"
+)
+
+func TestFilterProjects(t *testing.T) {
+	projects := []*gitlabCore.Project{
+		{ID: 1, Path: "app", PathWithNamespace: "team/app", Namespace: &gitlabCore.ProjectNamespace{FullPath: "team"}},
+		{ID: 2, Path: "api", PathWithNamespace: "team/backend/api", Archived: true},
+	}
+
+	all := FilterProjects(projects, nil)
+	if len(all) != 2 {
+		t.Fatalf("Expected 2 projects without filter, got %d", len(all))
+	}
+	if all[0].NamespacePath != "team" || all[1].NamespacePath != "" {
+		t.Errorf("Unexpected namespace paths %q and %q", all[0].NamespacePath, all[1].NamespacePath)
+	}
+	if !all[1].Archived {
+		t.Error("Expected archived state to be kept")
+	}
+
+	filtered := FilterProjects(projects, selectProjects(t, "api", "missing"))
+	if len(filtered) != 1 || filtered[0].ID != 2 {
+		t.Errorf("Expected only project api, got %v", filtered)
+	}
+}
+
+func TestDestinationProjectPath(t *testing.T) {
+	project := ProjectInfo{Path: "postgres", NamespacePath: "org/team/backend/db"}
+
+	if got := DestinationProjectPath(project, "org/team", "/platform/", false); got != "platform/postgres" {
+		t.Errorf("Expected platform/postgres without keep-parent, got %s", got)
+	}
+	if got := DestinationProjectPath(project, "org/team", "platform", true); got != "platform/team/backend/db/postgres" {
+		t.Errorf("Expected platform/team/backend/db/postgres with keep-parent, got %s", got)
+	}
+
+	rootProject := ProjectInfo{Path: "app", NamespacePath: "org/team"}
+	if got := DestinationProjectPath(rootProject, "org/team", "platform", true); got != "platform/team/app" {
+		t.Errorf("Expected platform/team/app with keep-parent, got %s", got)
+	}
+
+	// org/team2 shares the prefix of org/team but is not one of its sub-groups
+	siblingProject := ProjectInfo{Path: "app", NamespacePath: "org/team2"}
+	if got := DestinationProjectPath(siblingProject, "org/team", "platform", true); got != "platform/team/app" {
+		t.Errorf("Expected platform/team/app for a sibling group, got %s", got)
+	}
+}
+
+func TestTransferProject(t *testing.T) {
+	gl := fake.NewGitLab(testRegistry)
+	project := gl.AddProject("team/app")
+	target := gl.AddGroup("platform")
+	pm := NewProjectMigrator(gl, false, newTestUI(nil))
+
+	if err := pm.TransferProject(t.Context(), project.Path, int(project.ID), int(target.ID)); err != nil {
+		t.Fatalf("TransferProject failed: %v", err)
+	}
+	if gl.Project("platform/app") == nil {
+		t.Error("Expected project to be moved to platform/app")
+	}
+}
+
+func TestTransferProject_Blocked(t *testing.T) {
+	gl := fake.NewGitLab(testRegistry)
+	project := gl.AddProject("team/app")
+	addImages(t, gl, 10, testRegistry+"/team/app:1.0")
+	target := gl.AddGroup("platform")
+	gl.AddProject("platform/app")
+	other := gl.AddProject("team/other/app")
+	pm := NewProjectMigrator(gl, false, newTestUI(nil))
+
+	if err := pm.TransferProject(t.Context(), project.Path, int(project.ID), int(target.ID)); err == nil {
+		t.Error("Expected transfer to fail while registry tags are present")
+	}
+	if err := pm.TransferProject(t.Context(), other.Path, int(other.ID), int(target.ID)); err == nil {
+		t.Error("Expected transfer to fail when the path is already taken")
+	}
+	if gl.Project("team/app") == nil || gl.Project("team/other/app") == nil {
+		t.Error("Expected projects to stay in place")
+	}
+}
+
+func TestArchiveAndUnarchiveProject(t *testing.T) {
+	gl := fake.NewGitLab(testRegistry)
+	project := gl.AddProject("team/app")
+	pm := NewProjectMigrator(gl, false, newTestUI(nil))
+
+	if err := pm.ArchiveProject(t.Context(), project.Path, int(project.ID)); err != nil {
+		t.Fatalf("ArchiveProject failed: %v", err)
+	}
+	if !gl.Project("team/app").Archived {
+		t.Error("Expected project to be archived")
+	}
+
+	if err := pm.UnarchiveProject(t.Context(), project.Path, int(project.ID)); err != nil {
+		t.Fatalf("UnarchiveProject failed: %v", err)
+	}
+	if gl.Project("team/app").Archived {
+		t.Error("Expected project to be unarchived")
+	}
+
+	dryRun := NewProjectMigrator(gl, true, newTestUI(nil))
+	if err := dryRun.ArchiveProject(t.Context(), project.Path, int(project.ID)); err != nil {
+		t.Fatalf("ArchiveProject failed: %v", err)
+	}
+	if gl.Project("team/app").Archived {
+		t.Error("Expected project to stay unarchived in dry run")
+	}
+}
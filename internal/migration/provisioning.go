@@ -0,0 +1,277 @@
+package migration
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"migraptor/internal/config"
+
+	gitlabCore "gitlab.com/gitlab-org/api/client-go"
+)
+
+// accessLevelsByName maps the access level names usable in the group template to GitLab values
+var accessLevelsByName = map[string]gitlabCore.AccessLevelValue{
+	"no_one":     gitlabCore.NoPermissions,
+	"developer":  gitlabCore.DeveloperPermissions,
+	"maintainer": gitlabCore.MaintainerPermissions,
+	"owner":      gitlabCore.OwnerPermissions,
+	"admin":      gitlabCore.AdminPermissions,
+}
+
+// GroupSettings holds the settings applied to a destination group when it is created
+type GroupSettings struct {
+	Name                    string
+	Path                    string
+	Visibility              gitlabCore.VisibilityValue
+	Description             string
+	ProjectCreationLevel    gitlabCore.ProjectCreationLevelValue
+	SharedRunnersSetting    gitlabCore.SharedRunnersSettingValue
+	DefaultBranchProtection *gitlabCore.DefaultBranchProtectionDefaultsOptions
+	AvatarFilename          string
+	Avatar                  []byte
+	Labels                  []*gitlabCore.GroupLabel
+}
+
+// SetGroupTemplate sets the template overriding the settings copied from source groups
+func (gm *GroupMigrator) SetGroupTemplate(template *config.GroupTemplate) {
+	gm.template = template
+}
+
+// BuildGroupSettings computes the settings of a destination group from its source group and the configured template
+func (gm *GroupMigrator) BuildGroupSettings(ctx context.Context, source *gitlabCore.Group) (*GroupSettings, error) {
+	settings := &GroupSettings{
+		Name:                 source.Name,
+		Path:                 source.Path,
+		Visibility:           source.Visibility,
+		Description:          source.Description,
+		ProjectCreationLevel: source.ProjectCreationLevel,
+		SharedRunnersSetting: source.SharedRunnersSetting,
+	}
+
+	if source.DefaultBranchProtectionDefaults != nil {
+		settings.DefaultBranchProtection = copyBranchProtection(source.DefaultBranchProtectionDefaults)
+	}
+
+	if source.AvatarURL != "" && source.ID != 0 {
+		avatar, err := gm.client.DownloadGroupAvatar(ctx, int(source.ID))
+		if err != nil {
+			gm.consoleUI.Warning("Cannot copy avatar of group %s: %v", source.FullPath, err)
+		} else {
+			data, err := io.ReadAll(avatar)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read avatar of group %s: %w", source.FullPath, err)
+			}
+			settings.Avatar = data
+			settings.AvatarFilename = path.Base(source.AvatarURL)
+		}
+	}
+
+	if source.ID != 0 {
+		labels, err := gm.client.ListGroupLabels(ctx, int(source.ID))
+		if err != nil {
+			gm.consoleUI.Warning("Cannot copy labels of group %s: %v", source.FullPath, err)
+		} else {
+			settings.Labels = labels
+		}
+	}
+
+	if gm.template != nil {
+		if err := applyGroupTemplate(settings, gm.template); err != nil {
+			return nil, err
+		}
+	}
+
+	return settings, nil
+}
+
+// applyGroupTemplate overrides the settings with the non-empty values of the template
+func applyGroupTemplate(settings *GroupSettings, template *config.GroupTemplate) error {
+	if template.Visibility != "" {
+		settings.Visibility = gitlabCore.VisibilityValue(template.Visibility)
+	}
+	if template.Description != "" {
+		settings.Description = template.Description
+	}
+	if template.ProjectCreationLevel != "" {
+		settings.ProjectCreationLevel = gitlabCore.ProjectCreationLevelValue(template.ProjectCreationLevel)
+	}
+	if template.SharedRunnersSetting != "" {
+		settings.SharedRunnersSetting = gitlabCore.SharedRunnersSettingValue(template.SharedRunnersSetting)
+	}
+
+	if template.DefaultBranchProtection != nil {
+		protection, err := templateBranchProtection(template.DefaultBranchProtection)
+		if err != nil {
+			return err
+		}
+		settings.DefaultBranchProtection = protection
+	}
+
+	if template.Avatar != "" {
+		data, err := os.ReadFile(template.Avatar)
+		if err != nil {
+			return fmt.Errorf("failed to read template avatar %s: %w", template.Avatar, err)
+		}
+		settings.Avatar = data
+		settings.AvatarFilename = filepath.Base(template.Avatar)
+	}
+
+	for _, label := range template.Labels {
+		found := false
+		for _, existing := range settings.Labels {
+			if existing.Name == label.Name {
+				existing.Color = label.Color
+				existing.Description = label.Description
+				found = true
+				break
+			}
+		}
+		if !found {
+			settings.Labels = append(settings.Labels, &gitlabCore.GroupLabel{
+				Name:        label.Name,
+				Color:       label.Color,
+				Description: label.Description,
+			})
+		}
+	}
+
+	return nil
+}
+
+// copyBranchProtection converts the branch protection of a group into creation options
+func copyBranchProtection(defaults *gitlabCore.BranchProtectionDefaults) *gitlabCore.DefaultBranchProtectionDefaultsOptions {
+	allowForcePush := defaults.AllowForcePush
+	developerCanInitialPush := defaults.DeveloperCanInitialPush
+	allowedToPush := defaults.AllowedToPush
+	allowedToMerge := defaults.AllowedToMerge
+
+	return &gitlabCore.DefaultBranchProtectionDefaultsOptions{
+		AllowedToPush:           &allowedToPush,
+		AllowedToMerge:          &allowedToMerge,
+		AllowForcePush:          &allowForcePush,
+		DeveloperCanInitialPush: &developerCanInitialPush,
+	}
+}
+
+// templateBranchProtection converts the branch protection of the template into creation options
+func templateBranchProtection(template *config.BranchProtectionTemplate) (*gitlabCore.DefaultBranchProtectionDefaultsOptions, error) {
+	toAccessLevels := func(names []string) ([]*gitlabCore.GroupAccessLevel, error) {
+		var levels []*gitlabCore.GroupAccessLevel
+		for _, name := range names {
+			level, ok := accessLevelsByName[strings.ToLower(strings.TrimSpace(name))]
+			if !ok {
+				return nil, fmt.Errorf("unknown access level %q in group template", name)
+			}
+			levels = append(levels, &gitlabCore.GroupAccessLevel{AccessLevel: &level})
+		}
+		return levels, nil
+	}
+
+	allowedToPush, err := toAccessLevels(template.AllowedToPush)
+	if err != nil {
+		return nil, err
+	}
+	allowedToMerge, err := toAccessLevels(template.AllowedToMerge)
+	if err != nil {
+		return nil, err
+	}
+
+	allowForcePush := template.AllowForcePush
+	developerCanInitialPush := template.DeveloperCanInitialPush
+	protection := &gitlabCore.DefaultBranchProtectionDefaultsOptions{
+		AllowForcePush:          &allowForcePush,
+		DeveloperCanInitialPush: &developerCanInitialPush,
+	}
+	if len(allowedToPush) > 0 {
+		protection.AllowedToPush = &allowedToPush
+	}
+	if len(allowedToMerge) > 0 {
+		protection.AllowedToMerge = &allowedToMerge
+	}
+	return protection, nil
+}
+
+// ProvisionGroup creates a group under parent with the given settings, then applies the settings
+// that cannot be set at creation time (shared runners, labels)
+func (gm *GroupMigrator) ProvisionGroup(ctx context.Context, settings *GroupSettings, parent *gitlabCore.Group) (*gitlabCore.Group, error) {
+	fullPath := fmt.Sprintf("%s/%s", parent.FullPath, settings.Path)
+
+	if gm.dryRun {
+		gm.consoleUI.Info("🌵 DRY RUN: Would create group %s (visibility: %s)", fullPath, settings.Visibility)
+		if settings.ProjectCreationLevel != "" {
+			gm.consoleUI.Info("🌵 DRY RUN: Would set project creation level of %s to %s", fullPath, settings.ProjectCreationLevel)
+		}
+		if settings.SharedRunnersSetting != "" {
+			gm.consoleUI.Info("🌵 DRY RUN: Would set shared runners of %s to %s", fullPath, settings.SharedRunnersSetting)
+		}
+		if len(settings.Avatar) > 0 {
+			gm.consoleUI.Info("🌵 DRY RUN: Would set avatar %s on %s", settings.AvatarFilename, fullPath)
+		}
+		if len(settings.Labels) > 0 {
+			gm.consoleUI.Info("🌵 DRY RUN: Would create %d labels on %s", len(settings.Labels), fullPath)
+		}
+		return &gitlabCore.Group{
+			Name:                 settings.Name,
+			Path:                 settings.Path,
+			FullPath:             fullPath,
+			Description:          settings.Description,
+			Visibility:           settings.Visibility,
+			ProjectCreationLevel: settings.ProjectCreationLevel,
+			SharedRunnersSetting: settings.SharedRunnersSetting,
+			ParentID:             parent.ID,
+		}, nil
+	}
+
+	parentID := parent.ID
+	opt := &gitlabCore.CreateGroupOptions{
+		Name:                            &settings.Name,
+		Path:                            &settings.Path,
+		Description:                     &settings.Description,
+		ParentID:                        &parentID,
+		DefaultBranchProtectionDefaults: settings.DefaultBranchProtection,
+	}
+	if settings.Visibility != "" {
+		opt.Visibility = &settings.Visibility
+	}
+	if settings.ProjectCreationLevel != "" {
+		opt.ProjectCreationLevel = &settings.ProjectCreationLevel
+	}
+	if len(settings.Avatar) > 0 {
+		opt.Avatar = &gitlabCore.GroupAvatar{
+			Filename: settings.AvatarFilename,
+			Image:    bytes.NewReader(settings.Avatar),
+		}
+	}
+
+	created, _, err := gm.client.CreateGroupWithOptions(ctx, opt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create group %s: %w", fullPath, err)
+	}
+	gm.consoleUI.PrintGroupCreated(created.FullPath, created.ID)
+
+	// Shared runners setting is only available on update
+	if settings.SharedRunnersSetting != "" && settings.SharedRunnersSetting != created.SharedRunnersSetting {
+		_, _, err := gm.client.UpdateGroup(ctx, int(created.ID), &gitlabCore.UpdateGroupOptions{
+			SharedRunnersSetting: &settings.SharedRunnersSetting,
+		})
+		if err != nil {
+			gm.consoleUI.Warning("Cannot set shared runners setting on group %s: %v", created.FullPath, err)
+		}
+	}
+
+	for _, label := range settings.Labels {
+		if _, err := gm.client.CreateGroupLabel(ctx, int(created.ID), label.Name, label.Color, label.Description); err != nil {
+			gm.consoleUI.Warning("Cannot create label %s on group %s: %v", label.Name, created.FullPath, err)
+			continue
+		}
+		gm.consoleUI.Debug("Created label %s on group %s", label.Name, created.FullPath)
+	}
+
+	return created, nil
+}
@@ -0,0 +1,146 @@
+package migration
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"migraptor/internal/config"
+
+	gitlabCore "gitlab.com/gitlab-org/api/client-go"
+)
+
+// sourceGroup returns a group not yet created on GitLab, whose avatar and labels are not fetched
+func sourceGroup() *gitlabCore.Group {
+	return &gitlabCore.Group{
+		Name:                 "Backend",
+		Path:                 "backend",
+		Description:          "Backend services",
+		Visibility:           gitlabCore.InternalVisibility,
+		ProjectCreationLevel: gitlabCore.MaintainerProjectCreation,
+		SharedRunnersSetting: gitlabCore.EnabledSharedRunnersSettingValue,
+		DefaultBranchProtectionDefaults: &gitlabCore.BranchProtectionDefaults{
+			AllowedToPush:  []*gitlabCore.GroupAccessLevel{{AccessLevel: gitlabCore.Ptr(gitlabCore.MaintainerPermissions)}},
+			AllowForcePush: true,
+		},
+	}
+}
+
+func TestBuildGroupSettings_CopiesSource(t *testing.T) {
+	gm := &GroupMigrator{}
+
+	settings, err := gm.BuildGroupSettings(t.Context(), sourceGroup())
+	if err != nil {
+		t.Fatalf("BuildGroupSettings failed: %v", err)
+	}
+	if settings.Name != "Backend" || settings.Path != "backend" || settings.Description != "Backend services" {
+		t.Errorf("Expected name, path and description of the source, got %+v", settings)
+	}
+	if settings.Visibility != gitlabCore.InternalVisibility {
+		t.Errorf("Expected visibility internal, got %s", settings.Visibility)
+	}
+	if settings.ProjectCreationLevel != gitlabCore.MaintainerProjectCreation {
+		t.Errorf("Expected project creation level maintainer, got %s", settings.ProjectCreationLevel)
+	}
+	if settings.SharedRunnersSetting != gitlabCore.EnabledSharedRunnersSettingValue {
+		t.Errorf("Expected shared runners enabled, got %s", settings.SharedRunnersSetting)
+	}
+
+	protection := settings.DefaultBranchProtection
+	if protection == nil || !*protection.AllowForcePush || *protection.DeveloperCanInitialPush {
+		t.Fatalf("Expected branch protection of the source, got %+v", protection)
+	}
+	if pushLevels := *protection.AllowedToPush; len(pushLevels) != 1 || *pushLevels[0].AccessLevel != gitlabCore.MaintainerPermissions {
+		t.Errorf("Expected maintainers allowed to push, got %v", pushLevels)
+	}
+}
+
+func TestBuildGroupSettings_Template(t *testing.T) {
+	avatar := filepath.Join(t.TempDir(), "logo.png")
+	if err := os.WriteFile(avatar, []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gm := &GroupMigrator{}
+	gm.SetGroupTemplate(&config.GroupTemplate{
+		Visibility:           "private",
+		Description:          "Migrated from team",
+		SharedRunnersSetting: "disabled_and_unoverridable",
+		Avatar:               avatar,
+		DefaultBranchProtection: &config.BranchProtectionTemplate{
+			AllowedToPush:  []string{"developer", " Maintainer "},
+			AllowedToMerge: []string{"maintainer"},
+		},
+		Labels: []config.LabelTemplate{{Name: "migrated", Color: "#00ff00"}},
+	})
+
+	settings, err := gm.BuildGroupSettings(t.Context(), sourceGroup())
+	if err != nil {
+		t.Fatalf("BuildGroupSettings failed: %v", err)
+	}
+	if settings.Visibility != gitlabCore.PrivateVisibility || settings.Description != "Migrated from team" {
+		t.Errorf("Expected visibility and description of the template, got %s and %q", settings.Visibility, settings.Description)
+	}
+	if settings.SharedRunnersSetting != gitlabCore.DisabledAndUnoverridableSharedRunnersSettingValue {
+		t.Errorf("Expected shared runners of the template, got %s", settings.SharedRunnersSetting)
+	}
+	// Settings not set in the template are copied from the source
+	if settings.ProjectCreationLevel != gitlabCore.MaintainerProjectCreation || settings.Name != "Backend" {
+		t.Errorf("Expected project creation level and name of the source, got %s and %s", settings.ProjectCreationLevel, settings.Name)
+	}
+	if string(settings.Avatar) != "png" || settings.AvatarFilename != "logo.png" {
+		t.Errorf("Expected template avatar logo.png, got %s (%d bytes)", settings.AvatarFilename, len(settings.Avatar))
+	}
+
+	protection := settings.DefaultBranchProtection
+	if *protection.AllowForcePush {
+		t.Error("Expected template branch protection to replace the one of the source")
+	}
+	if pushLevels := *protection.AllowedToPush; len(pushLevels) != 2 || *pushLevels[1].AccessLevel != gitlabCore.MaintainerPermissions {
+		t.Errorf("Expected developers and maintainers allowed to push, got %v", pushLevels)
+	}
+	if mergeLevels := *protection.AllowedToMerge; len(mergeLevels) != 1 || *mergeLevels[0].AccessLevel != gitlabCore.MaintainerPermissions {
+		t.Errorf("Expected maintainers allowed to merge, got %v", mergeLevels)
+	}
+	if len(settings.Labels) != 1 || settings.Labels[0].Name != "migrated" || settings.Labels[0].Color != "#00ff00" {
+		t.Errorf("Expected template label migrated, got %v", settings.Labels)
+	}
+}
+
+func TestApplyGroupTemplate_Labels(t *testing.T) {
+	settings := &GroupSettings{Labels: []*gitlabCore.GroupLabel{
+		{Name: "bug", Color: "#ff0000", Description: "Something is broken"},
+		{Name: "feature", Color: "#0000ff"},
+	}}
+	template := &config.GroupTemplate{Labels: []config.LabelTemplate{
+		{Name: "bug", Color: "#cc0000", Description: "Broken"},
+		{Name: "migrated", Color: "#00ff00"},
+	}}
+
+	if err := applyGroupTemplate(settings, template); err != nil {
+		t.Fatalf("applyGroupTemplate failed: %v", err)
+	}
+	// Labels of the template replace the source labels with the same name and are added otherwise
+	if len(settings.Labels) != 3 {
+		t.Fatalf("Expected 3 labels, got %d", len(settings.Labels))
+	}
+	if bug := settings.Labels[0]; bug.Color != "#cc0000" || bug.Description != "Broken" {
+		t.Errorf("Expected label bug to be overridden, got %s %q", bug.Color, bug.Description)
+	}
+	if settings.Labels[1].Color != "#0000ff" || settings.Labels[2].Name != "migrated" {
+		t.Errorf("Expected label feature to be kept and migrated to be added, got %v", settings.Labels)
+	}
+}
+
+func TestApplyGroupTemplate_Errors(t *testing.T) {
+	tests := map[string]*config.GroupTemplate{
+		"unknown access level": {DefaultBranchProtection: &config.BranchProtectionTemplate{AllowedToPush: []string{"reporter"}}},
+		"missing avatar":       {Avatar: filepath.Join(t.TempDir(), "missing.png")},
+	}
+	for name, template := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := applyGroupTemplate(&GroupSettings{}, template); err == nil {
+				t.Error("Expected template to be rejected")
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package migration
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"migraptor/internal/config"
+)
+
+// Archived filters applied by a selection
+const (
+	// ArchivedInclude selects archived projects as well as the others
+	ArchivedInclude = "include"
+	// ArchivedExclude leaves archived projects in place
+	ArchivedExclude = "exclude"
+	// ArchivedOnly selects archived projects only
+	ArchivedOnly = "only"
+)
+
+// SelectionRules are the rules selecting the projects to migrate, as given by the configuration.
+// A project is migrated when it passes every filter set and matches one of the projects or include patterns,
+// if any is given.
+type SelectionRules struct {
+	// Projects are the paths of the projects to migrate
+	Projects []string
+	// Include and Exclude are patterns matched against the path with namespace of the projects:
+	// globs ("*" stops at "/", "**" does not) or regular expressions prefixed with "re:".
+	// A glob without "/" is matched against the project path only.
+	Include []string
+	Exclude []string
+	// Topics selects the projects having one of these topics, topics prefixed with "!" exclude projects instead
+	Topics []string
+	// ActiveSince selects the projects active since this date, InactiveSince the ones which were not.
+	// Dates are written 2006-01-02, as RFC 3339 timestamps or as durations before now: 90d, 12w, 36h.
+	ActiveSince   string
+	InactiveSince string
+	// Archived is ArchivedInclude, ArchivedExclude or ArchivedOnly, ArchivedInclude if empty
+	Archived string
+	// ExcludeGroups are the paths of sub-groups left in place with their sub-groups and projects,
+	// full or relative to Group
+	ExcludeGroups []string
+	// Group is the full path of the group the projects are selected from
+	Group string
+	// Now is the time relative dates are computed from, the current time if zero
+	Now time.Time
+}
+
+// Selection decides which projects are migrated
+type Selection struct {
+	projects      []string
+	include       []*pattern
+	exclude       []*pattern
+	topics        []string
+	excludeTopics []string
+	activeSince   time.Time
+	inactiveSince time.Time
+	archived      string
+	// excludeGroups are the full paths of the excluded sub-groups
+	excludeGroups []string
+}
+
+// Decision tells whether a project is migrated and why
+type Decision struct {
+	Project  *ProjectInfo
+	Included bool
+	Reason   string
+}
+
+// pattern is a glob or a regular expression matching project paths
+type pattern struct {
+	text   string
+	regexp *regexp.Regexp
+	// pathOnly matches the project path instead of its path with namespace
+	pathOnly bool
+}
+
+// NewSelection parses selection rules
+func NewSelection(rules SelectionRules) (*Selection, error) {
+	now := rules.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	s := &Selection{projects: rules.Projects, archived: rules.Archived}
+	switch rules.Archived {
+	case "":
+		s.archived = ArchivedInclude
+	case ArchivedInclude, ArchivedExclude, ArchivedOnly:
+	default:
+		return nil, fmt.Errorf("invalid archived filter %q, expected %s, %s or %s", rules.Archived, ArchivedInclude, ArchivedExclude, ArchivedOnly)
+	}
+
+	var err error
+	if s.include, err = parsePatterns(rules.Include); err != nil {
+		return nil, fmt.Errorf("invalid include pattern: %w", err)
+	}
+	if s.exclude, err = parsePatterns(rules.Exclude); err != nil {
+		return nil, fmt.Errorf("invalid exclude pattern: %w", err)
+	}
+	for _, topic := range rules.Topics {
+		if excluded, found := strings.CutPrefix(topic, "!"); found {
+			s.excludeTopics = append(s.excludeTopics, excluded)
+		} else {
+			s.topics = append(s.topics, topic)
+		}
+	}
+	if s.activeSince, err = parseActivityDate(rules.ActiveSince, now); err != nil {
+		return nil, fmt.Errorf("invalid active_since: %w", err)
+	}
+	if s.inactiveSince, err = parseActivityDate(rules.InactiveSince, now); err != nil {
+		return nil, fmt.Errorf("invalid inactive_since: %w", err)
+	}
+
+	group := strings.Trim(rules.Group, "/")
+	for _, groupPath := range rules.ExcludeGroups {
+		groupPath = strings.Trim(groupPath, "/")
+		if group != "" && groupPath != group && !strings.HasPrefix(groupPath, group+"/") {
+			groupPath = group + "/" + groupPath
+		}
+		if groupPath == group {
+			return nil, fmt.Errorf("invalid exclude_groups: %s is the group migrated, not one of its sub-groups", groupPath)
+		}
+		s.excludeGroups = append(s.excludeGroups, groupPath)
+	}
+	return s, nil
+}
+
+// SelectionFromConfig returns the selection made by the projects list and the project rules of a configuration
+func SelectionFromConfig(cfg *config.Config) (*Selection, error) {
+	return NewSelection(SelectionRules{
+		Projects:      cfg.ProjectsList,
+		Include:       cfg.Include,
+		Exclude:       cfg.Exclude,
+		Topics:        cfg.Topics,
+		ActiveSince:   cfg.ActiveSince,
+		InactiveSince: cfg.InactiveSince,
+		Archived:      cfg.Archived,
+		ExcludeGroups: cfg.ExcludeGroups,
+		Group:         cfg.OldGroupName,
+	})
+}
+
+// SelectsAll returns true when the selection has no rule, so that every project is migrated
+func (s *Selection) SelectsAll() bool {
+	return s == nil || (len(s.projects) == 0 && len(s.include) == 0 && len(s.exclude) == 0 &&
+		len(s.topics) == 0 && len(s.excludeTopics) == 0 && s.activeSince.IsZero() && s.inactiveSince.IsZero() &&
+		s.archived == ArchivedInclude && len(s.excludeGroups) == 0)
+}
+
+// ExcludeGroups returns the full paths of the excluded sub-groups
+func (s *Selection) ExcludeGroups() []string {
+	if s == nil {
+		return nil
+	}
+	return s.excludeGroups
+}
+
+// ExcludedGroup returns the excluded sub-group containing the group at fullPath, or this group itself if it is excluded.
+// It returns an empty string if the group is not excluded.
+func (s *Selection) ExcludedGroup(fullPath string) string {
+	for _, excluded := range s.ExcludeGroups() {
+		if fullPath == excluded || strings.HasPrefix(fullPath, excluded+"/") {
+			return excluded
+		}
+	}
+	return ""
+}
+
+// Decide tells whether a project is migrated, with the first rule excluding it or the rule including it.
+// A nil selection includes every project.
+func (s *Selection) Decide(project *ProjectInfo) Decision {
+	if s.SelectsAll() {
+		return Decision{Project: project, Included: true, Reason: "no selection rule"}
+	}
+	exclude := func(format string, args ...interface{}) Decision {
+		return Decision{Project: project, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	if group := s.ExcludedGroup(project.NamespacePath); group != "" {
+		return exclude("in excluded sub-group %s", group)
+	}
+	if s.archived == ArchivedExclude && project.Archived {
+		return exclude("archived, archived projects are excluded")
+	}
+	if s.archived == ArchivedOnly && !project.Archived {
+		return exclude("not archived, only archived projects are selected")
+	}
+	for _, p := range s.exclude {
+		if p.match(project) {
+			return exclude("matches exclude pattern %s", p.text)
+		}
+	}
+	for _, topic := range s.excludeTopics {
+		if slices.Contains(project.Topics, topic) {
+			return exclude("has excluded topic %s", topic)
+		}
+	}
+	if !s.activeSince.IsZero() && (project.LastActivityAt == nil || project.LastActivityAt.Before(s.activeSince)) {
+		return exclude("no activity since %s", s.activeSince.Format(time.DateOnly))
+	}
+	if !s.inactiveSince.IsZero() && project.LastActivityAt != nil && !project.LastActivityAt.Before(s.inactiveSince) {
+		return exclude("active on %s, after %s", project.LastActivityAt.Format(time.DateOnly), s.inactiveSince.Format(time.DateOnly))
+	}
+	if len(s.topics) > 0 && !slices.ContainsFunc(s.topics, func(topic string) bool { return slices.Contains(project.Topics, topic) }) {
+		return exclude("has none of the topics %s", strings.Join(s.topics, ", "))
+	}
+
+	include := func(reason string) Decision {
+		return Decision{Project: project, Included: true, Reason: reason}
+	}
+	if len(s.projects) == 0 && len(s.include) == 0 {
+		return include("passes all filters")
+	}
+	if slices.Contains(s.projects, project.Path) {
+		return include("in projects_list")
+	}
+	for _, p := range s.include {
+		if p.match(project) {
+			return include("matches include pattern " + p.text)
+		}
+	}
+	return exclude("not in projects_list and matches no include pattern")
+}
+
+// Includes returns true if a project is migrated
+func (s *Selection) Includes(project *ProjectInfo) bool {
+	return s.Decide(project).Included
+}
+
+func (p *pattern) match(project *ProjectInfo) bool {
+	if p.pathOnly {
+		return p.regexp.MatchString(project.Path)
+	}
+	return p.regexp.MatchString(project.PathWithNamespace)
+}
+
+func parsePatterns(texts []string) ([]*pattern, error) {
+	var patterns []*pattern
+	for _, text := range texts {
+		p := &pattern{text: text}
+		var err error
+		if expr, isRegexp := strings.CutPrefix(text, "re:"); isRegexp {
+			p.regexp, err = regexp.Compile(expr)
+		} else {
+			glob := strings.Trim(text, "/")
+			p.pathOnly = !strings.Contains(glob, "/")
+			p.regexp, err = regexp.Compile(globToRegexp(glob))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", text, err)
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+// globToRegexp converts a glob to an anchored regular expression.
+// "*" and "?" do not match "/", "**" matches any number of path segments and [...] is a character class.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if strings.HasPrefix(glob[i:], "**/") {
+				b.WriteString("(.*/)?")
+				i += 2
+			} else if strings.HasPrefix(glob[i:], "**") {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if negated, found := strings.CutPrefix(class, "!"); found {
+				class = "^" + negated
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// parseActivityDate parses a date, a timestamp or a duration before now, returning the zero time for an empty value
+func parseActivityDate(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if date, err := time.Parse(time.DateOnly, value); err == nil {
+		return date, nil
+	}
+	if date, err := time.Parse(time.RFC3339, value); err == nil {
+		return date, nil
+	}
+
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if count, found := strings.CutSuffix(value, suffix); found {
+			n, err := strconv.Atoi(count)
+			if err != nil || n < 0 {
+				return time.Time{}, fmt.Errorf("%s is not a date nor a duration", value)
+			}
+			return now.Add(-time.Duration(n) * unit), nil
+		}
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return time.Time{}, fmt.Errorf("%s is not a date nor a duration", value)
+	}
+	return now.Add(-duration), nil
+}
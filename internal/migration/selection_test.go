@@ -0,0 +1,167 @@
+package migration
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"migraptor/internal/config"
+)
+
+func TestSelection_Decide(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	recent := now.Add(-10 * 24 * time.Hour)
+	old := now.Add(-400 * 24 * time.Hour)
+	app := &ProjectInfo{Path: "app", PathWithNamespace: "org/team/app", ContainerRegistryEnabled: true, LastActivityAt: &recent, Topics: []string{"frontend"}}
+	api := &ProjectInfo{Path: "api", PathWithNamespace: "org/team/backend/api", LastActivityAt: &recent, Topics: []string{"backend"}}
+	legacy := &ProjectInfo{Path: "legacy-api", PathWithNamespace: "org/team/backend/legacy-api", Archived: true, LastActivityAt: &old, Topics: []string{"backend", "deprecated"}}
+	docs := &ProjectInfo{Path: "docs", PathWithNamespace: "org/team/docs"}
+
+	tests := []struct {
+		name     string
+		rules    SelectionRules
+		project  *ProjectInfo
+		included bool
+		reason   string
+	}{
+		{"no rule", SelectionRules{}, legacy, true, "no selection rule"},
+		{"in projects list", SelectionRules{Projects: []string{"app"}}, app, true, "in projects_list"},
+		{"not in projects list", SelectionRules{Projects: []string{"api"}}, app, false, "not in projects_list"},
+		// Projects without registry are not selected silently anymore
+		{"no registry not in projects list", SelectionRules{Projects: []string{"api"}}, docs, false, "not in projects_list"},
+		{"glob on path", SelectionRules{Include: []string{"*api"}}, legacy, true, "matches include pattern *api"},
+		{"glob on path with namespace", SelectionRules{Include: []string{"org/team/*"}}, api, false, "matches no include pattern"},
+		{"recursive glob", SelectionRules{Include: []string{"org/**/api"}}, api, true, "matches include pattern org/**/api"},
+		{"recursive glob at any depth", SelectionRules{Include: []string{"**/backend/*"}}, legacy, true, "matches include pattern"},
+		{"regexp", SelectionRules{Include: []string{"re:/backend/"}}, api, true, "matches include pattern re:/backend/"},
+		{"exclude wins over include", SelectionRules{Include: []string{"**"}, Exclude: []string{"org/team/backend/**"}}, api, false, "matches exclude pattern org/team/backend/**"},
+		{"exclude only", SelectionRules{Exclude: []string{"docs"}}, app, true, "passes all filters"},
+		{"topic", SelectionRules{Topics: []string{"backend", "ops"}}, api, true, "passes all filters"},
+		{"missing topic", SelectionRules{Topics: []string{"backend", "ops"}}, app, false, "has none of the topics backend, ops"},
+		{"excluded topic", SelectionRules{Topics: []string{"backend", "!deprecated"}}, legacy, false, "has excluded topic deprecated"},
+		{"active since duration", SelectionRules{ActiveSince: "90d"}, legacy, false, "no activity since 2026-03-03"},
+		{"active since date", SelectionRules{ActiveSince: "2026-05-01"}, app, true, "passes all filters"},
+		{"no activity known", SelectionRules{ActiveSince: "2w"}, docs, false, "no activity since"},
+		{"inactive since", SelectionRules{InactiveSince: "30d"}, app, false, "active on 2026-05-22, after 2026-05-02"},
+		{"inactive since date", SelectionRules{InactiveSince: "2026-01-01"}, legacy, true, "passes all filters"},
+		{"archived excluded", SelectionRules{Archived: ArchivedExclude}, legacy, false, "archived projects are excluded"},
+		{"archived only", SelectionRules{Archived: ArchivedOnly}, app, false, "only archived projects"},
+		{"archived included", SelectionRules{Archived: ArchivedInclude, Include: []string{"legacy-*"}}, legacy, true, "matches include pattern legacy-*"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.rules.Now = now
+			selection, err := NewSelection(tt.rules)
+			if err != nil {
+				t.Fatalf("NewSelection failed: %v", err)
+			}
+			decision := selection.Decide(tt.project)
+			if decision.Included != tt.included || !strings.Contains(decision.Reason, tt.reason) {
+				t.Errorf("Expected included=%v because %q, got included=%v because %q", tt.included, tt.reason, decision.Included, decision.Reason)
+			}
+			if decision.Project != tt.project {
+				t.Error("Expected the decision to refer to the project")
+			}
+		})
+	}
+}
+
+func TestNewSelection_Invalid(t *testing.T) {
+	tests := map[string]SelectionRules{
+		"regexp":         {Include: []string{"re:("}},
+		"archived":       {Archived: "yes"},
+		"active since":   {ActiveSince: "last year"},
+		"inactive since": {InactiveSince: "-3d"},
+	}
+	for name, rules := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewSelection(rules); err == nil {
+				t.Errorf("Expected rules %+v to be refused", rules)
+			}
+		})
+	}
+}
+
+func TestSelection_ExcludedGroup(t *testing.T) {
+	selection, err := NewSelection(SelectionRules{Group: "/org/team/", ExcludeGroups: []string{"backend/", "org/team/ops/legacy", "other"}})
+	if err != nil {
+		t.Fatalf("NewSelection failed: %v", err)
+	}
+	if selection.SelectsAll() {
+		t.Error("Expected excluded sub-groups to restrict the selection")
+	}
+
+	tests := map[string]string{
+		"org/team/backend":        "org/team/backend",
+		"org/team/backend/db":     "org/team/backend",
+		"org/team/backend-legacy": "",
+		"org/team/ops":            "",
+		"org/team/ops/legacy/v1":  "org/team/ops/legacy",
+		"org/team/other":          "org/team/other",
+	}
+	for fullPath, expected := range tests {
+		if got := selection.ExcludedGroup(fullPath); got != expected {
+			t.Errorf("Expected %s to be excluded by %q, got %q", fullPath, expected, got)
+		}
+	}
+
+	decision := selection.Decide(&ProjectInfo{Path: "api", PathWithNamespace: "org/team/backend/db/api", NamespacePath: "org/team/backend/db"})
+	if decision.Included || decision.Reason != "in excluded sub-group org/team/backend" {
+		t.Errorf("Expected project to be excluded with its sub-group, got %+v", decision)
+	}
+
+	if _, err := NewSelection(SelectionRules{Group: "org/team", ExcludeGroups: []string{"org/team"}}); err == nil {
+		t.Error("Expected the group migrated itself to be refused")
+	}
+}
+
+func TestSelection_SelectsAll(t *testing.T) {
+	var none *Selection
+	if !none.SelectsAll() || !none.Includes(&ProjectInfo{Path: "app"}) {
+		t.Error("Expected a nil selection to include every project")
+	}
+
+	selection, err := SelectionFromConfig(&config.Config{Archived: ArchivedInclude})
+	if err != nil {
+		t.Fatalf("SelectionFromConfig failed: %v", err)
+	}
+	if !selection.SelectsAll() {
+		t.Error("Expected archived projects included by default to select all projects")
+	}
+
+	selection, err = SelectionFromConfig(&config.Config{Exclude: []string{"docs"}})
+	if err != nil {
+		t.Fatalf("SelectionFromConfig failed: %v", err)
+	}
+	if selection.SelectsAll() {
+		t.Error("Expected an exclude pattern to restrict the selection")
+	}
+}
+
+func TestGlobToRegexp(t *testing.T) {
+	tests := []struct {
+		glob    string
+		path    string
+		matches bool
+	}{
+		{"org/*/app", "org/team/app", true},
+		{"org/*/app", "org/team/sub/app", false},
+		{"org/**/app", "org/app", true},
+		{"org/**/app", "org/team/sub/app", true},
+		{"org/**", "org/team/sub/app", true},
+		{"org/app-?", "org/app-1", true},
+		{"org/app-[!0-9]", "org/app-1", false},
+		{"org/app-[ab]", "org/app-b", true},
+		{"org/app.v2", "org/appxv2", false},
+	}
+	for _, tt := range tests {
+		patterns, err := parsePatterns([]string{tt.glob})
+		if err != nil {
+			t.Fatalf("parsePatterns(%s) failed: %v", tt.glob, err)
+		}
+		if got := patterns[0].match(&ProjectInfo{PathWithNamespace: tt.path}); got != tt.matches {
+			t.Errorf("Expected %s matching %s to be %v, got %v", tt.glob, tt.path, tt.matches, got)
+		}
+	}
+}
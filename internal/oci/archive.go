@@ -0,0 +1,307 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Archive saves images from a registry to an image layout on disk, and pushes them back under another name.
+// Indexes are copied with all their platform manifests, and images with the artifacts referring to them.
+type Archive struct {
+	client *Client
+	dir    string
+	layout *Layout
+}
+
+// NewArchive creates an archive of the images of the client registry, stored in dir.
+// The directory is only created when the first image is saved.
+func NewArchive(client *Client, dir string) *Archive {
+	return &Archive{client: client, dir: dir}
+}
+
+// Dir returns the directory of the image layout
+func (a *Archive) Dir() string {
+	return a.dir
+}
+
+// NeedsArchive returns true if a container engine would not push back the image as it is in the registry:
+// multi-architecture indexes, artifacts which are not images, and images referred to by artifacts
+func (a *Archive) NeedsArchive(ctx context.Context, imageRef string) (bool, error) {
+	repository, tag, err := a.parseReference(imageRef)
+	if err != nil {
+		return false, err
+	}
+	manifest, err := a.client.GetManifest(ctx, repository, tag)
+	if err != nil {
+		return false, err
+	}
+	if manifest.IsIndex() {
+		return true, nil
+	}
+
+	fields, err := manifest.fields()
+	if err != nil {
+		return false, err
+	}
+	if fields.ArtifactType != "" || (fields.Config.MediaType != ocispec.MediaTypeImageConfig && fields.Config.MediaType != mediaTypeDockerConfig) {
+		return true, nil
+	}
+
+	referrers, err := a.client.Referrers(ctx, repository, manifest.Digest)
+	if err != nil {
+		return false, err
+	}
+	return len(referrers) > 0, nil
+}
+
+// ImageLayers returns the layers of a single platform image, keyed by the digest of their uncompressed content
+// as listed by container engines, with their compressed size in the registry. It returns nil for indexes
+// and artifacts, the layers a container engine pulls from them are not known.
+func (a *Archive) ImageLayers(ctx context.Context, imageRef string) (map[string]int64, error) {
+	repository, tag, err := a.parseReference(imageRef)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := a.client.GetManifest(ctx, repository, tag)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.IsIndex() {
+		return nil, nil
+	}
+	fields, err := manifest.fields()
+	if err != nil {
+		return nil, err
+	}
+	if fields.Config.MediaType != ocispec.MediaTypeImageConfig && fields.Config.MediaType != mediaTypeDockerConfig {
+		return nil, nil
+	}
+
+	blob, err := a.client.GetBlob(ctx, repository, fields.Config.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get configuration of %s: %w", imageRef, err)
+	}
+	defer blob.Close()
+	var config ocispec.Image
+	if err := json.NewDecoder(blob).Decode(&config); err != nil {
+		return nil, fmt.Errorf("invalid configuration of %s: %w", imageRef, err)
+	}
+	if len(config.RootFS.DiffIDs) != len(fields.Layers) {
+		return nil, fmt.Errorf("configuration of %s lists %d layers, its manifest %d", imageRef, len(config.RootFS.DiffIDs), len(fields.Layers))
+	}
+
+	layers := make(map[string]int64, len(fields.Layers))
+	for i, diffID := range config.RootFS.DiffIDs {
+		layers[diffID.String()] = fields.Layers[i].Size
+	}
+	return layers, nil
+}
+
+// Save copies an image, all the manifests and blobs it references and the artifacts referring to them to the archive
+func (a *Archive) Save(ctx context.Context, imageRef string) error {
+	repository, tag, err := a.parseReference(imageRef)
+	if err != nil {
+		return err
+	}
+	if a.layout == nil {
+		if a.layout, err = OpenLayout(a.dir); err != nil {
+			return err
+		}
+	}
+
+	root, err := a.client.GetManifest(ctx, repository, tag)
+	if err != nil {
+		return err
+	}
+	subjects, err := a.saveManifest(ctx, repository, root)
+	if err != nil {
+		return fmt.Errorf("failed to save %s: %w", imageRef, err)
+	}
+
+	// Artifacts may refer to the index, to a platform manifest or to another artifact
+	var referrers []ocispec.Descriptor
+	seen := make(map[digest.Digest]bool)
+	for len(subjects) > 0 {
+		subject := subjects[0]
+		subjects = subjects[1:]
+
+		descriptors, err := a.client.Referrers(ctx, repository, subject)
+		if err != nil {
+			return fmt.Errorf("failed to list artifacts referring to %s: %w", imageRef, err)
+		}
+		for _, desc := range descriptors {
+			if seen[desc.Digest] {
+				continue
+			}
+			seen[desc.Digest] = true
+
+			manifest, err := a.client.GetManifest(ctx, repository, desc.Digest.String())
+			if err != nil {
+				return err
+			}
+			saved, err := a.saveManifest(ctx, repository, manifest)
+			if err != nil {
+				return fmt.Errorf("failed to save artifact %s referring to %s: %w", desc.Digest, imageRef, err)
+			}
+			referrers = append(referrers, manifest.Descriptor())
+			subjects = append(subjects, saved...)
+		}
+	}
+
+	return a.layout.Set(imageRef, root.Descriptor(), referrers)
+}
+
+// saveManifest stores a manifest with the manifests and blobs it references, and returns the digests of all the manifests stored
+func (a *Archive) saveManifest(ctx context.Context, repository string, manifest *Manifest) ([]digest.Digest, error) {
+	fields, err := manifest.fields()
+	if err != nil {
+		return nil, err
+	}
+
+	saved := []digest.Digest{manifest.Digest}
+	if manifest.IsIndex() {
+		for _, desc := range fields.Manifests {
+			child, err := a.client.GetManifest(ctx, repository, desc.Digest.String())
+			if err != nil {
+				return nil, err
+			}
+			children, err := a.saveManifest(ctx, repository, child)
+			if err != nil {
+				return nil, err
+			}
+			saved = append(saved, children...)
+		}
+	} else {
+		for _, desc := range append([]ocispec.Descriptor{fields.Config}, fields.Layers...) {
+			if err := a.saveBlob(ctx, repository, desc); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := a.layout.WriteBlob(manifest.Digest, bytes.NewReader(manifest.Content)); err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// saveBlob stores a blob of the repository unless the archive already holds it
+func (a *Archive) saveBlob(ctx context.Context, repository string, desc ocispec.Descriptor) error {
+	if desc.Digest == "" || a.layout.HasBlob(desc.Digest) {
+		return nil
+	}
+
+	content, err := a.client.GetBlob(ctx, repository, desc.Digest)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+	return a.layout.WriteBlob(desc.Digest, content)
+}
+
+// Contains checks if an image was saved to the archive
+func (a *Archive) Contains(imageRef string) bool {
+	if a.layout == nil {
+		if _, err := os.Stat(filepath.Join(a.dir, ocispec.ImageIndexFile)); err != nil {
+			return false
+		}
+		layout, err := OpenLayout(a.dir)
+		if err != nil {
+			return false
+		}
+		a.layout = layout
+	}
+	_, _, ok := a.layout.Get(imageRef)
+	return ok
+}
+
+// Remove deletes an image from the archive, with the blobs no other image of the archive uses
+func (a *Archive) Remove(imageRef string) error {
+	if !a.Contains(imageRef) {
+		return nil
+	}
+	return a.layout.Delete(imageRef)
+}
+
+// Restore pushes an image saved under sourceRef, with the artifacts referring to it, to targetRef.
+// Manifests are pushed unchanged, the image keeps its digest.
+func (a *Archive) Restore(ctx context.Context, sourceRef, targetRef string) error {
+	if !a.Contains(sourceRef) {
+		return fmt.Errorf("image %s is not in archive %s", sourceRef, a.dir)
+	}
+	repository, tag, err := a.parseReference(targetRef)
+	if err != nil {
+		return err
+	}
+
+	image, referrers, _ := a.layout.Get(sourceRef)
+	if err := a.pushManifest(ctx, repository, tag, *image); err != nil {
+		return fmt.Errorf("failed to push %s: %w", targetRef, err)
+	}
+	for _, referrer := range referrers {
+		if err := a.pushManifest(ctx, repository, referrer.Digest.String(), referrer); err != nil {
+			return fmt.Errorf("failed to push artifact %s referring to %s: %w", referrer.Digest, targetRef, err)
+		}
+	}
+	return nil
+}
+
+// pushManifest pushes the blobs and manifests referenced by a manifest, then the manifest itself under reference
+func (a *Archive) pushManifest(ctx context.Context, repository, reference string, desc ocispec.Descriptor) error {
+	content, err := a.layout.ReadBlob(desc.Digest)
+	if err != nil {
+		return fmt.Errorf("manifest %s missing from archive: %w", desc.Digest, err)
+	}
+	manifest := &Manifest{MediaType: desc.MediaType, Digest: desc.Digest, Content: content}
+	fields, err := manifest.fields()
+	if err != nil {
+		return err
+	}
+
+	if manifest.IsIndex() {
+		for _, child := range fields.Manifests {
+			if err := a.pushManifest(ctx, repository, child.Digest.String(), child); err != nil {
+				return err
+			}
+		}
+	} else {
+		for _, blob := range append([]ocispec.Descriptor{fields.Config}, fields.Layers...) {
+			if blob.Digest == "" {
+				continue
+			}
+			err := a.client.PushBlob(ctx, repository, blob, func() (io.ReadCloser, error) {
+				return a.layout.OpenBlob(blob.Digest)
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return a.client.PutManifest(ctx, repository, reference, manifest)
+}
+
+// parseReference returns the repository and tag of an image of the archive registry
+func (a *Archive) parseReference(imageRef string) (string, string, error) {
+	name, found := strings.CutPrefix(imageRef, a.client.Host()+"/")
+	if !found {
+		return "", "", fmt.Errorf("image %s is not served by registry %s", imageRef, a.client.Host())
+	}
+	if idx := strings.LastIndex(name, ":"); idx > strings.LastIndex(name, "/") {
+		return name[:idx], name[idx+1:], nil
+	}
+	if name == "" {
+		return "", "", errors.New("empty image reference")
+	}
+	return name, "latest", nil
+}
@@ -0,0 +1,199 @@
+package oci
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"migraptor/internal/fake"
+
+	"github.com/docker/docker/api/types/registry"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const signatureType = "application/vnd.dev.cosign.artifact.sig.v1+json"
+
+func newTestArchive(t *testing.T) (*fake.Server, *Archive) {
+	t.Helper()
+	server, err := fake.NewServer("")
+	if err != nil {
+		t.Fatalf("Failed to start fake server: %v", err)
+	}
+	t.Cleanup(server.Close)
+
+	server.GitLab.AddProject("team/app")
+	server.GitLab.AddProject("platform/app")
+	client := NewClient(server.Addr(), registry.AuthConfig{})
+	return server, NewArchive(client, filepath.Join(t.TempDir(), "images"))
+}
+
+func TestArchive_NeedsArchive(t *testing.T) {
+	server, archive := newTestArchive(t)
+	ctx := t.Context()
+	host := server.Addr()
+
+	if err := server.AddImage(host+"/team/app:plain", 1024); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.AddImage(host+"/team/app:signed", 1024); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.AddArtifact(host+"/team/app:signed", signatureType); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.AddIndex(host+"/team/app:multi", []string{"amd64", "arm64"}, 1024); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]bool{"plain": false, "signed": true, "multi": true}
+	for tag, expected := range tests {
+		needed, err := archive.NeedsArchive(ctx, host+"/team/app:"+tag)
+		if err != nil {
+			t.Fatalf("NeedsArchive(%s) failed: %v", tag, err)
+		}
+		if needed != expected {
+			t.Errorf("Expected NeedsArchive(%s) to be %v, got %v", tag, expected, needed)
+		}
+	}
+
+	if _, err := archive.NeedsArchive(ctx, "other.example.com/team/app:plain"); err == nil {
+		t.Error("Expected error for an image of another registry")
+	}
+}
+
+func TestArchive_ImageLayers(t *testing.T) {
+	server, archive := newTestArchive(t)
+	ctx := t.Context()
+	host := server.Addr()
+
+	if err := server.AddImage(host+"/team/app:plain", 1024); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.AddIndex(host+"/team/app:multi", []string{"amd64", "arm64"}, 1024); err != nil {
+		t.Fatal(err)
+	}
+
+	layers, err := archive.ImageLayers(ctx, host+"/team/app:plain")
+	if err != nil {
+		t.Fatalf("ImageLayers failed: %v", err)
+	}
+	if len(layers) != 1 {
+		t.Fatalf("Expected 1 layer, got %v", layers)
+	}
+	for diffID, size := range layers {
+		if !strings.HasPrefix(diffID, "sha256:") || size <= 0 {
+			t.Errorf("Expected uncompressed digest with a size, got %s with %d bytes", diffID, size)
+		}
+	}
+
+	if layers, err := archive.ImageLayers(ctx, host+"/team/app:multi"); err != nil || layers != nil {
+		t.Errorf("Expected no layer for an index, got %v (%v)", layers, err)
+	}
+	if _, err := archive.ImageLayers(ctx, host+"/team/app:missing"); err == nil {
+		t.Error("Expected error for an unknown image")
+	}
+}
+
+func TestArchive_SaveAndRestore(t *testing.T) {
+	server, archive := newTestArchive(t)
+	ctx := t.Context()
+	source := server.Addr() + "/team/app:1.0"
+	target := server.Addr() + "/platform/app:1.0"
+
+	if err := server.AddIndex(source, []string{"amd64", "arm64"}, 4096); err != nil {
+		t.Fatal(err)
+	}
+	signature, err := server.AddArtifact(source, signatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if archive.Contains(source) {
+		t.Error("Expected empty archive before saving")
+	}
+	if err := archive.Save(ctx, source); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if !archive.Contains(source) {
+		t.Fatal("Expected archive to contain the saved image")
+	}
+
+	// A new archive on the same directory finds the saved image
+	reopened := NewArchive(archive.client, archive.Dir())
+	if err := reopened.Restore(ctx, source, target); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	original, err := archive.client.GetManifest(ctx, "team/app", "1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := archive.client.GetManifest(ctx, "platform/app", "1.0")
+	if err != nil {
+		t.Fatalf("Restored image not found: %v", err)
+	}
+	if restored.Digest != original.Digest {
+		t.Errorf("Expected index digest %s, got %s", original.Digest, restored.Digest)
+	}
+	if restored.MediaType != ocispec.MediaTypeImageIndex {
+		t.Errorf("Expected media type %s, got %s", ocispec.MediaTypeImageIndex, restored.MediaType)
+	}
+
+	fields, err := restored.fields()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields.Manifests) != 2 {
+		t.Fatalf("Expected 2 platform manifests, got %d", len(fields.Manifests))
+	}
+	for _, desc := range fields.Manifests {
+		manifest, err := archive.client.GetManifest(ctx, "platform/app", desc.Digest.String())
+		if err != nil {
+			t.Errorf("Platform manifest %s not restored: %v", desc.Digest, err)
+			continue
+		}
+		image, err := manifest.fields()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, layer := range image.Layers {
+			exists, err := archive.client.BlobExists(ctx, "platform/app", layer.Digest)
+			if err != nil || !exists {
+				t.Errorf("Expected layer %s to be restored, got %v (%v)", layer.Digest, exists, err)
+			}
+		}
+	}
+
+	referrers, err := archive.client.Referrers(ctx, "platform/app", restored.Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.ContainsFunc(referrers, func(desc ocispec.Descriptor) bool { return desc.Digest.String() == signature }) {
+		t.Errorf("Expected signature %s to refer to the restored index, got %v", signature, referrers)
+	}
+
+	// Removing the only image of the archive removes all its blobs
+	if err := archive.Remove(source); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if archive.Contains(source) {
+		t.Error("Expected removed image not to be in the archive")
+	}
+	blobs, err := os.ReadDir(filepath.Join(archive.Dir(), "blobs", "sha256"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) != 0 {
+		t.Errorf("Expected no blob left, got %d", len(blobs))
+	}
+}
+
+func TestArchive_RestoreUnknownImage(t *testing.T) {
+	server, archive := newTestArchive(t)
+
+	if err := archive.Restore(t.Context(), server.Addr()+"/team/app:missing", server.Addr()+"/platform/app:missing"); err == nil {
+		t.Error("Expected error when restoring an image which was not saved")
+	}
+}
@@ -0,0 +1,490 @@
+// Package oci copies images at registry level with the OCI distribution API. Unlike a pull through a
+// container engine, every platform of multi-architecture images and the artifacts referring to them
+// (signatures, SBOMs) are kept, with their digests.
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api/types/registry"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	// MediaTypeDockerManifestList is the Docker equivalent of an OCI image index
+	MediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	// MediaTypeDockerManifest is the Docker equivalent of an OCI image manifest
+	MediaTypeDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+	// mediaTypeDockerConfig is the Docker equivalent of an OCI image configuration
+	mediaTypeDockerConfig = "application/vnd.docker.container.image.v1+json"
+)
+
+// manifestMediaTypes are the manifest media types accepted from registries
+var manifestMediaTypes = []string{
+	ocispec.MediaTypeImageIndex,
+	MediaTypeDockerManifestList,
+	ocispec.MediaTypeImageManifest,
+	MediaTypeDockerManifest,
+}
+
+// ErrNotFound is returned when a manifest or blob does not exist in the registry
+var ErrNotFound = errors.New("not found")
+
+// Manifest is a manifest as stored in a registry. Its content is kept byte for byte so that its digest,
+// referenced by indexes and signatures, does not change when it is pushed elsewhere.
+type Manifest struct {
+	MediaType string
+	Digest    digest.Digest
+	Content   []byte
+}
+
+// manifestFields are the fields of image manifests and indexes referencing other content
+type manifestFields struct {
+	MediaType    string               `json:"mediaType,omitempty"`
+	ArtifactType string               `json:"artifactType,omitempty"`
+	Config       ocispec.Descriptor   `json:"config"`
+	Layers       []ocispec.Descriptor `json:"layers,omitempty"`
+	Manifests    []ocispec.Descriptor `json:"manifests,omitempty"`
+	Subject      *ocispec.Descriptor  `json:"subject,omitempty"`
+}
+
+// IsIndex returns true for OCI image indexes and Docker manifest lists
+func (m *Manifest) IsIndex() bool {
+	return m.MediaType == ocispec.MediaTypeImageIndex || m.MediaType == MediaTypeDockerManifestList
+}
+
+// Descriptor returns the descriptor referencing the manifest
+func (m *Manifest) Descriptor() ocispec.Descriptor {
+	return ocispec.Descriptor{MediaType: m.MediaType, Digest: m.Digest, Size: int64(len(m.Content))}
+}
+
+// fields decodes the references of the manifest
+func (m *Manifest) fields() (*manifestFields, error) {
+	var fields manifestFields
+	if err := json.Unmarshal(m.Content, &fields); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", m.Digest, err)
+	}
+	return &fields, nil
+}
+
+// Client talks to a registry with the OCI distribution API, authenticating with token or basic authentication
+type Client struct {
+	host       string
+	baseURL    string
+	auth       registry.AuthConfig
+	httpClient *http.Client
+
+	mu sync.Mutex
+	// tokens are the bearer tokens obtained for each repository
+	tokens map[string]string
+	// basic is true once the registry asked for basic authentication
+	basic bool
+}
+
+// NewClient creates a client for the registry served on host. Local registries are reached over HTTP,
+// as the Docker daemon does, others over HTTPS.
+func NewClient(host string, auth registry.AuthConfig) *Client {
+	scheme := "https"
+	hostname := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		hostname = h
+	}
+	if hostname == "localhost" || net.ParseIP(hostname).IsLoopback() {
+		scheme = "http"
+	}
+
+	return &Client{
+		host:       host,
+		baseURL:    scheme + "://" + host,
+		auth:       auth,
+		httpClient: &http.Client{},
+		tokens:     make(map[string]string),
+	}
+}
+
+// Host returns the registry host, as found in image references
+func (c *Client) Host() string {
+	return c.host
+}
+
+// GetManifest fetches a manifest by tag or digest
+func (c *Client) GetManifest(ctx context.Context, repository, reference string) (*Manifest, error) {
+	resp, err := c.do(ctx, repository, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url(repository, "manifests", reference), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp, http.StatusOK); err != nil {
+		return nil, fmt.Errorf("failed to get manifest %s:%s: %w", repository, reference, err)
+	}
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest %s:%s: %w", repository, reference, err)
+	}
+	manifest := &Manifest{Digest: digest.FromBytes(content), Content: content}
+	if expected, err := digest.Parse(reference); err == nil && expected != manifest.Digest {
+		return nil, fmt.Errorf("manifest %s of %s has digest %s", reference, repository, manifest.Digest)
+	}
+
+	manifest.MediaType, _, _ = mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if fields, err := manifest.fields(); err == nil && fields.MediaType != "" {
+		manifest.MediaType = fields.MediaType
+	}
+	return manifest, nil
+}
+
+// PutManifest pushes a manifest under a tag or its digest, and checks the registry kept its digest
+func (c *Client) PutManifest(ctx context.Context, repository, reference string, manifest *Manifest) error {
+	resp, err := c.do(ctx, repository, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.url(repository, "manifests", reference), bytes.NewReader(manifest.Content))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", manifest.MediaType)
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp, http.StatusCreated); err != nil {
+		return fmt.Errorf("failed to push manifest %s:%s: %w", repository, reference, err)
+	}
+
+	if stored := resp.Header.Get("Docker-Content-Digest"); stored != "" && stored != manifest.Digest.String() {
+		return fmt.Errorf("registry stored manifest %s:%s as %s instead of %s", repository, reference, stored, manifest.Digest)
+	}
+	return nil
+}
+
+// Referrers returns the descriptors of the artifacts referring to a manifest, with the referrers API
+// or, for registries not supporting it, the sha256-<digest> tag of the referrers tag schema
+func (c *Client) Referrers(ctx context.Context, repository string, subject digest.Digest) ([]ocispec.Descriptor, error) {
+	resp, err := c.do(ctx, repository, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url(repository, "referrers", subject.String()), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", ocispec.MediaTypeImageIndex)
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var index ocispec.Index
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if err := json.NewDecoder(resp.Body).Decode(&index); err != nil {
+			return nil, fmt.Errorf("invalid referrers of %s@%s: %w", repository, subject, err)
+		}
+	case http.StatusNotFound:
+		fallback, err := c.GetManifest(ctx, repository, fmt.Sprintf("%s-%s", subject.Algorithm(), subject.Encoded()))
+		if errors.Is(err, ErrNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(fallback.Content, &index); err != nil {
+			return nil, fmt.Errorf("invalid referrers of %s@%s: %w", repository, subject, err)
+		}
+	default:
+		return nil, fmt.Errorf("failed to list referrers of %s@%s: %w", repository, subject, checkResponse(resp, http.StatusOK))
+	}
+	return index.Manifests, nil
+}
+
+// BlobExists checks if a blob is stored in the repository
+func (c *Client) BlobExists(ctx context.Context, repository string, dgst digest.Digest) (bool, error) {
+	resp, err := c.do(ctx, repository, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodHead, c.url(repository, "blobs", dgst.String()), nil)
+	})
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("failed to check blob %s@%s: %w", repository, dgst, checkResponse(resp, http.StatusOK))
+	}
+}
+
+// GetBlob returns the content of a blob, to be closed by the caller
+func (c *Client) GetBlob(ctx context.Context, repository string, dgst digest.Digest) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, repository, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodGet, c.url(repository, "blobs", dgst.String()), nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp, http.StatusOK); err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to get blob %s@%s: %w", repository, dgst, err)
+	}
+	return resp.Body, nil
+}
+
+// PushBlob uploads a blob in a single request unless the repository already holds it.
+// open returns the content of the blob, it may be called again if the registry asks to authenticate.
+func (c *Client) PushBlob(ctx context.Context, repository string, desc ocispec.Descriptor, open func() (io.ReadCloser, error)) error {
+	exists, err := c.BlobExists(ctx, repository, desc.Digest)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	resp, err := c.do(ctx, repository, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodPost, c.url(repository, "blobs", "uploads/"), nil)
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if err := checkResponse(resp, http.StatusAccepted); err != nil {
+		return fmt.Errorf("failed to start upload of blob %s@%s: %w", repository, desc.Digest, err)
+	}
+
+	location, err := resp.Request.URL.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return fmt.Errorf("invalid upload location for blob %s@%s: %w", repository, desc.Digest, err)
+	}
+	query := location.Query()
+	query.Set("digest", desc.Digest.String())
+	location.RawQuery = query.Encode()
+
+	resp, err = c.do(ctx, repository, func() (*http.Request, error) {
+		content, err := open()
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, location.String(), content)
+		if err != nil {
+			content.Close()
+			return nil, err
+		}
+		req.ContentLength = desc.Size
+		req.Header.Set("Content-Type", "application/octet-stream")
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp, http.StatusCreated); err != nil {
+		return fmt.Errorf("failed to upload blob %s@%s: %w", repository, desc.Digest, err)
+	}
+	return nil
+}
+
+// url returns the URL of an endpoint of a repository
+func (c *Client) url(repository, endpoint, reference string) string {
+	return fmt.Sprintf("%s/v2/%s/%s/%s", c.baseURL, repository, endpoint, reference)
+}
+
+// do sends the request built by newRequest, authenticating and sending it again if the registry asks to
+func (c *Client) do(ctx context.Context, repository string, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+		c.authorize(req, repository)
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusUnauthorized || attempt > 0 {
+			return resp, nil
+		}
+
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if err := c.authenticate(ctx, repository, challenge); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// authorize adds the credentials obtained for the repository to a request
+func (c *Client) authorize(req *http.Request, repository string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if token, ok := c.tokens[repository]; ok {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if c.basic {
+		req.SetBasicAuth(c.auth.Username, c.auth.Password)
+	}
+}
+
+// authenticate answers an authentication challenge of the registry
+func (c *Client) authenticate(ctx context.Context, repository, challenge string) error {
+	scheme, params := parseChallenge(challenge)
+	switch scheme {
+	case "basic":
+		c.mu.Lock()
+		c.basic = true
+		c.mu.Unlock()
+		return nil
+	case "bearer":
+		token, err := c.fetchToken(ctx, params)
+		if err != nil {
+			return fmt.Errorf("failed to authenticate to registry %s: %w", c.host, err)
+		}
+		c.mu.Lock()
+		c.tokens[repository] = token
+		c.mu.Unlock()
+		return nil
+	default:
+		return fmt.Errorf("registry %s asked for unsupported authentication %q", c.host, challenge)
+	}
+}
+
+// fetchToken gets a bearer token from the token service of the registry
+func (c *Client) fetchToken(ctx context.Context, params map[string]string) (string, error) {
+	if c.auth.RegistryToken != "" {
+		return c.auth.RegistryToken, nil
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", fmt.Errorf("invalid token realm %q", params["realm"])
+	}
+	query := url.Values{}
+	for _, key := range []string{"service", "scope"} {
+		if params[key] != "" {
+			query.Set(key, params[key])
+		}
+	}
+
+	var req *http.Request
+	if c.auth.IdentityToken != "" {
+		// Identity tokens stored by the Docker CLI are OAuth2 refresh tokens
+		query.Set("grant_type", "refresh_token")
+		query.Set("refresh_token", c.auth.IdentityToken)
+		query.Set("client_id", "migraptor")
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, realm.String(), strings.NewReader(query.Encode()))
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		realm.RawQuery = query.Encode()
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+		if err != nil {
+			return "", err
+		}
+		if c.auth.Username != "" {
+			req.SetBasicAuth(c.auth.Username, c.auth.Password)
+		}
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp, http.StatusOK); err != nil {
+		return "", err
+	}
+
+	var answer struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+		return "", fmt.Errorf("invalid token response: %w", err)
+	}
+	if answer.Token != "" {
+		return answer.Token, nil
+	}
+	if answer.AccessToken != "" {
+		return answer.AccessToken, nil
+	}
+	return "", errors.New("token service returned no token")
+}
+
+// parseChallenge returns the lowercase scheme and the parameters of a WWW-Authenticate header.
+// Quoted values may contain commas, as the scopes of token challenges do.
+func parseChallenge(header string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	params := make(map[string]string)
+
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimLeft(rest, ", ") {
+		key, value, ok := strings.Cut(rest, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		if strings.HasPrefix(value, `"`) {
+			end := strings.Index(value[1:], `"`)
+			if end < 0 {
+				params[key] = value[1:]
+				break
+			}
+			params[key], rest = value[1:end+1], value[end+2:]
+		} else {
+			params[key], rest, _ = strings.Cut(value, ",")
+		}
+	}
+	return strings.ToLower(scheme), params
+}
+
+// checkResponse returns the error reported by the registry if the status code is not the expected one
+func checkResponse(resp *http.Response, expected int) error {
+	if resp.StatusCode == expected {
+		return nil
+	}
+
+	var body struct {
+		Errors []struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	var messages []string
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err == nil {
+		for _, e := range body.Errors {
+			messages = append(messages, strings.TrimSpace(e.Code+" "+e.Message))
+		}
+	}
+
+	err := fmt.Errorf("registry returned %s", resp.Status)
+	if len(messages) > 0 {
+		err = fmt.Errorf("registry returned %s: %s", resp.Status, strings.Join(messages, ", "))
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+	return err
+}
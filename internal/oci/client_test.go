@@ -0,0 +1,90 @@
+package oci
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/registry"
+)
+
+func TestParseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(`Bearer realm="https://gitlab.example.com/jwt/auth",service="container_registry",scope="repository:team/app:pull,push"`)
+	if scheme != "bearer" {
+		t.Errorf("Expected scheme bearer, got %s", scheme)
+	}
+	expected := map[string]string{
+		"realm":   "https://gitlab.example.com/jwt/auth",
+		"service": "container_registry",
+		"scope":   "repository:team/app:pull,push",
+	}
+	for key, value := range expected {
+		if params[key] != value {
+			t.Errorf("Expected %s to be %q, got %q", key, value, params[key])
+		}
+	}
+
+	scheme, params = parseChallenge(`Basic realm=registry`)
+	if scheme != "basic" || params["realm"] != "registry" {
+		t.Errorf("Expected basic challenge with realm registry, got %s %v", scheme, params)
+	}
+}
+
+func TestClient_TokenAuthentication(t *testing.T) {
+	var tokenRequests int
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	mux.HandleFunc("/jwt/auth", func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests++
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "alice" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("scope") != "repository:team/app:pull" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, `{"token":"registry-token"}`)
+	})
+	mux.HandleFunc("/v2/team/app/manifests/1.0", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer registry-token" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/jwt/auth",service="container_registry",scope="repository:team/app:pull"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", MediaTypeDockerManifest)
+		fmt.Fprint(w, `{"schemaVersion":2,"config":{"mediaType":"application/vnd.docker.container.image.v1+json"}}`)
+	})
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	client := NewClient(host, registry.AuthConfig{Username: "alice", Password: "secret"})
+	for range 2 {
+		manifest, err := client.GetManifest(t.Context(), "team/app", "1.0")
+		if err != nil {
+			t.Fatalf("GetManifest failed: %v", err)
+		}
+		if manifest.MediaType != MediaTypeDockerManifest {
+			t.Errorf("Expected media type %s, got %s", MediaTypeDockerManifest, manifest.MediaType)
+		}
+	}
+	if tokenRequests != 1 {
+		t.Errorf("Expected the token to be requested once, got %d", tokenRequests)
+	}
+
+	// Wrong credentials are reported
+	client = NewClient(host, registry.AuthConfig{Username: "alice", Password: "wrong"})
+	if _, err := client.GetManifest(t.Context(), "team/app", "1.0"); err == nil {
+		t.Error("Expected authentication error")
+	}
+
+	// Missing manifests are reported as not found
+	if _, err := NewClient(host, registry.AuthConfig{}).GetManifest(t.Context(), "team/app", "2.0"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,225 @@
+package oci
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// annotationReferrerOf marks the index entries of the artifacts referring to an image, with the image reference as value
+const annotationReferrerOf = "io.migraptor.referrer.of"
+
+// Layout is an OCI image layout on disk. Images are recorded in its index under their full reference,
+// along with the artifacts referring to them.
+type Layout struct {
+	dir string
+
+	mu    sync.Mutex
+	index ocispec.Index
+}
+
+// OpenLayout opens the image layout in dir, creating it if needed
+func OpenLayout(dir string) (*Layout, error) {
+	if err := os.MkdirAll(filepath.Join(dir, ocispec.ImageBlobsDir, string(digest.SHA256)), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create image layout %s: %w", dir, err)
+	}
+
+	layoutFile := filepath.Join(dir, ocispec.ImageLayoutFile)
+	if _, err := os.Stat(layoutFile); errors.Is(err, os.ErrNotExist) {
+		content, err := json.Marshal(ocispec.ImageLayout{Version: ocispec.ImageLayoutVersion})
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(layoutFile, content, 0644); err != nil {
+			return nil, fmt.Errorf("failed to create image layout %s: %w", dir, err)
+		}
+	}
+
+	l := &Layout{dir: dir}
+	l.index.SchemaVersion = 2
+	l.index.MediaType = ocispec.MediaTypeImageIndex
+
+	content, err := os.ReadFile(filepath.Join(dir, ocispec.ImageIndexFile))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read image layout %s: %w", dir, err)
+	default:
+		if err := json.Unmarshal(content, &l.index); err != nil {
+			return nil, fmt.Errorf("invalid index in image layout %s: %w", dir, err)
+		}
+	}
+	return l, nil
+}
+
+// blobPath returns the path of a blob in the layout
+func (l *Layout) blobPath(dgst digest.Digest) string {
+	return filepath.Join(l.dir, ocispec.ImageBlobsDir, string(dgst.Algorithm()), dgst.Encoded())
+}
+
+// HasBlob checks if a blob is stored in the layout
+func (l *Layout) HasBlob(dgst digest.Digest) bool {
+	_, err := os.Stat(l.blobPath(dgst))
+	return err == nil
+}
+
+// WriteBlob stores a blob, checking its content matches its digest
+func (l *Layout) WriteBlob(dgst digest.Digest, content io.Reader) error {
+	if err := dgst.Validate(); err != nil {
+		return fmt.Errorf("invalid digest %q: %w", dgst, err)
+	}
+	path := l.blobPath(dgst)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-"+dgst.Encoded())
+	if err != nil {
+		return fmt.Errorf("failed to store blob %s: %w", dgst, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	verifier := dgst.Verifier()
+	_, err = io.Copy(io.MultiWriter(tmp, verifier), content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store blob %s: %w", dgst, err)
+	}
+	if !verifier.Verified() {
+		return fmt.Errorf("content of blob %s does not match its digest", dgst)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// OpenBlob returns the content of a blob, to be closed by the caller
+func (l *Layout) OpenBlob(dgst digest.Digest) (io.ReadCloser, error) {
+	return os.Open(l.blobPath(dgst))
+}
+
+// ReadBlob returns the content of a small blob, such as a manifest
+func (l *Layout) ReadBlob(dgst digest.Digest) ([]byte, error) {
+	return os.ReadFile(l.blobPath(dgst))
+}
+
+// Set records an image and the artifacts referring to it under a reference, replacing any previous record
+func (l *Layout) Set(ref string, image ocispec.Descriptor, referrers []ocispec.Descriptor) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.index.Manifests = slices.DeleteFunc(l.index.Manifests, func(desc ocispec.Descriptor) bool {
+		return desc.Annotations[ocispec.AnnotationRefName] == ref || desc.Annotations[annotationReferrerOf] == ref
+	})
+
+	image.Annotations = map[string]string{ocispec.AnnotationRefName: ref}
+	l.index.Manifests = append(l.index.Manifests, image)
+	for _, referrer := range referrers {
+		referrer.Annotations = map[string]string{annotationReferrerOf: ref}
+		l.index.Manifests = append(l.index.Manifests, referrer)
+	}
+	return l.writeIndex()
+}
+
+// Get returns the image recorded under a reference and the artifacts referring to it
+func (l *Layout) Get(ref string) (*ocispec.Descriptor, []ocispec.Descriptor, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var image *ocispec.Descriptor
+	var referrers []ocispec.Descriptor
+	for _, desc := range l.index.Manifests {
+		switch {
+		case desc.Annotations[ocispec.AnnotationRefName] == ref:
+			image = &desc
+		case desc.Annotations[annotationReferrerOf] == ref:
+			referrers = append(referrers, desc)
+		}
+	}
+	return image, referrers, image != nil
+}
+
+// Delete removes the record of an image and the blobs no other record references
+func (l *Layout) Delete(ref string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.index.Manifests = slices.DeleteFunc(l.index.Manifests, func(desc ocispec.Descriptor) bool {
+		return desc.Annotations[ocispec.AnnotationRefName] == ref || desc.Annotations[annotationReferrerOf] == ref
+	})
+	if err := l.writeIndex(); err != nil {
+		return err
+	}
+
+	referenced := make(map[digest.Digest]bool)
+	for _, desc := range l.index.Manifests {
+		if err := l.addReferences(desc, referenced); err != nil {
+			return err
+		}
+	}
+
+	blobsDir := filepath.Join(l.dir, ocispec.ImageBlobsDir, string(digest.SHA256))
+	entries, err := os.ReadDir(blobsDir)
+	if err != nil {
+		return fmt.Errorf("failed to list blobs of image layout %s: %w", l.dir, err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || referenced[digest.NewDigestFromEncoded(digest.SHA256, entry.Name())] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(blobsDir, entry.Name())); err != nil {
+			return fmt.Errorf("failed to remove blob %s: %w", entry.Name(), err)
+		}
+	}
+	return nil
+}
+
+// addReferences marks a manifest and all the manifests and blobs it references as referenced
+func (l *Layout) addReferences(desc ocispec.Descriptor, referenced map[digest.Digest]bool) error {
+	referenced[desc.Digest] = true
+	if !slices.Contains(manifestMediaTypes, desc.MediaType) {
+		return nil
+	}
+
+	content, err := l.ReadBlob(desc.Digest)
+	if err != nil {
+		return fmt.Errorf("manifest %s missing from image layout %s: %w", desc.Digest, l.dir, err)
+	}
+	manifest := &Manifest{MediaType: desc.MediaType, Digest: desc.Digest, Content: content}
+	fields, err := manifest.fields()
+	if err != nil {
+		return err
+	}
+
+	referenced[fields.Config.Digest] = true
+	for _, layer := range fields.Layers {
+		referenced[layer.Digest] = true
+	}
+	for _, child := range fields.Manifests {
+		if err := l.addReferences(child, referenced); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeIndex saves the index of the layout
+func (l *Layout) writeIndex() error {
+	content, err := json.MarshalIndent(l.index, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(l.dir, ocispec.ImageIndexFile), content, 0644); err != nil {
+		return fmt.Errorf("failed to write index of image layout %s: %w", l.dir, err)
+	}
+	return nil
+}
@@ -1,14 +1,20 @@
 package ui
 
 import (
+	"context"
 	"fmt"
-	"migraptor/internal/gitlab"
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	gitlabCore "gitlab.com/gitlab-org/api/client-go"
 )
 
+// TagDeleter deletes tags from a registry repository
+type TagDeleter interface {
+	DeleteRegistryRepositoryTag(ctx context.Context, projectID, repositoryID int, tagName string) (*gitlabCore.Response, error)
+}
+
 // ImageItem holds image information with project/registry context for selection UI
 type ImageItem struct {
 	ImageInfo    ImageInfo
@@ -61,7 +67,7 @@ type ImageSelectorModel struct {
 	images          []ImageItem
 	tree            []*TreeNode
 	cursor          int
-	gitlabClient    *gitlab.Client
+	gitlabClient    TagDeleter
 	dryRun          bool
 	showConfirm     bool
 	confirmMsg      string
@@ -76,7 +82,7 @@ type ImageSelectorModel struct {
 }
 
 // NewImageSelectorModel creates a new image selector model
-func NewImageSelectorModel(images []ImageItem, gitlabClient *gitlab.Client, dryRun bool) *ImageSelectorModel {
+func NewImageSelectorModel(images []ImageItem, gitlabClient TagDeleter, dryRun bool) *ImageSelectorModel {
 	model := &ImageSelectorModel{
 		images:          images,
 		gitlabClient:    gitlabClient,
@@ -631,7 +637,7 @@ func (m *ImageSelectorModel) deleteSelected() tea.Cmd {
 			if m.dryRun {
 				deletedCount++
 			} else {
-				_, err := m.gitlabClient.DeleteRegistryRepositoryTag(img.ProjectID, img.RegistryID, img.ImageInfo.Name)
+				_, err := m.gitlabClient.DeleteRegistryRepositoryTag(context.Background(), img.ProjectID, img.RegistryID, img.ImageInfo.Name)
 				if err != nil {
 					failedCount++
 				} else {
@@ -2,10 +2,13 @@ package ui
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"migraptor/internal/config"
 	"os"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fatih/color"
@@ -29,12 +32,23 @@ var (
 	logFile *os.File
 	logger  *log.Logger
 	verbose bool
+
+	// secrets are replaced by a mask in the log
+	secrets   []string
+	secretsMu sync.RWMutex
 )
 
+// secretMask replaces secrets in the log
+const secretMask = "********"
+
+// gitlabTokenPrefixes are the fixed prefixes of GitLab tokens, telling their kind
+var gitlabTokenPrefixes = []string{"glpat-", "gldt-", "gloas-", "glrt-", "glptt-", "glft-", "glimt-", "glagent-", "glcbt-"}
+
 type UI struct {
 	verbose bool
 	logFile *os.File
 	logger  *log.Logger
+	sleep   func(time.Duration)
 }
 
 // Init initializes the UI system with logging
@@ -53,7 +67,7 @@ func Init(verboseMode bool) (*UI, error) {
 	for _, logPath := range logPaths {
 		logFile, err = os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 		if err == nil {
-			logger = log.New(logFile, "", log.LstdFlags)
+			logger = log.New(maskingWriter{logFile}, "", log.LstdFlags)
 			break
 		}
 	}
@@ -61,7 +75,7 @@ func Init(verboseMode bool) (*UI, error) {
 	// If all paths fail, use stderr as fallback
 	if logFile == nil {
 		yellow.Printf("⚠️  Warning: Could not open log file, using stderr instead\n")
-		logger = log.New(os.Stderr, "", log.LstdFlags)
+		logger = log.New(maskingWriter{os.Stderr}, "", log.LstdFlags)
 	}
 
 	ui := &UI{
@@ -79,6 +93,67 @@ func Init(verboseMode bool) (*UI, error) {
 	return ui, nil
 }
 
+// New creates a UI logging to the given writer, without log file nor welcome message
+func New(verboseMode bool, logWriter io.Writer) *UI {
+	verbose = verboseMode
+	logger = log.New(maskingWriter{logWriter}, "", log.LstdFlags)
+
+	return &UI{
+		verbose: verboseMode,
+		logger:  logger,
+	}
+}
+
+// SetConsoleOutput sends the console messages to w instead of stdout, so that stdout only receives a report
+func SetConsoleOutput(w io.Writer) {
+	color.Output = w
+}
+
+// MaskSecrets registers secrets, such as tokens, to replace by a mask in the log
+func MaskSecrets(values ...string) {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	for _, value := range values {
+		if value != "" && !slices.Contains(secrets, value) {
+			secrets = append(secrets, value)
+		}
+	}
+}
+
+// maskingWriter replaces the registered secrets in what it writes
+type maskingWriter struct {
+	w io.Writer
+}
+
+func (m maskingWriter) Write(p []byte) (int, error) {
+	secretsMu.RLock()
+	masked := string(p)
+	for _, secret := range secrets {
+		masked = strings.ReplaceAll(masked, secret, secretMask)
+	}
+	secretsMu.RUnlock()
+	if _, err := io.WriteString(m.w, masked); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// MaskSecret returns a secret to display, entirely masked. Only the prefix of GitLab tokens is kept,
+// to recognise the kind of token used.
+func MaskSecret(value string) string {
+	for _, prefix := range gitlabTokenPrefixes {
+		if len(value) > len(prefix) && strings.HasPrefix(value, prefix) {
+			return prefix + secretMask
+		}
+	}
+	return secretMask
+}
+
+// SetSleep replaces the function used by SleepWithLog to wait
+func (ui *UI) SetSleep(sleep func(time.Duration)) {
+	ui.sleep = sleep
+}
+
 // Close closes the log file
 func Close() error {
 	if logFile != nil {
@@ -103,7 +178,7 @@ func (ui *UI) Debug(format string, args ...interface{}) {
 
 // Info prints informational messages
 func (ui *UI) Info(format string, args ...interface{}) {
-	fmt.Printf(format+"\n", args...)
+	fmt.Fprintf(color.Output, format+"\n", args...)
 	logger.Printf("[INFO] "+format, args...)
 }
 
@@ -175,10 +250,39 @@ func (ui *UI) PrintMigrationStart(config *config.Config) {
 	}
 	cyan.Printf(" 🐳 Registry URL: ")
 	lightBlue.Printf("%s\n", config.GitLabRegistry)
+	printCredentials(config)
 	if len(config.ProjectsList) > 0 {
 		cyan.Printf(" 📋 Project filtered list: ")
 		lightBlue.Printf("%s\n", config.ProjectsList)
 	}
+	if len(config.Include) > 0 {
+		cyan.Printf(" ✅ Include patterns: ")
+		lightBlue.Printf("%s\n", config.Include)
+	}
+	if len(config.Exclude) > 0 {
+		cyan.Printf(" 🚫 Exclude patterns: ")
+		lightBlue.Printf("%s\n", config.Exclude)
+	}
+	if len(config.Topics) > 0 {
+		cyan.Printf(" 🏷️ Topics: ")
+		lightBlue.Printf("%s\n", config.Topics)
+	}
+	if config.ActiveSince != "" {
+		cyan.Printf(" 🕒 Active since: ")
+		lightBlue.Printf("%s\n", config.ActiveSince)
+	}
+	if config.InactiveSince != "" {
+		cyan.Printf(" 💤 Inactive since: ")
+		lightBlue.Printf("%s\n", config.InactiveSince)
+	}
+	if len(config.ExcludeGroups) > 0 {
+		cyan.Printf(" 🚧 Excluded sub-groups: ")
+		lightBlue.Printf("%s\n", config.ExcludeGroups)
+	}
+	if config.Archived != "" {
+		cyan.Printf(" 🗄️ Archived projects: ")
+		lightBlue.Printf("%s\n", config.Archived)
+	}
 	if len(config.TagsList) > 0 {
 		cyan.Printf(" 🔖 Image tag filters:")
 		lightBlue.Printf("%s\n", config.TagsList)
@@ -203,6 +307,21 @@ func (ui *UI) PrintMigrationStart(config *config.Config) {
 	cyan.Printf("🛫 Starting migration...\n")
 }
 
+// printCredentials prints the masked GitLab token and registry password of a summary
+func printCredentials(config *config.Config) {
+	cyan.Printf(" 🔑 GitLab token: ")
+	lightBlue.Printf("%s\n", MaskSecret(config.GitLabToken))
+	cyan.Printf(" 🔑 Registry login: ")
+	switch {
+	case config.DockerUser != "":
+		lightBlue.Printf("%s / %s\n", config.DockerUser, MaskSecret(config.DockerToken))
+	case config.DockerToken != config.GitLabToken:
+		lightBlue.Printf("%s\n", MaskSecret(config.DockerToken))
+	default:
+		lightBlue.Printf("GitLab token\n")
+	}
+}
+
 func (ui *UI) PrintCleanStart(config *config.Config) {
 	cyan.Printf("----------------------------------------\n")
 	cyan.Printf(" 🧼 GitLab Cleaning Command Summary\n")
@@ -211,6 +330,7 @@ func (ui *UI) PrintCleanStart(config *config.Config) {
 	lightBlue.Printf("%s/%s\n", config.GitLabInstance, config.OldGroupName)
 	cyan.Printf(" 🐳 Registry URL: ")
 	lightBlue.Printf("%s\n", config.GitLabRegistry)
+	printCredentials(config)
 	if config.Verbose {
 		lightYellow.Printf(" 🔬 DEBUG on\n")
 	}
@@ -303,6 +423,19 @@ func (ui *UI) PrintNoImagesAfterFilter(projectID string) {
 	yellow.Printf(" has no images in registry after filtering\n")
 }
 
+// PrintBackupEstimate prints the disk space estimate of an image backup
+func (ui *UI) PrintBackupEstimate(tags int, totalSize, localSize, requiredSize int64) {
+	cyan.Printf("💽 %d tags to pull: ", tags)
+	lightBlue.Printf("%s", FormatBytes(totalSize))
+	if localSize > 0 {
+		cyan.Printf(" (%s already present locally)", FormatBytes(localSize))
+	}
+	cyan.Printf(", ")
+	lightBlue.Printf("%s", FormatBytes(requiredSize))
+	cyan.Printf(" to download\n")
+	logger.Printf("[INFO] Backup estimate: %d tags, %d bytes, %d bytes local, %d bytes required", tags, totalSize, localSize, requiredSize)
+}
+
 // PrintPullingImages prints pulling images message
 func (ui *UI) PrintPullingImages() {
 	cyan.Printf("📩 Pulling existing images...\n")
@@ -320,11 +453,18 @@ func (ui *UI) PrintTagAndPush(newImage string) {
 	white.Printf("%s\n", newImage)
 }
 
-// PrintDockerNotStarted prints Docker not started error
-func (ui *UI) PrintDockerNotStarted() {
-	red.Printf("⛔️ Docker not started\n")
-	red.Printf("🐳 You must first start docker daemon.\n")
-	lightBlue.Printf("Use : sudo service docker start\n")
+// PrintDockerNotStarted prints container engine not started error
+func (ui *UI) PrintDockerNotStarted(engine string) {
+	red.Printf("⛔️ %s not started\n", engine)
+	red.Printf("🐳 You must first start the %s daemon.\n", engine)
+	switch engine {
+	case "Podman":
+		lightBlue.Printf("Use : systemctl --user start podman.socket\n")
+	case "containerd":
+		lightBlue.Printf("Use : sudo systemctl start containerd\n")
+	default:
+		lightBlue.Printf("Use : sudo service docker start\n")
+	}
 }
 
 // PrintDockerLoginSuccess prints Docker login success
@@ -408,6 +548,108 @@ func (ui *UI) PrintOptionNeedsArgument(option string) {
 	red.Printf("⛔️ Option %s needs a valid argument\n", option)
 }
 
+// PrintPreflightIssue prints an issue found during preflight checks
+func (ui *UI) PrintPreflightIssue(blocking bool, subject, message string) {
+	if blocking {
+		red.Printf("⛔️ ")
+		lightBlue.Printf("%s", subject)
+		red.Printf(": %s\n", message)
+		logger.Printf("[PREFLIGHT] BLOCKING %s: %s", subject, message)
+	} else {
+		yellow.Printf("⚠️ ")
+		lightBlue.Printf("%s", subject)
+		yellow.Printf(": %s\n", message)
+		logger.Printf("[PREFLIGHT] WARNING %s: %s", subject, message)
+	}
+}
+
+// PrintPreflightFailed prints preflight failure message
+func (ui *UI) PrintPreflightFailed() {
+	red.Printf("⛔️ Preflight checks failed, nothing has been changed.\n")
+	cyan.Printf("Fix the blocking issues above and re-run the migration\n")
+}
+
+// PrintInterrupted prints the interruption message of a migration
+func (ui *UI) PrintInterrupted() {
+	yellow.Printf("⏸️  Migration interrupted, the following steps remain:\n")
+	logger.Printf("[INTERRUPTED] Migration interrupted")
+}
+
+// PrintIncomplete prints the message of a migration which failed leaving backups to restore
+func (ui *UI) PrintIncomplete() {
+	yellow.Printf("⚠️  Migration incomplete, the following steps remain:\n")
+	logger.Printf("[INCOMPLETE] Migration incomplete")
+}
+
+// PrintRemainingSteps prints the steps remaining for a project of an interrupted or incomplete migration
+func (ui *UI) PrintRemainingSteps(projectName, steps string, images, packages []string) {
+	lightBlue.Printf("  %s", projectName)
+	yellow.Printf(": %s\n", steps)
+	for _, image := range images {
+		cyan.Printf("    🐳 %s to push\n", image)
+	}
+	for _, pkg := range packages {
+		cyan.Printf("    📚 %s to publish\n", pkg)
+	}
+	logger.Printf("[INTERRUPTED] %s: %s, %d images to push, %d packages to publish", projectName, steps, len(images), len(packages))
+}
+
+// BatchLine is a migration of a batch as printed in its summary
+type BatchLine struct {
+	Source      string
+	Destination string
+	// Status is "succeeded", "failed" or "skipped"
+	Status string
+	// Migrated is the number of projects migrated out of Projects
+	Migrated int
+	Projects int
+	Err      error
+}
+
+// PrintBatchSummary prints the outcome of each migration of a batch
+func (ui *UI) PrintBatchSummary(lines []BatchLine) {
+	cyan.Printf("----------------------------------------\n")
+	cyan.Printf(" 📋 Batch summary\n")
+	cyan.Printf("----------------------------------------\n")
+	counts := make(map[string]int)
+	for _, line := range lines {
+		counts[line.Status]++
+		switch line.Status {
+		case "succeeded":
+			green.Printf("✅ ")
+		case "failed":
+			red.Printf("❌ ")
+		default:
+			yellow.Printf("⏭️ ")
+		}
+		lightBlue.Printf("%s → %s", line.Source, line.Destination)
+		switch {
+		case line.Err != nil:
+			red.Printf(": %d/%d projects migrated, %v\n", line.Migrated, line.Projects, line.Err)
+		case line.Status == "skipped":
+			yellow.Printf(": skipped\n")
+		default:
+			cyan.Printf(": %d/%d projects migrated\n", line.Migrated, line.Projects)
+		}
+		logger.Printf("[BATCH] %s → %s: %s, %d/%d projects migrated, error: %v", line.Source, line.Destination, line.Status, line.Migrated, line.Projects, line.Err)
+	}
+	cyan.Printf("%d succeeded, %d failed, %d skipped\n", counts["succeeded"], counts["failed"], counts["skipped"])
+}
+
+// FormatBytes formats a size in bytes into a human readable string
+func FormatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
 // LogToFile logs a message to the log file
 func (ui *UI) LogToFile(format string, args ...interface{}) {
 	if logger != nil {
@@ -420,6 +662,10 @@ func (ui *UI) SleepWithLog(duration time.Duration) {
 	if verbose {
 		ui.Debug("Sleeping for %v", duration)
 	}
+	if ui.sleep != nil {
+		ui.sleep(duration)
+		return
+	}
 	time.Sleep(duration)
 }
 
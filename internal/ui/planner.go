@@ -0,0 +1,684 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"migraptor/internal/config"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	groupStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color("39")).Bold(true)
+	sizeStyle        = lipgloss.NewStyle().Foreground(lipgloss.Color("244"))
+	destinationStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("81")).Bold(true)
+	inputStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color("212")).Underline(true)
+)
+
+// PlannerGroup is a group of the source tree shown by the migration planner.
+// An unticked sub-group is left in place with its content.
+type PlannerGroup struct {
+	FullPath string
+	Groups   []*PlannerGroup
+	Projects []*PlannerProject
+	Selected bool
+}
+
+// PlannerProject is a project of the source tree shown by the migration planner
+type PlannerProject struct {
+	Path              string
+	PathWithNamespace string
+	NamespacePath     string
+	Archived          bool
+	Repositories      []PlannerRepository
+	Selected          bool
+}
+
+// PlannerRepository is a registry repository of a project
+type PlannerRepository struct {
+	// Path is the path of the repository, starting with the path with namespace of its project
+	Path string
+	Tags int
+	Size int64
+}
+
+// Size returns the size of the registry repositories of the project
+func (p *PlannerProject) Size() int64 {
+	var size int64
+	for _, repo := range p.Repositories {
+		size += repo.Size
+	}
+	return size
+}
+
+// Size returns the size of the registry repositories of the projects of the group and its sub-groups
+func (g *PlannerGroup) Size() int64 {
+	var size int64
+	for _, project := range g.Projects {
+		size += project.Size()
+	}
+	for _, group := range g.Groups {
+		size += group.Size()
+	}
+	return size
+}
+
+// PlannerDestinationFunc returns the full path a project gets once migrated to destination
+type PlannerDestinationFunc func(project *PlannerProject, destination string, keepParent bool) string
+
+// PlannerOptions are the settings the planner starts from
+type PlannerOptions struct {
+	// Destination is the full path of the group receiving the projects
+	Destination string
+	// Destinations are the groups suggested while typing the destination
+	Destinations []string
+	KeepParent   bool
+	// Registry is the host of the container registry, to preview image renames
+	Registry        string
+	DestinationPath PlannerDestinationFunc
+	DryRun          bool
+}
+
+// PlannerChoice is what was chosen in the planner
+type PlannerChoice struct {
+	// Confirmed is false when the planner was left without migrating
+	Confirmed   bool
+	Destination string
+	KeepParent  bool
+	// AllSelected is true when every project and sub-group is ticked
+	AllSelected bool
+	// Projects are the paths with namespace of the ticked projects
+	Projects []string
+	// ExcludedGroups are the full paths of the unticked sub-groups whose parent is ticked
+	ExcludedGroups []string
+}
+
+// Apply replaces the destination and the project rules of a configuration with the choice:
+// ticked projects are included by their path with namespace and unticked sub-groups are excluded
+func (c *PlannerChoice) Apply(cfg *config.Config) {
+	cfg.NewGroupName = c.Destination
+	cfg.KeepParent = c.KeepParent
+	cfg.ProjectsList = nil
+	cfg.Include = nil
+	cfg.Exclude = nil
+	cfg.Topics = nil
+	cfg.ActiveSince = ""
+	cfg.InactiveSince = ""
+	cfg.Archived = ""
+	cfg.ExcludeGroups = nil
+	if c.AllSelected {
+		return
+	}
+	cfg.Include = c.Projects
+	cfg.ExcludeGroups = c.ExcludedGroups
+}
+
+// plannerNode is a visible line of the tree, a group or a project
+type plannerNode struct {
+	group   *PlannerGroup
+	project *PlannerProject
+	depth   int
+}
+
+// PlannerModel represents the bubbletea model of the migration planner
+type PlannerModel struct {
+	root      *PlannerGroup
+	opts      PlannerOptions
+	collapsed map[*PlannerGroup]bool
+	cursor    int
+	// offset is the first line shown by the preview
+	offset int
+
+	destination     string
+	keepParent      bool
+	editing         bool
+	input           string
+	suggestion      int
+	showPreview     bool
+	showConfirm     bool
+	showQuitConfirm bool
+	confirmed       bool
+
+	width  int
+	height int
+}
+
+// NewPlannerModel creates a new planner model over the tree of the source group
+func NewPlannerModel(root *PlannerGroup, opts PlannerOptions) *PlannerModel {
+	return &PlannerModel{
+		root:        root,
+		opts:        opts,
+		collapsed:   make(map[*PlannerGroup]bool),
+		destination: strings.Trim(opts.Destination, "/"),
+		keepParent:  opts.KeepParent,
+		suggestion:  -1,
+	}
+}
+
+// Init initializes the model
+func (m *PlannerModel) Init() tea.Cmd {
+	return nil
+}
+
+// Update handles messages and updates the model
+func (m *PlannerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.WindowSizeMsg); ok {
+		m.width = msg.Width
+		m.height = msg.Height
+		return m, nil
+	}
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	switch {
+	case m.showQuitConfirm:
+		return m.updateQuitConfirm(key)
+	case m.showConfirm:
+		return m.updateConfirm(key)
+	case m.editing:
+		return m.updateDestination(key)
+	}
+
+	switch key.String() {
+	case "ctrl+c", "q":
+		m.showQuitConfirm = true
+	case "up", "k":
+		m.move(-1)
+	case "down", "j":
+		m.move(1)
+	case " ":
+		if !m.showPreview {
+			m.toggleSelection()
+		}
+	case "enter":
+		if !m.showPreview {
+			m.toggleExpand()
+		}
+	case "tab":
+		m.toggleExpandAll()
+	case "d":
+		m.editing = true
+		m.input = m.destination
+		m.suggestion = -1
+	case "p":
+		m.keepParent = !m.keepParent
+	case "v":
+		m.showPreview = !m.showPreview
+		m.offset = 0
+	case "m":
+		if m.destination != "" && len(m.selectedProjects()) > 0 {
+			m.showConfirm = true
+		}
+	}
+	return m, nil
+}
+
+// updateConfirm handles the confirmation of the migration
+func (m *PlannerModel) updateConfirm(key tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch key.String() {
+	case "y", "Y":
+		m.showConfirm = false
+		m.confirmed = true
+		return m, tea.Quit
+	case "n", "N", "esc":
+		m.showConfirm = false
+	}
+	return m, nil
+}
+
+// updateQuitConfirm handles the confirmation of leaving the planner without migrating
+func (m *PlannerModel) updateQuitConfirm(key tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch key.String() {
+	case "y", "Y":
+		m.showQuitConfirm = false
+		return m, tea.Quit
+	case "n", "N", "esc":
+		m.showQuitConfirm = false
+	}
+	return m, nil
+}
+
+// updateDestination edits the destination. Tab and the arrows cycle through the suggested groups matching the input.
+func (m *PlannerModel) updateDestination(key tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch key.Type {
+	case tea.KeyEnter:
+		m.editing = false
+		m.destination = strings.Trim(strings.TrimSpace(m.currentInput()), "/")
+	case tea.KeyEsc, tea.KeyCtrlC:
+		m.editing = false
+	case tea.KeyTab, tea.KeyDown:
+		m.cycleSuggestion(1)
+	case tea.KeyShiftTab, tea.KeyUp:
+		m.cycleSuggestion(-1)
+	case tea.KeyBackspace:
+		m.input = m.currentInput()
+		m.suggestion = -1
+		if runes := []rune(m.input); len(runes) > 0 {
+			m.input = string(runes[:len(runes)-1])
+		}
+	case tea.KeyCtrlU:
+		m.input = ""
+		m.suggestion = -1
+	case tea.KeyRunes:
+		m.input = m.currentInput() + string(key.Runes)
+		m.suggestion = -1
+	}
+	return m, nil
+}
+
+// currentInput returns the suggestion picked, or the text typed if none is
+func (m *PlannerModel) currentInput() string {
+	suggestions := m.suggestions()
+	if m.suggestion >= 0 && m.suggestion < len(suggestions) {
+		return suggestions[m.suggestion]
+	}
+	return m.input
+}
+
+func (m *PlannerModel) cycleSuggestion(delta int) {
+	suggestions := m.suggestions()
+	if len(suggestions) == 0 {
+		return
+	}
+	m.suggestion = (m.suggestion + delta + len(suggestions) + 1) % (len(suggestions) + 1)
+	if m.suggestion == len(suggestions) {
+		m.suggestion = -1
+	}
+}
+
+// suggestions returns the suggested groups containing the text typed
+func (m *PlannerModel) suggestions() []string {
+	var suggestions []string
+	for _, destination := range m.opts.Destinations {
+		if strings.Contains(strings.ToLower(destination), strings.ToLower(m.input)) {
+			suggestions = append(suggestions, destination)
+		}
+	}
+	return suggestions
+}
+
+// Choice returns what was chosen in the planner
+func (m *PlannerModel) Choice() PlannerChoice {
+	choice := PlannerChoice{
+		Confirmed:   m.confirmed,
+		Destination: m.destination,
+		KeepParent:  m.keepParent,
+		AllSelected: true,
+	}
+	var traverse func(group *PlannerGroup)
+	traverse = func(group *PlannerGroup) {
+		for _, project := range group.Projects {
+			if project.Selected {
+				choice.Projects = append(choice.Projects, project.PathWithNamespace)
+			} else {
+				choice.AllSelected = false
+			}
+		}
+		for _, subGroup := range group.Groups {
+			if !subGroup.Selected {
+				choice.AllSelected = false
+				choice.ExcludedGroups = append(choice.ExcludedGroups, subGroup.FullPath)
+				continue
+			}
+			traverse(subGroup)
+		}
+	}
+	traverse(m.root)
+	slices.Sort(choice.Projects)
+	slices.Sort(choice.ExcludedGroups)
+	return choice
+}
+
+// View renders the UI
+func (m *PlannerModel) View() string {
+	if m.width == 0 {
+		return "Initializing..."
+	}
+
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("🗺️ GitLab Migration Planner"))
+	b.WriteString("\n\n")
+	b.WriteString(m.renderDestination())
+	b.WriteString("\n\n")
+
+	var lines []string
+	if m.showPreview {
+		lines = m.previewLines()
+	} else {
+		for i, node := range m.getFlatNodes() {
+			lines = append(lines, m.renderNode(node, i == m.cursor))
+		}
+	}
+	maxHeight := m.height - 12 // Reserve space for the destination, status bar and help
+	if m.editing {
+		maxHeight -= len(m.suggestions())
+	}
+	if maxHeight < 1 {
+		maxHeight = 1
+	}
+	start := 0
+	if m.showPreview {
+		start = min(m.offset, max(len(lines)-maxHeight, 0))
+	} else if m.cursor >= maxHeight {
+		start = m.cursor - maxHeight + 1
+	}
+	for i := start; i < len(lines) && i < start+maxHeight; i++ {
+		b.WriteString(lines[i])
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+
+	b.WriteString(statusBarStyle.Width(m.width).Render(m.renderStatusBar()))
+	b.WriteString("\n")
+	b.WriteString(helpStyle.Render(m.renderHelp()))
+
+	if m.showQuitConfirm {
+		b.WriteString("\n\n")
+		b.WriteString(confirmStyle.Render("Quit without migrating? (y/n)"))
+	} else if m.showConfirm {
+		b.WriteString("\n\n")
+		msg := fmt.Sprintf("Migrate %d project(s) to %s? (y/n)", len(m.selectedProjects()), m.destination)
+		if m.opts.DryRun {
+			msg = "DRY RUN: " + msg
+		}
+		b.WriteString(confirmStyle.Render(msg))
+	}
+	return b.String()
+}
+
+// renderDestination renders the destination, with the suggested groups while it is edited
+func (m *PlannerModel) renderDestination() string {
+	keepParent := "projects are moved into the destination"
+	if m.keepParent {
+		keepParent = fmt.Sprintf("the group is moved into the destination as %s", m.destinationRoot())
+	}
+	if !m.editing {
+		destination := m.destination
+		if destination == "" {
+			destination = "(none, press d to set it)"
+		}
+		return fmt.Sprintf("🛬 Destination: %s\n%s", destinationStyle.Render(destination), helpStyle.Render(keepParent))
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("🛬 Destination: %s", inputStyle.Render(m.currentInput()+"█")))
+	for i, suggestion := range m.suggestions() {
+		b.WriteString("\n   ")
+		if i == m.suggestion {
+			b.WriteString(selectedStyle.Render("▶ " + suggestion))
+		} else {
+			b.WriteString(unselectedStyle.Render("  " + suggestion))
+		}
+	}
+	return b.String()
+}
+
+// destinationRoot returns the full path the source group gets when it is kept
+func (m *PlannerModel) destinationRoot() string {
+	parts := strings.Split(m.root.FullPath, "/")
+	if m.destination == "" {
+		return parts[len(parts)-1]
+	}
+	return m.destination + "/" + parts[len(parts)-1]
+}
+
+// getFlatNodes returns a flat list of visible nodes
+func (m *PlannerModel) getFlatNodes() []plannerNode {
+	result := []plannerNode{{group: m.root}}
+	var traverse func(group *PlannerGroup, depth int)
+	traverse = func(group *PlannerGroup, depth int) {
+		if m.collapsed[group] {
+			return
+		}
+		for _, subGroup := range group.Groups {
+			result = append(result, plannerNode{group: subGroup, depth: depth})
+			traverse(subGroup, depth+1)
+		}
+		for _, project := range group.Projects {
+			result = append(result, plannerNode{project: project, depth: depth})
+		}
+	}
+	traverse(m.root, 1)
+	return result
+}
+
+// renderNode renders a single node
+func (m *PlannerModel) renderNode(node plannerNode, isCursor bool) string {
+	cursor := " "
+	if isCursor {
+		cursor = cursorStyle.Render("▶")
+	}
+	indent := strings.Repeat("  ", node.depth)
+
+	if node.group != nil {
+		expand := "▼"
+		if m.collapsed[node.group] {
+			expand = "▶"
+		}
+		checkbox := checkboxStyle.Render("☑")
+		if !node.group.Selected {
+			checkbox = checkboxEmptyStyle.Render("☐")
+		}
+		style := groupStyle
+		if !node.group.Selected {
+			style = unselectedStyle
+		}
+		if isCursor {
+			style = style.Underline(true)
+		}
+		return fmt.Sprintf("%s%s %s %s %s %s", cursor, indent, expand, checkbox, style.Render(node.group.FullPath),
+			sizeStyle.Render(FormatBytes(node.group.Size())))
+	}
+
+	project := node.project
+	checkbox := checkboxEmptyStyle.Render("☐")
+	style := imageStyle
+	if project.Selected {
+		checkbox = checkboxStyle.Render("☑")
+		style = selectedStyle
+	}
+	if isCursor {
+		style = style.Bold(true).Underline(true)
+	}
+	details := fmt.Sprintf("%s, %d repositories", FormatBytes(project.Size()), len(project.Repositories))
+	if project.Archived {
+		details += ", archived"
+	}
+	return fmt.Sprintf("%s%s   %s %s %s", cursor, indent, checkbox, style.Render(project.Path), sizeStyle.Render(details))
+}
+
+// previewLines lists the paths of the ticked projects and the images they rename once migrated
+func (m *PlannerModel) previewLines() []string {
+	if m.destination == "" {
+		return []string{helpStyle.Render("Press d to set the destination")}
+	}
+	projects := m.selectedProjects()
+	if len(projects) == 0 {
+		return []string{helpStyle.Render("No project is ticked")}
+	}
+
+	var lines []string
+	for _, project := range projects {
+		destination := m.opts.DestinationPath(project, m.destination, m.keepParent)
+		lines = append(lines, fmt.Sprintf("%s → %s", projectStyle.Render(project.PathWithNamespace), destinationStyle.Render(destination)))
+		for _, repo := range project.Repositories {
+			newPath := destination + strings.TrimPrefix(repo.Path, project.PathWithNamespace)
+			lines = append(lines, fmt.Sprintf("    🐳 %s/%s → %s/%s %s", m.opts.Registry, repo.Path, m.opts.Registry, newPath,
+				sizeStyle.Render(fmt.Sprintf("(%d tags, %s)", repo.Tags, FormatBytes(repo.Size)))))
+		}
+	}
+	return lines
+}
+
+// move moves the cursor in the tree, or scrolls the preview
+func (m *PlannerModel) move(delta int) {
+	if m.showPreview {
+		m.offset = max(m.offset+delta, 0)
+		if lines := len(m.previewLines()); m.offset >= lines {
+			m.offset = max(lines-1, 0)
+		}
+		return
+	}
+	m.cursor = min(max(m.cursor+delta, 0), len(m.getFlatNodes())-1)
+}
+
+// toggleSelection ticks or unticks the current item. Ticking a project ticks the groups containing it,
+// toggling a group toggles its whole content.
+func (m *PlannerModel) toggleSelection() {
+	nodes := m.getFlatNodes()
+	if m.cursor >= len(nodes) {
+		return
+	}
+	node := nodes[m.cursor]
+	if node.project != nil {
+		node.project.Selected = !node.project.Selected
+		if node.project.Selected {
+			m.selectAncestors(node.project.NamespacePath)
+		}
+		return
+	}
+
+	selected := !node.group.Selected
+	if node.group == m.root {
+		// The source group itself is always migrated, toggling it toggles everything it contains
+		selected = !m.allSelected(m.root)
+	}
+	m.setSelected(node.group, selected)
+	m.root.Selected = true
+	if selected {
+		m.selectAncestors(node.group.FullPath)
+	}
+}
+
+// selectAncestors ticks the groups containing the given path
+func (m *PlannerModel) selectAncestors(fullPath string) {
+	var traverse func(group *PlannerGroup)
+	traverse = func(group *PlannerGroup) {
+		if group.FullPath != fullPath && !strings.HasPrefix(fullPath, group.FullPath+"/") {
+			return
+		}
+		group.Selected = true
+		for _, subGroup := range group.Groups {
+			traverse(subGroup)
+		}
+	}
+	traverse(m.root)
+}
+
+// setSelected ticks or unticks a group with its sub-groups and projects
+func (m *PlannerModel) setSelected(group *PlannerGroup, selected bool) {
+	group.Selected = selected
+	for _, project := range group.Projects {
+		project.Selected = selected
+	}
+	for _, subGroup := range group.Groups {
+		m.setSelected(subGroup, selected)
+	}
+}
+
+// allSelected returns true if a group and its whole content are ticked
+func (m *PlannerModel) allSelected(group *PlannerGroup) bool {
+	if !group.Selected {
+		return false
+	}
+	for _, project := range group.Projects {
+		if !project.Selected {
+			return false
+		}
+	}
+	for _, subGroup := range group.Groups {
+		if !m.allSelected(subGroup) {
+			return false
+		}
+	}
+	return true
+}
+
+// toggleExpand toggles expansion of the current group
+func (m *PlannerModel) toggleExpand() {
+	nodes := m.getFlatNodes()
+	if m.cursor < len(nodes) && nodes[m.cursor].group != nil {
+		m.collapsed[nodes[m.cursor].group] = !m.collapsed[nodes[m.cursor].group]
+	}
+}
+
+// toggleExpandAll collapses every sub-group, or expands them all if one is collapsed already
+func (m *PlannerModel) toggleExpandAll() {
+	collapse := true
+	for _, collapsed := range m.collapsed {
+		if collapsed {
+			collapse = false
+			break
+		}
+	}
+	m.collapsed = make(map[*PlannerGroup]bool)
+	if !collapse {
+		return
+	}
+	var traverse func(group *PlannerGroup)
+	traverse = func(group *PlannerGroup) {
+		for _, subGroup := range group.Groups {
+			m.collapsed[subGroup] = true
+			traverse(subGroup)
+		}
+	}
+	traverse(m.root)
+	m.cursor = min(m.cursor, len(m.getFlatNodes())-1)
+}
+
+// selectedProjects returns the ticked projects in the ticked groups, in tree order
+func (m *PlannerModel) selectedProjects() []*PlannerProject {
+	var projects []*PlannerProject
+	var traverse func(group *PlannerGroup)
+	traverse = func(group *PlannerGroup) {
+		if !group.Selected {
+			return
+		}
+		for _, subGroup := range group.Groups {
+			traverse(subGroup)
+		}
+		for _, project := range group.Projects {
+			if project.Selected {
+				projects = append(projects, project)
+			}
+		}
+	}
+	traverse(m.root)
+	return projects
+}
+
+// renderStatusBar renders the status bar
+func (m *PlannerModel) renderStatusBar() string {
+	projects := m.selectedProjects()
+	var size int64
+	for _, project := range projects {
+		size += project.Size()
+	}
+	excluded := len(m.Choice().ExcludedGroups)
+	status := fmt.Sprintf("Selected: %d projects, %s of images | Sub-groups left in place: %d", len(projects), FormatBytes(size), excluded)
+	if m.keepParent {
+		status += " | Keep parent"
+	}
+	if m.opts.DryRun {
+		status += " | 🌵 DRY RUN"
+	}
+	return status
+}
+
+// renderHelp renders the help text
+func (m *PlannerModel) renderHelp() string {
+	switch {
+	case m.showQuitConfirm, m.showConfirm:
+		return "Press 'y' to confirm, 'n' to cancel"
+	case m.editing:
+		return "Type the destination | Tab/↑/↓: Pick a group | Ctrl+U: Clear | Enter: Validate | Esc: Cancel"
+	case m.showPreview:
+		return "↑/↓: Scroll | v: Back to the tree | d: Destination | p: Keep parent | m: Migrate | q: Quit"
+	}
+	return "↑/↓: Navigate | Space: Toggle | Enter: Expand/Collapse | Tab: Expand/Collapse All | d: Destination | p: Keep parent | v: Preview | m: Migrate | q: Quit"
+}
@@ -0,0 +1,137 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+const (
+	// progressBarWidth is the number of characters of a progress bar
+	progressBarWidth = 30
+	// progressRefresh is the minimum delay between two redraws of the progress bars
+	progressRefresh = 100 * time.Millisecond
+)
+
+// ProgressBars renders the progress of image pulls and pushes with a bar per layer.
+// Without a terminal, only the layers reaching a final status are printed.
+type ProgressBars struct {
+	mu       sync.Mutex
+	out      io.Writer
+	terminal bool
+	action   string
+	image    string
+	layers   []string
+	states   map[string]*layerState
+	drawn    int
+	lastDraw time.Time
+}
+
+// layerState is the last status and progress of a layer
+type layerState struct {
+	status  string
+	current int64
+	total   int64
+}
+
+// NewProgressBars creates progress bars printed on the standard output
+func (ui *UI) NewProgressBars() *ProgressBars {
+	return &ProgressBars{
+		out:      color.Output,
+		terminal: !color.NoColor,
+		states:   make(map[string]*layerState),
+	}
+}
+
+// Start resets the bars for the pull or push of an image
+func (p *ProgressBars) Start(action, imageRef string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.action, p.image = action, imageRef
+	p.layers = nil
+	p.states = make(map[string]*layerState)
+	p.drawn = 0
+	logger.Printf("[PROGRESS] %s %s", action, imageRef)
+}
+
+// Layer updates the bar of a layer
+func (p *ProgressBars) Layer(id, status string, current, total int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	state, ok := p.states[id]
+	if !ok {
+		state = &layerState{}
+		p.states[id] = state
+		p.layers = append(p.layers, id)
+	}
+	changed := state.status != status
+	state.status, state.current, state.total = status, current, total
+
+	if !p.terminal {
+		if changed && isFinalStatus(status) {
+			fmt.Fprintf(p.out, "   %s: %s\n", id, status)
+		}
+		return
+	}
+	if changed || time.Since(p.lastDraw) >= progressRefresh {
+		p.draw()
+	}
+}
+
+// Finish clears the bars once the pull or push is over
+func (p *ProgressBars) Finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.terminal && p.drawn > 0 {
+		fmt.Fprintf(p.out, "\033[%dA\033[J", p.drawn)
+	}
+	p.drawn = 0
+	logger.Printf("[PROGRESS] %s %s done, %d layers", p.action, p.image, len(p.layers))
+}
+
+// draw redraws all the bars over the previous ones
+func (p *ProgressBars) draw() {
+	var b strings.Builder
+	if p.drawn > 0 {
+		fmt.Fprintf(&b, "\033[%dA", p.drawn)
+	}
+	for _, id := range p.layers {
+		state := p.states[id]
+		fmt.Fprintf(&b, "\033[2K   %-12s %-20s", id, state.status)
+		if state.total > 0 {
+			fmt.Fprintf(&b, " %s %s/%s", progressBar(state.current, state.total), FormatBytes(state.current), FormatBytes(state.total))
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprint(p.out, b.String())
+	p.drawn = len(p.layers)
+	p.lastDraw = time.Now()
+}
+
+// progressBar returns a bar filled in proportion of current to total
+func progressBar(current, total int64) string {
+	filled := int(current * progressBarWidth / total)
+	filled = min(max(filled, 0), progressBarWidth)
+	if filled == progressBarWidth {
+		return "[" + strings.Repeat("=", progressBarWidth) + "]"
+	}
+	return "[" + strings.Repeat("=", filled) + ">" + strings.Repeat(" ", progressBarWidth-filled-1) + "]"
+}
+
+// isFinalStatus returns true for the statuses ending the pull or push of a layer
+func isFinalStatus(status string) bool {
+	status = strings.ToLower(status)
+	for _, final := range []string{"pull complete", "already exists", "pushed", "mounted from"} {
+		if strings.Contains(status, final) {
+			return true
+		}
+	}
+	return false
+}